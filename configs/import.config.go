@@ -0,0 +1,10 @@
+package configs
+
+// Catalogue import configurations
+const (
+	ImportFileDir     = "uploads/imports"
+	ImportFileMaxSize = 50 << 20
+
+	// ImportRowBatch is how many rows of an import are processed between progress updates
+	ImportRowBatch = 100
+)
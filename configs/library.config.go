@@ -0,0 +1,41 @@
+package configs
+
+import "time"
+
+// Ebook delivery configurations
+const (
+	BookFileDir     = "uploads/books"
+	BookFileMaxSize = 500 << 20
+
+	// DownloadLinkTTL is how long a signed download link works
+	DownloadLinkTTL = 15 * time.Minute
+	// DownloadLimit is how many times a purchase can be downloaded
+	DownloadLimit = 5
+)
+
+// DownloadSigningSecretEnv is the environment variable holding the secret download links are signed with
+const DownloadSigningSecretEnv = "DOWNLOAD_SIGNING_SECRET"
+
+// DownloadSigningSecret signs download links, links signed with another secret are rejected. It is set by
+// LoadDownloadSigningSecret.
+var DownloadSigningSecret string
+
+// LoadDownloadSigningSecret reads the download signing secret from the environment, it fails when it is
+// missing
+func LoadDownloadSigningSecret() error {
+	secret, err := requireEnv(DownloadSigningSecretEnv)
+	if err != nil {
+		return err
+	}
+	DownloadSigningSecret = secret
+	return nil
+}
+
+// BookFileContentTypes are the accepted digital book file extensions and the content type they are served with
+var BookFileContentTypes = map[string]string{
+	".epub": "application/epub+zip",
+	".pdf":  "application/pdf",
+	".mp3":  "audio/mpeg",
+	".m4b":  "audio/mp4",
+	".zip":  "application/zip",
+}
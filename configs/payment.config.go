@@ -0,0 +1,83 @@
+package configs
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// DefaultCurrency is the store currency, shipping rates, coupon amounts and reports are kept in it
+const DefaultCurrency = "IDR"
+
+// Payment provider configurations
+const (
+	DefaultPaymentProvider = ManualTransferProvider
+
+	ManualTransferProvider = "manual_transfer"
+	FakeCardProvider       = "fake_card"
+)
+
+// Payment webhook configurations
+const (
+	PaymentWebhookSignatureHeader = "X-Webhook-Signature"
+	PaymentWebhookTolerance       = 5 * time.Minute
+)
+
+// PaymentWebhookSecretEnvs are the environment variables holding the secret shared with each provider to
+// sign webhook events
+var PaymentWebhookSecretEnvs = map[string]string{
+	ManualTransferProvider: "PAYMENT_WEBHOOK_SECRET_MANUAL_TRANSFER",
+	FakeCardProvider:       "PAYMENT_WEBHOOK_SECRET_FAKE_CARD",
+}
+
+// PaymentWebhookSecrets are the secrets shared with each provider to sign webhook events, they are set by
+// LoadPaymentWebhookSecrets
+var PaymentWebhookSecrets = map[string]string{}
+
+// LoadPaymentWebhookSecrets reads the webhook secret of every provider from the environment, it fails when
+// one is missing
+func LoadPaymentWebhookSecrets() error {
+	for provider, env := range PaymentWebhookSecretEnvs {
+		secret, err := requireEnv(env)
+		if err != nil {
+			return err
+		}
+		PaymentWebhookSecrets[provider] = secret
+	}
+	return nil
+}
+
+// requireEnv returns the value of an environment variable which must be set
+func requireEnv(name string) (string, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return value, nil
+}
+
+// Payment deadline configurations
+const (
+	// PaymentPendingTTL is how long a payment can wait for the customer to complete it, e.g. to upload the
+	// transfer receipt, before it expires and stops holding the order
+	PaymentPendingTTL = 24 * time.Hour
+	// PartialPaymentTTL is how long a partly paid order waits for the rest of the payment, counted from when
+	// it got its books. The order is then cancelled and the paid amount credited to the customer.
+	PartialPaymentTTL = 72 * time.Hour
+	// AllocatedPaymentHours is how long a pre-order or back-order which got its books waits for payment
+	// before the books go back to stock
+	AllocatedPaymentHours = 48
+)
+
+// Receipt upload configurations
+const (
+	ReceiptUploadDir = "uploads/receipts"
+	ReceiptMaxSize   = 5 << 20
+)
+
+// ReceiptContentTypes are the accepted receipt file types and the extension they are stored with
+var ReceiptContentTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"application/pdf": ".pdf",
+}
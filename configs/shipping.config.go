@@ -0,0 +1,10 @@
+package configs
+
+// Shipping configurations
+const (
+	// DefaultBookWeightGrams is used for books without a weight
+	DefaultBookWeightGrams = 500
+
+	// VolumetricDivisor turns a parcel volume in cubic millimetres into its volumetric weight in grams
+	VolumetricDivisor = 6000
+)
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/agustadewa/book-system/models"
+	"github.com/agustadewa/book-system/repo"
+	"github.com/agustadewa/book-system/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func NewAuthor(engine *gin.Engine, client *mongo.Client) *AuthorHandler {
+	return &AuthorHandler{
+		engine:  engine,
+		author:  repo.NewAuthor(client),
+		book:    repo.NewBook(client),
+		user:    repo.NewUser(client),
+		catalog: utils.NewCatalog(client),
+	}
+}
+
+type AuthorHandler struct {
+	engine  *gin.Engine
+	author  *repo.Author
+	book    *repo.Book
+	user    *repo.User
+	catalog *utils.Catalog
+}
+
+func (h *AuthorHandler) RegisterEndpoints() {
+	h.engine.POST("/author", h.addAuthor)
+	h.engine.GET("/author/all", h.getAllAuthors)
+	h.engine.GET("/author/:author_id", h.getAuthor)
+	h.engine.PUT("/author/:author_id", h.updateAuthor)
+	h.engine.DELETE("/author/:author_id", h.deleteAuthor)
+	h.engine.GET("/author/:author_id/books", h.getAuthorBooks)
+}
+
+func (h *AuthorHandler) addAuthor(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var addAuthor models.AddAuthor
+	if err := c.BindJSON(&addAuthor); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("error parsing request data: %s", err)})
+		return
+	}
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, addAuthor.AdminId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	nameKey := utils.NameKey(addAuthor.Name)
+	if nameKey == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+
+	id, err := h.author.Add(ctx, models.Author{
+		Id:        primitive.NewObjectID().Hex(),
+		Name:      strings.TrimSpace(addAuthor.Name),
+		NameKey:   nameKey,
+		Bio:       addAuthor.Bio,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": gin.H{"id": id}})
+}
+
+func (h *AuthorHandler) getAuthor(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	author, err := h.author.Get(ctx, c.Param("author_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": author})
+}
+
+// getAllAuthors lists authors by name, q finds the authors whose name starts with it
+func (h *AuthorHandler) getAllAuthors(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	limit, _ := strconv.ParseInt(c.Query("limit"), 10, 64)
+	if limit < 10 || limit > 100 {
+		limit = 10
+	}
+
+	authors, err := h.author.GetAll(ctx, utils.NameKey(c.Query("q")), limit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if authors == nil {
+		as := make([]models.Author, 0)
+		authors = &as
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": authors})
+}
+
+func (h *AuthorHandler) updateAuthor(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	authorId := c.Param("author_id")
+
+	var updateAuthor models.UpdateAuthor
+	if err := c.BindJSON(&updateAuthor); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("error parsing request data: %s", err)})
+		return
+	}
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, updateAuthor.AdminId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	author, err := h.catalog.UpdateAuthor(ctx, authorId, updateAuthor)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": author})
+}
+
+// deleteAuthor deletes an author, authors with books can't be deleted
+func (h *AuthorHandler) deleteAuthor(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	authorId := c.Param("author_id")
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, c.Query("admin_id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.catalog.DeleteAuthor(ctx, authorId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": fmt.Sprintf("author %s has been deleted", authorId)})
+}
+
+// getAuthorBooks lists the books an author contributed to in any role
+func (h *AuthorHandler) getAuthorBooks(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	authorId := c.Param("author_id")
+
+	limit, _ := strconv.ParseInt(c.Query("limit"), 10, 64)
+	if limit < 10 || limit > 100 {
+		limit = 10
+	}
+
+	// check existing author
+	if _, err := h.author.Get(ctx, authorId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	books, err := h.book.GetAllByAuthorId(ctx, authorId, limit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if books == nil {
+		bs := make([]models.Book, 0)
+		books = &bs
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": books})
+}
@@ -6,9 +6,12 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/agustadewa/book-system/configs"
 	"github.com/agustadewa/book-system/models"
 	"github.com/agustadewa/book-system/repo"
+	"github.com/agustadewa/book-system/utils"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -17,23 +20,50 @@ import (
 
 func NewBook(engine *gin.Engine, client *mongo.Client) *BookHandler {
 	return &BookHandler{
-		engine: engine,
-		book:   repo.NewBook(client),
+		engine:   engine,
+		book:     repo.NewBook(client),
+		user:     repo.NewUser(client),
+		schedule: repo.NewPriceSchedule(client),
+		history:  repo.NewPriceHistory(client),
+		pricing:  utils.NewPricing(client),
+		prices:   utils.NewPrices(client),
+		catalog:  utils.NewCatalog(client),
+		series:   utils.NewSeries(client),
+		books:    utils.NewBooks(client),
+
+		inventory: utils.NewInventory(client),
 	}
 }
 
 type BookHandler struct {
-	engine *gin.Engine
-	book   *repo.Book
+	engine   *gin.Engine
+	book     *repo.Book
+	user     *repo.User
+	schedule *repo.PriceSchedule
+	history  *repo.PriceHistory
+	pricing  *utils.Pricing
+	prices   *utils.Prices
+	catalog  *utils.Catalog
+	series   *utils.Series
+	books    *utils.Books
+
+	inventory *utils.Inventory
 }
 
 func (h *BookHandler) RegisterEndpoints() {
 	h.engine.POST("/book", h.addBook)
+	h.engine.POST("/book/:book_id/variant", h.addVariant)
 	h.engine.GET("/book/:book_id", h.getBook)
+	h.engine.GET("/book/isbn/:isbn", h.getBookByIsbn)
 	h.engine.GET("/book/all", h.getAllBook)
+	h.engine.GET("/book/search", h.searchBooks)
+	h.engine.PUT("/book/:book_id/edition", h.setEdition)
 	h.engine.DELETE("/book/:book_id", h.delete)
 	h.engine.PUT("/book/updatestock/:book_id/:new_stock", h.updateBookStock)
 	h.engine.POST("/book/update", h.updateBook)
+	h.engine.GET("/book/:book_id/prices", h.getPrices)
+	h.engine.POST("/book/:book_id/prices/schedule", h.addPriceSchedule)
+	h.engine.DELETE("/book/:book_id/prices/schedule/:schedule_id", h.cancelPriceSchedule)
 }
 
 func (h *BookHandler) addBook(c *gin.Context) {
@@ -54,31 +84,135 @@ func (h *BookHandler) addBook(c *gin.Context) {
 		return
 	}
 
-	addBook := models.AddBook{
-		Price:       *addBookReq.Price,
-		Qty:         *addBookReq.Qty,
-		Name:        *addBookReq.Name,
-		Author:      *addBookReq.Author,
-		Publisher:   *addBookReq.Publisher,
-		Category:    *addBookReq.Category,
-		Language:    *addBookReq.Language,
-		Description: *addBookReq.Description,
-		Image:       *addBookReq.Image,
+	addBook, err := utils.AddBookOf(addBookReq)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	if addBook.Qty <= 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "quantity minimum is 1"})
+	// check existing book, books are told apart by isbn and other formats of a title are added as its variants
+	if err = h.books.CheckIsbn(ctx, addBook.Isbn13); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	if addBook.Price <= 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "price minimum is 1"})
+
+	// link the author, publisher, category and work
+	if err = h.books.Link(ctx, &addBook, addBookReq); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// check existing book
-	_, err := h.book.GetByName(ctx, addBook.Name)
+	// add book
+	id, err := h.books.Create(ctx, addBook, "")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": gin.H{"id": id}})
+}
+
+func (h *BookHandler) addVariant(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	bookId := c.Param("book_id")
+
+	var addVariantReq models.AddBookVariantReq
+	if err := c.BindJSON(&addVariantReq); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("error parsing request data: %s", err)})
+		return
+	}
+
+	// check existing book, the variant joins its title
+	title, err := h.book.Get(ctx, bookId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	format, err := models.IsValidBookFormat(*addVariantReq.Format)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// the title details come from the book, the rest is the variant's own
+	addBook := models.AddBook{
+		Format:      format,
+		Price:       *addVariantReq.Price,
+		Name:        title.Name,
+		Author:      title.Author,
+		Publisher:   title.Publisher,
+		Category:    title.Category,
+		Language:    title.Language,
+		Description: title.Description,
+		Image:       title.Image,
+		Dimensions:  addVariantReq.Dimensions,
+		TaxClass:    title.TaxClass,
+		Currency:    utils.CurrencyOrDefault(title.Currency),
+		ReleaseDate: addVariantReq.ReleaseDate,
+
+		Contributors: title.Contributors,
+		PublisherId:  title.PublisherId,
+		CategoryIds:  title.CategoryIds,
+
+		WorkId:  title.WorkId,
+		Edition: title.Edition,
+		Series:  title.Series,
+	}
+	if addVariantReq.Sku != nil {
+		addBook.Sku = strings.TrimSpace(*addVariantReq.Sku)
+	}
+	if addVariantReq.Isbn != nil {
+		if addBook.Isbn13, addBook.Isbn10, err = utils.ParseIsbn(*addVariantReq.Isbn); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if addVariantReq.Qty != nil {
+		addBook.Qty = *addVariantReq.Qty
+	}
+	if addVariantReq.WeightGrams != nil {
+		addBook.WeightGrams = *addVariantReq.WeightGrams
+	}
+	if addVariantReq.ReorderThreshold != nil {
+		addBook.ReorderThreshold = *addVariantReq.ReorderThreshold
+	}
+	if addVariantReq.AllowBackorder != nil {
+		addBook.AllowBackorder = *addVariantReq.AllowBackorder
+	}
+	if addVariantReq.TaxClass != nil {
+		if addBook.TaxClass, err = models.IsValidTaxClass(*addVariantReq.TaxClass); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if addVariantReq.Currency != nil {
+		currency, err := models.IsValidCurrency(*addVariantReq.Currency)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		addBook.Currency = currency.Code
+	}
+
+	if err = utils.ValidateAddBook(addBook); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// check existing book and variant
+	if err = h.books.CheckIsbn(ctx, addBook.Isbn13); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	titleId := title.TitleId
+	if titleId == "" {
+		titleId = title.Id
+	}
+	_, err = h.book.GetByTitleIdAndFormat(ctx, titleId, addBook.Format)
 	if err == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": repo.ErrBookExists.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": repo.ErrBookVariantExists.Error()})
 		return
 	}
 	if err != nil && err != repo.ErrBookNotFound {
@@ -86,26 +220,14 @@ func (h *BookHandler) addBook(c *gin.Context) {
 		return
 	}
 
-	// add book
-	addBookPayload := models.Book{
-		Id:          primitive.NewObjectID().Hex(),
-		Name:        addBook.Name,
-		Author:      addBook.Author,
-		Publisher:   addBook.Publisher,
-		Category:    addBook.Category,
-		Language:    addBook.Language,
-		Price:       addBook.Price,
-		Qty:         addBook.Qty,
-		Description: addBook.Description,
-		Image:       addBook.Image,
-	}
-	id, err := h.book.Add(ctx, addBookPayload)
+	// add variant
+	id, err := h.books.Create(ctx, addBook, titleId)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"success": true, "result": gin.H{"id": id}})
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": gin.H{"id": id, "title_id": titleId}})
 }
 
 func (h *BookHandler) getBook(c *gin.Context) {
@@ -123,7 +245,86 @@ func (h *BookHandler) getBook(c *gin.Context) {
 		book = &models.Book{}
 	}
 
-	c.JSON(http.StatusOK, gin.H{"success": true, "result": book})
+	h.respondBook(c, book)
+}
+
+// getBookByIsbn looks a book up by its isbn-10 or isbn-13, with or without hyphens
+func (h *BookHandler) getBookByIsbn(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	isbn13, _, err := utils.ParseIsbn(c.Param("isbn"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	book, err := h.book.GetByIsbn(ctx, isbn13)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.respondBook(c, book)
+}
+
+// respondBook writes a book with its price as the customer sees it, its availability and its other formats
+func (h *BookHandler) respondBook(c *gin.Context, book *models.Book) {
+	ctx := c.Request.Context()
+
+	// price as shown in the customer's tax region and currency
+	var currency string
+	if c.Query("currency") != "" {
+		displayCurrency, err := models.IsValidCurrency(c.Query("currency"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		currency = displayCurrency.Code
+	}
+	priceDisplay, err := h.pricing.PriceDisplay(ctx, book, c.Query("tax_region_id"), currency)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// the other formats of the title
+	now := time.Now()
+	var variants []models.BookVariant
+	if book.TitleId != "" {
+		books, err := h.book.GetAllByTitleId(ctx, book.TitleId)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		for _, variant := range *books {
+			variants = append(variants, models.BookVariantOf(variant, now))
+		}
+	}
+
+	// where the book is in its series and the other editions of its work
+	seriesNav, err := h.series.Nav(ctx, *book)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var editions []models.Edition
+	if book.WorkId != "" {
+		books, err := h.book.GetAllByWorkId(ctx, book.WorkId)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		editions = models.EditionsOf(*books, book.TitleId, now)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": models.BookResp{
+		Book:         *book,
+		PriceDisplay: priceDisplay,
+		Availability: book.Availability(now),
+		Variants:     variants,
+		SeriesNav:    seriesNav,
+		Editions:     editions,
+	}})
 }
 
 func (h *BookHandler) getAllBook(c *gin.Context) {
@@ -149,6 +350,94 @@ func (h *BookHandler) getAllBook(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"success": true, "result": books})
 }
 
+// searchBooks finds books by name, author or isbn, with collapse_editions=true the editions of a work are
+// one hit
+func (h *BookHandler) searchBooks(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q is required"})
+		return
+	}
+	isbn13, _, _ := utils.ParseIsbn(q)
+	collapseEditions, _ := strconv.ParseBool(c.Query("collapse_editions"))
+
+	limit, _ := strconv.ParseInt(c.Query("limit"), 10, 64)
+	if limit < 10 || limit > 100 {
+		limit = 10
+	}
+
+	hits, err := h.book.Search(ctx, q, isbn13, collapseEditions, limit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if hits == nil {
+		hs := make([]models.BookSearchHit, 0)
+		hits = &hs
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": hits})
+}
+
+// setEdition makes the title of a book an edition of the work of another book, the editions the title
+// had join that work too. Without edition_of the title is split off into a work of its own.
+func (h *BookHandler) setEdition(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	bookId := c.Param("book_id")
+
+	var setEdition models.SetEditionReq
+	if err := c.BindJSON(&setEdition); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("error parsing request data: %s", err)})
+		return
+	}
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, setEdition.AdminId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// check existing book
+	book, err := h.book.Get(ctx, bookId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if setEdition.EditionOf != nil && *setEdition.EditionOf != "" {
+		work, err := h.book.Get(ctx, *setEdition.EditionOf)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if work.WorkId != book.WorkId {
+			if err = h.book.MergeWorks(ctx, book.WorkId, work.WorkId); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+	} else if setEdition.EditionOf != nil {
+		if err = h.book.SetWork(ctx, book.TitleId, primitive.NewObjectID().Hex()); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if setEdition.Edition != nil {
+		edition := strings.TrimSpace(*setEdition.Edition)
+		if err = h.book.UpdateTitle(ctx, book.TitleId, models.UpdateTitle{Edition: &edition}); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": fmt.Sprintf("book %s edition has been updated", bookId)})
+}
+
 func (h *BookHandler) delete(c *gin.Context) {
 	ctx := c.Request.Context()
 
@@ -167,14 +456,26 @@ func (h *BookHandler) updateBookStock(c *gin.Context) {
 	bookId := c.Param("book_id")
 	newStock := c.Param("new_stock")
 
-	newStockInt, _ := strconv.ParseInt(newStock, 10, 64)
+	adminId := c.Query("admin_id")
+
+	newStockInt, err := strconv.ParseInt(newStock, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid stock number: %s", newStock)})
+		return
+	}
+
+	if newStockInt <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "number minimum is 1"})
+		return
+	}
 
-	if newStockInt < 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "number mustn't be negative"})
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, adminId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if err := h.book.UpdateStock(ctx, bookId, newStockInt); err != nil {
+	if _, err := h.inventory.Move(ctx, bookId, configs.DefaultLocationId, newStockInt, models.StockReceipt, adminId, "", ""); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -192,31 +493,316 @@ func (h *BookHandler) updateBook(c *gin.Context) {
 	}
 
 	updatePayload := models.UpdateBook{
+		Sku:         updateBook.Sku,
 		Price:       updateBook.Price,
-		Qty:         updateBook.Qty,
+		WeightGrams: updateBook.WeightGrams,
+		Dimensions:  updateBook.Dimensions,
+
+		ReorderThreshold: updateBook.ReorderThreshold,
+		ReleaseDate:      updateBook.ReleaseDate,
+		AllowBackorder:   updateBook.AllowBackorder,
+	}
+
+	titlePayload := models.UpdateTitle{
 		Name:        updateBook.Name,
-		Author:      updateBook.Author,
-		Publisher:   updateBook.Publisher,
-		Category:    updateBook.Category,
 		Language:    updateBook.Language,
 		Description: updateBook.Description,
 		Image:       updateBook.Image,
+		Edition:     updateBook.Edition,
+	}
+
+	if updateBook.Qty != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Qty can't be updated, adjust the stock through /stock/:book_id/adjust"})
+		return
 	}
 
-	if *updatePayload.Qty < 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Qty can't be lower than 0"})
+	if updatePayload.Sku != nil && strings.TrimSpace(*updatePayload.Sku) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Sku can't be empty"})
 		return
 	}
 
-	if *updatePayload.Price < 0 {
+	if updatePayload.Price != nil && *updatePayload.Price < 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Price can't be lower than 0"})
 		return
 	}
 
-	if err := h.book.Update(ctx, *updateBook.Id, updatePayload); err != nil {
+	if updatePayload.WeightGrams != nil && *updatePayload.WeightGrams < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Weight can't be lower than 0"})
+		return
+	}
+
+	if d := updatePayload.Dimensions; d != nil && (d.LengthMm <= 0 || d.WidthMm <= 0 || d.HeightMm <= 0) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dimensions minimum is 1"})
+		return
+	}
+
+	if updatePayload.ReorderThreshold != nil && *updatePayload.ReorderThreshold < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Reorder threshold can't be lower than 0"})
+		return
+	}
+
+	if updateBook.Isbn != nil {
+		isbn13, isbn10, err := utils.ParseIsbn(*updateBook.Isbn)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		updatePayload.Isbn13, updatePayload.Isbn10 = &isbn13, &isbn10
+	}
+
+	if updateBook.TaxClass != nil {
+		taxClass, err := models.IsValidTaxClass(*updateBook.TaxClass)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		updatePayload.TaxClass = &taxClass
+	}
+
+	if updateBook.Currency != nil {
+		currency, err := models.IsValidCurrency(*updateBook.Currency)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		updatePayload.Currency = &currency.Code
+	}
+
+	// check existing book
+	book, err := h.book.Get(ctx, *updateBook.Id)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	// the author, publisher and category names are linked to their entities like on a new book
+	if updateBook.Contributors != nil || updateBook.Author != nil {
+		var reqs []models.ContributorReq
+		if updateBook.Contributors != nil {
+			reqs = *updateBook.Contributors
+		}
+		var authorNames string
+		if updateBook.Author != nil {
+			authorNames = *updateBook.Author
+		}
+		contributors, err := h.catalog.Contributors(ctx, reqs, authorNames)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		names := utils.AuthorNames(contributors)
+		titlePayload.Contributors, titlePayload.Author = &contributors, &names
+	}
+	if updateBook.PublisherId != nil || updateBook.Publisher != nil {
+		publisher, err := h.catalog.PublisherOf(ctx, updateBook.PublisherId, updateBook.Publisher)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		titlePayload.PublisherId, titlePayload.Publisher = &publisher.Id, &publisher.Name
+	}
+	if updateBook.CategoryId != nil || updateBook.Category != nil {
+		category, err := h.catalog.CategoryOf(ctx, updateBook.CategoryId, updateBook.Category)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		categoryIds := category.Lineage()
+		titlePayload.CategoryIds, titlePayload.Category = &categoryIds, &category.Name
+	}
+
+	// a new currency converts the price unless a new price is given
+	priceReason := models.PriceManual
+	if updatePayload.Currency != nil && *updatePayload.Currency != utils.CurrencyOrDefault(book.Currency) {
+		price, err := h.prices.ChangeCurrency(ctx, book, *updatePayload.Currency, updatePayload.Price)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		updatePayload.Price = &price
+		priceReason = models.PriceCurrencyChange
+	}
+
+	if !updatePayload.IsEmpty() {
+		if err = h.book.Update(ctx, book.Id, updatePayload); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// the title details are shared by every format of the title
+	if !titlePayload.IsEmpty() {
+		titleId := book.TitleId
+		if titleId == "" {
+			titleId = book.Id
+		}
+		if err = h.book.UpdateTitle(ctx, titleId, titlePayload); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// keep the old price in the history
+	if updatePayload.Price != nil && (*updatePayload.Price != book.Price || priceReason == models.PriceCurrencyChange) {
+		if err = h.prices.Record(ctx, book.Id, book.Price, *updatePayload.Price, priceReason, "", ""); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{"success": true, "result": fmt.Sprintf("Book %s has been updated", *updateBook.Id)})
 }
+
+func (h *BookHandler) getPrices(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	bookId := c.Param("book_id")
+
+	// check existing book
+	book, err := h.book.Get(ctx, bookId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	limitStr := c.Request.URL.Query().Get("limit")
+	limit, _ := strconv.ParseInt(limitStr, 10, 64)
+
+	if limit < 10 || limit > 100 {
+		limit = 10
+	}
+	history, err := h.history.GetAllByBookId(ctx, book.Id, limit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	schedules, err := h.schedule.GetAllByBookId(ctx, book.Id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if history == nil {
+		hs := make([]models.PriceHistory, 0)
+		history = &hs
+	}
+	if schedules == nil {
+		ss := make([]models.PriceSchedule, 0)
+		schedules = &ss
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": gin.H{
+		"book_id":   book.Id,
+		"price":     book.Price,
+		"history":   history,
+		"schedules": schedules,
+	}})
+}
+
+func (h *BookHandler) addPriceSchedule(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	bookId := c.Param("book_id")
+
+	var addSchedule models.AddPriceSchedule
+	if err := c.BindJSON(&addSchedule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("error parsing request data: %s", err)})
+		return
+	}
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, addSchedule.AdminId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if addSchedule.Price <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "price minimum is 1"})
+		return
+	}
+	if addSchedule.EndsAt != nil && !addSchedule.EndsAt.After(addSchedule.StartsAt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ends at must be after starts at"})
+		return
+	}
+	if addSchedule.EndsAt != nil && !addSchedule.EndsAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ends at must be in the future"})
+		return
+	}
+
+	// check existing book
+	book, err := h.book.Get(ctx, bookId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// one price at a time, the price to put back would be ambiguous otherwise
+	overlapping, err := h.schedule.GetAllOverlapping(ctx, book.Id, addSchedule.StartsAt, addSchedule.EndsAt)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(*overlapping) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("schedule overlaps schedule %s", (*overlapping)[0].Id)})
+		return
+	}
+
+	// add price schedule, the cron job starts it
+	addSchedulePayload := models.PriceSchedule{
+		Id:        primitive.NewObjectID().Hex(),
+		BookId:    book.Id,
+		Price:     addSchedule.Price,
+		StartsAt:  addSchedule.StartsAt,
+		EndsAt:    addSchedule.EndsAt,
+		Status:    models.PriceScheduled,
+		CreatedBy: addSchedule.AdminId,
+		CreatedAt: time.Now(),
+	}
+	id, err := h.schedule.Add(ctx, addSchedulePayload)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": gin.H{"id": id}})
+}
+
+// cancelPriceSchedule cancels a schedule which hasn't started, or ends a running one and puts back its old price
+func (h *BookHandler) cancelPriceSchedule(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	bookId := c.Param("book_id")
+	scheduleId := c.Param("schedule_id")
+	adminId := c.Query("admin_id")
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, adminId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	schedule, err := h.schedule.Get(ctx, scheduleId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if schedule.BookId != bookId {
+		c.JSON(http.StatusBadRequest, gin.H{"error": repo.ErrPriceScheduleNotFound.Error()})
+		return
+	}
+
+	switch {
+	case schedule.Status.IsScheduled():
+		err = h.prices.CancelSchedule(ctx, schedule, time.Now())
+	case schedule.Status.IsActive():
+		err = h.prices.EndSchedule(ctx, schedule, time.Now(), adminId)
+	default:
+		err = fmt.Errorf("price schedule is already %s", strings.ToLower(schedule.Status.String()))
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": fmt.Sprintf("price schedule %s has been cancelled", schedule.Id)})
+}
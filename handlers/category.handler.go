@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/agustadewa/book-system/models"
+	"github.com/agustadewa/book-system/repo"
+	"github.com/agustadewa/book-system/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func NewCategory(engine *gin.Engine, client *mongo.Client) *CategoryHandler {
+	return &CategoryHandler{
+		engine:   engine,
+		category: repo.NewCategory(client),
+		book:     repo.NewBook(client),
+		user:     repo.NewUser(client),
+		catalog:  utils.NewCatalog(client),
+	}
+}
+
+type CategoryHandler struct {
+	engine   *gin.Engine
+	category *repo.Category
+	book     *repo.Book
+	user     *repo.User
+	catalog  *utils.Catalog
+}
+
+func (h *CategoryHandler) RegisterEndpoints() {
+	h.engine.POST("/category", h.addCategory)
+	h.engine.GET("/category/tree", h.getCategoryTree)
+	h.engine.GET("/category/:category_id", h.getCategory)
+	h.engine.PUT("/category/:category_id", h.updateCategory)
+	h.engine.DELETE("/category/:category_id", h.deleteCategory)
+	h.engine.GET("/category/:category_id/books", h.getCategoryBooks)
+}
+
+func (h *CategoryHandler) addCategory(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var addCategory models.AddCategory
+	if err := c.BindJSON(&addCategory); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("error parsing request data: %s", err)})
+		return
+	}
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, addCategory.AdminId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	category, err := h.catalog.AddCategory(ctx, addCategory.ParentId, addCategory.Name, false)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": gin.H{"id": category.Id}})
+}
+
+func (h *CategoryHandler) getCategoryTree(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	tree, err := h.catalog.Tree(ctx)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": tree})
+}
+
+// getCategory returns a category with its ancestors from the root, for breadcrumbs
+func (h *CategoryHandler) getCategory(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	category, err := h.category.Get(ctx, c.Param("category_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ancestors := make([]models.Category, 0, len(category.Path))
+	if len(category.Path) > 0 {
+		categories, err := h.category.GetAllByIds(ctx, category.Path)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		byId := make(map[string]models.Category, len(*categories))
+		for _, ancestor := range *categories {
+			byId[ancestor.Id] = ancestor
+		}
+		for _, id := range category.Path {
+			if ancestor, ok := byId[id]; ok {
+				ancestors = append(ancestors, ancestor)
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": gin.H{"category": category, "ancestors": ancestors}})
+}
+
+// updateCategory renames a category or moves it, the categories and books below it move along
+func (h *CategoryHandler) updateCategory(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	categoryId := c.Param("category_id")
+
+	var updateCategory models.UpdateCategory
+	if err := c.BindJSON(&updateCategory); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("error parsing request data: %s", err)})
+		return
+	}
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, updateCategory.AdminId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	category, err := h.catalog.UpdateCategory(ctx, categoryId, updateCategory)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": category})
+}
+
+// deleteCategory deletes a category, categories with subcategories or books can't be deleted
+func (h *CategoryHandler) deleteCategory(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	categoryId := c.Param("category_id")
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, c.Query("admin_id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.catalog.DeleteCategory(ctx, categoryId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": fmt.Sprintf("category %s has been deleted", categoryId)})
+}
+
+// getCategoryBooks lists the books in a category and its subcategories
+func (h *CategoryHandler) getCategoryBooks(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	categoryId := c.Param("category_id")
+
+	limit, _ := strconv.ParseInt(c.Query("limit"), 10, 64)
+	if limit < 10 || limit > 100 {
+		limit = 10
+	}
+
+	// check existing category
+	if _, err := h.category.Get(ctx, categoryId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	books, err := h.book.GetAllByCategoryId(ctx, categoryId, limit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if books == nil {
+		bs := make([]models.Book, 0)
+		books = &bs
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": books})
+}
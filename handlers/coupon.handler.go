@@ -0,0 +1,293 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/agustadewa/book-system/models"
+	"github.com/agustadewa/book-system/repo"
+	"github.com/agustadewa/book-system/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func NewCoupon(engine *gin.Engine, client *mongo.Client) *CouponHandler {
+	return &CouponHandler{
+		engine:     engine,
+		coupon:     repo.NewCoupon(client),
+		redemption: repo.NewCouponRedemption(client),
+		user:       repo.NewUser(client),
+		author:     repo.NewAuthor(client),
+		category:   repo.NewCategory(client),
+	}
+}
+
+type CouponHandler struct {
+	engine     *gin.Engine
+	coupon     *repo.Coupon
+	redemption *repo.CouponRedemption
+	user       *repo.User
+	author     *repo.Author
+	category   *repo.Category
+}
+
+func (h *CouponHandler) RegisterEndpoints() {
+	h.engine.POST("/coupon", h.addCoupon)
+	h.engine.GET("/coupon/all", h.getAllCoupons)
+	h.engine.GET("/coupon/bycode/:code", h.getCouponByCode)
+	h.engine.GET("/coupon/:coupon_id", h.getCoupon)
+	h.engine.GET("/coupon/:coupon_id/redemptions", h.getRedemptions)
+	h.engine.PUT("/coupon/:coupon_id", h.updateCoupon)
+	h.engine.DELETE("/coupon/:coupon_id", h.deleteCoupon)
+}
+
+func (h *CouponHandler) addCoupon(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var addCoupon models.AddCoupon
+	if err := c.BindJSON(&addCoupon); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("error parsing request data: %s", err)})
+		return
+	}
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, addCoupon.AdminId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// check authors and categories
+	if err := h.checkEntities(ctx, addCoupon); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	addCouponPayload := couponOf(primitive.NewObjectID().Hex(), addCoupon, true)
+	addCouponPayload.CreatedAt = time.Now()
+	if err := utils.ValidateCoupon(addCouponPayload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// add coupon
+	id, err := h.coupon.Add(ctx, addCouponPayload)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": gin.H{"id": id, "code": addCouponPayload.Code}})
+}
+
+func (h *CouponHandler) getAllCoupons(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, c.Query("admin_id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	limitStr := c.Request.URL.Query().Get("limit")
+	limit, _ := strconv.ParseInt(limitStr, 10, 64)
+
+	if limit < 10 || limit > 100 {
+		limit = 10
+	}
+	coupons, err := h.coupon.GetAll(ctx, limit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if coupons == nil {
+		cs := make([]models.Coupon, 0)
+		coupons = &cs
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": coupons})
+}
+
+// getCouponByCode lets customers check a code before checkout, inactive coupons aren't shown
+func (h *CouponHandler) getCouponByCode(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	coupon, err := h.coupon.GetByCode(ctx, utils.NormalizeCouponCode(c.Param("code")))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !coupon.Active {
+		c.JSON(http.StatusBadRequest, gin.H{"error": utils.ErrCouponInactive.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": gin.H{
+		"code":         coupon.Code,
+		"type":         coupon.Type,
+		"value":        coupon.Value,
+		"max_discount": coupon.MaxDiscount,
+		"min_spend":    coupon.MinSpend,
+		"valid_from":   coupon.ValidFrom,
+		"valid_until":  coupon.ValidUntil,
+		"book_ids":     coupon.BookIds,
+		"author_ids":   coupon.AuthorIds,
+		"category_ids": coupon.CategoryIds,
+	}})
+}
+
+func (h *CouponHandler) getCoupon(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	couponId := c.Param("coupon_id")
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, c.Query("admin_id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	coupon, err := h.coupon.Get(ctx, couponId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": coupon})
+}
+
+func (h *CouponHandler) getRedemptions(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	couponId := c.Param("coupon_id")
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, c.Query("admin_id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	limitStr := c.Request.URL.Query().Get("limit")
+	limit, _ := strconv.ParseInt(limitStr, 10, 64)
+
+	if limit < 10 || limit > 100 {
+		limit = 10
+	}
+	redemptions, err := h.redemption.GetAllByCouponId(ctx, couponId, limit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if redemptions == nil {
+		rs := make([]models.CouponRedemption, 0)
+		redemptions = &rs
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": redemptions})
+}
+
+func (h *CouponHandler) updateCoupon(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	couponId := c.Param("coupon_id")
+
+	var updateCoupon models.AddCoupon
+	if err := c.BindJSON(&updateCoupon); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("error parsing request data: %s", err)})
+		return
+	}
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, updateCoupon.AdminId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// an update without active keeps the coupon enabled or disabled as it is
+	coupon, err := h.coupon.Get(ctx, couponId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// check authors and categories
+	if err = h.checkEntities(ctx, updateCoupon); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updateCouponPayload := couponOf(couponId, updateCoupon, coupon.Active)
+	if err := utils.ValidateCoupon(updateCouponPayload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.coupon.Update(ctx, updateCouponPayload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": fmt.Sprintf("coupon %s has been updated", couponId)})
+}
+
+func (h *CouponHandler) deleteCoupon(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	couponId := c.Param("coupon_id")
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, c.Query("admin_id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.coupon.Delete(ctx, couponId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": fmt.Sprintf("coupon %s has been deleted", couponId)})
+}
+
+// checkEntities checks the authors and categories a coupon is restricted to are in the catalogue
+func (h *CouponHandler) checkEntities(ctx context.Context, req models.AddCoupon) error {
+	for _, authorId := range req.AuthorIds {
+		if _, err := h.author.Get(ctx, authorId); err != nil {
+			return fmt.Errorf("%s: %w", authorId, err)
+		}
+	}
+	for _, categoryId := range req.CategoryIds {
+		if _, err := h.category.Get(ctx, categoryId); err != nil {
+			return fmt.Errorf("%s: %w", categoryId, err)
+		}
+	}
+	return nil
+}
+
+// couponOf builds a coupon from a request, active is used when the request doesn't set it
+func couponOf(id string, req models.AddCoupon, active bool) models.Coupon {
+	if req.Active != nil {
+		active = *req.Active
+	}
+	return models.Coupon{
+		Id:           id,
+		Code:         utils.NormalizeCouponCode(req.Code),
+		Type:         models.CouponType(req.Type),
+		Value:        req.Value,
+		MaxDiscount:  req.MaxDiscount,
+		MinSpend:     req.MinSpend,
+		UsageLimit:   req.UsageLimit,
+		PerUserLimit: req.PerUserLimit,
+		ValidFrom:    req.ValidFrom,
+		ValidUntil:   req.ValidUntil,
+		BookIds:      req.BookIds,
+		AuthorIds:    req.AuthorIds,
+		CategoryIds:  req.CategoryIds,
+		Active:       active,
+	}
+}
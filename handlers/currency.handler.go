@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/agustadewa/book-system/configs"
+	"github.com/agustadewa/book-system/models"
+	"github.com/agustadewa/book-system/repo"
+	"github.com/agustadewa/book-system/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func NewCurrency(engine *gin.Engine, client *mongo.Client) *CurrencyHandler {
+	return &CurrencyHandler{
+		engine: engine,
+		rate:   repo.NewExchangeRate(client),
+		user:   repo.NewUser(client),
+		rates:  utils.NewRates(client),
+	}
+}
+
+type CurrencyHandler struct {
+	engine *gin.Engine
+	rate   *repo.ExchangeRate
+	user   *repo.User
+	rates  *utils.Rates
+}
+
+func (h *CurrencyHandler) RegisterEndpoints() {
+	h.engine.GET("/currency/all", h.getAllCurrencies)
+	h.engine.GET("/currency/convert", h.convert)
+	h.engine.POST("/currency/rate", h.addRate)
+	h.engine.GET("/currency/rate/all", h.getAllRates)
+}
+
+func (h *CurrencyHandler) getAllCurrencies(c *gin.Context) {
+	currencies := make([]models.Currency, 0, len(models.Currencies))
+	for _, currency := range models.Currencies {
+		currencies = append(currencies, currency)
+	}
+	sort.Slice(currencies, func(i, j int) bool { return currencies[i].Code < currencies[j].Code })
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": gin.H{
+		"store_currency": configs.DefaultCurrency,
+		"currencies":     currencies,
+	}})
+}
+
+// convert converts an amount in minor units with the rate in effect now
+func (h *CurrencyHandler) convert(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	amount, err := strconv.ParseInt(c.Query("amount"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "amount must be an integer of minor units"})
+		return
+	}
+	from, err := models.IsValidCurrency(c.Query("from"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	to, err := models.IsValidCurrency(c.Query("to"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	converted, locked, err := h.rates.Convert(ctx, amount, from.Code, to.Code, time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result := models.Money{Amount: converted, Currency: to.Code}
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": gin.H{
+		"from":      models.Money{Amount: amount, Currency: from.Code},
+		"to":        result,
+		"formatted": result.String(),
+		"rate":      locked,
+	}})
+}
+
+func (h *CurrencyHandler) addRate(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var addRate models.AddExchangeRate
+	if err := c.BindJSON(&addRate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("error parsing request data: %s", err)})
+		return
+	}
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, addRate.AdminId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	base, err := models.IsValidCurrency(addRate.Base)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	quote, err := models.IsValidCurrency(addRate.Quote)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if base.Code == quote.Code {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "base and quote currency must differ"})
+		return
+	}
+	rate := strings.TrimSpace(addRate.Rate)
+	if _, err = utils.ParseExchangeRate(rate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	effectiveFrom := now
+	if addRate.EffectiveFrom != nil {
+		effectiveFrom = *addRate.EffectiveFrom
+	}
+
+	// add exchange rate
+	addRatePayload := models.ExchangeRate{
+		Id:            primitive.NewObjectID().Hex(),
+		Base:          base.Code,
+		Quote:         quote.Code,
+		Rate:          rate,
+		EffectiveFrom: effectiveFrom,
+		CreatedBy:     addRate.AdminId,
+		CreatedAt:     now,
+	}
+	id, err := h.rate.Add(ctx, addRatePayload)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": gin.H{"id": id}})
+}
+
+func (h *CurrencyHandler) getAllRates(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var base, quote string
+	if c.Query("base") != "" {
+		currency, err := models.IsValidCurrency(c.Query("base"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		base = currency.Code
+	}
+	if c.Query("quote") != "" {
+		currency, err := models.IsValidCurrency(c.Query("quote"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		quote = currency.Code
+	}
+
+	limitStr := c.Request.URL.Query().Get("limit")
+	limit, _ := strconv.ParseInt(limitStr, 10, 64)
+
+	if limit < 10 || limit > 100 {
+		limit = 10
+	}
+	rates, err := h.rate.GetAll(ctx, base, quote, limit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if rates == nil {
+		rs := make([]models.ExchangeRate, 0)
+		rates = &rs
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": rates})
+}
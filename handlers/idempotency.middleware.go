@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/agustadewa/book-system/configs"
+	"github.com/agustadewa/book-system/repo"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+func NewIdempotency(client *mongo.Client) *IdempotencyMiddleware {
+	return &IdempotencyMiddleware{
+		idempotency:  repo.NewIdempotency(client),
+		ttl:          configs.IdempotencyKeyTTL,
+		lease:        configs.IdempotencyKeyLease,
+		writeTimeout: configs.IdempotencyWriteTimeout,
+	}
+}
+
+type IdempotencyMiddleware struct {
+	idempotency  *repo.Idempotency
+	ttl          time.Duration
+	lease        time.Duration
+	writeTimeout time.Duration
+}
+
+// responseRecorder keeps a copy of the response body so it can be replayed
+type responseRecorder struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// idempotencyCaller is the part of a request body telling who makes the request
+type idempotencyCaller struct {
+	UserId string `json:"user_id"`
+}
+
+// Handle stores the first successful response of a request made with an Idempotency-Key header
+// and replays it for retries with the same key and body. Keys are kept per user, the same key sent
+// by another user is another request. Requests without the header pass through.
+func (m *IdempotencyMiddleware) Handle(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		sum := sha256.Sum256(body)
+		requestHash := hex.EncodeToString(sum[:])
+
+		var caller idempotencyCaller
+		_ = json.Unmarshal(body, &caller)
+
+		// reserve the key, or replay the stored response
+		err = m.idempotency.Reserve(ctx, scope, caller.UserId, key, requestHash, m.ttl, m.lease)
+		if err == repo.ErrIdempotencyKeyExists {
+			existing, err := m.idempotency.Get(ctx, scope, caller.UserId, key)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			// expired keys are removed by the ttl index in the background and keys of requests which died before
+			// completing lose their lease, treat them as unused
+			now := time.Now()
+			if existing.ExpiresAt.Before(now) || (!existing.Completed && existing.LockedUntil.Before(now)) {
+				err = m.idempotency.TakeOver(ctx, scope, caller.UserId, key, requestHash, m.ttl, m.lease)
+				if err == repo.ErrIdempotencyKeyExists {
+					c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "request with this idempotency key is still in progress"})
+					return
+				}
+			} else {
+				if existing.RequestHash != requestHash {
+					c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "idempotency key is already used with a different request body"})
+					return
+				}
+				if !existing.Completed {
+					c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "request with this idempotency key is still in progress"})
+					return
+				}
+
+				c.Header("Idempotent-Replayed", "true")
+				c.Data(existing.StatusCode, "application/json; charset=utf-8", existing.Response)
+				c.Abort()
+				return
+			}
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer, body: &bytes.Buffer{}}
+		c.Writer = recorder
+
+		c.Next()
+
+		// the outcome is stored even when the client went away meanwhile, retries depend on it
+		writeCtx, cancel := context.WithTimeout(context.Background(), m.writeTimeout)
+		defer cancel()
+
+		// only successful responses are kept, failed requests release the key so they can be retried
+		status := recorder.Status()
+		if status >= http.StatusOK && status < http.StatusMultipleChoices {
+			if err := m.idempotency.Complete(writeCtx, scope, caller.UserId, key, status, recorder.body.Bytes()); err != nil {
+				log.Println("[IDEMPOTENCY ERROR] ", err.Error())
+			}
+			return
+		}
+		if err := m.idempotency.Delete(writeCtx, scope, caller.UserId, key); err != nil {
+			log.Println("[IDEMPOTENCY ERROR] ", err.Error())
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/agustadewa/book-system/configs"
+	"github.com/agustadewa/book-system/models"
+	"github.com/agustadewa/book-system/repo"
+	"github.com/agustadewa/book-system/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func NewImport(engine *gin.Engine, client *mongo.Client) *ImportHandler {
+	return &ImportHandler{
+		engine:   engine,
+		job:      repo.NewImportJob(client),
+		user:     repo.NewUser(client),
+		importer: utils.NewImporter(client),
+	}
+}
+
+type ImportHandler struct {
+	engine   *gin.Engine
+	job      *repo.ImportJob
+	user     *repo.User
+	importer *utils.Importer
+}
+
+func (h *ImportHandler) RegisterEndpoints() {
+	h.engine.POST("/book/import", h.addImport)
+	h.engine.GET("/book/import/all", h.getAllImports)
+	h.engine.GET("/book/import/:job_id", h.getImport)
+	h.engine.GET("/book/import/:job_id/report", h.getReport)
+}
+
+// addImport queues a csv or ONIX 3.0 file of books, the import runs in the background. The format is
+// told by the file extension unless given.
+func (h *ImportHandler) addImport(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	adminId := c.PostForm("admin_id")
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, adminId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("error reading import file: %s", err)})
+		return
+	}
+	if fileHeader.Size > configs.ImportFileMaxSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("import file maximum size is %v bytes", configs.ImportFileMaxSize)})
+		return
+	}
+
+	formatStr := strings.ToUpper(c.PostForm("format"))
+	if formatStr == "" {
+		switch strings.ToLower(filepath.Ext(fileHeader.Filename)) {
+		case ".csv":
+			formatStr = models.CsvImport.String()
+		case ".xml", ".onix":
+			formatStr = models.OnixImport.String()
+		}
+	}
+	format, err := models.IsValidImportFormat(formatStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	job, err := h.importer.Queue(ctx, adminId, format, filepath.Base(fileHeader.Filename), file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": job})
+}
+
+func (h *ImportHandler) getAllImports(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, c.Query("admin_id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	limit, _ := strconv.ParseInt(c.Query("limit"), 10, 64)
+	if limit < 10 || limit > 100 {
+		limit = 10
+	}
+
+	jobs, err := h.job.GetAll(ctx, limit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if jobs == nil {
+		js := make([]models.ImportJob, 0)
+		jobs = &js
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": jobs})
+}
+
+// getImport returns an import job, its counts show the progress while it runs
+func (h *ImportHandler) getImport(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, c.Query("admin_id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	job, err := h.job.Get(ctx, c.Param("job_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": job})
+}
+
+// getReport downloads the result of every row of an import as csv, ?status=ERROR gives the rows to fix
+func (h *ImportHandler) getReport(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, c.Query("admin_id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	job, err := h.job.Get(ctx, c.Param("job_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	status := models.ImportRowStatus(strings.ToUpper(c.Query("status")))
+	switch status {
+	case "", models.ImportRowCreated, models.ImportRowUpdated, models.ImportRowSkipped, models.ImportRowError:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status is one of CREATED, UPDATED, SKIPPED and ERROR"})
+		return
+	}
+
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="import-%s.csv"`, job.Id))
+	if err = h.importer.Report(ctx, job.Id, status, c.Writer); err != nil {
+		// the headers are sent, the report just ends
+		_ = c.Error(err)
+	}
+}
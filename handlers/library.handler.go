@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/agustadewa/book-system/configs"
+	"github.com/agustadewa/book-system/models"
+	"github.com/agustadewa/book-system/repo"
+	"github.com/agustadewa/book-system/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func NewLibrary(engine *gin.Engine, client *mongo.Client) *LibraryHandler {
+	return &LibraryHandler{
+		engine:   engine,
+		entry:    repo.NewLibrary(client),
+		download: repo.NewDownload(client),
+		book:     repo.NewBook(client),
+		user:     repo.NewUser(client),
+		library:  utils.NewLibrary(client),
+	}
+}
+
+type LibraryHandler struct {
+	engine   *gin.Engine
+	entry    *repo.Library
+	download *repo.Download
+	book     *repo.Book
+	user     *repo.User
+	library  *utils.Library
+}
+
+func (h *LibraryHandler) RegisterEndpoints() {
+	h.engine.POST("/book/:book_id/file", h.uploadFile)
+	h.engine.GET("/library/all/byuserid/:user_id", h.getAllByUserId)
+	h.engine.POST("/library/:entry_id/link", h.getLink)
+	h.engine.GET("/library/:entry_id/downloads", h.getDownloads)
+	h.engine.GET("/library/download/:entry_id", h.downloadFile)
+}
+
+// uploadFile stores the file customers download for a digital book, a new upload replaces the file
+func (h *LibraryHandler) uploadFile(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	bookId := c.Param("book_id")
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, c.PostForm("admin_id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// check existing book
+	book, err := h.book.Get(ctx, bookId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !book.IsDigital() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "only digital books have files"})
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("error reading book file: %s", err)})
+		return
+	}
+	if fileHeader.Size > configs.BookFileMaxSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("book file maximum size is %v bytes", configs.BookFileMaxSize)})
+		return
+	}
+	ext := strings.ToLower(filepath.Ext(fileHeader.Filename))
+	contentType, ok := configs.BookFileContentTypes[ext]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("book file type %s isn't accepted", ext)})
+		return
+	}
+
+	// store book file
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	key := book.Id + ext
+	size, err := h.library.Store().Put(ctx, key, file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	bookFile := models.BookFile{
+		Key:         key,
+		FileName:    filepath.Base(fileHeader.Filename),
+		ContentType: contentType,
+		Size:        size,
+		UploadedAt:  time.Now(),
+	}
+	if err = h.book.SetFile(ctx, book.Id, bookFile); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// a file of another type is left behind by the new key
+	if book.File != nil && book.File.Key != key {
+		if err = h.library.Store().Delete(ctx, book.File.Key); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": bookFile})
+}
+
+func (h *LibraryHandler) getAllByUserId(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	userId := c.Param("user_id")
+
+	entries, err := h.entry.GetAllByUserId(ctx, userId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if entries == nil {
+		es := make([]models.LibraryEntry, 0)
+		entries = &es
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": entries})
+}
+
+// getLink returns a signed download link of a library entry, links expire but don't use up downloads
+func (h *LibraryHandler) getLink(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	entryId := c.Param("entry_id")
+
+	var requestLink models.RequestDownloadLink
+	if err := c.BindJSON(&requestLink); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("error parsing request data: %s", err)})
+		return
+	}
+
+	link, err := h.library.Link(ctx, entryId, requestLink.UserId, time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": link})
+}
+
+// getDownloads returns the download log of a library entry
+func (h *LibraryHandler) getDownloads(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	entryId := c.Param("entry_id")
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, c.Query("admin_id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	downloads, err := h.download.GetAllByEntryId(ctx, entryId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if downloads == nil {
+		ds := make([]models.Download, 0)
+		downloads = &ds
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": downloads})
+}
+
+// downloadFile serves the file of a library entry through a signed link
+func (h *LibraryHandler) downloadFile(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	entryId := c.Param("entry_id")
+
+	file, bookFile, err := h.library.Open(ctx, entryId, c.Query("expires"), c.Query("signature"), time.Now(), c.ClientIP(), c.Request.UserAgent())
+	if err == utils.ErrInvalidDownloadLink || err == utils.ErrDownloadLinkExpired {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	c.DataFromReader(http.StatusOK, bookFile.Size, bookFile.ContentType, file, map[string]string{
+		"Content-Disposition": fmt.Sprintf("attachment; filename=%q", bookFile.FileName),
+	})
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/agustadewa/book-system/models"
+	"github.com/agustadewa/book-system/repo"
+	"github.com/agustadewa/book-system/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func NewLocation(engine *gin.Engine, client *mongo.Client) *LocationHandler {
+	return &LocationHandler{
+		engine:    engine,
+		location:  repo.NewLocation(client),
+		user:      repo.NewUser(client),
+		inventory: utils.NewInventory(client),
+	}
+}
+
+type LocationHandler struct {
+	engine    *gin.Engine
+	location  *repo.Location
+	user      *repo.User
+	inventory *utils.Inventory
+}
+
+func (h *LocationHandler) RegisterEndpoints() {
+	h.engine.POST("/location", h.addLocation)
+	h.engine.GET("/location/all", h.getAllLocations)
+	h.engine.GET("/location/:location_id", h.getLocation)
+	h.engine.PUT("/location/:location_id", h.updateLocation)
+}
+
+func (h *LocationHandler) addLocation(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var addLocation models.AddLocation
+	if err := c.BindJSON(&addLocation); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("error parsing request data: %s", err)})
+		return
+	}
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, addLocation.AdminId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	locationId := strings.TrimSpace(addLocation.Id)
+	if locationId == "" {
+		locationId = primitive.NewObjectID().Hex()
+	}
+	addLocationPayload, err := locationOf(locationId, addLocation)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	addLocationPayload.CreatedAt = time.Now()
+
+	id, err := h.location.Add(ctx, addLocationPayload)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": gin.H{"id": id}})
+}
+
+func (h *LocationHandler) getLocation(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	locationId := c.Param("location_id")
+
+	location, err := h.location.Get(ctx, locationId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": location})
+}
+
+func (h *LocationHandler) getAllLocations(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	locations, err := h.location.GetAll(ctx)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if locations == nil {
+		ls := make([]models.Location, 0)
+		locations = &ls
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": locations})
+}
+
+func (h *LocationHandler) updateLocation(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	locationId := c.Param("location_id")
+
+	var updateLocation models.AddLocation
+	if err := c.BindJSON(&updateLocation); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("error parsing request data: %s", err)})
+		return
+	}
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, updateLocation.AdminId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	location, err := h.location.Get(ctx, locationId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	updateLocationPayload, err := locationOf(locationId, updateLocation)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err = h.location.Update(ctx, updateLocationPayload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// stock of the location joins or leaves the catalogue availability
+	if location.Sellable != updateLocationPayload.Sellable {
+		if err = h.inventory.RecomputeAvailability(ctx); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("location is updated but the availability failed, retry the update: %s", err)})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": fmt.Sprintf("location %s has been updated", locationId)})
+}
+
+func locationOf(id string, req models.AddLocation) (models.Location, error) {
+	locationType, err := models.IsValidLocationType(req.Type)
+	if err != nil {
+		return models.Location{}, err
+	}
+	if c := req.Coordinates; c != nil && (c.Latitude < -90 || c.Latitude > 90 || c.Longitude < -180 || c.Longitude > 180) {
+		return models.Location{}, fmt.Errorf("coordinates %v,%v are out of range", c.Latitude, c.Longitude)
+	}
+	return models.Location{
+		Id:          id,
+		Name:        req.Name,
+		Type:        locationType,
+		Sellable:    req.Sellable,
+		Priority:    req.Priority,
+		Coordinates: req.Coordinates,
+	}, nil
+}
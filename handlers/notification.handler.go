@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/agustadewa/book-system/configs"
+	"github.com/agustadewa/book-system/models"
+	"github.com/agustadewa/book-system/repo"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func NewNotification(engine *gin.Engine, client *mongo.Client) *NotificationHandler {
+	return &NotificationHandler{
+		engine:       engine,
+		notification: repo.NewNotification(client),
+		user:         repo.NewUser(client),
+	}
+}
+
+type NotificationHandler struct {
+	engine       *gin.Engine
+	notification *repo.Notification
+	user         *repo.User
+}
+
+func (h *NotificationHandler) RegisterEndpoints() {
+	h.engine.GET("/notification/all", h.getAllNotifications)
+	h.engine.GET("/notification/all/byuserid/:user_id", h.getAllNotificationsByUserId)
+	h.engine.PUT("/notification/:notification_id/read", h.readNotification)
+}
+
+// getAllNotifications returns the notifications of a team, the purchasing team by default.
+// unread=true returns only the notifications the admin hasn't read.
+func (h *NotificationHandler) getAllNotifications(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	adminId := c.Query("admin_id")
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, adminId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	team := c.Query("team")
+	if team == "" {
+		team = configs.PurchasingTeam
+	}
+	var unreadBy string
+	if c.Query("unread") == "true" {
+		unreadBy = adminId
+	}
+
+	limitStr := c.Request.URL.Query().Get("limit")
+	limit, _ := strconv.ParseInt(limitStr, 10, 64)
+
+	if limit < 10 || limit > 100 {
+		limit = 10
+	}
+	notifications, err := h.notification.GetAll(ctx, team, unreadBy, limit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if notifications == nil {
+		ns := make([]models.Notification, 0)
+		notifications = &ns
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": notifications})
+}
+
+func (h *NotificationHandler) getAllNotificationsByUserId(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	userId := c.Param("user_id")
+
+	limitStr := c.Request.URL.Query().Get("limit")
+	limit, _ := strconv.ParseInt(limitStr, 10, 64)
+
+	if limit < 10 || limit > 100 {
+		limit = 10
+	}
+	notifications, err := h.notification.GetAllByUserId(ctx, userId, limit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if notifications == nil {
+		ns := make([]models.Notification, 0)
+		notifications = &ns
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": notifications})
+}
+
+func (h *NotificationHandler) readNotification(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	notificationId := c.Param("notification_id")
+
+	var readNotification models.ReadNotification
+	if err := c.BindJSON(&readNotification); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("error parsing request data: %s", err)})
+		return
+	}
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, readNotification.AdminId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.notification.SetRead(ctx, notificationId, readNotification.AdminId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": fmt.Sprintf("notification %s has been read", notificationId)})
+}
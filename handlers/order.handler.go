@@ -1,24 +1,38 @@
 package handlers
 
 import (
+	"context"
 	"fmt"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/agustadewa/book-system/configs"
 	"github.com/agustadewa/book-system/models"
 	"github.com/agustadewa/book-system/repo"
+	"github.com/agustadewa/book-system/utils"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
 func NewOrder(engine *gin.Engine, client *mongo.Client) *OrderHandler {
+	inventory := utils.NewInventory(client)
 	return &OrderHandler{
 		engine: engine,
 		order:  repo.NewOrder(client),
 		book:   repo.NewBook(client),
 		user:   repo.NewUser(client),
+
+		pricing:     utils.NewPricing(client),
+		coupons:     utils.NewCoupons(client),
+		orders:      utils.NewOrders(client),
+		inventory:   inventory,
+		waitlist:    inventory.Waitlist(),
+		library:     utils.NewLibrary(client),
+		idempotency: NewIdempotency(client),
 	}
 }
 
@@ -27,10 +41,18 @@ type OrderHandler struct {
 	order  *repo.Order
 	book   *repo.Book
 	user   *repo.User
+
+	pricing     *utils.Pricing
+	coupons     *utils.Coupons
+	orders      *utils.Orders
+	inventory   *utils.Inventory
+	waitlist    *utils.Waitlist
+	library     *utils.Library
+	idempotency *IdempotencyMiddleware
 }
 
 func (h *OrderHandler) RegisterEndpoints() {
-	h.engine.POST("/order", h.addOrder)
+	h.engine.POST("/order", h.idempotency.Handle("order"), h.addOrder)
 	h.engine.GET("/order/:order_id", h.getOrder)
 	h.engine.GET("/order/all", h.getAllOrders)
 	h.engine.GET("/order/all/byuserid/:user_id", h.getAllOrdersByUserId)
@@ -71,42 +93,159 @@ func (h *OrderHandler) addOrder(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	if addOrder.Qty > book.Qty {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "quantity is greater than stock"})
+
+	// another format of the title is another book
+	if addOrder.Format != "" && addOrder.Format != book.Format.String() {
+		format, err := models.IsValidBookFormat(addOrder.Format)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if book, err = h.book.GetByTitleIdAndFormat(ctx, book.TitleId, format); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// books owed to orders waiting for stock aren't for sale, books held for the customer by the waitlist
+	// aren't in stock but are theirs
+	available, err := h.inventory.Available(ctx, *book)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	heldQty, err := h.waitlist.HeldQty(ctx, addOrder.UserId, book.Id, addOrder.Qty)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	available += heldQty
 	if addOrder.Qty <= 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "quantity minimum is 1"})
 		return
 	}
-	if book.Qty-addOrder.Qty < 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("maximum quantity is %v", book.Qty)})
+
+	// unreleased books are pre-ordered, books short of stock are back-ordered when the book allows it, both
+	// get their books when stock comes in
+	now := time.Now()
+	status := models.WaitingForPayment
+	if !book.IsReleased(now) {
+		status = models.Preordered
+	} else if addOrder.Qty > available && !book.IsDigital() {
+		if !book.AllowBackorder {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "quantity is greater than stock", "waitlist": true})
+			return
+		}
+		status = models.Backordered
+	}
+
+	// check currency
+	var currency string
+	if addOrder.Currency != "" {
+		orderCurrency, err := models.IsValidCurrency(addOrder.Currency)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		currency = orderCurrency.Code
+	}
+
+	// check allocation strategy
+	allocation := models.AllocationStrategy(configs.DefaultAllocationStrategy)
+	if addOrder.Allocation != "" {
+		if allocation, err = models.IsValidAllocationStrategy(addOrder.Allocation); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// check coupon
+	var coupon *models.Coupon
+	if addOrder.CouponCode != "" {
+		if coupon, err = h.coupons.GetByCode(ctx, addOrder.CouponCode); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// price the order, discount, shipping and tax included, the exchange rate is locked in here
+	quote, err := h.pricing.Quote(ctx, book, addOrder.Qty, addOrder.ShippingAddress, coupon, currency)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	// take the books held for the customer, or from a location holding them all, fails when someone else
+	// got them first. Orders waiting for stock take nothing yet, digital books have no stock.
+	orderId := primitive.NewObjectID().Hex()
+	var locationId string
+	var hold *models.WaitlistEntry
+	if !status.AwaitsStock() && !book.IsDigital() {
+		hold, err = h.waitlist.ClaimHold(ctx, addOrder.UserId, book.Id, addOrder.Qty)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if hold != nil {
+			locationId = hold.HoldLocationId
+		} else {
+			sale, err := h.inventory.Sell(ctx, book.Id, addOrder.Qty, allocation, addOrder.ShippingAddress, addOrder.UserId, orderId)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			locationId = sale.LocationId
+		}
+	}
+
+	// redeem coupon, fails when the coupon is used up
+	if coupon != nil {
+		if err = h.coupons.Redeem(ctx, coupon, addOrder.UserId, orderId, quote.Discount); err != nil {
+			h.restoreStock(ctx, book.Id, locationId, addOrder.Qty, orderId, hold)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
 	// add order
 	addOrderPayload := models.Order{
-		Id:         primitive.NewObjectID().Hex(),
-		UserId:     addOrder.UserId,
-		BookId:     addOrder.BookId,
-		Qty:        addOrder.Qty,
-		OrderTime:  time.Now().Format(time.RFC3339),
-		Status:     models.WaitingForPayment,
-		TotalPrice: book.Price * addOrder.Qty,
+		Id:             orderId,
+		UserId:         addOrder.UserId,
+		BookId:         book.Id,
+		Format:         book.Format,
+		Sku:            book.Sku,
+		Qty:            addOrder.Qty,
+		LocationId:     locationId,
+		OrderTime:      now.Format(time.RFC3339),
+		Status:         status,
+		Currency:       quote.Currency,
+		ExchangeRate:   quote.ExchangeRate,
+		BaseTotalPrice: quote.BaseGrandTotal,
+		UnitPrice:      quote.UnitPrice,
+		Subtotal:       quote.Subtotal,
+		CouponCode:     quote.CouponCode,
+		Discount:       quote.Discount,
+		ShippingFee:    quote.ShippingFee,
+		TaxTotal:       quote.TaxTotal,
+		TaxLines:       quote.TaxLines,
+		TotalPrice:     quote.GrandTotal,
+
+		ShippingAddress: addOrder.ShippingAddress,
 	}
 	id, err := h.order.Add(ctx, addOrderPayload)
 	if err != nil {
+		_ = h.coupons.Release(ctx, orderId)
+		h.restoreStock(ctx, book.Id, locationId, addOrder.Qty, orderId, hold)
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// update book quantity
-	if err = h.book.UpdateStock(ctx, book.Id, -addOrder.Qty); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+	// the customer doesn't wait for the book anymore
+	if err = h.waitlist.Fulfilled(ctx, addOrder.UserId, book.Id); err != nil {
+		log.Printf("[ORDER] can't close the waitlist entries of user %v for book %v: %v\n", addOrder.UserId, book.Id, err)
 	}
 
-	c.JSON(http.StatusOK, gin.H{"success": true, "result": gin.H{"id": id}})
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": gin.H{"id": id, "status": status}})
 }
 
 func (h *OrderHandler) getOrder(c *gin.Context) {
@@ -221,24 +360,73 @@ func (h *OrderHandler) setOrderStatus(c *gin.Context) {
 		return
 	}
 
-	// if declined or cancelled, add book quantity
-	if orderStatus.IsDeclined() || orderStatus.IsCancelled() {
-		// get order by id
-		order, err := h.order.Get(ctx, orderId)
-		if err != nil {
+	// get order by id
+	order, err := h.order.Get(ctx, orderId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// check transition, calling off again finishes a call-off which failed halfway
+	retry := order.Status == orderStatus && orderStatus.IsCalledOff()
+	if !order.Status.CanMoveTo(orderStatus) && !retry {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("order can't go from %s to %s", order.Status, orderStatus)})
+		return
+	}
+
+	switch {
+	case orderStatus.IsCalledOff():
+		// books go back to stock, the coupon is given back and what was paid is credited
+		if err = h.orders.CallOff(ctx, order, orderStatus, "order "+strings.ToLower(orderStatus.String())); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		if err = h.book.UpdateStock(ctx, order.BookId, order.Qty); err != nil {
+	case orderStatus.IsPaid():
+		// payments mark orders paid, an admin only confirms an order paid in full
+		if order.PaidAmount < order.TotalPrice {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("order is paid %v of %v", order.PaidAmount, order.TotalPrice)})
+			return
+		}
+		if err = h.order.UpdateStatusFrom(ctx, orderId, order.Status, orderStatus); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-	}
 
-	if err = h.order.UpdateStatus(ctx, orderId, orderStatus); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+		// paid digital books go to the customer library
+		if err = h.library.Grant(ctx, orderId); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+	default:
+		if orderStatus.IsDelivered() && order.Status.IsPaid() && order.TakesStock() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "order has to be shipped before it is delivered"})
+			return
+		}
+		if orderStatus.IsRefunded() && order.RefundedAmount < order.PaidAmount {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("order is refunded %v of %v", order.RefundedAmount, order.PaidAmount)})
+			return
+		}
+		if orderStatus.IsPartiallyRefunded() && order.RefundedAmount <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "order has no refunds"})
+			return
+		}
+		if err = h.order.UpdateStatusFrom(ctx, orderId, order.Status, orderStatus); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// a delivered ebook goes to the customer library, a refunded one leaves it
+		if orderStatus.IsDelivered() {
+			err = h.library.Grant(ctx, orderId)
+		} else if (orderStatus.IsRefunded() || orderStatus.IsPartiallyRefunded()) && order.Format.IsDigital() {
+			err = h.library.Revoke(ctx, orderId)
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 	}
 
 	c.JSON(http.StatusOK, gin.H{"success": true, "result": fmt.Sprintf("order status setted to %s", orderStatus.String())})
@@ -256,10 +444,16 @@ func (h *OrderHandler) delete(c *gin.Context) {
 		return
 	}
 
-	// update book quantity
-	if err = h.book.UpdateStock(ctx, order.BookId, order.Qty); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+	// an order which isn't shipped is called off first, its books, coupon and payments are given back
+	if order.Status.CanBeCalledOff() || order.Status.IsCalledOff() {
+		status := models.Cancelled
+		if order.Status.IsCalledOff() {
+			status = order.Status
+		}
+		if err = h.orders.CallOff(ctx, order, status, "order deleted"); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 	}
 
 	// delete order
@@ -270,3 +464,21 @@ func (h *OrderHandler) delete(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"success": true, "result": fmt.Sprintf("order %s has been deleted", orderId)})
 }
+
+// restoreStock puts back the books of an order which couldn't be placed, books claimed from a hold are held
+// for the customer again. Orders waiting for stock and digital orders took none and have no location.
+func (h *OrderHandler) restoreStock(ctx context.Context, bookId string, locationId string, qty int64, orderId string, hold *models.WaitlistEntry) {
+	if locationId == "" {
+		return
+	}
+	if hold != nil {
+		err := h.waitlist.RestoreHold(ctx, *hold, qty)
+		if err == nil {
+			return
+		}
+		log.Printf("[ORDER] can't restore hold %v for order %v: %v\n", hold.Id, orderId, err)
+	}
+	if _, err := h.inventory.Move(ctx, bookId, locationId, qty, models.StockCancellationRestore, configs.StockSystemActor, orderId, "order not placed"); err != nil {
+		log.Printf("[ORDER] can't restore stock of book %v for order %v: %v\n", bookId, orderId, err)
+	}
+}
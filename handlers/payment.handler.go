@@ -1,12 +1,24 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
 
+	"github.com/agustadewa/book-system/configs"
 	"github.com/agustadewa/book-system/models"
 	"github.com/agustadewa/book-system/repo"
+	"github.com/agustadewa/book-system/utils"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 )
@@ -17,6 +29,12 @@ func NewPayment(engine *gin.Engine, client *mongo.Client) *PaymentHandler {
 		payment: repo.NewPayment(client),
 		user:    repo.NewUser(client),
 		order:   repo.NewOrder(client),
+		credit:  repo.NewCredit(client),
+		webhook: repo.NewPaymentWebhook(client),
+
+		orders:      utils.NewOrders(client),
+		library:     utils.NewLibrary(client),
+		idempotency: NewIdempotency(client),
 	}
 }
 
@@ -25,12 +43,27 @@ type PaymentHandler struct {
 	payment *repo.Payment
 	user    *repo.User
 	order   *repo.Order
+	credit  *repo.Credit
+	webhook *repo.PaymentWebhook
+
+	orders      *utils.Orders
+	library     *utils.Library
+	idempotency *IdempotencyMiddleware
 }
 
 func (h *PaymentHandler) RegisterEndpoints() {
-	h.engine.POST("/payment", h.addPayment)
+	h.engine.POST("/payment", h.idempotency.Handle("payment"), h.addPayment)
 	h.engine.GET("/payment/:payment_id", h.getPayment)
 	h.engine.GET("/payment/byorderid/:order_id", h.getPaymentByOrderId)
+	h.engine.GET("/payment/all/byorderid/:order_id", h.getAllPaymentsByOrderId)
+	h.engine.GET("/payment/credit/byuserid/:user_id", h.getCreditsByUserId)
+	h.engine.POST("/payment/webhook/:provider", h.receiveWebhook)
+	h.engine.GET("/payment/review/queue", h.getReviewQueue)
+	h.engine.POST("/payment/:payment_id/receipt", h.uploadReceipt)
+	h.engine.GET("/payment/:payment_id/receipt", h.getReceipt)
+	h.engine.PUT("/payment/:payment_id/approve", h.approvePayment)
+	h.engine.PUT("/payment/:payment_id/reject", h.rejectPayment)
+	h.engine.PUT("/payment/:payment_id/refresh", h.refreshPayment)
 	h.engine.DELETE("/payment/:payment_id", h.delete)
 }
 
@@ -43,8 +76,13 @@ func (h *PaymentHandler) addPayment(c *gin.Context) {
 		return
 	}
 
-	// check existing payment
-	_, err := h.payment.GetByOrderId(ctx, addPayment.OrderId)
+	if addPayment.Amount <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "amount minimum is 1"})
+		return
+	}
+
+	// check payment in progress, the next payment can be made once it is settled
+	_, err := h.payment.GetByOrderIdAndInProgress(ctx, addPayment.OrderId)
 	if err == nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": repo.ErrPaymentExists.Error()})
 		return
@@ -54,24 +92,59 @@ func (h *PaymentHandler) addPayment(c *gin.Context) {
 		return
 	}
 
+	// check existing user
+	if _, err = h.user.Get(ctx, addPayment.UserId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// check existing order
-	if _, err = h.order.Get(ctx, addPayment.OrderId); err != nil {
+	order, err := h.order.Get(ctx, addPayment.OrderId)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if order.UserId != addPayment.UserId {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "order doesn't belong to the user"})
+		return
+	}
+	if !order.Status.AcceptsPayment() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("order is %s and can't be paid", order.Status.String())})
+		return
+	}
 
-	// check existing user
-	if _, err = h.user.Get(ctx, addPayment.UserId); err != nil {
+	// charge through the payment provider
+	provider, err := utils.GetPaymentProvider(addPayment.Provider)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	intent, err := provider.CreateIntent(ctx, order.Id, addPayment.Amount, utils.CurrencyOrDefault(order.Currency))
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	// reviewed payments are captured once the receipt is uploaded
+	if _, reviewable := provider.(utils.ReviewableProvider); !reviewable {
+		intent, err = provider.Capture(ctx, intent.Reference, utils.CaptureDetails{CardNumber: addPayment.CardNumber})
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
 	// add payment
 	addPaymentPayload := models.Payment{
-		Id:      primitive.NewObjectID().Hex(),
-		UserId:  addPayment.UserId,
-		OrderId: addPayment.OrderId,
-		Receipt: addPayment.Receipt,
+		Id:          primitive.NewObjectID().Hex(),
+		UserId:      addPayment.UserId,
+		OrderId:     addPayment.OrderId,
+		Provider:    provider.Name(),
+		ProviderRef: intent.Reference,
+		Amount:      intent.Amount,
+		Currency:    intent.Currency,
+		Status:      models.PaymentPending,
+		CreatedAt:   time.Now().Format(time.RFC3339),
 	}
 	id, err := h.payment.Add(ctx, addPaymentPayload)
 	if err != nil {
@@ -79,13 +152,475 @@ func (h *PaymentHandler) addPayment(c *gin.Context) {
 		return
 	}
 
-	// update order status
-	if err = h.order.UpdateStatus(ctx, addPayment.OrderId, models.Paid); err != nil {
+	// update order status once the provider confirms the payment
+	if err = h.settlePayment(ctx, &addPaymentPayload, intent.Status); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if intent.Status.IsFailed() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("payment %s failed: %s", id, intent.FailureReason)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": gin.H{
+		"id":       id,
+		"status":   addPaymentPayload.Status,
+		"applied":  addPaymentPayload.Applied,
+		"credited": addPaymentPayload.Credited,
+	}})
+}
+
+func (h *PaymentHandler) refreshPayment(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	paymentId := c.Param("payment_id")
+
+	payment, err := h.payment.Get(ctx, paymentId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// query the provider for the latest status
+	provider, err := utils.GetPaymentProvider(payment.Provider)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	intent, err := provider.Status(ctx, payment.ProviderRef)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err = h.settlePayment(ctx, payment, intent.Status); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": gin.H{"id": payment.Id, "status": intent.Status}})
+}
+
+func (h *PaymentHandler) receiveWebhook(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	providerName := c.Param("provider")
+	secret, ok := configs.PaymentWebhookSecrets[providerName]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": utils.ErrUnknownPaymentProvider.Error()})
+		return
+	}
+
+	// verify signature and timestamp
+	body, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	signature := c.GetHeader(configs.PaymentWebhookSignatureHeader)
+	if err = utils.VerifyWebhook(secret, signature, body, time.Now(), configs.PaymentWebhookTolerance); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	// payments of providers with a review are settled by the admin review only, pending or not
+	provider, err := utils.GetPaymentProvider(providerName)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if _, ok = provider.(utils.ReviewableProvider); ok {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("%s payments are settled by the admin review and can't be settled by a webhook", provider.Name())})
+		return
+	}
+
+	var webhookReq models.PaymentWebhookReq
+	if err = binding.JSON.BindBody(body, &webhookReq); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("error parsing request data: %s", err)})
+		return
+	}
+	eventType, err := models.IsValidPaymentWebhookEventType(webhookReq.Type)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// get payment
+	payment, err := h.payment.GetByProviderRef(ctx, providerName, webhookReq.Data.Reference)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// deduplicate by provider event id, a duplicate is acknowledged so the provider stops retrying
+	eventId, err := h.webhook.Add(ctx, models.PaymentWebhookEvent{
+		Provider:   providerName,
+		EventId:    webhookReq.Id,
+		Type:       eventType,
+		Reference:  webhookReq.Data.Reference,
+		PaymentId:  payment.Id,
+		ReceivedAt: time.Now(),
+	})
+	if err == repo.ErrPaymentWebhookEventExists {
+		c.JSON(http.StatusOK, gin.H{"success": true, "result": fmt.Sprintf("event %s has already been processed", webhookReq.Id)})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err = h.settlePayment(ctx, payment, eventType.PaymentStatus()); err != nil {
+		// forget the event so the provider retry is processed again
+		_ = h.webhook.Delete(ctx, eventId)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": gin.H{"id": payment.Id, "status": payment.Status}})
+}
+
+func (h *PaymentHandler) uploadReceipt(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	paymentId := c.Param("payment_id")
+	userId := c.PostForm("user_id")
+
+	// get payment
+	payment, err := h.payment.Get(ctx, paymentId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if payment.UserId != userId {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "payment doesn't belong to the user"})
+		return
+	}
+	if !payment.Status.IsPending() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "payment isn't waiting for a receipt"})
+		return
+	}
+
+	provider, err := utils.GetPaymentProvider(payment.Provider)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, reviewable := provider.(utils.ReviewableProvider); !reviewable {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s payments don't take receipts", provider.Name())})
+		return
+	}
+
+	// store receipt file
+	fileHeader, err := c.FormFile("receipt")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("error reading receipt file: %s", err)})
+		return
+	}
+	receipt, err := saveReceipt(c, payment.Id, fileHeader)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// capture moves the payment to review
+	intent, err := provider.Capture(ctx, payment.ProviderRef, utils.CaptureDetails{Receipt: receipt.Path})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err = h.payment.SetReceipt(ctx, payment.Id, *receipt, intent.Status); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": gin.H{"id": payment.Id, "status": intent.Status}})
+}
+
+// getReceipt downloads the receipt of a payment for the payment owner, ?user_id=, or an admin, ?admin_id=
+func (h *PaymentHandler) getReceipt(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	paymentId := c.Param("payment_id")
+
+	payment, err := h.payment.Get(ctx, paymentId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// check owner or admin
+	if adminId := c.Query("admin_id"); adminId != "" {
+		if _, err = h.user.GetAdmin(ctx, adminId); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	} else if userId := c.Query("user_id"); userId == "" || payment.UserId != userId {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "payment doesn't belong to the user"})
+		return
+	}
+	if payment.Receipt == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "payment has no receipt"})
+		return
+	}
+
+	c.FileAttachment(payment.Receipt.Path, payment.Receipt.FileName)
+}
+
+func (h *PaymentHandler) getReviewQueue(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, c.Query("admin_id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	limitStr := c.Request.URL.Query().Get("limit")
+	limit, _ := strconv.ParseInt(limitStr, 10, 64)
+	if limit < 10 || limit > 100 {
+		limit = 10
+	}
+
+	payments, err := h.payment.GetAllPendingReview(ctx, limit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if payments == nil {
+		ps := make([]models.Payment, 0)
+		payments = &ps
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": payments})
+}
+
+func (h *PaymentHandler) approvePayment(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var approvePayment models.ApprovePayment
+	if err := c.BindJSON(&approvePayment); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("error parsing request data: %s", err)})
+		return
+	}
+
+	review := models.PaymentReview{
+		AdminId:    approvePayment.AdminId,
+		Approved:   true,
+		ReviewedAt: time.Now(),
+	}
+	status, err := h.reviewPayment(ctx, c.Param("payment_id"), review)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": fmt.Sprintf("payment status setted to %s", status.String())})
+}
+
+func (h *PaymentHandler) rejectPayment(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var rejectPayment models.RejectPayment
+	if err := c.BindJSON(&rejectPayment); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("error parsing request data: %s", err)})
+		return
+	}
+
+	review := models.PaymentReview{
+		AdminId:    rejectPayment.AdminId,
+		Approved:   false,
+		Reason:     rejectPayment.Reason,
+		ReviewedAt: time.Now(),
+	}
+	status, err := h.reviewPayment(ctx, c.Param("payment_id"), review)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"success": true, "result": gin.H{"id": id}})
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": fmt.Sprintf("payment status setted to %s", status.String())})
+}
+
+// reviewPayment applies an admin decision on a payment waiting for review
+func (h *PaymentHandler) reviewPayment(ctx context.Context, paymentId string, review models.PaymentReview) (models.PaymentStatus, error) {
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, review.AdminId); err != nil {
+		return "", err
+	}
+
+	payment, err := h.payment.Get(ctx, paymentId)
+	if err != nil {
+		return "", err
+	}
+	if !payment.Status.IsPendingReview() {
+		return "", errors.New("payment isn't waiting for review")
+	}
+
+	provider, err := utils.GetPaymentProvider(payment.Provider)
+	if err != nil {
+		return "", err
+	}
+	reviewable, ok := provider.(utils.ReviewableProvider)
+	if !ok {
+		return "", fmt.Errorf("%s payments can't be reviewed", provider.Name())
+	}
+	intent, err := reviewable.Review(ctx, payment.ProviderRef, review.Approved)
+	if err != nil {
+		return "", err
+	}
+
+	if err = h.payment.SetReview(ctx, payment.Id, review); err != nil {
+		return "", err
+	}
+	if err = h.settlePayment(ctx, payment, intent.Status); err != nil {
+		return "", err
+	}
+	return intent.Status, nil
+}
+
+// settlePayment stores the payment status confirmed by the provider, a succeeded payment marks the order paid
+// and a rejected one declines the order. Settled payments keep their status, a late or out of order
+// confirmation can't flip them. The order changes are applied once per payment, so they are applied again
+// when the payment already has the status: a settlement which failed halfway is finished by the retry.
+func (h *PaymentHandler) settlePayment(ctx context.Context, payment *models.Payment, status models.PaymentStatus) error {
+	if payment.Status != status {
+		if payment.Status.IsSettled() {
+			return nil
+		}
+		if err := h.payment.UpdateStatusFrom(ctx, payment.Id, payment.Status, status); err == repo.ErrPaymentStatusChanged {
+			// settled concurrently, finish the order changes when it was settled the same way
+			current, err := h.payment.Get(ctx, payment.Id)
+			if err != nil {
+				return err
+			}
+			if current.Status != status {
+				return nil
+			}
+		} else if err != nil {
+			return err
+		}
+		payment.Status = status
+	}
+
+	switch {
+	case status.IsSucceeded():
+		return h.applyPayment(ctx, payment)
+	case status.IsRejected():
+		return h.declineOrder(ctx, payment.OrderId)
+	}
+	return nil
+}
+
+// applyPayment adds a succeeded payment to the order paid amount. An underpayment leaves the order waiting
+// for the rest, an overpayment pays the order and the excess is recorded as credit for the user. Pre-orders
+// and back-orders paid in advance stay waiting for stock, allocation makes them paid.
+func (h *PaymentHandler) applyPayment(ctx context.Context, payment *models.Payment) error {
+	applied := payment.Amount
+	credited := int64(0)
+	reason := ""
+
+	order, err := h.order.AddPaidAmount(ctx, payment.OrderId, payment.Id, payment.Amount)
+	if err == repo.ErrOrderNotAwaitingPayment {
+		// money arrived after the order was paid, cancelled or expired
+		applied, credited = 0, payment.Amount
+		reason = "order isn't waiting for payment"
+	} else if err != nil {
+		return err
+	} else {
+		if excess := order.PaidAmount - order.TotalPrice; excess > 0 {
+			if excess > payment.Amount {
+				excess = payment.Amount
+			}
+			applied, credited = payment.Amount-excess, excess
+			reason = "overpayment"
+		}
+		if order.PaidAmount >= order.TotalPrice && order.Status.IsWaitingForPayment() {
+			if err = h.order.UpdateStatusFrom(ctx, order.Id, order.Status, models.Paid); err == nil {
+				order.Status = models.Paid
+			} else if err != repo.ErrOrderStatusChanged {
+				return err
+			}
+		}
+		// paid digital books go to the customer library, the payment stands when it fails
+		if order.Status.IsPaid() {
+			if err = h.library.Grant(ctx, order.Id); err != nil {
+				log.Printf("[PAYMENT] can't add order %v to the library: %v\n", order.Id, err)
+			}
+		}
+	}
+
+	if credited > 0 {
+		// one credit per payment, a retry doesn't credit twice
+		if _, err = h.credit.Add(ctx, models.Credit{
+			Id:        "payment-" + payment.Id,
+			UserId:    payment.UserId,
+			OrderId:   payment.OrderId,
+			PaymentId: payment.Id,
+			Amount:    credited,
+			Currency:  payment.Currency,
+			Reason:    reason,
+			CreatedAt: time.Now(),
+		}); err != nil && err != repo.ErrCreditExists {
+			return err
+		}
+	}
+
+	payment.Applied, payment.Credited = applied, credited
+	return h.payment.SetAllocation(ctx, payment.Id, applied, credited)
+}
+
+// declineOrder declines an order waiting for payment, restores the book stock, gives back its coupon and
+// credits what an earlier payment already paid
+func (h *PaymentHandler) declineOrder(ctx context.Context, orderId string) error {
+	order, err := h.order.Get(ctx, orderId)
+	if err != nil {
+		return err
+	}
+	// a declined order is declined again to finish a call-off which failed halfway
+	if !order.Status.IsWaitingForPayment() && !order.Status.IsDeclined() {
+		return nil
+	}
+	return h.orders.CallOff(ctx, order, models.Declined, "payment declined")
+}
+
+// saveReceipt stores an uploaded receipt file named after the payment
+func saveReceipt(c *gin.Context, paymentId string, fileHeader *multipart.FileHeader) (*models.Receipt, error) {
+	if fileHeader.Size > configs.ReceiptMaxSize {
+		return nil, fmt.Errorf("receipt file maximum size is %v bytes", configs.ReceiptMaxSize)
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, err
+	}
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(file, head)
+	_ = file.Close()
+
+	contentType := http.DetectContentType(head[:n])
+	ext, ok := configs.ReceiptContentTypes[contentType]
+	if !ok {
+		return nil, fmt.Errorf("receipt file type %s isn't accepted", contentType)
+	}
+
+	if err = os.MkdirAll(configs.ReceiptUploadDir, 0o755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(configs.ReceiptUploadDir, paymentId+ext)
+	if err = c.SaveUploadedFile(fileHeader, path); err != nil {
+		return nil, err
+	}
+
+	return &models.Receipt{
+		FileName:    filepath.Base(fileHeader.Filename),
+		ContentType: contentType,
+		Size:        fileHeader.Size,
+		Path:        path,
+		UploadedAt:  time.Now(),
+	}, nil
 }
 
 func (h *PaymentHandler) getPaymentByOrderId(c *gin.Context) {
@@ -106,6 +641,50 @@ func (h *PaymentHandler) getPaymentByOrderId(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"success": true, "result": payment})
 }
 
+func (h *PaymentHandler) getAllPaymentsByOrderId(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	orderId := c.Param("order_id")
+
+	payments, err := h.payment.GetAllByOrderId(ctx, orderId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if payments == nil {
+		ps := make([]models.Payment, 0)
+		payments = &ps
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": payments})
+}
+
+func (h *PaymentHandler) getCreditsByUserId(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	userId := c.Param("user_id")
+
+	limitStr := c.Request.URL.Query().Get("limit")
+	limit, _ := strconv.ParseInt(limitStr, 10, 64)
+	if limit < 10 || limit > 100 {
+		limit = 10
+	}
+
+	credits, err := h.credit.GetAllByUserId(ctx, userId, limit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if credits == nil {
+		cs := make([]models.Credit, 0)
+		credits = &cs
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": credits})
+}
+
 func (h *PaymentHandler) getPaymentByUserId(c *gin.Context) {
 	ctx := c.Request.Context()
 
@@ -147,6 +726,17 @@ func (h *PaymentHandler) delete(c *gin.Context) {
 
 	paymentId := c.Param("payment_id")
 
+	// money received is given back through refunds, not by removing the record
+	payment, err := h.payment.Get(ctx, paymentId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if payment.Status.IsSucceeded() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "succeeded payment can't be deleted, use a return to refund it"})
+		return
+	}
+
 	if err := h.payment.Delete(ctx, paymentId); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
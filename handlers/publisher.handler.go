@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/agustadewa/book-system/models"
+	"github.com/agustadewa/book-system/repo"
+	"github.com/agustadewa/book-system/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func NewPublisher(engine *gin.Engine, client *mongo.Client) *PublisherHandler {
+	return &PublisherHandler{
+		engine:    engine,
+		publisher: repo.NewPublisher(client),
+		book:      repo.NewBook(client),
+		user:      repo.NewUser(client),
+		catalog:   utils.NewCatalog(client),
+	}
+}
+
+type PublisherHandler struct {
+	engine    *gin.Engine
+	publisher *repo.Publisher
+	book      *repo.Book
+	user      *repo.User
+	catalog   *utils.Catalog
+}
+
+func (h *PublisherHandler) RegisterEndpoints() {
+	h.engine.POST("/publisher", h.addPublisher)
+	h.engine.GET("/publisher/all", h.getAllPublishers)
+	h.engine.GET("/publisher/:publisher_id", h.getPublisher)
+	h.engine.PUT("/publisher/:publisher_id", h.updatePublisher)
+	h.engine.DELETE("/publisher/:publisher_id", h.deletePublisher)
+	h.engine.GET("/publisher/:publisher_id/books", h.getPublisherBooks)
+}
+
+func (h *PublisherHandler) addPublisher(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var addPublisher models.AddPublisher
+	if err := c.BindJSON(&addPublisher); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("error parsing request data: %s", err)})
+		return
+	}
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, addPublisher.AdminId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	nameKey := utils.NameKey(addPublisher.Name)
+	if nameKey == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+
+	id, err := h.publisher.Add(ctx, models.Publisher{
+		Id:        primitive.NewObjectID().Hex(),
+		Name:      strings.TrimSpace(addPublisher.Name),
+		NameKey:   nameKey,
+		Website:   addPublisher.Website,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": gin.H{"id": id}})
+}
+
+func (h *PublisherHandler) getPublisher(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	publisher, err := h.publisher.Get(ctx, c.Param("publisher_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": publisher})
+}
+
+// getAllPublishers lists publishers by name, q finds the publishers whose name starts with it
+func (h *PublisherHandler) getAllPublishers(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	limit, _ := strconv.ParseInt(c.Query("limit"), 10, 64)
+	if limit < 10 || limit > 100 {
+		limit = 10
+	}
+
+	publishers, err := h.publisher.GetAll(ctx, utils.NameKey(c.Query("q")), limit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if publishers == nil {
+		ps := make([]models.Publisher, 0)
+		publishers = &ps
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": publishers})
+}
+
+func (h *PublisherHandler) updatePublisher(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	publisherId := c.Param("publisher_id")
+
+	var updatePublisher models.UpdatePublisher
+	if err := c.BindJSON(&updatePublisher); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("error parsing request data: %s", err)})
+		return
+	}
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, updatePublisher.AdminId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	publisher, err := h.catalog.UpdatePublisher(ctx, publisherId, updatePublisher)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": publisher})
+}
+
+// deletePublisher deletes a publisher, publishers with books can't be deleted
+func (h *PublisherHandler) deletePublisher(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	publisherId := c.Param("publisher_id")
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, c.Query("admin_id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.catalog.DeletePublisher(ctx, publisherId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": fmt.Sprintf("publisher %s has been deleted", publisherId)})
+}
+
+// getPublisherBooks lists the books of a publisher
+func (h *PublisherHandler) getPublisherBooks(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	publisherId := c.Param("publisher_id")
+
+	limit, _ := strconv.ParseInt(c.Query("limit"), 10, 64)
+	if limit < 10 || limit > 100 {
+		limit = 10
+	}
+
+	// check existing publisher
+	if _, err := h.publisher.Get(ctx, publisherId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	books, err := h.book.GetAllByPublisherId(ctx, publisherId, limit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if books == nil {
+		bs := make([]models.Book, 0)
+		books = &bs
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": books})
+}
@@ -0,0 +1,415 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/agustadewa/book-system/configs"
+	"github.com/agustadewa/book-system/models"
+	"github.com/agustadewa/book-system/repo"
+	"github.com/agustadewa/book-system/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func NewPurchase(engine *gin.Engine, client *mongo.Client) *PurchaseHandler {
+	return &PurchaseHandler{
+		engine:        engine,
+		supplier:      repo.NewSupplier(client),
+		purchaseOrder: repo.NewPurchaseOrder(client),
+		book:          repo.NewBook(client),
+		location:      repo.NewLocation(client),
+		user:          repo.NewUser(client),
+		purchasing:    utils.NewPurchasing(client),
+	}
+}
+
+type PurchaseHandler struct {
+	engine        *gin.Engine
+	supplier      *repo.Supplier
+	purchaseOrder *repo.PurchaseOrder
+	book          *repo.Book
+	location      *repo.Location
+	user          *repo.User
+	purchasing    *utils.Purchasing
+}
+
+func (h *PurchaseHandler) RegisterEndpoints() {
+	h.engine.POST("/supplier", h.addSupplier)
+	h.engine.GET("/supplier/all", h.getAllSuppliers)
+	h.engine.GET("/supplier/:supplier_id", h.getSupplier)
+	h.engine.PUT("/supplier/:supplier_id", h.updateSupplier)
+
+	h.engine.POST("/purchase/order", h.addPurchaseOrder)
+	h.engine.GET("/purchase/order/all", h.getAllPurchaseOrders)
+	h.engine.GET("/purchase/order/outstanding", h.getOutstanding)
+	h.engine.GET("/purchase/order/:purchase_order_id", h.getPurchaseOrder)
+	h.engine.POST("/purchase/order/:purchase_order_id/receive", h.receivePurchaseOrder)
+	h.engine.PUT("/purchase/order/:purchase_order_id/cancel", h.cancelPurchaseOrder)
+}
+
+func (h *PurchaseHandler) addSupplier(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var addSupplier models.AddSupplier
+	if err := c.BindJSON(&addSupplier); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("error parsing request data: %s", err)})
+		return
+	}
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, addSupplier.AdminId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	addSupplierPayload, err := supplierOf(primitive.NewObjectID().Hex(), addSupplier)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	addSupplierPayload.CreatedAt = time.Now()
+
+	id, err := h.supplier.Add(ctx, addSupplierPayload)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": gin.H{"id": id}})
+}
+
+func (h *PurchaseHandler) getSupplier(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	supplierId := c.Param("supplier_id")
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, c.Query("admin_id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	supplier, err := h.supplier.Get(ctx, supplierId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": supplier})
+}
+
+func (h *PurchaseHandler) getAllSuppliers(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, c.Query("admin_id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	suppliers, err := h.supplier.GetAll(ctx)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if suppliers == nil {
+		ss := make([]models.Supplier, 0)
+		suppliers = &ss
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": suppliers})
+}
+
+func (h *PurchaseHandler) updateSupplier(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	supplierId := c.Param("supplier_id")
+
+	var updateSupplier models.AddSupplier
+	if err := c.BindJSON(&updateSupplier); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("error parsing request data: %s", err)})
+		return
+	}
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, updateSupplier.AdminId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updateSupplierPayload, err := supplierOf(supplierId, updateSupplier)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err = h.supplier.Update(ctx, updateSupplierPayload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": fmt.Sprintf("supplier %s has been updated", supplierId)})
+}
+
+func (h *PurchaseHandler) addPurchaseOrder(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var addPurchaseOrder models.AddPurchaseOrder
+	if err := c.BindJSON(&addPurchaseOrder); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("error parsing request data: %s", err)})
+		return
+	}
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, addPurchaseOrder.AdminId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// check supplier
+	supplier, err := h.supplier.Get(ctx, addPurchaseOrder.SupplierId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !supplier.Active {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("supplier %s is inactive", supplier.Name)})
+		return
+	}
+
+	// check location, books are received into the default location unless told otherwise
+	locationId := addPurchaseOrder.LocationId
+	if locationId == "" {
+		locationId = configs.DefaultLocationId
+	}
+	if _, err = h.location.Get(ctx, locationId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// check currency
+	currency := configs.DefaultCurrency
+	if addPurchaseOrder.Currency != "" {
+		purchaseCurrency, err := models.IsValidCurrency(addPurchaseOrder.Currency)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		currency = purchaseCurrency.Code
+	}
+
+	// check lines
+	if err = utils.ValidatePurchaseOrderLines(addPurchaseOrder.Lines); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	lines := make([]models.PurchaseOrderLine, 0, len(addPurchaseOrder.Lines))
+	var total int64
+	for _, line := range addPurchaseOrder.Lines {
+		if _, err = h.book.Get(ctx, line.BookId); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s: %s", line.BookId, err)})
+			return
+		}
+		lines = append(lines, models.PurchaseOrderLine{BookId: line.BookId, Qty: line.Qty, UnitCost: line.UnitCost})
+		total += line.Qty * line.UnitCost
+	}
+
+	addPurchaseOrderPayload := models.PurchaseOrder{
+		Id:         primitive.NewObjectID().Hex(),
+		SupplierId: supplier.Id,
+		LocationId: locationId,
+		Status:     models.PurchaseOrdered,
+		Currency:   currency,
+		Lines:      lines,
+		Total:      total,
+		Receipts:   make([]models.GoodsReceipt, 0),
+		ExpectedAt: addPurchaseOrder.ExpectedAt,
+		Note:       addPurchaseOrder.Note,
+		CreatedBy:  addPurchaseOrder.AdminId,
+		CreatedAt:  time.Now(),
+	}
+	id, err := h.purchaseOrder.Add(ctx, addPurchaseOrderPayload)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": gin.H{"id": id}})
+}
+
+func (h *PurchaseHandler) getPurchaseOrder(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	purchaseOrderId := c.Param("purchase_order_id")
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, c.Query("admin_id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	purchaseOrder, err := h.purchaseOrder.Get(ctx, purchaseOrderId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": purchaseOrder})
+}
+
+// getAllPurchaseOrders returns the purchase orders, filtered by ?supplier_id= and ?status= when given
+func (h *PurchaseHandler) getAllPurchaseOrders(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, c.Query("admin_id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var status models.PurchaseOrderStatus
+	if c.Query("status") != "" {
+		purchaseStatus, err := models.IsValidPurchaseOrderStatus(strings.ToUpper(c.Query("status")))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		status = purchaseStatus
+	}
+
+	limitStr := c.Request.URL.Query().Get("limit")
+	limit, _ := strconv.ParseInt(limitStr, 10, 64)
+
+	if limit < 10 || limit > 100 {
+		limit = 10
+	}
+	purchaseOrders, err := h.purchaseOrder.GetAll(ctx, c.Query("supplier_id"), status, limit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if purchaseOrders == nil {
+		ps := make([]models.PurchaseOrder, 0)
+		purchaseOrders = &ps
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": purchaseOrders})
+}
+
+// receivePurchaseOrder books a delivery, deliveries may cover part of the purchase order
+func (h *PurchaseHandler) receivePurchaseOrder(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	purchaseOrderId := c.Param("purchase_order_id")
+
+	var receivePurchaseOrder models.ReceivePurchaseOrder
+	if err := c.BindJSON(&receivePurchaseOrder); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("error parsing request data: %s", err)})
+		return
+	}
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, receivePurchaseOrder.AdminId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	purchaseOrder, err := h.purchasing.Receive(ctx, purchaseOrderId, receivePurchaseOrder.Lines, receivePurchaseOrder.AdminId, receivePurchaseOrder.Note)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": purchaseOrder})
+}
+
+func (h *PurchaseHandler) cancelPurchaseOrder(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	purchaseOrderId := c.Param("purchase_order_id")
+
+	var cancelPurchaseOrder models.CancelPurchaseOrder
+	if err := c.BindJSON(&cancelPurchaseOrder); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("error parsing request data: %s", err)})
+		return
+	}
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, cancelPurchaseOrder.AdminId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.purchaseOrder.SetCancelled(ctx, purchaseOrderId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": fmt.Sprintf("purchase order %s has been cancelled", purchaseOrderId)})
+}
+
+// getOutstanding returns what every supplier still has to deliver on open purchase orders
+func (h *PurchaseHandler) getOutstanding(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, c.Query("admin_id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rows, err := h.purchaseOrder.OutstandingBySupplier(ctx, time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	suppliers, err := h.supplier.GetAll(ctx)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	names := map[string]string{}
+	for _, supplier := range *suppliers {
+		names[supplier.Id] = supplier.Name
+	}
+	for i := range rows {
+		rows[i].SupplierName = names[rows[i].SupplierId]
+	}
+	if rows == nil {
+		rows = make([]models.SupplierOutstanding, 0)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": rows})
+}
+
+func supplierOf(id string, req models.AddSupplier) (models.Supplier, error) {
+	supplierType, err := models.IsValidSupplierType(req.Type)
+	if err != nil {
+		return models.Supplier{}, err
+	}
+	if req.LeadTimeDays < 0 {
+		return models.Supplier{}, fmt.Errorf("lead time can't be lower than 0")
+	}
+	active := true
+	if req.Active != nil {
+		active = *req.Active
+	}
+	return models.Supplier{
+		Id:           id,
+		Name:         req.Name,
+		Type:         supplierType,
+		Email:        req.Email,
+		Phone:        req.Phone,
+		Address:      req.Address,
+		LeadTimeDays: req.LeadTimeDays,
+		Active:       active,
+	}, nil
+}
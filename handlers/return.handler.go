@@ -0,0 +1,498 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/agustadewa/book-system/models"
+	"github.com/agustadewa/book-system/repo"
+	"github.com/agustadewa/book-system/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func NewReturn(engine *gin.Engine, client *mongo.Client) *ReturnHandler {
+	return &ReturnHandler{
+		engine:  engine,
+		ret:     repo.NewReturn(client),
+		refund:  repo.NewRefund(client),
+		order:   repo.NewOrder(client),
+		payment: repo.NewPayment(client),
+		user:    repo.NewUser(client),
+
+		inventory: utils.NewInventory(client),
+		library:   utils.NewLibrary(client),
+	}
+}
+
+type ReturnHandler struct {
+	engine  *gin.Engine
+	ret     *repo.Return
+	refund  *repo.Refund
+	order   *repo.Order
+	payment *repo.Payment
+	user    *repo.User
+
+	inventory *utils.Inventory
+	library   *utils.Library
+}
+
+func (h *ReturnHandler) RegisterEndpoints() {
+	h.engine.POST("/return", h.addReturn)
+	h.engine.GET("/return/:return_id", h.getReturn)
+	h.engine.GET("/return/all/byuserid/:user_id", h.getAllReturnsByUserId)
+	h.engine.GET("/return/all/bystatus/:status", h.getAllReturnsByStatus)
+	h.engine.GET("/return/refund/byorderid/:order_id", h.getRefundsByOrderId)
+	h.engine.PUT("/return/:return_id/approve", h.approveReturn)
+	h.engine.PUT("/return/:return_id/reject", h.rejectReturn)
+	h.engine.PUT("/return/:return_id/receive", h.receiveReturn)
+	h.engine.PUT("/return/:return_id/refund", h.retryRefund)
+}
+
+func (h *ReturnHandler) addReturn(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var addReturn models.AddReturn
+	if err := c.BindJSON(&addReturn); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("error parsing request data: %s", err)})
+		return
+	}
+
+	if addReturn.Qty <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "quantity minimum is 1"})
+		return
+	}
+
+	// check existing order
+	order, err := h.order.Get(ctx, addReturn.OrderId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if order.UserId != addReturn.UserId {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "order doesn't belong to the user"})
+		return
+	}
+	if !order.Status.IsDelivered() && !order.Status.IsPartiallyRefunded() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "only delivered orders can be returned"})
+		return
+	}
+
+	// check quantity left to return
+	returns, err := h.ret.GetAllByOrderId(ctx, order.Id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	returnable := order.Qty
+	for _, ret := range *returns {
+		if !ret.Status.IsRejected() {
+			returnable -= ret.Qty
+		}
+	}
+	if addReturn.Qty > returnable {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("maximum quantity to return is %v", returnable)})
+		return
+	}
+
+	// add return
+	addReturnPayload := models.Return{
+		Id:          primitive.NewObjectID().Hex(),
+		OrderId:     order.Id,
+		UserId:      order.UserId,
+		BookId:      order.BookId,
+		Qty:         addReturn.Qty,
+		Reason:      addReturn.Reason,
+		Status:      models.ReturnRequested,
+		RequestedAt: time.Now(),
+	}
+	id, err := h.ret.Add(ctx, addReturnPayload)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": gin.H{"id": id}})
+}
+
+func (h *ReturnHandler) getReturn(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	returnId := c.Param("return_id")
+
+	ret, err := h.ret.Get(ctx, returnId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": ret})
+}
+
+func (h *ReturnHandler) getAllReturnsByUserId(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	userId := c.Param("user_id")
+
+	limitStr := c.Request.URL.Query().Get("limit")
+	limit, _ := strconv.ParseInt(limitStr, 10, 64)
+	if limit < 10 || limit > 100 {
+		limit = 10
+	}
+
+	returns, err := h.ret.GetAllByUserId(ctx, userId, limit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if returns == nil {
+		rs := make([]models.Return, 0)
+		returns = &rs
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": returns})
+}
+
+func (h *ReturnHandler) getAllReturnsByStatus(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, c.Query("admin_id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// validate return status
+	status, err := models.IsValidReturnStatus(c.Param("status"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	limitStr := c.Request.URL.Query().Get("limit")
+	limit, _ := strconv.ParseInt(limitStr, 10, 64)
+	if limit < 10 || limit > 100 {
+		limit = 10
+	}
+
+	returns, err := h.ret.GetAllByStatus(ctx, status, limit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if returns == nil {
+		rs := make([]models.Return, 0)
+		returns = &rs
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": returns})
+}
+
+func (h *ReturnHandler) getRefundsByOrderId(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	orderId := c.Param("order_id")
+
+	refunds, err := h.refund.GetAllByOrderId(ctx, orderId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if refunds == nil {
+		rs := make([]models.Refund, 0)
+		refunds = &rs
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": refunds})
+}
+
+func (h *ReturnHandler) approveReturn(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	returnId := c.Param("return_id")
+
+	var reviewReturn models.ReviewReturn
+	if err := c.BindJSON(&reviewReturn); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("error parsing request data: %s", err)})
+		return
+	}
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, reviewReturn.AdminId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.ret.Review(ctx, returnId, models.ReturnApproved, reviewReturn.AdminId, ""); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": fmt.Sprintf("return status setted to %s", models.ReturnApproved.String())})
+}
+
+func (h *ReturnHandler) rejectReturn(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	returnId := c.Param("return_id")
+
+	var reviewReturn models.ReviewReturn
+	if err := c.BindJSON(&reviewReturn); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("error parsing request data: %s", err)})
+		return
+	}
+	if reviewReturn.Reason == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reason is required"})
+		return
+	}
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, reviewReturn.AdminId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.ret.Review(ctx, returnId, models.ReturnRejected, reviewReturn.AdminId, reviewReturn.Reason); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": fmt.Sprintf("return status setted to %s", models.ReturnRejected.String())})
+}
+
+func (h *ReturnHandler) receiveReturn(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	returnId := c.Param("return_id")
+
+	var receiveReturn models.ReceiveReturn
+	if err := c.BindJSON(&receiveReturn); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("error parsing request data: %s", err)})
+		return
+	}
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, receiveReturn.AdminId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ret, err := h.ret.Get(ctx, returnId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !ret.Status.IsApproved() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "only approved returns can be received"})
+		return
+	}
+	order, err := h.order.Get(ctx, ret.OrderId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// refund the returned share of the items and their tax unless the admin decides otherwise, shipping
+	// isn't refunded
+	refundable := order.PaidAmount - order.RefundedAmount
+	refundAmount := order.ItemsTotal() * ret.Qty / order.Qty
+	if receiveReturn.RefundAmount != nil {
+		refundAmount = *receiveReturn.RefundAmount
+	}
+	if refundAmount < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "refund amount can't be lower than 0"})
+		return
+	}
+	if refundAmount > refundable {
+		refundAmount = refundable
+	}
+
+	// returned books go back to stock before the return is received, a failed receipt is retried and the
+	// books already restocked aren't counted again
+	if order.TakesStock() {
+		if _, err = h.inventory.MoveOnce(ctx, ret.BookId, order.LocationId, ret.Qty, models.StockReturn, receiveReturn.AdminId, ret.Id, ""); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if err = h.ret.SetReceived(ctx, ret.Id, refundAmount); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ret.RefundAmount = refundAmount
+
+	if err = h.issueRefund(ctx, ret); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("return is received but the refund failed, retry the refund: %s", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": fmt.Sprintf("return %s is refunded by %v", ret.Id, ret.RefundAmount)})
+}
+
+func (h *ReturnHandler) retryRefund(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	returnId := c.Param("return_id")
+
+	var reviewReturn models.ReviewReturn
+	if err := c.BindJSON(&reviewReturn); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("error parsing request data: %s", err)})
+		return
+	}
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, reviewReturn.AdminId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ret, err := h.ret.Get(ctx, returnId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !ret.Status.IsReceived() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "only received returns can be refunded"})
+		return
+	}
+
+	if err = h.issueRefund(ctx, ret); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": fmt.Sprintf("return %s is refunded by %v", ret.Id, ret.RefundAmount)})
+}
+
+// issueRefund refunds a received return through the providers of the order payments. Each refund is recorded
+// as pending before the provider is asked and its id is the provider idempotency key, so a retry completes the
+// pending refunds without refunding twice and continues where a failed attempt stopped.
+func (h *ReturnHandler) issueRefund(ctx context.Context, ret *models.Return) error {
+	refunds, err := h.refund.GetAllByReturnId(ctx, ret.Id)
+	if err != nil {
+		return err
+	}
+	// refunds of an earlier try are finished, every step of a refund is done once
+	remaining := ret.RefundAmount
+	for _, refund := range *refunds {
+		remaining -= refund.Amount
+		if err = h.completeRefund(ctx, refund); err != nil {
+			return err
+		}
+	}
+
+	if remaining > 0 {
+		payments, err := h.payment.GetAllByOrderId(ctx, ret.OrderId)
+		if err != nil {
+			return err
+		}
+		// what a payment can still give back follows from the refunds recorded against it, a refund which
+		// failed halfway isn't counted on the payment yet
+		orderRefunds, err := h.refund.GetAllByOrderId(ctx, ret.OrderId)
+		if err != nil {
+			return err
+		}
+		refundedByPayment := map[string]int64{}
+		for _, refund := range *orderRefunds {
+			refundedByPayment[refund.PaymentId] += refund.Amount
+		}
+
+		for _, payment := range *payments {
+			if remaining == 0 {
+				break
+			}
+			refundable := payment.Applied - refundedByPayment[payment.Id]
+			if !payment.Status.IsSucceeded() || refundable <= 0 {
+				continue
+			}
+			amount := remaining
+			if amount > refundable {
+				amount = refundable
+			}
+
+			refund := models.Refund{
+				Id:        ret.Id + "-" + payment.Id,
+				ReturnId:  ret.Id,
+				OrderId:   ret.OrderId,
+				PaymentId: payment.Id,
+				UserId:    ret.UserId,
+				Provider:  payment.Provider,
+				Amount:    amount,
+				Currency:  payment.Currency,
+				Status:    models.RefundPending,
+				CreatedAt: time.Now(),
+			}
+			if _, err = h.refund.Add(ctx, refund); err != nil {
+				return err
+			}
+			if err = h.completeRefund(ctx, refund); err != nil {
+				return err
+			}
+			remaining -= amount
+		}
+
+		if remaining > 0 {
+			return errors.New("order payments can't cover the refund amount")
+		}
+	}
+
+	order, err := h.order.Get(ctx, ret.OrderId)
+	if err != nil {
+		return err
+	}
+	// a returned ebook can't be downloaded anymore
+	if order.Format.IsDigital() {
+		if err = h.library.Revoke(ctx, order.Id); err != nil {
+			return err
+		}
+	}
+
+	if err = h.ret.SetRefunded(ctx, ret.Id); err != nil {
+		return err
+	}
+
+	// fully refunded once everything paid is given back
+	status := models.PartiallyRefunded
+	if order.RefundedAmount >= order.PaidAmount {
+		status = models.Refunded
+	}
+	return h.order.UpdateStatus(ctx, order.Id, status)
+}
+
+// completeRefund sends a refund to the provider and adds it to the refunded amounts of the payment and the
+// order. Every step is done once per refund, a refund which failed halfway is finished by calling it again.
+func (h *ReturnHandler) completeRefund(ctx context.Context, refund models.Refund) error {
+	// a succeeded refund was given back by the provider already
+	if refund.Status.IsPending() {
+		payment, err := h.payment.Get(ctx, refund.PaymentId)
+		if err != nil {
+			return err
+		}
+		provider, err := utils.GetPaymentProvider(refund.Provider)
+		if err != nil {
+			return err
+		}
+		providerRefund, err := provider.Refund(ctx, payment.ProviderRef, refund.Amount, refund.Id)
+		if err != nil {
+			return err
+		}
+		if err = h.refund.SetSucceeded(ctx, refund.Id, providerRefund.Reference); err != nil && err != repo.ErrRefundNotPending {
+			return err
+		}
+	}
+
+	if err := h.payment.AddRefunded(ctx, refund.PaymentId, refund.Id, refund.Amount); err != nil {
+		return err
+	}
+	_, err := h.order.AddRefundedAmount(ctx, refund.OrderId, refund.Id, refund.Amount)
+	return err
+}
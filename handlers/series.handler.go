@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/agustadewa/book-system/models"
+	"github.com/agustadewa/book-system/repo"
+	"github.com/agustadewa/book-system/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func NewSeries(engine *gin.Engine, client *mongo.Client) *SeriesHandler {
+	return &SeriesHandler{
+		engine:  engine,
+		series:  repo.NewSeries(client),
+		user:    repo.NewUser(client),
+		volumes: utils.NewSeries(client),
+	}
+}
+
+type SeriesHandler struct {
+	engine  *gin.Engine
+	series  *repo.Series
+	user    *repo.User
+	volumes *utils.Series
+}
+
+func (h *SeriesHandler) RegisterEndpoints() {
+	h.engine.POST("/series", h.addSeries)
+	h.engine.GET("/series/all", h.getAllSeries)
+	h.engine.GET("/series/:series_id", h.getSeries)
+	h.engine.PUT("/series/:series_id", h.updateSeries)
+	h.engine.DELETE("/series/:series_id", h.deleteSeries)
+	h.engine.PUT("/series/:series_id/volume", h.setVolume)
+	h.engine.DELETE("/series/:series_id/volume/:book_id", h.removeVolume)
+}
+
+func (h *SeriesHandler) addSeries(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var addSeries models.AddSeries
+	if err := c.BindJSON(&addSeries); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("error parsing request data: %s", err)})
+		return
+	}
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, addSeries.AdminId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	id, err := h.volumes.Add(ctx, addSeries)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": gin.H{"id": id}})
+}
+
+// getSeries returns a series with its volumes in order
+func (h *SeriesHandler) getSeries(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	series, err := h.volumes.Get(ctx, c.Param("series_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": series})
+}
+
+// getAllSeries lists series by name, q finds the series whose name starts with it
+func (h *SeriesHandler) getAllSeries(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	limit, _ := strconv.ParseInt(c.Query("limit"), 10, 64)
+	if limit < 10 || limit > 100 {
+		limit = 10
+	}
+
+	series, err := h.series.GetAll(ctx, utils.NameKey(c.Query("q")), limit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if series == nil {
+		ss := make([]models.Series, 0)
+		series = &ss
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": series})
+}
+
+func (h *SeriesHandler) updateSeries(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	seriesId := c.Param("series_id")
+
+	var updateSeries models.UpdateSeries
+	if err := c.BindJSON(&updateSeries); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("error parsing request data: %s", err)})
+		return
+	}
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, updateSeries.AdminId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	series, err := h.volumes.Update(ctx, seriesId, updateSeries)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": series})
+}
+
+// deleteSeries deletes a series, series with books can't be deleted
+func (h *SeriesHandler) deleteSeries(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	seriesId := c.Param("series_id")
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, c.Query("admin_id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.volumes.Delete(ctx, seriesId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": fmt.Sprintf("series %s has been deleted", seriesId)})
+}
+
+// setVolume places the title of a book in the series, every format of the title is the same volume
+func (h *SeriesHandler) setVolume(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	seriesId := c.Param("series_id")
+
+	var setVolume models.SetSeriesVolume
+	if err := c.BindJSON(&setVolume); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("error parsing request data: %s", err)})
+		return
+	}
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, setVolume.AdminId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.volumes.SetVolume(ctx, seriesId, setVolume.BookId, *setVolume.Volume); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": fmt.Sprintf("book %s is volume %v of series %s", setVolume.BookId, *setVolume.Volume, seriesId)})
+}
+
+func (h *SeriesHandler) removeVolume(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	seriesId := c.Param("series_id")
+	bookId := c.Param("book_id")
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, c.Query("admin_id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.volumes.RemoveVolume(ctx, seriesId, bookId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": fmt.Sprintf("book %s has been taken out of series %s", bookId, seriesId)})
+}
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/agustadewa/book-system/models"
+	"github.com/agustadewa/book-system/repo"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func NewShipment(engine *gin.Engine, client *mongo.Client) *ShipmentHandler {
+	return &ShipmentHandler{
+		engine:   engine,
+		shipment: repo.NewShipment(client),
+		order:    repo.NewOrder(client),
+		user:     repo.NewUser(client),
+	}
+}
+
+type ShipmentHandler struct {
+	engine   *gin.Engine
+	shipment *repo.Shipment
+	order    *repo.Order
+	user     *repo.User
+}
+
+func (h *ShipmentHandler) RegisterEndpoints() {
+	h.engine.POST("/shipment", h.addShipment)
+	h.engine.GET("/shipment/carriers", h.getCarriers)
+	h.engine.GET("/shipment/:shipment_id", h.getShipment)
+	h.engine.PUT("/shipment/:shipment_id/delivered", h.setDelivered)
+	h.engine.GET("/order/:order_id/tracking", h.getTracking)
+}
+
+func (h *ShipmentHandler) addShipment(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var addShipment models.AddShipment
+	if err := c.BindJSON(&addShipment); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("error parsing request data: %s", err)})
+		return
+	}
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, addShipment.AdminId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	carrier, err := models.IsValidCarrier(addShipment.Carrier)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	serviceLevel, err := models.IsValidServiceLevel(addShipment.ServiceLevel)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// check existing order
+	order, err := h.order.Get(ctx, addShipment.OrderId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !order.Status.IsPaid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "only paid orders can be shipped"})
+		return
+	}
+	if !order.TakesStock() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "digital orders aren't shipped"})
+		return
+	}
+
+	// snapshot the destination, later changes to the order don't move the parcel
+	address := order.ShippingAddress
+	if addShipment.Address != nil {
+		address = addShipment.Address
+	}
+	if address == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "order has no shipping address"})
+		return
+	}
+
+	// add shipment
+	addShipmentPayload := models.Shipment{
+		Id:             primitive.NewObjectID().Hex(),
+		OrderId:        order.Id,
+		UserId:         order.UserId,
+		Carrier:        carrier,
+		ServiceLevel:   serviceLevel,
+		TrackingNumber: addShipment.TrackingNumber,
+		TrackingUrl:    carrier.TrackingUrl(addShipment.TrackingNumber),
+		Address:        *address,
+		Status:         models.ShipmentShipped,
+		ShippedBy:      addShipment.AdminId,
+		ShippedAt:      time.Now(),
+	}
+	id, err := h.shipment.Add(ctx, addShipmentPayload)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// update order status
+	if err = h.order.UpdateStatus(ctx, order.Id, models.OnShipping); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": gin.H{"id": id}})
+}
+
+func (h *ShipmentHandler) getCarriers(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": models.Carriers()})
+}
+
+func (h *ShipmentHandler) getShipment(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	shipmentId := c.Param("shipment_id")
+
+	shipment, err := h.shipment.Get(ctx, shipmentId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": shipment})
+}
+
+func (h *ShipmentHandler) setDelivered(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	shipmentId := c.Param("shipment_id")
+
+	var deliverShipment models.DeliverShipment
+	if err := c.BindJSON(&deliverShipment); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("error parsing request data: %s", err)})
+		return
+	}
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, deliverShipment.AdminId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	shipment, err := h.shipment.Get(ctx, shipmentId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err = h.shipment.SetDelivered(ctx, shipment.Id, time.Now()); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// update order status
+	if err = h.order.UpdateStatus(ctx, shipment.OrderId, models.Delivered); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": fmt.Sprintf("shipment %s has been delivered", shipment.Id)})
+}
+
+func (h *ShipmentHandler) getTracking(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	orderId := c.Param("order_id")
+	userId := c.Query("user_id")
+
+	// check existing order
+	order, err := h.order.Get(ctx, orderId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if order.UserId != userId {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "order doesn't belong to the user"})
+		return
+	}
+
+	shipment, err := h.shipment.GetByOrderId(ctx, order.Id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": gin.H{
+		"order_status": order.Status,
+		"shipment":     shipment,
+	}})
+}
@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/agustadewa/book-system/models"
+	"github.com/agustadewa/book-system/repo"
+	"github.com/agustadewa/book-system/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func NewShipping(engine *gin.Engine, client *mongo.Client) *ShippingHandler {
+	return &ShippingHandler{
+		engine:  engine,
+		zone:    repo.NewShippingZone(client),
+		book:    repo.NewBook(client),
+		user:    repo.NewUser(client),
+		pricing: utils.NewPricing(client),
+		coupons: utils.NewCoupons(client),
+	}
+}
+
+type ShippingHandler struct {
+	engine  *gin.Engine
+	zone    *repo.ShippingZone
+	book    *repo.Book
+	user    *repo.User
+	pricing *utils.Pricing
+	coupons *utils.Coupons
+}
+
+func (h *ShippingHandler) RegisterEndpoints() {
+	h.engine.POST("/shipping/quote", h.getQuote)
+	h.engine.POST("/shipping/zone", h.addZone)
+	h.engine.GET("/shipping/zone/all", h.getAllZones)
+	h.engine.GET("/shipping/zone/:zone_id", h.getZone)
+	h.engine.PUT("/shipping/zone/:zone_id", h.updateZone)
+	h.engine.DELETE("/shipping/zone/:zone_id", h.deleteZone)
+}
+
+func (h *ShippingHandler) getQuote(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var quoteReq models.QuoteReq
+	if err := c.BindJSON(&quoteReq); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("error parsing request data: %s", err)})
+		return
+	}
+	if quoteReq.Qty <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "quantity minimum is 1"})
+		return
+	}
+
+	// check existing book
+	book, err := h.book.Get(ctx, quoteReq.BookId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// check coupon, usage limits are only counted when the order is placed
+	var coupon *models.Coupon
+	if quoteReq.CouponCode != "" {
+		if coupon, err = h.coupons.GetByCode(ctx, quoteReq.CouponCode); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// check currency
+	var currency string
+	if quoteReq.Currency != "" {
+		quoteCurrency, err := models.IsValidCurrency(quoteReq.Currency)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		currency = quoteCurrency.Code
+	}
+
+	quote, err := h.pricing.Quote(ctx, book, quoteReq.Qty, quoteReq.ShippingAddress, coupon, currency)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": quote})
+}
+
+func (h *ShippingHandler) addZone(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var addZone models.AddShippingZone
+	if err := c.BindJSON(&addZone); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("error parsing request data: %s", err)})
+		return
+	}
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, addZone.AdminId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := utils.ValidateShippingRate(addZone.Rate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// add shipping zone
+	addZonePayload := models.ShippingZone{
+		Id:        primitive.NewObjectID().Hex(),
+		Name:      addZone.Name,
+		Countries: addZone.Countries,
+		Provinces: addZone.Provinces,
+		IsDefault: addZone.IsDefault,
+		Rate:      addZone.Rate,
+	}
+	id, err := h.zone.Add(ctx, addZonePayload)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": gin.H{"id": id}})
+}
+
+func (h *ShippingHandler) getAllZones(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	zones, err := h.zone.GetAll(ctx)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if zones == nil {
+		zs := make([]models.ShippingZone, 0)
+		zones = &zs
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": zones})
+}
+
+func (h *ShippingHandler) getZone(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	zoneId := c.Param("zone_id")
+
+	zone, err := h.zone.Get(ctx, zoneId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": zone})
+}
+
+func (h *ShippingHandler) updateZone(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	zoneId := c.Param("zone_id")
+
+	var updateZone models.AddShippingZone
+	if err := c.BindJSON(&updateZone); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("error parsing request data: %s", err)})
+		return
+	}
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, updateZone.AdminId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := utils.ValidateShippingRate(updateZone.Rate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updateZonePayload := models.ShippingZone{
+		Id:        zoneId,
+		Name:      updateZone.Name,
+		Countries: updateZone.Countries,
+		Provinces: updateZone.Provinces,
+		IsDefault: updateZone.IsDefault,
+		Rate:      updateZone.Rate,
+	}
+	if err := h.zone.Replace(ctx, updateZonePayload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": fmt.Sprintf("shipping zone %s has been updated", zoneId)})
+}
+
+func (h *ShippingHandler) deleteZone(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	zoneId := c.Param("zone_id")
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, c.Query("admin_id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.zone.Delete(ctx, zoneId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": fmt.Sprintf("shipping zone %s has been deleted", zoneId)})
+}
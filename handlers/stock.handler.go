@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/agustadewa/book-system/configs"
+	"github.com/agustadewa/book-system/models"
+	"github.com/agustadewa/book-system/repo"
+	"github.com/agustadewa/book-system/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func NewStock(engine *gin.Engine, client *mongo.Client) *StockHandler {
+	return &StockHandler{
+		engine:    engine,
+		book:      repo.NewBook(client),
+		movement:  repo.NewStockMovement(client),
+		level:     repo.NewStockLevel(client),
+		transfer:  repo.NewStockTransfer(client),
+		user:      repo.NewUser(client),
+		inventory: utils.NewInventory(client),
+		reorder:   utils.NewReorder(client),
+	}
+}
+
+type StockHandler struct {
+	engine    *gin.Engine
+	book      *repo.Book
+	movement  *repo.StockMovement
+	level     *repo.StockLevel
+	transfer  *repo.StockTransfer
+	user      *repo.User
+	inventory *utils.Inventory
+	reorder   *utils.Reorder
+}
+
+func (h *StockHandler) RegisterEndpoints() {
+	h.engine.GET("/stock/discrepancies", h.getDiscrepancies)
+	h.engine.GET("/stock/low", h.getLowStock)
+	h.engine.GET("/stock/reorder", h.getReorderSuggestions)
+	h.engine.POST("/stock/transfer", h.transferStock)
+	h.engine.GET("/stock/transfer/all", h.getAllTransfers)
+	h.engine.GET("/stock/:book_id/levels", h.getLevels)
+	h.engine.POST("/stock/:book_id/adjust", h.adjustStock)
+	h.engine.GET("/stock/:book_id/ledger", h.getLedger)
+	h.engine.GET("/stock/:book_id/audit", h.getAudit)
+	h.engine.POST("/stock/:book_id/rebuild", h.rebuildStock)
+}
+
+func (h *StockHandler) adjustStock(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	bookId := c.Param("book_id")
+
+	var adjustStock models.AdjustStock
+	if err := c.BindJSON(&adjustStock); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("error parsing request data: %s", err)})
+		return
+	}
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, adjustStock.AdminId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	reason, err := models.IsValidStockReason(adjustStock.Reason)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !reason.IsManual() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s movements are made by orders and returns", reason)})
+		return
+	}
+	if reason == models.StockReceipt && adjustStock.Delta <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "receipt quantity minimum is 1"})
+		return
+	}
+	if reason != models.StockReceipt && adjustStock.Note == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "note is required for adjustments"})
+		return
+	}
+
+	movement, err := h.inventory.Move(ctx, bookId, adjustStock.LocationId, adjustStock.Delta, reason, adjustStock.AdminId, "", adjustStock.Note)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": movement})
+}
+
+func (h *StockHandler) getLedger(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	bookId := c.Param("book_id")
+
+	limitStr := c.Request.URL.Query().Get("limit")
+	limit, _ := strconv.ParseInt(limitStr, 10, 64)
+
+	if limit < 10 || limit > 100 {
+		limit = 10
+	}
+	movements, err := h.movement.GetAllByBookId(ctx, bookId, limit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if movements == nil {
+		ms := make([]models.StockMovement, 0)
+		movements = &ms
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": movements})
+}
+
+func (h *StockHandler) getAudit(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	bookId := c.Param("book_id")
+
+	audit, err := h.inventory.Audit(ctx, bookId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": audit})
+}
+
+// rebuildStock sets a book stock at every location to its ledger sum, run after a discrepancy is investigated
+func (h *StockHandler) rebuildStock(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	bookId := c.Param("book_id")
+
+	var rebuildStock models.RebuildStock
+	if err := c.BindJSON(&rebuildStock); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("error parsing request data: %s", err)})
+		return
+	}
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, rebuildStock.AdminId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	before, err := h.inventory.Audit(ctx, bookId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	after, err := h.inventory.Rebuild(ctx, bookId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": gin.H{"before": before, "after": after}})
+}
+
+func (h *StockHandler) getDiscrepancies(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, c.Query("admin_id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	audits, err := h.inventory.Discrepancies(ctx)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": audits})
+}
+
+func (h *StockHandler) getLevels(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	bookId := c.Param("book_id")
+
+	levels, err := h.level.GetAllByBookId(ctx, bookId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if levels == nil {
+		ls := make([]models.StockLevel, 0)
+		levels = &ls
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": levels})
+}
+
+func (h *StockHandler) transferStock(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var addTransfer models.AddStockTransfer
+	if err := c.BindJSON(&addTransfer); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("error parsing request data: %s", err)})
+		return
+	}
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, addTransfer.AdminId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	transfer, err := h.inventory.Transfer(ctx, addTransfer.BookId, addTransfer.FromLocationId, addTransfer.ToLocationId, addTransfer.Qty, addTransfer.AdminId, addTransfer.Note)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": transfer})
+}
+
+func (h *StockHandler) getAllTransfers(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	limitStr := c.Request.URL.Query().Get("limit")
+	limit, _ := strconv.ParseInt(limitStr, 10, 64)
+
+	if limit < 10 || limit > 100 {
+		limit = 10
+	}
+	transfers, err := h.transfer.GetAll(ctx, c.Query("book_id"), limit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if transfers == nil {
+		ts := make([]models.StockTransfer, 0)
+		transfers = &ts
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": transfers})
+}
+
+func (h *StockHandler) getLowStock(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, c.Query("admin_id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	books, err := h.book.GetAllLowStock(ctx)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if books == nil {
+		bs := make([]models.Book, 0)
+		books = &bs
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": books})
+}
+
+// getReorderSuggestions returns how much of every book to reorder, days is how many days of sales the sales
+// velocity is computed from and cover_days how many days of sales the reorder should last
+func (h *StockHandler) getReorderSuggestions(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, c.Query("admin_id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	salesDays, _ := strconv.Atoi(c.Query("days"))
+	if salesDays <= 0 || salesDays > 365 {
+		salesDays = configs.ReorderSalesDays
+	}
+	coverDays, _ := strconv.Atoi(c.Query("cover_days"))
+	if coverDays <= 0 || coverDays > 365 {
+		coverDays = configs.ReorderCoverDays
+	}
+
+	suggestions, err := h.reorder.Suggestions(ctx, salesDays, coverDays, time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": gin.H{"days": salesDays, "cover_days": coverDays, "suggestions": suggestions}})
+}
@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/agustadewa/book-system/models"
+	"github.com/agustadewa/book-system/repo"
+	"github.com/agustadewa/book-system/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func NewStockTake(engine *gin.Engine, client *mongo.Client) *StockTakeHandler {
+	return &StockTakeHandler{
+		engine:     engine,
+		stockTake:  repo.NewStockTake(client),
+		user:       repo.NewUser(client),
+		stockTakes: utils.NewStockTakes(client),
+	}
+}
+
+type StockTakeHandler struct {
+	engine     *gin.Engine
+	stockTake  *repo.StockTake
+	user       *repo.User
+	stockTakes *utils.StockTakes
+}
+
+func (h *StockTakeHandler) RegisterEndpoints() {
+	h.engine.POST("/stock/take", h.openStockTake)
+	h.engine.GET("/stock/take/all", h.getAllStockTakes)
+	h.engine.GET("/stock/take/:stock_take_id", h.getStockTake)
+	h.engine.POST("/stock/take/:stock_take_id/counts", h.submitCounts)
+	h.engine.POST("/stock/take/:stock_take_id/commit", h.commitStockTake)
+	h.engine.POST("/stock/take/:stock_take_id/cancel", h.cancelStockTake)
+}
+
+func (h *StockTakeHandler) openStockTake(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var openStockTake models.OpenStockTake
+	if err := c.BindJSON(&openStockTake); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("error parsing request data: %s", err)})
+		return
+	}
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, openStockTake.AdminId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	stockTake, err := h.stockTakes.Open(ctx, openStockTake.LocationId, openStockTake.AdminId, openStockTake.Note)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": stockTake})
+}
+
+func (h *StockTakeHandler) getAllStockTakes(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, c.Query("admin_id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	limitStr := c.Request.URL.Query().Get("limit")
+	limit, _ := strconv.ParseInt(limitStr, 10, 64)
+
+	if limit < 10 || limit > 100 {
+		limit = 10
+	}
+	stockTakes, err := h.stockTake.GetAll(ctx, limit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if stockTakes == nil {
+		ss := make([]models.StockTake, 0)
+		stockTakes = &ss
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": stockTakes})
+}
+
+// getStockTake returns a stock take with the variance of every book, ?variances_only=true leaves out the
+// books whose count matches
+func (h *StockTakeHandler) getStockTake(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	stockTakeId := c.Param("stock_take_id")
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, c.Query("admin_id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	stockTake, err := h.stockTake.Get(ctx, stockTakeId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	variances, err := h.stockTakes.Variances(ctx, *stockTake)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var counted int
+	filtered := make([]models.StockTakeVariance, 0, len(variances))
+	for _, variance := range variances {
+		if variance.Counted {
+			counted++
+		}
+		if c.Query("variances_only") == "true" && variance.Variance == 0 {
+			continue
+		}
+		filtered = append(filtered, variance)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": gin.H{
+		"stock_take": stockTake,
+		"books":      len(variances),
+		"counted":    counted,
+		"variances":  filtered,
+	}})
+}
+
+func (h *StockTakeHandler) submitCounts(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	stockTakeId := c.Param("stock_take_id")
+
+	var submitCounts models.SubmitStockCounts
+	if err := c.BindJSON(&submitCounts); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("error parsing request data: %s", err)})
+		return
+	}
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, submitCounts.AdminId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.stockTakes.Count(ctx, stockTakeId, submitCounts.Counts, submitCounts.AdminId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": fmt.Sprintf("%v counts have been added to stock take %s", len(submitCounts.Counts), stockTakeId)})
+}
+
+func (h *StockTakeHandler) commitStockTake(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	stockTakeId := c.Param("stock_take_id")
+
+	var closeStockTake models.CloseStockTake
+	if err := c.BindJSON(&closeStockTake); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("error parsing request data: %s", err)})
+		return
+	}
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, closeStockTake.AdminId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	corrections, err := h.stockTakes.Commit(ctx, stockTakeId, closeStockTake.AdminId, closeStockTake.ZeroUncounted)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": corrections})
+}
+
+func (h *StockTakeHandler) cancelStockTake(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	stockTakeId := c.Param("stock_take_id")
+
+	var closeStockTake models.CloseStockTake
+	if err := c.BindJSON(&closeStockTake); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("error parsing request data: %s", err)})
+		return
+	}
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, closeStockTake.AdminId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.stockTakes.Cancel(ctx, stockTakeId, closeStockTake.AdminId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": fmt.Sprintf("stock take %s has been cancelled", stockTakeId)})
+}
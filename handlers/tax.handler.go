@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/agustadewa/book-system/models"
+	"github.com/agustadewa/book-system/repo"
+	"github.com/agustadewa/book-system/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// characters of an RFC3339 order time that make a report period
+var taxReportPeriods = map[string]string{
+	"day":   "%Y-%m-%d",
+	"month": "%Y-%m",
+	"year":  "%Y",
+}
+
+func NewTax(engine *gin.Engine, client *mongo.Client) *TaxHandler {
+	return &TaxHandler{
+		engine: engine,
+		region: repo.NewTaxRegion(client),
+		order:  repo.NewOrder(client),
+		user:   repo.NewUser(client),
+	}
+}
+
+type TaxHandler struct {
+	engine *gin.Engine
+	region *repo.TaxRegion
+	order  *repo.Order
+	user   *repo.User
+}
+
+func (h *TaxHandler) RegisterEndpoints() {
+	h.engine.POST("/tax/region", h.addRegion)
+	h.engine.GET("/tax/region/all", h.getAllRegions)
+	h.engine.GET("/tax/region/:region_id", h.getRegion)
+	h.engine.PUT("/tax/region/:region_id", h.updateRegion)
+	h.engine.DELETE("/tax/region/:region_id", h.deleteRegion)
+	h.engine.GET("/tax/report", h.getReport)
+}
+
+func (h *TaxHandler) addRegion(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var addRegion models.AddTaxRegion
+	if err := c.BindJSON(&addRegion); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("error parsing request data: %s", err)})
+		return
+	}
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, addRegion.AdminId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	addRegionPayload := taxRegionOf(primitive.NewObjectID().Hex(), addRegion)
+	if err := utils.ValidateTaxRegion(addRegionPayload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// add tax region
+	id, err := h.region.Add(ctx, addRegionPayload)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": gin.H{"id": id}})
+}
+
+func (h *TaxHandler) getAllRegions(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	regions, err := h.region.GetAll(ctx)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if regions == nil {
+		rs := make([]models.TaxRegion, 0)
+		regions = &rs
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": regions})
+}
+
+func (h *TaxHandler) getRegion(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	regionId := c.Param("region_id")
+
+	region, err := h.region.Get(ctx, regionId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": region})
+}
+
+func (h *TaxHandler) updateRegion(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	regionId := c.Param("region_id")
+
+	var updateRegion models.AddTaxRegion
+	if err := c.BindJSON(&updateRegion); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("error parsing request data: %s", err)})
+		return
+	}
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, updateRegion.AdminId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updateRegionPayload := taxRegionOf(regionId, updateRegion)
+	if err := utils.ValidateTaxRegion(updateRegionPayload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.region.Replace(ctx, updateRegionPayload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": fmt.Sprintf("tax region %s has been updated", regionId)})
+}
+
+func (h *TaxHandler) deleteRegion(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	regionId := c.Param("region_id")
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, c.Query("admin_id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.region.Delete(ctx, regionId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": fmt.Sprintf("tax region %s has been deleted", regionId)})
+}
+
+// getReport sums the tax collected on paid orders less refunds, from and to are dates (2006-01-02) or RFC3339
+// times and the range includes from but not to. Dates and periods are in UTC.
+func (h *TaxHandler) getReport(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, c.Query("admin_id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	from, err := parseReportTime(c.Query("from"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid from: %s", err)})
+		return
+	}
+	to, err := parseReportTime(c.Query("to"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid to: %s", err)})
+		return
+	}
+	if !from.Before(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+		return
+	}
+
+	period := c.DefaultQuery("period", "month")
+	periodFormat, ok := taxReportPeriods[period]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "period must be day, month or year"})
+		return
+	}
+
+	rows, err := h.order.TaxReport(ctx, from, to, periodFormat)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if rows == nil {
+		rs := make([]models.TaxReportRow, 0)
+		rows = &rs
+	}
+
+	// orders are taxed in the currency they were paid in
+	taxTotals := map[string]int64{}
+	for _, row := range *rows {
+		taxTotals[row.Currency] += row.TaxAmount
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": gin.H{
+		"from":       from.UTC().Format(time.RFC3339),
+		"to":         to.UTC().Format(time.RFC3339),
+		"period":     period,
+		"tax_totals": taxTotals,
+		"rows":       rows,
+	}})
+}
+
+func taxRegionOf(id string, req models.AddTaxRegion) models.TaxRegion {
+	return models.TaxRegion{
+		Id:               id,
+		Name:             req.Name,
+		Countries:        req.Countries,
+		Provinces:        req.Provinces,
+		IsDefault:        req.IsDefault,
+		StandardRateBps:  req.StandardRateBps,
+		ReducedRateBps:   req.ReducedRateBps,
+		PricesIncludeTax: req.PricesIncludeTax,
+	}
+}
+
+func parseReportTime(value string) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", value, time.UTC); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
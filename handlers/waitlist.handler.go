@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/agustadewa/book-system/models"
+	"github.com/agustadewa/book-system/repo"
+	"github.com/agustadewa/book-system/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func NewWaitlist(engine *gin.Engine, client *mongo.Client) *WaitlistHandler {
+	return &WaitlistHandler{
+		engine:   engine,
+		entry:    repo.NewWaitlist(client),
+		book:     repo.NewBook(client),
+		user:     repo.NewUser(client),
+		waitlist: utils.NewWaitlist(client),
+	}
+}
+
+type WaitlistHandler struct {
+	engine   *gin.Engine
+	entry    *repo.Waitlist
+	book     *repo.Book
+	user     *repo.User
+	waitlist *utils.Waitlist
+}
+
+func (h *WaitlistHandler) RegisterEndpoints() {
+	h.engine.POST("/waitlist", h.subscribe)
+	h.engine.GET("/waitlist/all/byuserid/:user_id", h.getAllByUserId)
+	h.engine.GET("/waitlist/all/bybookid/:book_id", h.getAllByBookId)
+	h.engine.DELETE("/waitlist/:entry_id", h.cancel)
+}
+
+// subscribe puts a customer on the waitlist of a sold out book, reserve asks to get the books held for a
+// while when they are back
+func (h *WaitlistHandler) subscribe(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var addEntry models.AddWaitlistEntry
+	if err := c.BindJSON(&addEntry); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("error parsing request data: %s", err)})
+		return
+	}
+	if addEntry.Qty == 0 {
+		addEntry.Qty = 1
+	}
+
+	// check existing user
+	if _, err := h.user.Get(ctx, addEntry.UserId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// check existing book
+	book, err := h.book.Get(ctx, addEntry.BookId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entry, err := h.waitlist.Subscribe(ctx, *book, addEntry.UserId, addEntry.Qty, addEntry.Reserve)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": entry})
+}
+
+func (h *WaitlistHandler) getAllByUserId(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	userId := c.Param("user_id")
+
+	limitStr := c.Request.URL.Query().Get("limit")
+	limit, _ := strconv.ParseInt(limitStr, 10, 64)
+
+	if limit < 10 || limit > 100 {
+		limit = 10
+	}
+	entries, err := h.entry.GetAllByUserId(ctx, userId, limit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if entries == nil {
+		es := make([]models.WaitlistEntry, 0)
+		entries = &es
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": entries})
+}
+
+// getAllByBookId returns the customers waiting for a book in the order they are served
+func (h *WaitlistHandler) getAllByBookId(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	bookId := c.Param("book_id")
+
+	// check admin
+	if _, err := h.user.GetAdmin(ctx, c.Query("admin_id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entries, err := h.entry.GetAllByBookId(ctx, bookId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if entries == nil {
+		es := make([]models.WaitlistEntry, 0)
+		entries = &es
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": entries})
+}
+
+func (h *WaitlistHandler) cancel(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	entryId := c.Param("entry_id")
+
+	if err := h.waitlist.Cancel(ctx, entryId, c.Query("user_id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": fmt.Sprintf("waitlist entry %s has been cancelled", entryId)})
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"log"
 
+	"github.com/agustadewa/book-system/configs"
 	"github.com/agustadewa/book-system/handlers"
+	"github.com/agustadewa/book-system/repo"
 	"github.com/agustadewa/book-system/utils"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -13,15 +15,119 @@ import (
 func main() {
 	ctx := context.Background()
 
+	if err := configs.LoadPaymentWebhookSecrets(); err != nil {
+		log.Fatalln("can't load payment webhook secrets: ", err.Error())
+	}
+	if err := configs.LoadDownloadSigningSecret(); err != nil {
+		log.Fatalln("can't load download signing secret: ", err.Error())
+	}
+
 	s := gin.Default()
 	s.Use(cors.New(cors.Config{AllowOrigins: []string{"*"}, AllowCredentials: true}))
 
 	mClient := utils.ConnectMongo(ctx)
 
+	if err := repo.NewBook(mClient).EnsureIndexes(ctx); err != nil {
+		log.Fatalln("can't create indexes: ", err.Error())
+	}
+	if err := repo.NewBook(mClient).EnsureVariants(ctx); err != nil {
+		log.Fatalln("can't set up book variants: ", err.Error())
+	}
+	if err := repo.NewBook(mClient).EnsureWorks(ctx); err != nil {
+		log.Fatalln("can't set up book works: ", err.Error())
+	}
+	if err := repo.NewIdempotency(mClient).EnsureIndexes(ctx); err != nil {
+		log.Fatalln("can't create indexes: ", err.Error())
+	}
+	if err := repo.NewShipment(mClient).EnsureIndexes(ctx); err != nil {
+		log.Fatalln("can't create indexes: ", err.Error())
+	}
+	if err := repo.NewCoupon(mClient).EnsureIndexes(ctx); err != nil {
+		log.Fatalln("can't create indexes: ", err.Error())
+	}
+	if err := repo.NewPriceHistory(mClient).EnsureIndexes(ctx); err != nil {
+		log.Fatalln("can't create indexes: ", err.Error())
+	}
+	if err := repo.NewExchangeRate(mClient).EnsureIndexes(ctx); err != nil {
+		log.Fatalln("can't create indexes: ", err.Error())
+	}
+	if err := repo.NewStockMovement(mClient).EnsureIndexes(ctx); err != nil {
+		log.Fatalln("can't create indexes: ", err.Error())
+	}
+	if err := repo.NewStockLevel(mClient).EnsureIndexes(ctx); err != nil {
+		log.Fatalln("can't create indexes: ", err.Error())
+	}
+	if err := repo.NewNotification(mClient).EnsureIndexes(ctx); err != nil {
+		log.Fatalln("can't create indexes: ", err.Error())
+	}
+	if err := repo.NewStockTake(mClient).EnsureIndexes(ctx); err != nil {
+		log.Fatalln("can't create indexes: ", err.Error())
+	}
+	if err := repo.NewStockTakeLine(mClient).EnsureIndexes(ctx); err != nil {
+		log.Fatalln("can't create indexes: ", err.Error())
+	}
+	if err := repo.NewWaitlist(mClient).EnsureIndexes(ctx); err != nil {
+		log.Fatalln("can't create indexes: ", err.Error())
+	}
+	if err := repo.NewLibrary(mClient).EnsureIndexes(ctx); err != nil {
+		log.Fatalln("can't create indexes: ", err.Error())
+	}
+	if err := repo.NewDownload(mClient).EnsureIndexes(ctx); err != nil {
+		log.Fatalln("can't create indexes: ", err.Error())
+	}
+	if err := repo.NewAuthor(mClient).EnsureIndexes(ctx); err != nil {
+		log.Fatalln("can't create indexes: ", err.Error())
+	}
+	if err := repo.NewPublisher(mClient).EnsureIndexes(ctx); err != nil {
+		log.Fatalln("can't create indexes: ", err.Error())
+	}
+	if err := repo.NewCategory(mClient).EnsureIndexes(ctx); err != nil {
+		log.Fatalln("can't create indexes: ", err.Error())
+	}
+	if err := repo.NewSeries(mClient).EnsureIndexes(ctx); err != nil {
+		log.Fatalln("can't create indexes: ", err.Error())
+	}
+	if err := repo.NewImportJob(mClient).EnsureIndexes(ctx); err != nil {
+		log.Fatalln("can't create indexes: ", err.Error())
+	}
+	if err := repo.NewImportRow(mClient).EnsureIndexes(ctx); err != nil {
+		log.Fatalln("can't create indexes: ", err.Error())
+	}
+	if err := repo.NewImportJob(mClient).Requeue(ctx); err != nil {
+		log.Fatalln("can't requeue catalogue imports: ", err.Error())
+	}
+	if err := utils.NewCatalog(mClient).EnsureEntities(ctx); err != nil {
+		log.Fatalln("can't set up catalogue entities: ", err.Error())
+	}
+	if err := utils.NewCoupons(mClient).EnsureEntities(ctx); err != nil {
+		log.Fatalln("can't link coupons to the catalogue: ", err.Error())
+	}
+	if err := utils.NewInventory(mClient).EnsureStockLevels(ctx); err != nil {
+		log.Fatalln("can't set up stock locations: ", err.Error())
+	}
+
 	handlers.NewUser(s, mClient).RegisterEndpoints()
 	handlers.NewBook(s, mClient).RegisterEndpoints()
 	handlers.NewOrder(s, mClient).RegisterEndpoints()
 	handlers.NewPayment(s, mClient).RegisterEndpoints()
+	handlers.NewReturn(s, mClient).RegisterEndpoints()
+	handlers.NewShipment(s, mClient).RegisterEndpoints()
+	handlers.NewShipping(s, mClient).RegisterEndpoints()
+	handlers.NewTax(s, mClient).RegisterEndpoints()
+	handlers.NewCoupon(s, mClient).RegisterEndpoints()
+	handlers.NewCurrency(s, mClient).RegisterEndpoints()
+	handlers.NewStock(s, mClient).RegisterEndpoints()
+	handlers.NewStockTake(s, mClient).RegisterEndpoints()
+	handlers.NewLocation(s, mClient).RegisterEndpoints()
+	handlers.NewNotification(s, mClient).RegisterEndpoints()
+	handlers.NewPurchase(s, mClient).RegisterEndpoints()
+	handlers.NewWaitlist(s, mClient).RegisterEndpoints()
+	handlers.NewLibrary(s, mClient).RegisterEndpoints()
+	handlers.NewAuthor(s, mClient).RegisterEndpoints()
+	handlers.NewPublisher(s, mClient).RegisterEndpoints()
+	handlers.NewCategory(s, mClient).RegisterEndpoints()
+	handlers.NewSeries(s, mClient).RegisterEndpoints()
+	handlers.NewImport(s, mClient).RegisterEndpoints()
 
 	utils.NewCronJob(mClient).DoCronJobTasks(ctx)
 
@@ -0,0 +1,19 @@
+package models
+
+type Address struct {
+	Recipient  string `json:"recipient" bson:"recipient" binding:"required"`
+	Phone      string `json:"phone" bson:"phone" binding:"required"`
+	Street     string `json:"street" bson:"street" binding:"required"`
+	City       string `json:"city" bson:"city" binding:"required"`
+	Province   string `json:"province" bson:"province" binding:"required"`
+	PostalCode string `json:"postal_code" bson:"postal_code" binding:"required"`
+	Country    string `json:"country" bson:"country" binding:"required"`
+
+	// Coordinates are optional, orders with them are fulfilled from the nearest location
+	Coordinates *Coordinates `json:"coordinates,omitempty" bson:"coordinates,omitempty"`
+}
+
+type Coordinates struct {
+	Latitude  float64 `json:"latitude" bson:"latitude"`
+	Longitude float64 `json:"longitude" bson:"longitude"`
+}
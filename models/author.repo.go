@@ -0,0 +1,80 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+type ContributorRole string
+
+var ErrUnknownContributorRole = errors.New("unknown contributor role")
+
+const (
+	AuthorRole      ContributorRole = "AUTHOR"
+	TranslatorRole  ContributorRole = "TRANSLATOR"
+	IllustratorRole ContributorRole = "ILLUSTRATOR"
+	EditorRole      ContributorRole = "EDITOR"
+	NarratorRole    ContributorRole = "NARRATOR"
+)
+
+func IsValidContributorRole(role string) (ContributorRole, error) {
+	switch role {
+	case AuthorRole.String():
+		break
+	case TranslatorRole.String():
+		break
+	case IllustratorRole.String():
+		break
+	case EditorRole.String():
+		break
+	case NarratorRole.String():
+		break
+	default:
+		return "", ErrUnknownContributorRole
+	}
+
+	return ContributorRole(role), nil
+}
+
+func (c ContributorRole) IsAuthor() bool {
+	return c == AuthorRole
+}
+func (c ContributorRole) String() string {
+	return string(c)
+}
+
+// Author is a person contributing to books, NameKey is the name without case, spaces and punctuation so
+// "J.K. Rowling" and "J. K. Rowling" are the same author
+type Author struct {
+	Id        string    `json:"id" bson:"_id"`
+	Name      string    `json:"name" bson:"name"`
+	NameKey   string    `json:"-" bson:"name_key"`
+	Bio       string    `json:"bio,omitempty" bson:"bio,omitempty"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+}
+
+type AddAuthor struct {
+	AdminId string `json:"admin_id" binding:"required"`
+	Name    string `json:"name" binding:"required"`
+	Bio     string `json:"bio"`
+}
+
+// Contributor is an author of a book in a role, Name is the author name as shown on the book
+type Contributor struct {
+	AuthorId string          `json:"author_id" bson:"author_id"`
+	Name     string          `json:"name" bson:"name"`
+	Role     ContributorRole `json:"role" bson:"role"`
+}
+
+// ContributorReq names an author by id, or by name when the author may not be in the catalogue yet
+type ContributorReq struct {
+	AuthorId string `json:"author_id"`
+	Name     string `json:"name"`
+	Role     string `json:"role"`
+}
+
+type UpdateAuthor struct {
+	AdminId string  `json:"admin_id" binding:"required"`
+	Name    *string `json:"name"`
+	Bio     *string `json:"bio"`
+}
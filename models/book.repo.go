@@ -1,8 +1,59 @@
 package models
 
+import (
+	"errors"
+	"time"
+)
+
+type BookFormat string
+
+var ErrUnknownBookFormat = errors.New("unknown book format")
+
+const (
+	Hardcover BookFormat = "HARDCOVER"
+	Paperback BookFormat = "PAPERBACK"
+	Ebook     BookFormat = "EBOOK"
+	Audiobook BookFormat = "AUDIOBOOK"
+)
+
+func IsValidBookFormat(format string) (BookFormat, error) {
+	switch format {
+	case Hardcover.String():
+		break
+	case Paperback.String():
+		break
+	case Ebook.String():
+		break
+	case Audiobook.String():
+		break
+	default:
+		return "", ErrUnknownBookFormat
+	}
+
+	return BookFormat(format), nil
+}
+
+// IsDigital reports whether the format is delivered as a file, digital books have unlimited stock and
+// aren't shipped
+func (f BookFormat) IsDigital() bool {
+	return f == Ebook || f == Audiobook
+}
+func (f BookFormat) String() string {
+	return string(f)
+}
+
+// Book is a variant of a title in one format, with its own SKU, price and stock. The variants of a title
+// share its TitleId, the id of the first variant, and the title details.
 type Book struct {
-	Id          string `json:"id" bson:"_id"`
+	Id      string     `json:"id" bson:"_id"`
+	TitleId string     `json:"title_id" bson:"title_id"`
+	Format  BookFormat `json:"format" bson:"format"`
+	Sku     string     `json:"sku" bson:"sku"`
+	// Isbn13 identifies the book, Isbn10 is the same isbn in its older form, 979 isbns have none
+	Isbn13      string `json:"isbn_13,omitempty" bson:"isbn_13,omitempty"`
+	Isbn10      string `json:"isbn_10,omitempty" bson:"isbn_10,omitempty"`
 	Price       int64  `json:"price" bson:"price"`
+	Currency    string `json:"currency" bson:"currency"`
 	Qty         int64  `json:"qty" bson:"qty"`
 	Name        string `json:"name" bson:"name"`
 	Author      string `json:"author" bson:"author"`
@@ -11,10 +62,158 @@ type Book struct {
 	Language    string `json:"language" bson:"language"`
 	Description string `json:"description" bson:"description" binding:"required"`
 	Image       string `json:"image" bson:"image" binding:"required"`
+
+	// Contributors, PublisherId and CategoryIds link the catalogue, Author, Publisher and Category are the
+	// names shown. CategoryIds is the lineage of the book category, the category itself last.
+	Contributors []Contributor `json:"contributors,omitempty" bson:"contributors,omitempty"`
+	PublisherId  string        `json:"publisher_id,omitempty" bson:"publisher_id,omitempty"`
+	CategoryIds  []string      `json:"category_ids,omitempty" bson:"category_ids,omitempty"`
+
+	// the editions of a work share its WorkId, the title id of its first edition. Edition tells them
+	// apart, e.g. "2nd edition" or "Anniversary edition".
+	WorkId  string       `json:"work_id" bson:"work_id"`
+	Edition string       `json:"edition,omitempty" bson:"edition,omitempty"`
+	Series  *SeriesEntry `json:"series,omitempty" bson:"series,omitempty"`
+
+	WeightGrams int64       `json:"weight_grams" bson:"weight_grams"`
+	Dimensions  *Dimensions `json:"dimensions,omitempty" bson:"dimensions,omitempty"`
+	TaxClass    TaxClass    `json:"tax_class" bson:"tax_class"`
+
+	// ReorderThreshold is the stock at or below which the purchasing team is alerted, 0 turns alerts off
+	ReorderThreshold int64 `json:"reorder_threshold" bson:"reorder_threshold"`
+
+	// a book before its release date takes pre-orders, a released book out of stock takes back-orders when
+	// allowed, both get their books when stock is received
+	ReleaseDate    *time.Time `json:"release_date,omitempty" bson:"release_date,omitempty"`
+	AllowBackorder bool       `json:"allow_backorder" bson:"allow_backorder"`
+
+	// File is what customers download, digital books only
+	File *BookFile `json:"file,omitempty" bson:"file,omitempty"`
+
+	// ScheduledPrice marks the price set by a running price schedule
+	ScheduledPrice *ScheduledPrice `json:"scheduled_price,omitempty" bson:"scheduled_price,omitempty"`
+}
+
+// IsDigital reports whether the book has unlimited stock
+func (b Book) IsDigital() bool {
+	return b.Format.IsDigital()
+}
+
+// IsReleased reports whether the book is out, books without a release date are
+func (b Book) IsReleased(now time.Time) bool {
+	return b.ReleaseDate == nil || !b.ReleaseDate.After(now)
+}
+
+// Availability returns how the book can be ordered now
+func (b Book) Availability(now time.Time) BookAvailability {
+	switch {
+	case !b.IsReleased(now):
+		return Preorder
+	case b.Qty > 0 || b.IsDigital():
+		return InStock
+	case b.AllowBackorder:
+		return Backorder
+	default:
+		return OutOfStock
+	}
+}
+
+type BookAvailability string
+
+const (
+	InStock    BookAvailability = "IN_STOCK"
+	Preorder   BookAvailability = "PREORDER"
+	Backorder  BookAvailability = "BACKORDER"
+	OutOfStock BookAvailability = "OUT_OF_STOCK"
+)
+
+type BookResp struct {
+	Book         `bson:",inline"`
+	PriceDisplay *PriceDisplay    `json:"price_display,omitempty" bson:"-"`
+	Availability BookAvailability `json:"availability,omitempty" bson:"-"`
+	Variants     []BookVariant    `json:"variants,omitempty" bson:"-"`
+	SeriesNav    *SeriesNav       `json:"series_nav,omitempty" bson:"-"`
+	Editions     []Edition        `json:"editions,omitempty" bson:"-"`
+}
+
+// Edition is another edition of the work of a book, with its formats
+type Edition struct {
+	TitleId   string        `json:"title_id"`
+	Name      string        `json:"name"`
+	Edition   string        `json:"edition,omitempty"`
+	Publisher string        `json:"publisher"`
+	Language  string        `json:"language"`
+	Variants  []BookVariant `json:"variants"`
+}
+
+// EditionsOf groups the books of a work by title, leaving out the title given
+func EditionsOf(books []Book, titleId string, now time.Time) []Edition {
+	var editions []Edition
+	index := make(map[string]int)
+	for _, book := range books {
+		if book.TitleId == titleId {
+			continue
+		}
+		i, ok := index[book.TitleId]
+		if !ok {
+			i = len(editions)
+			index[book.TitleId] = i
+			editions = append(editions, Edition{
+				TitleId:   book.TitleId,
+				Name:      book.Name,
+				Edition:   book.Edition,
+				Publisher: book.Publisher,
+				Language:  book.Language,
+			})
+		}
+		editions[i].Variants = append(editions[i].Variants, BookVariantOf(book, now))
+	}
+	return editions
+}
+
+// BookSearchHit is a book found by a search, Editions is how many editions of its work were found when
+// editions are collapsed into one hit
+type BookSearchHit struct {
+	Book     `bson:",inline"`
+	Editions int64 `json:"editions,omitempty" bson:"editions,omitempty"`
+}
+
+// BookVariant is a format of a title as listed with the other formats
+type BookVariant struct {
+	Id           string           `json:"id"`
+	Format       BookFormat       `json:"format"`
+	Sku          string           `json:"sku"`
+	Isbn13       string           `json:"isbn_13,omitempty"`
+	Price        int64            `json:"price"`
+	Currency     string           `json:"currency"`
+	Qty          int64            `json:"qty"`
+	Availability BookAvailability `json:"availability"`
+}
+
+func BookVariantOf(book Book, now time.Time) BookVariant {
+	return BookVariant{
+		Id:           book.Id,
+		Format:       book.Format,
+		Sku:          book.Sku,
+		Isbn13:       book.Isbn13,
+		Price:        book.Price,
+		Currency:     book.Currency,
+		Qty:          book.Qty,
+		Availability: book.Availability(now),
+	}
+}
+
+// Dimensions of a packed book in millimetres
+type Dimensions struct {
+	LengthMm int64 `json:"length_mm" bson:"length_mm" binding:"required"`
+	WidthMm  int64 `json:"width_mm" bson:"width_mm" binding:"required"`
+	HeightMm int64 `json:"height_mm" bson:"height_mm" binding:"required"`
 }
 
 type UpdateBookReq struct {
 	Id          *string `json:"id" binding:"required"`
+	Sku         *string `json:"sku"`
+	Isbn        *string `json:"isbn"`
 	Price       *int64  `json:"price"`
 	Qty         *int64  `json:"qty"`
 	Name        *string `json:"name"`
@@ -24,11 +223,49 @@ type UpdateBookReq struct {
 	Language    *string `json:"language"`
 	Description *string `json:"description"`
 	Image       *string `json:"image"`
+
+	Contributors *[]ContributorReq `json:"contributors"`
+	PublisherId  *string           `json:"publisher_id"`
+	CategoryId   *string           `json:"category_id"`
+	Edition      *string           `json:"edition"`
+
+	WeightGrams *int64      `json:"weight_grams"`
+	Dimensions  *Dimensions `json:"dimensions"`
+	TaxClass    *string     `json:"tax_class"`
+	Currency    *string     `json:"currency"`
+
+	ReorderThreshold *int64     `json:"reorder_threshold"`
+	ReleaseDate      *time.Time `json:"release_date"`
+	AllowBackorder   *bool      `json:"allow_backorder"`
 }
 
+// UpdateBook has no qty, stock only changes through the stock ledger. The title details go to every
+// variant of the title through UpdateTitle.
 type UpdateBook struct {
-	Price       *int64  `bson:"price,omitempty"`
-	Qty         *int64  `bson:"qty,omitempty"`
+	Sku    *string `bson:"sku,omitempty"`
+	Isbn13 *string `bson:"isbn_13,omitempty"`
+	Isbn10 *string `bson:"isbn_10,omitempty"`
+	Price  *int64  `bson:"price,omitempty"`
+
+	WeightGrams *int64      `bson:"weight_grams,omitempty"`
+	Dimensions  *Dimensions `bson:"dimensions,omitempty"`
+	TaxClass    *TaxClass   `bson:"tax_class,omitempty"`
+	Currency    *string     `bson:"currency,omitempty"`
+
+	ReorderThreshold *int64     `bson:"reorder_threshold,omitempty"`
+	ReleaseDate      *time.Time `bson:"release_date,omitempty"`
+	AllowBackorder   *bool      `bson:"allow_backorder,omitempty"`
+}
+
+// IsEmpty reports whether the update changes nothing
+func (u UpdateBook) IsEmpty() bool {
+	return u.Sku == nil && u.Isbn13 == nil && u.Price == nil && u.WeightGrams == nil && u.Dimensions == nil &&
+		u.TaxClass == nil && u.Currency == nil && u.ReorderThreshold == nil && u.ReleaseDate == nil &&
+		u.AllowBackorder == nil
+}
+
+// UpdateTitle is the part of a book shared by every variant of its title
+type UpdateTitle struct {
 	Name        *string `bson:"name,omitempty"`
 	Author      *string `bson:"author,omitempty"`
 	Publisher   *string `bson:"publisher,omitempty"`
@@ -36,28 +273,110 @@ type UpdateBook struct {
 	Language    *string `bson:"language,omitempty"`
 	Description *string `bson:"description,omitempty"`
 	Image       *string `bson:"image,omitempty"`
+
+	Contributors *[]Contributor `bson:"contributors,omitempty"`
+	PublisherId  *string        `bson:"publisher_id,omitempty"`
+	CategoryIds  *[]string      `bson:"category_ids,omitempty"`
+
+	Edition *string `bson:"edition,omitempty"`
+}
+
+// IsEmpty reports whether the update changes nothing
+func (u UpdateTitle) IsEmpty() bool {
+	return u.Name == nil && u.Author == nil && u.Publisher == nil && u.Category == nil && u.Language == nil &&
+		u.Description == nil && u.Image == nil && u.Contributors == nil && u.PublisherId == nil && u.CategoryIds == nil &&
+		u.Edition == nil
 }
 
 type AddBook struct {
-	Price       int64  `bson:"price"`
-	Qty         int64  `bson:"qty"`
-	Name        string `bson:"name"`
-	Author      string `bson:"author"`
-	Publisher   string `bson:"publisher"`
-	Category    string `bson:"category"`
-	Language    string `bson:"language"`
-	Description string `bson:"description"`
-	Image       string `bson:"image"`
+	Format      BookFormat `bson:"format"`
+	Sku         string     `bson:"sku"`
+	Isbn13      string     `bson:"isbn_13"`
+	Isbn10      string     `bson:"isbn_10"`
+	Price       int64      `bson:"price"`
+	Qty         int64      `bson:"qty"`
+	Name        string     `bson:"name"`
+	Author      string     `bson:"author"`
+	Publisher   string     `bson:"publisher"`
+	Category    string     `bson:"category"`
+	Language    string     `bson:"language"`
+	Description string     `bson:"description"`
+	Image       string     `bson:"image"`
+
+	Contributors []Contributor `bson:"contributors"`
+	PublisherId  string        `bson:"publisher_id"`
+	CategoryIds  []string      `bson:"category_ids"`
+
+	WorkId  string       `bson:"work_id"`
+	Edition string       `bson:"edition"`
+	Series  *SeriesEntry `bson:"series"`
+
+	WeightGrams int64       `bson:"weight_grams"`
+	Dimensions  *Dimensions `bson:"dimensions"`
+	TaxClass    TaxClass    `bson:"tax_class"`
+	Currency    string      `bson:"currency"`
+
+	ReorderThreshold int64      `bson:"reorder_threshold"`
+	ReleaseDate      *time.Time `bson:"release_date"`
+	AllowBackorder   bool       `bson:"allow_backorder"`
 }
 
 type AddBookReq struct {
+	Format      *string `json:"format"`
+	Sku         *string `json:"sku"`
+	Isbn        *string `json:"isbn"`
 	Price       *int64  `json:"price" binding:"required"`
 	Qty         *int64  `json:"qty" binding:"required"`
 	Name        *string `json:"name" binding:"required"`
-	Author      *string `json:"author" binding:"required"`
-	Publisher   *string `json:"publisher" binding:"required"`
-	Category    *string `json:"category" binding:"required"`
+	Author      *string `json:"author"`
+	Publisher   *string `json:"publisher"`
+	Category    *string `json:"category"`
 	Language    *string `json:"language" binding:"required"`
 	Description *string `json:"description" binding:"required"`
 	Image       *string `json:"image" binding:"required"`
+
+	// the catalogue entities of the book, they replace the author, publisher and category names. A name
+	// without an entity is matched to the entity of the same name, or one is created.
+	Contributors []ContributorReq `json:"contributors"`
+	PublisherId  *string          `json:"publisher_id"`
+	CategoryId   *string          `json:"category_id"`
+
+	// EditionOf is a book of the work the new book is another edition of
+	EditionOf *string `json:"edition_of"`
+	Edition   *string `json:"edition"`
+
+	WeightGrams *int64      `json:"weight_grams"`
+	Dimensions  *Dimensions `json:"dimensions"`
+	TaxClass    *string     `json:"tax_class"`
+	Currency    *string     `json:"currency"`
+
+	ReorderThreshold *int64     `json:"reorder_threshold"`
+	ReleaseDate      *time.Time `json:"release_date"`
+	AllowBackorder   *bool      `json:"allow_backorder"`
+}
+
+// AddBookVariantReq adds a format to the title of an existing book, the title details are the book's
+type AddBookVariantReq struct {
+	Format *string `json:"format" binding:"required"`
+	Sku    *string `json:"sku"`
+	Isbn   *string `json:"isbn"`
+	Price  *int64  `json:"price" binding:"required"`
+	Qty    *int64  `json:"qty"`
+
+	WeightGrams *int64      `json:"weight_grams"`
+	Dimensions  *Dimensions `json:"dimensions"`
+	TaxClass    *string     `json:"tax_class"`
+	Currency    *string     `json:"currency"`
+
+	ReorderThreshold *int64     `json:"reorder_threshold"`
+	ReleaseDate      *time.Time `json:"release_date"`
+	AllowBackorder   *bool      `json:"allow_backorder"`
+}
+
+// SetEditionReq makes the title of a book an edition of the work of another book, without EditionOf the
+// title becomes a work of its own
+type SetEditionReq struct {
+	AdminId   string  `json:"admin_id" binding:"required"`
+	EditionOf *string `json:"edition_of"`
+	Edition   *string `json:"edition"`
 }
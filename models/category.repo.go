@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// Category is a node of the category tree, Path is the ids of its ancestors from the root. Names are
+// unique among the children of a category.
+type Category struct {
+	Id        string    `json:"id" bson:"_id"`
+	Name      string    `json:"name" bson:"name"`
+	NameKey   string    `json:"-" bson:"name_key"`
+	ParentId  string    `json:"parent_id" bson:"parent_id"`
+	Path      []string  `json:"path" bson:"path"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+}
+
+// Lineage returns the ids of the category and its ancestors from the root, books in the category are
+// browsed by any of them
+func (c Category) Lineage() []string {
+	lineage := make([]string, 0, len(c.Path)+1)
+	lineage = append(lineage, c.Path...)
+	return append(lineage, c.Id)
+}
+
+type CategoryNode struct {
+	Category `bson:",inline"`
+	Children []CategoryNode `json:"children"`
+}
+
+type AddCategory struct {
+	AdminId  string `json:"admin_id" binding:"required"`
+	Name     string `json:"name" binding:"required"`
+	ParentId string `json:"parent_id"`
+}
+
+// UpdateCategory renames or moves a category, an empty parent id moves it to the root
+type UpdateCategory struct {
+	AdminId  string  `json:"admin_id" binding:"required"`
+	Name     *string `json:"name"`
+	ParentId *string `json:"parent_id"`
+}
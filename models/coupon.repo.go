@@ -0,0 +1,95 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+type CouponType string
+
+var ErrUnknownCouponType = errors.New("unknown coupon type")
+
+const (
+	PercentageCoupon CouponType = "PERCENTAGE"
+	FixedCoupon      CouponType = "FIXED"
+)
+
+func IsValidCouponType(couponType string) (CouponType, error) {
+	switch couponType {
+	case PercentageCoupon.String():
+		break
+	case FixedCoupon.String():
+		break
+	default:
+		return "", ErrUnknownCouponType
+	}
+
+	return CouponType(couponType), nil
+}
+
+func (c CouponType) IsPercentage() bool {
+	return c == PercentageCoupon
+}
+func (c CouponType) IsFixed() bool {
+	return c == FixedCoupon
+}
+func (c CouponType) String() string {
+	return string(c)
+}
+
+// Coupon is a discount code. Value is a percentage for percentage coupons and an amount for fixed coupons.
+// Zero limits mean unlimited, and a coupon restricted to books, authors or categories only applies to
+// books matching at least one of them. A category takes in the categories below it.
+type Coupon struct {
+	Id           string     `json:"id" bson:"_id"`
+	Code         string     `json:"code" bson:"code"`
+	Type         CouponType `json:"type" bson:"type"`
+	Value        int64      `json:"value" bson:"value"`
+	MaxDiscount  int64      `json:"max_discount" bson:"max_discount"`
+	MinSpend     int64      `json:"min_spend" bson:"min_spend"`
+	UsageLimit   int64      `json:"usage_limit" bson:"usage_limit"`
+	PerUserLimit int64      `json:"per_user_limit" bson:"per_user_limit"`
+	UsedCount    int64      `json:"used_count" bson:"used_count"`
+	ValidFrom    *time.Time `json:"valid_from,omitempty" bson:"valid_from,omitempty"`
+	ValidUntil   *time.Time `json:"valid_until,omitempty" bson:"valid_until,omitempty"`
+	BookIds      []string   `json:"book_ids" bson:"book_ids"`
+	AuthorIds    []string   `json:"author_ids" bson:"author_ids"`
+	CategoryIds  []string   `json:"category_ids" bson:"category_ids"`
+	Active       bool       `json:"active" bson:"active"`
+	CreatedAt    time.Time  `json:"created_at" bson:"created_at"`
+
+	// Authors and Categories are the names coupons from before the catalogue entities are restricted to,
+	// EnsureEntities links them to their authors and categories
+	Authors    []string `json:"-" bson:"authors,omitempty"`
+	Categories []string `json:"-" bson:"categories,omitempty"`
+}
+
+type AddCoupon struct {
+	AdminId      string     `json:"admin_id" binding:"required"`
+	Code         string     `json:"code" binding:"required"`
+	Type         string     `json:"type" binding:"required"`
+	Value        int64      `json:"value" binding:"required"`
+	MaxDiscount  int64      `json:"max_discount"`
+	MinSpend     int64      `json:"min_spend"`
+	UsageLimit   int64      `json:"usage_limit"`
+	PerUserLimit int64      `json:"per_user_limit"`
+	ValidFrom    *time.Time `json:"valid_from"`
+	ValidUntil   *time.Time `json:"valid_until"`
+	BookIds      []string   `json:"book_ids"`
+	AuthorIds    []string   `json:"author_ids"`
+	CategoryIds  []string   `json:"category_ids"`
+	Active       *bool      `json:"active"`
+}
+
+// CouponRedemption is a coupon used by an order, it is released when the order doesn't go through
+type CouponRedemption struct {
+	Id         string     `json:"id" bson:"_id"`
+	CouponId   string     `json:"coupon_id" bson:"coupon_id"`
+	Code       string     `json:"code" bson:"code"`
+	UserId     string     `json:"user_id" bson:"user_id"`
+	OrderId    string     `json:"order_id" bson:"order_id"`
+	Discount   int64      `json:"discount" bson:"discount"`
+	Released   bool       `json:"released" bson:"released"`
+	CreatedAt  time.Time  `json:"created_at" bson:"created_at"`
+	ReleasedAt *time.Time `json:"released_at,omitempty" bson:"released_at,omitempty"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// Credit is money received from a customer which isn't applied to any order, e.g. an overpayment
+type Credit struct {
+	Id        string    `json:"id" bson:"_id"`
+	UserId    string    `json:"user_id" bson:"user_id"`
+	OrderId   string    `json:"order_id" bson:"order_id"`
+	PaymentId string    `json:"payment_id" bson:"payment_id"`
+	Amount    int64     `json:"amount" bson:"amount"`
+	Currency  string    `json:"currency" bson:"currency"`
+	Reason    string    `json:"reason" bson:"reason"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+}
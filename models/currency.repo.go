@@ -0,0 +1,91 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var ErrUnknownCurrency = errors.New("unknown currency")
+
+// Currency is an ISO 4217 currency, amounts are stored as integers of its minor unit
+type Currency struct {
+	Code       string `json:"code"`
+	Name       string `json:"name"`
+	MinorUnits int    `json:"minor_units"`
+}
+
+// Currencies are the currencies prices can be shown and paid in. Rupiah is priced in whole rupiah,
+// its sen haven't been used for decades, and yen has no minor unit.
+var Currencies = map[string]Currency{
+	"IDR": {Code: "IDR", Name: "Indonesian Rupiah", MinorUnits: 0},
+	"USD": {Code: "USD", Name: "US Dollar", MinorUnits: 2},
+	"EUR": {Code: "EUR", Name: "Euro", MinorUnits: 2},
+	"SGD": {Code: "SGD", Name: "Singapore Dollar", MinorUnits: 2},
+	"MYR": {Code: "MYR", Name: "Malaysian Ringgit", MinorUnits: 2},
+	"JPY": {Code: "JPY", Name: "Japanese Yen", MinorUnits: 0},
+}
+
+func IsValidCurrency(code string) (Currency, error) {
+	currency, ok := Currencies[strings.ToUpper(strings.TrimSpace(code))]
+	if !ok {
+		return Currency{}, ErrUnknownCurrency
+	}
+	return currency, nil
+}
+
+// Money is an amount in minor units of a currency
+type Money struct {
+	Amount   int64  `json:"amount" bson:"amount"`
+	Currency string `json:"currency" bson:"currency"`
+}
+
+// String formats the amount with its decimals, e.g. "USD 12.50"
+func (m Money) String() string {
+	currency, err := IsValidCurrency(m.Currency)
+	if err != nil || currency.MinorUnits == 0 {
+		return fmt.Sprintf("%s %d", m.Currency, m.Amount)
+	}
+
+	scale := int64(1)
+	for i := 0; i < currency.MinorUnits; i++ {
+		scale *= 10
+	}
+	sign, amount := "", m.Amount
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	return fmt.Sprintf("%s %s%d.%0*d", m.Currency, sign, amount/scale, currency.MinorUnits, amount%scale)
+}
+
+// ExchangeRate is the price of one Base unit in Quote units from EffectiveFrom until a newer rate takes
+// over. Rate is a decimal string so it isn't rounded by floats, e.g. "15675.50".
+type ExchangeRate struct {
+	Id            string    `json:"id" bson:"_id"`
+	Base          string    `json:"base" bson:"base"`
+	Quote         string    `json:"quote" bson:"quote"`
+	Rate          string    `json:"rate" bson:"rate"`
+	EffectiveFrom time.Time `json:"effective_from" bson:"effective_from"`
+	CreatedBy     string    `json:"created_by" bson:"created_by"`
+	CreatedAt     time.Time `json:"created_at" bson:"created_at"`
+}
+
+type AddExchangeRate struct {
+	AdminId       string     `json:"admin_id" binding:"required"`
+	Base          string     `json:"base" binding:"required"`
+	Quote         string     `json:"quote" binding:"required"`
+	Rate          string     `json:"rate" binding:"required"`
+	EffectiveFrom *time.Time `json:"effective_from"`
+}
+
+// LockedRate is the exchange rate an order was priced with
+type LockedRate struct {
+	RateId        string    `json:"rate_id" bson:"rate_id"`
+	Base          string    `json:"base" bson:"base"`
+	Quote         string    `json:"quote" bson:"quote"`
+	Rate          string    `json:"rate" bson:"rate"`
+	Inverted      bool      `json:"inverted" bson:"inverted"`
+	EffectiveFrom time.Time `json:"effective_from" bson:"effective_from"`
+	LockedAt      time.Time `json:"locked_at" bson:"locked_at"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+type IdempotencyKey struct {
+	Id          string    `json:"id" bson:"_id"`
+	Scope       string    `json:"scope" bson:"scope"`
+	Caller      string    `json:"caller" bson:"caller"`
+	Key         string    `json:"key" bson:"key"`
+	RequestHash string    `json:"request_hash" bson:"request_hash"`
+	Completed   bool      `json:"completed" bson:"completed"`
+	StatusCode  int       `json:"status_code" bson:"status_code"`
+	Response    []byte    `json:"response" bson:"response"`
+	CreatedAt   time.Time `json:"created_at" bson:"created_at"`
+	LockedUntil time.Time `json:"locked_until" bson:"locked_until"`
+	ExpiresAt   time.Time `json:"expires_at" bson:"expires_at"`
+}
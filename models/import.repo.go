@@ -0,0 +1,99 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+type ImportFormat string
+
+var ErrUnknownImportFormat = errors.New("unknown import format")
+
+const (
+	CsvImport  ImportFormat = "CSV"
+	OnixImport ImportFormat = "ONIX"
+)
+
+func IsValidImportFormat(format string) (ImportFormat, error) {
+	switch format {
+	case CsvImport.String():
+		break
+	case OnixImport.String():
+		break
+	default:
+		return "", ErrUnknownImportFormat
+	}
+
+	return ImportFormat(format), nil
+}
+
+func (f ImportFormat) String() string {
+	return string(f)
+}
+
+type ImportJobStatus string
+
+const (
+	ImportQueued  ImportJobStatus = "QUEUED"
+	ImportRunning ImportJobStatus = "RUNNING"
+	ImportDone    ImportJobStatus = "DONE"
+	ImportFailed  ImportJobStatus = "FAILED"
+)
+
+func (s ImportJobStatus) String() string {
+	return string(s)
+}
+
+// ImportJob is a catalogue file being imported in the background, the counts grow as its rows are
+// processed. Error is why a job failed as a whole, e.g. a file which can't be read.
+type ImportJob struct {
+	Id         string          `json:"id" bson:"_id"`
+	AdminId    string          `json:"admin_id" bson:"admin_id"`
+	Format     ImportFormat    `json:"format" bson:"format"`
+	FileName   string          `json:"file_name" bson:"file_name"`
+	FileKey    string          `json:"-" bson:"file_key"`
+	Status     ImportJobStatus `json:"status" bson:"status"`
+	Rows       int64           `json:"rows" bson:"rows"`
+	Created    int64           `json:"created" bson:"created"`
+	Updated    int64           `json:"updated" bson:"updated"`
+	Skipped    int64           `json:"skipped" bson:"skipped"`
+	Errors     int64           `json:"errors" bson:"errors"`
+	Error      string          `json:"error,omitempty" bson:"error,omitempty"`
+	CreatedAt  time.Time       `json:"created_at" bson:"created_at"`
+	StartedAt  *time.Time      `json:"started_at,omitempty" bson:"started_at,omitempty"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty" bson:"finished_at,omitempty"`
+}
+
+type ImportRowStatus string
+
+const (
+	ImportRowCreated ImportRowStatus = "CREATED"
+	ImportRowUpdated ImportRowStatus = "UPDATED"
+	ImportRowSkipped ImportRowStatus = "SKIPPED"
+	ImportRowError   ImportRowStatus = "ERROR"
+)
+
+func (s ImportRowStatus) String() string {
+	return string(s)
+}
+
+// ImportRow is the result of a row of an import, Row counts from 1 for the first book of the file
+type ImportRow struct {
+	Id     string          `json:"id" bson:"_id"`
+	JobId  string          `json:"job_id" bson:"job_id"`
+	Row    int64           `json:"row" bson:"row"`
+	Isbn   string          `json:"isbn" bson:"isbn"`
+	Name   string          `json:"name" bson:"name"`
+	Status ImportRowStatus `json:"status" bson:"status"`
+	BookId string          `json:"book_id,omitempty" bson:"book_id,omitempty"`
+	Reason string          `json:"reason,omitempty" bson:"reason,omitempty"`
+}
+
+// ImportRecord is a book read from an import file, Err is set when the row can't be read and Skip is why
+// a row isn't imported
+type ImportRecord struct {
+	Row  int64
+	Book AddBookReq
+	Err  error
+	Skip string
+}
@@ -0,0 +1,63 @@
+package models
+
+import "time"
+
+// BookFile is the file of a digital book, Key is where the file store keeps it
+type BookFile struct {
+	Key         string    `json:"-" bson:"key"`
+	FileName    string    `json:"file_name" bson:"file_name"`
+	ContentType string    `json:"content_type" bson:"content_type"`
+	Size        int64     `json:"size" bson:"size"`
+	UploadedAt  time.Time `json:"uploaded_at" bson:"uploaded_at"`
+}
+
+// LibraryEntry is a digital book a customer bought, every paid digital order adds one
+type LibraryEntry struct {
+	Id             string     `json:"id" bson:"_id"`
+	UserId         string     `json:"user_id" bson:"user_id"`
+	BookId         string     `json:"book_id" bson:"book_id"`
+	OrderId        string     `json:"order_id" bson:"order_id"`
+	Name           string     `json:"name" bson:"name"`
+	Format         BookFormat `json:"format" bson:"format"`
+	DownloadLimit  int64      `json:"download_limit" bson:"download_limit"`
+	Downloads      int64      `json:"downloads" bson:"downloads"`
+	CreatedAt      time.Time  `json:"created_at" bson:"created_at"`
+	LastDownloadAt *time.Time `json:"last_download_at,omitempty" bson:"last_download_at,omitempty"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty" bson:"revoked_at,omitempty"`
+}
+
+// IsRevoked tells whether the order of the entry was refunded, a revoked entry can't be downloaded
+func (e LibraryEntry) IsRevoked() bool {
+	return e.RevokedAt != nil
+}
+
+// DownloadsLeft returns how many more times the book can be downloaded
+func (e LibraryEntry) DownloadsLeft() int64 {
+	if left := e.DownloadLimit - e.Downloads; left > 0 {
+		return left
+	}
+	return 0
+}
+
+// DownloadLink is a signed link to the file of a library entry, it works until ExpiresAt
+type DownloadLink struct {
+	EntryId       string    `json:"entry_id"`
+	Url           string    `json:"url"`
+	ExpiresAt     time.Time `json:"expires_at"`
+	DownloadsLeft int64     `json:"downloads_left"`
+}
+
+// Download is a log entry of a library entry file served to a customer
+type Download struct {
+	Id        string    `json:"id" bson:"_id"`
+	EntryId   string    `json:"entry_id" bson:"entry_id"`
+	UserId    string    `json:"user_id" bson:"user_id"`
+	BookId    string    `json:"book_id" bson:"book_id"`
+	Ip        string    `json:"ip" bson:"ip"`
+	UserAgent string    `json:"user_agent,omitempty" bson:"user_agent,omitempty"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+}
+
+type RequestDownloadLink struct {
+	UserId string `json:"user_id" binding:"required"`
+}
@@ -0,0 +1,111 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+type LocationType string
+
+var ErrUnknownLocationType = errors.New("unknown location type")
+
+const (
+	WarehouseLocation LocationType = "WAREHOUSE"
+	ShopLocation      LocationType = "SHOP"
+)
+
+func IsValidLocationType(locationType string) (LocationType, error) {
+	switch locationType {
+	case WarehouseLocation.String():
+		break
+	case ShopLocation.String():
+		break
+	default:
+		return "", ErrUnknownLocationType
+	}
+
+	return LocationType(locationType), nil
+}
+
+func (l LocationType) String() string {
+	return string(l)
+}
+
+type AllocationStrategy string
+
+var ErrUnknownAllocationStrategy = errors.New("unknown allocation strategy")
+
+const (
+	// PriorityAllocation takes stock from the sellable location with the lowest priority number first
+	PriorityAllocation AllocationStrategy = "PRIORITY"
+	// NearestAllocation takes stock from the sellable location nearest to the shipping address
+	NearestAllocation AllocationStrategy = "NEAREST"
+)
+
+func IsValidAllocationStrategy(strategy string) (AllocationStrategy, error) {
+	switch strategy {
+	case PriorityAllocation.String():
+		break
+	case NearestAllocation.String():
+		break
+	default:
+		return "", ErrUnknownAllocationStrategy
+	}
+
+	return AllocationStrategy(strategy), nil
+}
+
+func (a AllocationStrategy) String() string {
+	return string(a)
+}
+
+// Location is a place holding stock, only stock at sellable locations is available to orders
+type Location struct {
+	Id          string       `json:"id" bson:"_id"`
+	Name        string       `json:"name" bson:"name"`
+	Type        LocationType `json:"type" bson:"type"`
+	Sellable    bool         `json:"sellable" bson:"sellable"`
+	Priority    int64        `json:"priority" bson:"priority"`
+	Coordinates *Coordinates `json:"coordinates,omitempty" bson:"coordinates,omitempty"`
+	CreatedAt   time.Time    `json:"created_at" bson:"created_at"`
+}
+
+type AddLocation struct {
+	AdminId     string       `json:"admin_id" binding:"required"`
+	Id          string       `json:"id"`
+	Name        string       `json:"name" binding:"required"`
+	Type        string       `json:"type" binding:"required"`
+	Sellable    bool         `json:"sellable"`
+	Priority    int64        `json:"priority"`
+	Coordinates *Coordinates `json:"coordinates"`
+}
+
+// StockLevel is the stock of a book at a location
+type StockLevel struct {
+	Id         string    `json:"id" bson:"_id"`
+	BookId     string    `json:"book_id" bson:"book_id"`
+	LocationId string    `json:"location_id" bson:"location_id"`
+	Qty        int64     `json:"qty" bson:"qty"`
+	UpdatedAt  time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+// StockTransfer moves stock of a book from a location to another
+type StockTransfer struct {
+	Id             string    `json:"id" bson:"_id"`
+	BookId         string    `json:"book_id" bson:"book_id"`
+	FromLocationId string    `json:"from_location_id" bson:"from_location_id"`
+	ToLocationId   string    `json:"to_location_id" bson:"to_location_id"`
+	Qty            int64     `json:"qty" bson:"qty"`
+	AdminId        string    `json:"admin_id" bson:"admin_id"`
+	Note           string    `json:"note,omitempty" bson:"note,omitempty"`
+	CreatedAt      time.Time `json:"created_at" bson:"created_at"`
+}
+
+type AddStockTransfer struct {
+	AdminId        string `json:"admin_id" binding:"required"`
+	BookId         string `json:"book_id" binding:"required"`
+	FromLocationId string `json:"from_location_id" binding:"required"`
+	ToLocationId   string `json:"to_location_id" binding:"required"`
+	Qty            int64  `json:"qty" binding:"required"`
+	Note           string `json:"note"`
+}
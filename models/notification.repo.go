@@ -0,0 +1,76 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+type NotificationType string
+
+var ErrUnknownNotificationType = errors.New("unknown notification type")
+
+const (
+	LowStockNotification    NotificationType = "LOW_STOCK"
+	BackInStockNotification NotificationType = "BACK_IN_STOCK"
+	OrderReadyNotification  NotificationType = "ORDER_READY"
+)
+
+func IsValidNotificationType(notificationType string) (NotificationType, error) {
+	switch notificationType {
+	case LowStockNotification.String():
+		break
+	case BackInStockNotification.String():
+		break
+	case OrderReadyNotification.String():
+		break
+	default:
+		return "", ErrUnknownNotificationType
+	}
+
+	return NotificationType(notificationType), nil
+}
+
+func (n NotificationType) String() string {
+	return string(n)
+}
+
+// Notification is a message to a team or to a customer. Key identifies what it is about, there is one open notification
+// per key until it is resolved, e.g. until the book is restocked.
+type Notification struct {
+	Id         string           `json:"id" bson:"_id"`
+	Type       NotificationType `json:"type" bson:"type"`
+	Team       string           `json:"team,omitempty" bson:"team,omitempty"`
+	UserId     string           `json:"user_id,omitempty" bson:"user_id,omitempty"`
+	Key        string           `json:"key" bson:"key"`
+	BookId     string           `json:"book_id,omitempty" bson:"book_id,omitempty"`
+	Message    string           `json:"message" bson:"message"`
+	Resolved   bool             `json:"resolved" bson:"resolved"`
+	ReadBy     []string         `json:"read_by" bson:"read_by"`
+	CreatedAt  time.Time        `json:"created_at" bson:"created_at"`
+	ResolvedAt *time.Time       `json:"resolved_at,omitempty" bson:"resolved_at,omitempty"`
+}
+
+type ReadNotification struct {
+	AdminId string `json:"admin_id" binding:"required"`
+}
+
+// ReorderSuggestion is how many copies of a book to order so its stock covers the coming sales, copies on
+// open purchase orders count as stock
+type ReorderSuggestion struct {
+	BookId           string   `json:"book_id" bson:"book_id"`
+	Name             string   `json:"name" bson:"name"`
+	Qty              int64    `json:"qty" bson:"qty"`
+	OnOrderQty       int64    `json:"on_order_qty" bson:"on_order_qty"`
+	ReorderThreshold int64    `json:"reorder_threshold" bson:"reorder_threshold"`
+	UnitsSold        int64    `json:"units_sold" bson:"units_sold"`
+	DailySales       float64  `json:"daily_sales" bson:"daily_sales"`
+	DaysOfStock      *float64 `json:"days_of_stock" bson:"days_of_stock"`
+	SuggestedQty     int64    `json:"suggested_qty" bson:"suggested_qty"`
+}
+
+// BookSales is how many copies of a book were sold
+type BookSales struct {
+	BookId string `bson:"_id"`
+	Qty    int64  `bson:"qty"`
+	Orders int64  `bson:"orders"`
+}
@@ -1,6 +1,9 @@
 package models
 
-import "errors"
+import (
+	"errors"
+	"time"
+)
 
 type OrderStatus string
 
@@ -12,6 +15,11 @@ const (
 	WaitingForPayment OrderStatus = "WAITING_FOR_PAYMENT"
 	Declined          OrderStatus = "DECLINED"
 	OnShipping        OrderStatus = "ON_SHIPPING"
+	Delivered         OrderStatus = "DELIVERED"
+	Refunded          OrderStatus = "REFUNDED"
+	PartiallyRefunded OrderStatus = "PARTIALLY_REFUNDED"
+	Preordered        OrderStatus = "PREORDERED"
+	Backordered       OrderStatus = "BACKORDERED"
 )
 
 func IsValidOrderStatus(status string) (OrderStatus, error) {
@@ -26,6 +34,16 @@ func IsValidOrderStatus(status string) (OrderStatus, error) {
 		break
 	case OnShipping.String():
 		break
+	case Delivered.String():
+		break
+	case Refunded.String():
+		break
+	case PartiallyRefunded.String():
+		break
+	case Preordered.String():
+		break
+	case Backordered.String():
+		break
 	default:
 		return "", ErrUnknownOrderStatus
 	}
@@ -48,24 +66,152 @@ func (o OrderStatus) IsDeclined() bool {
 func (o OrderStatus) IsOnShipping() bool {
 	return o == OnShipping
 }
+func (o OrderStatus) IsDelivered() bool {
+	return o == Delivered
+}
+func (o OrderStatus) IsRefunded() bool {
+	return o == Refunded
+}
+func (o OrderStatus) IsPartiallyRefunded() bool {
+	return o == PartiallyRefunded
+}
+func (o OrderStatus) IsPreordered() bool {
+	return o == Preordered
+}
+func (o OrderStatus) IsBackordered() bool {
+	return o == Backordered
+}
+
+// AwaitsStock reports whether the order was accepted without stock and waits for books to be allocated
+func (o OrderStatus) AwaitsStock() bool {
+	return o == Preordered || o == Backordered
+}
+
+// AcceptsPayment reports whether payments can still be made for the order, orders waiting for stock can be
+// paid in advance
+func (o OrderStatus) AcceptsPayment() bool {
+	return o == WaitingForPayment || o.AwaitsStock()
+}
+
+// ReservesStock reports whether the books of the order are still on hand and go back to stock when the
+// order is called off
+func (o OrderStatus) ReservesStock() bool {
+	return o == WaitingForPayment || o == Paid
+}
+
+// IsCalledOff reports whether the order was ended before it was shipped, cancelled or declined
+func (o OrderStatus) IsCalledOff() bool {
+	return o == Cancelled || o == Declined
+}
+
+// CanBeCalledOff reports whether the order can still be cancelled or declined, it isn't shipped yet
+func (o OrderStatus) CanBeCalledOff() bool {
+	return o.AcceptsPayment() || o == Paid
+}
+
+// orderTransitions are the statuses an order can be moved to from each status, called off and refunded
+// orders are final
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	WaitingForPayment: {Paid, Cancelled, Declined},
+	Preordered:        {Cancelled, Declined},
+	Backordered:       {Cancelled, Declined},
+	Paid:              {OnShipping, Delivered, Cancelled},
+	OnShipping:        {Delivered},
+	Delivered:         {PartiallyRefunded, Refunded},
+	PartiallyRefunded: {Refunded},
+}
+
+// CanMoveTo reports whether the order can go from the status to another one
+func (o OrderStatus) CanMoveTo(to OrderStatus) bool {
+	for _, status := range orderTransitions[o] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
 func (o OrderStatus) String() string {
 	return string(o)
 }
 
 type Order struct {
-	Id         string      `json:"id" bson:"_id"`
-	UserId     string      `json:"user_id" bson:"user_id"`
-	BookId     string      `json:"book_id" bson:"book_id"`
-	OrderTime  string      `json:"order_time" bson:"order_time"`
-	Status     OrderStatus `json:"status" bson:"status"`
-	Qty        int64       `json:"qty" bson:"qty"`
-	TotalPrice int64       `json:"total_price" bson:"total_price"`
+	Id        string      `json:"id" bson:"_id"`
+	UserId    string      `json:"user_id" bson:"user_id"`
+	BookId    string      `json:"book_id" bson:"book_id"`
+	Format    BookFormat  `json:"format,omitempty" bson:"format,omitempty"`
+	Sku       string      `json:"sku,omitempty" bson:"sku,omitempty"`
+	OrderTime string      `json:"order_time" bson:"order_time"`
+	Status    OrderStatus `json:"status" bson:"status"`
+	Qty       int64       `json:"qty" bson:"qty"`
+
+	// LocationId is where the books were taken from, they go back there when the order is called off.
+	// Orders from before locations have none and use the default location. Pre-orders and back-orders get
+	// their books when stock arrives, at AllocatedAt.
+	LocationId  string     `json:"location_id,omitempty" bson:"location_id,omitempty"`
+	AllocatedAt *time.Time `json:"allocated_at,omitempty" bson:"allocated_at,omitempty"`
+
+	// amounts are in Currency, an order in another currency than the store's keeps the rate it was priced
+	// with and its grand total in the store currency
+	Currency       string      `json:"currency" bson:"currency"`
+	ExchangeRate   *LockedRate `json:"exchange_rate,omitempty" bson:"exchange_rate,omitempty"`
+	BaseTotalPrice int64       `json:"base_total_price" bson:"base_total_price"`
+
+	// TotalPrice is the grand total, the item subtotal less the discount plus shipping and tax which isn't
+	// included in the prices
+	UnitPrice   int64     `json:"unit_price" bson:"unit_price"`
+	Subtotal    int64     `json:"subtotal" bson:"subtotal"`
+	CouponCode  string    `json:"coupon_code,omitempty" bson:"coupon_code,omitempty"`
+	Discount    int64     `json:"discount" bson:"discount"`
+	ShippingFee int64     `json:"shipping_fee" bson:"shipping_fee"`
+	TaxTotal    int64     `json:"tax_total" bson:"tax_total"`
+	TaxLines    []TaxLine `json:"tax_lines" bson:"tax_lines"`
+	TotalPrice  int64     `json:"total_price" bson:"total_price"`
+
+	PaidAmount     int64 `json:"paid_amount" bson:"paid_amount"`
+	RefundedAmount int64 `json:"refunded_amount" bson:"refunded_amount"`
+
+	// PaymentIds are the payments added to PaidAmount and RefundIds the refunds added to RefundedAmount,
+	// each is added once
+	PaymentIds []string `json:"-" bson:"payment_ids,omitempty"`
+	RefundIds  []string `json:"-" bson:"refund_ids,omitempty"`
+
+	ShippingAddress *Address `json:"shipping_address,omitempty" bson:"shipping_address,omitempty"`
+}
+
+// TakesStock reports whether the order takes books from stock, digital books have unlimited stock
+func (o Order) TakesStock() bool {
+	return !o.Format.IsDigital()
+}
+
+// ItemsTotal returns what the customer paid for the books, the discounted subtotal and the tax added to it,
+// shipping left out. A return refunds its share of it.
+func (o Order) ItemsTotal() int64 {
+	if o.Subtotal == 0 {
+		return o.TotalPrice - o.ShippingFee
+	}
+	total := o.Subtotal - o.Discount
+	for _, line := range o.TaxLines {
+		if !line.Inclusive {
+			total += line.Amount
+		}
+	}
+	return total
 }
 
 type AddOrder struct {
 	UserId string `json:"user_id" bson:"user_id" binding:"required"`
 	BookId string `json:"book_id" bson:"book_id" binding:"required"`
 	Qty    int64  `json:"qty" bson:"qty" binding:"required"`
+
+	// Format orders another format of the title of the book, empty is the book itself
+	Format string `json:"format" bson:"format"`
+
+	ShippingAddress *Address `json:"shipping_address" bson:"shipping_address"`
+	CouponCode      string   `json:"coupon_code" bson:"coupon_code"`
+	Currency        string   `json:"currency" bson:"currency"`
+
+	// Allocation is the strategy choosing the location the books are taken from, PRIORITY or NEAREST
+	Allocation string `json:"allocation" bson:"allocation"`
 }
 
 type UpdateStatusOrder struct {
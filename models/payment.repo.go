@@ -1,14 +1,114 @@
 package models
 
+import (
+	"errors"
+	"time"
+)
+
+type PaymentStatus string
+
+var ErrUnknownPaymentStatus = errors.New("unknown payment status")
+
+const (
+	PaymentPending       PaymentStatus = "PENDING"
+	PaymentPendingReview PaymentStatus = "PENDING_REVIEW"
+	PaymentSucceeded     PaymentStatus = "SUCCEEDED"
+	PaymentFailed        PaymentStatus = "FAILED"
+	PaymentRejected      PaymentStatus = "REJECTED"
+)
+
+func IsValidPaymentStatus(status string) (PaymentStatus, error) {
+	switch status {
+	case PaymentPending.String():
+		break
+	case PaymentPendingReview.String():
+		break
+	case PaymentSucceeded.String():
+		break
+	case PaymentFailed.String():
+		break
+	case PaymentRejected.String():
+		break
+	default:
+		return "", ErrUnknownPaymentStatus
+	}
+
+	return PaymentStatus(status), nil
+}
+
+func (p PaymentStatus) IsPending() bool {
+	return p == PaymentPending
+}
+func (p PaymentStatus) IsPendingReview() bool {
+	return p == PaymentPendingReview
+}
+func (p PaymentStatus) IsSucceeded() bool {
+	return p == PaymentSucceeded
+}
+func (p PaymentStatus) IsFailed() bool {
+	return p == PaymentFailed
+}
+func (p PaymentStatus) IsRejected() bool {
+	return p == PaymentRejected
+}
+
+// IsSettled reports whether the provider has made its final decision on the payment
+func (p PaymentStatus) IsSettled() bool {
+	return p.IsSucceeded() || p.IsFailed() || p.IsRejected()
+}
+func (p PaymentStatus) String() string {
+	return string(p)
+}
+
 type Payment struct {
-	Id      string `json:"id" bson:"_id"`
-	UserId  string `json:"user_id" bson:"user_id"`
-	OrderId string `json:"order_id" bson:"order_id"`
-	Receipt string `json:"receipt" bson:"receipt"`
+	Id          string         `json:"id" bson:"_id"`
+	UserId      string         `json:"user_id" bson:"user_id"`
+	OrderId     string         `json:"order_id" bson:"order_id"`
+	Provider    string         `json:"provider" bson:"provider"`
+	ProviderRef string         `json:"provider_ref" bson:"provider_ref"`
+	Amount      int64          `json:"amount" bson:"amount"`
+	Applied     int64          `json:"applied" bson:"applied"`
+	Credited    int64          `json:"credited" bson:"credited"`
+	Refunded    int64          `json:"refunded" bson:"refunded"`
+	Currency    string         `json:"currency" bson:"currency"`
+	Status      PaymentStatus  `json:"status" bson:"status"`
+	Receipt     *Receipt       `json:"receipt,omitempty" bson:"receipt,omitempty"`
+	Review      *PaymentReview `json:"review,omitempty" bson:"review,omitempty"`
+	CreatedAt   string         `json:"created_at" bson:"created_at"`
+
+	// RefundIds are the refunds added to Refunded, a refund is added once
+	RefundIds []string `json:"-" bson:"refund_ids,omitempty"`
+}
+
+// Receipt is the bank transfer proof uploaded by the customer
+type Receipt struct {
+	FileName    string    `json:"file_name" bson:"file_name"`
+	ContentType string    `json:"content_type" bson:"content_type"`
+	Size        int64     `json:"size" bson:"size"`
+	Path        string    `json:"-" bson:"path"`
+	UploadedAt  time.Time `json:"uploaded_at" bson:"uploaded_at"`
+}
+
+type PaymentReview struct {
+	AdminId    string    `json:"admin_id" bson:"admin_id"`
+	Approved   bool      `json:"approved" bson:"approved"`
+	Reason     string    `json:"reason,omitempty" bson:"reason,omitempty"`
+	ReviewedAt time.Time `json:"reviewed_at" bson:"reviewed_at"`
 }
 
 type AddPayment struct {
-	UserId  string `json:"user_id" bson:"user_id" binding:"required"`
-	OrderId string `json:"order_id" bson:"order_id" binding:"required"`
-	Receipt string `json:"receipt" bson:"receipt" binding:"required"`
+	UserId     string `json:"user_id" bson:"user_id" binding:"required"`
+	OrderId    string `json:"order_id" bson:"order_id" binding:"required"`
+	Amount     int64  `json:"amount" bson:"amount" binding:"required"`
+	Provider   string `json:"provider" bson:"provider"`
+	CardNumber string `json:"card_number" bson:"-"`
+}
+
+type ApprovePayment struct {
+	AdminId string `json:"admin_id" binding:"required"`
+}
+
+type RejectPayment struct {
+	AdminId string `json:"admin_id" binding:"required"`
+	Reason  string `json:"reason" binding:"required"`
 }
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+type PaymentWebhookEventType string
+
+var ErrUnknownPaymentWebhookEventType = errors.New("unknown payment webhook event type")
+
+const (
+	PaymentSucceededEvent PaymentWebhookEventType = "payment.succeeded"
+	PaymentFailedEvent    PaymentWebhookEventType = "payment.failed"
+)
+
+func IsValidPaymentWebhookEventType(eventType string) (PaymentWebhookEventType, error) {
+	switch eventType {
+	case PaymentSucceededEvent.String():
+		break
+	case PaymentFailedEvent.String():
+		break
+	default:
+		return "", ErrUnknownPaymentWebhookEventType
+	}
+
+	return PaymentWebhookEventType(eventType), nil
+}
+
+// PaymentStatus returns the payment status confirmed by the event
+func (e PaymentWebhookEventType) PaymentStatus() PaymentStatus {
+	switch e {
+	case PaymentSucceededEvent:
+		return PaymentSucceeded
+	case PaymentFailedEvent:
+		return PaymentFailed
+	}
+	return PaymentPending
+}
+func (e PaymentWebhookEventType) String() string {
+	return string(e)
+}
+
+type PaymentWebhookEvent struct {
+	Id         string                  `json:"id" bson:"_id"`
+	Provider   string                  `json:"provider" bson:"provider"`
+	EventId    string                  `json:"event_id" bson:"event_id"`
+	Type       PaymentWebhookEventType `json:"type" bson:"type"`
+	Reference  string                  `json:"reference" bson:"reference"`
+	PaymentId  string                  `json:"payment_id" bson:"payment_id"`
+	ReceivedAt time.Time               `json:"received_at" bson:"received_at"`
+}
+
+type PaymentWebhookReq struct {
+	Id   string `json:"id" binding:"required"`
+	Type string `json:"type" binding:"required"`
+	Data struct {
+		Reference string `json:"reference" binding:"required"`
+	} `json:"data" binding:"required"`
+}
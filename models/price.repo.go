@@ -0,0 +1,105 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+type PriceScheduleStatus string
+
+var ErrUnknownPriceScheduleStatus = errors.New("unknown price schedule status")
+
+const (
+	PriceScheduled         PriceScheduleStatus = "SCHEDULED"
+	PriceScheduleActive    PriceScheduleStatus = "ACTIVE"
+	PriceScheduleEnded     PriceScheduleStatus = "ENDED"
+	PriceScheduleCancelled PriceScheduleStatus = "CANCELLED"
+)
+
+func IsValidPriceScheduleStatus(status string) (PriceScheduleStatus, error) {
+	switch status {
+	case PriceScheduled.String():
+		break
+	case PriceScheduleActive.String():
+		break
+	case PriceScheduleEnded.String():
+		break
+	case PriceScheduleCancelled.String():
+		break
+	default:
+		return "", ErrUnknownPriceScheduleStatus
+	}
+
+	return PriceScheduleStatus(status), nil
+}
+
+func (p PriceScheduleStatus) IsScheduled() bool {
+	return p == PriceScheduled
+}
+func (p PriceScheduleStatus) IsActive() bool {
+	return p == PriceScheduleActive
+}
+func (p PriceScheduleStatus) String() string {
+	return string(p)
+}
+
+// PriceSchedule sets a book price from StartsAt, when EndsAt is set the price before the schedule
+// is put back at that time
+type PriceSchedule struct {
+	Id            string              `json:"id" bson:"_id"`
+	BookId        string              `json:"book_id" bson:"book_id"`
+	Price         int64               `json:"price" bson:"price"`
+	PreviousPrice int64               `json:"previous_price" bson:"previous_price"`
+	StartsAt      time.Time           `json:"starts_at" bson:"starts_at"`
+	EndsAt        *time.Time          `json:"ends_at,omitempty" bson:"ends_at,omitempty"`
+	Status        PriceScheduleStatus `json:"status" bson:"status"`
+	CreatedBy     string              `json:"created_by" bson:"created_by"`
+	CreatedAt     time.Time           `json:"created_at" bson:"created_at"`
+	ActivatedAt   *time.Time          `json:"activated_at,omitempty" bson:"activated_at,omitempty"`
+	EndedAt       *time.Time          `json:"ended_at,omitempty" bson:"ended_at,omitempty"`
+}
+
+// ScheduledPrice is the price schedule which set a book price and the price it replaced, it is written with
+// the price so a start cut short can be finished without losing the replaced price
+type ScheduledPrice struct {
+	ScheduleId    string `json:"schedule_id" bson:"schedule_id"`
+	PreviousPrice int64  `json:"previous_price" bson:"previous_price"`
+}
+
+type AddPriceSchedule struct {
+	AdminId  string     `json:"admin_id" binding:"required"`
+	Price    int64      `json:"price" binding:"required"`
+	StartsAt time.Time  `json:"starts_at" binding:"required"`
+	EndsAt   *time.Time `json:"ends_at"`
+}
+
+type PriceChangeReason string
+
+const (
+	PriceInitial       PriceChangeReason = "INITIAL"
+	PriceManual        PriceChangeReason = "MANUAL"
+	PriceScheduleStart PriceChangeReason = "SCHEDULE_START"
+	PriceScheduleEnd   PriceChangeReason = "SCHEDULE_END"
+	PriceImport        PriceChangeReason = "IMPORT"
+
+	// PriceCurrencyChange is a price converted to another currency, its previous price is in the old currency
+	PriceCurrencyChange PriceChangeReason = "CURRENCY_CHANGE"
+)
+
+func (p PriceChangeReason) String() string {
+	return string(p)
+}
+
+// PriceHistory is a price a book had from ChangedAt until the next entry. Entries without a currency are in
+// the book currency, the entries from before a currency change are stamped with the old one.
+type PriceHistory struct {
+	Id            string            `json:"id" bson:"_id"`
+	BookId        string            `json:"book_id" bson:"book_id"`
+	Price         int64             `json:"price" bson:"price"`
+	PreviousPrice int64             `json:"previous_price" bson:"previous_price"`
+	Currency      string            `json:"currency,omitempty" bson:"currency,omitempty"`
+	Reason        PriceChangeReason `json:"reason" bson:"reason"`
+	ScheduleId    string            `json:"schedule_id,omitempty" bson:"schedule_id,omitempty"`
+	ChangedBy     string            `json:"changed_by,omitempty" bson:"changed_by,omitempty"`
+	ChangedAt     time.Time         `json:"changed_at" bson:"changed_at"`
+}
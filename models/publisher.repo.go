@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Publisher is a publishing house, NameKey is the name without case, spaces and punctuation
+type Publisher struct {
+	Id        string    `json:"id" bson:"_id"`
+	Name      string    `json:"name" bson:"name"`
+	NameKey   string    `json:"-" bson:"name_key"`
+	Website   string    `json:"website,omitempty" bson:"website,omitempty"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+}
+
+type AddPublisher struct {
+	AdminId string `json:"admin_id" binding:"required"`
+	Name    string `json:"name" binding:"required"`
+	Website string `json:"website"`
+}
+
+type UpdatePublisher struct {
+	AdminId string  `json:"admin_id" binding:"required"`
+	Name    *string `json:"name"`
+	Website *string `json:"website"`
+}
@@ -0,0 +1,189 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+type SupplierType string
+
+var ErrUnknownSupplierType = errors.New("unknown supplier type")
+
+const (
+	PublisherSupplier   SupplierType = "PUBLISHER"
+	DistributorSupplier SupplierType = "DISTRIBUTOR"
+)
+
+func IsValidSupplierType(supplierType string) (SupplierType, error) {
+	switch supplierType {
+	case PublisherSupplier.String():
+		break
+	case DistributorSupplier.String():
+		break
+	default:
+		return "", ErrUnknownSupplierType
+	}
+
+	return SupplierType(supplierType), nil
+}
+
+func (s SupplierType) String() string {
+	return string(s)
+}
+
+// Supplier is a publisher or distributor books are bought from
+type Supplier struct {
+	Id           string       `json:"id" bson:"_id"`
+	Name         string       `json:"name" bson:"name"`
+	Type         SupplierType `json:"type" bson:"type"`
+	Email        string       `json:"email" bson:"email"`
+	Phone        string       `json:"phone" bson:"phone"`
+	Address      *Address     `json:"address,omitempty" bson:"address,omitempty"`
+	LeadTimeDays int64        `json:"lead_time_days" bson:"lead_time_days"`
+	Active       bool         `json:"active" bson:"active"`
+	CreatedAt    time.Time    `json:"created_at" bson:"created_at"`
+}
+
+type AddSupplier struct {
+	AdminId      string   `json:"admin_id" binding:"required"`
+	Name         string   `json:"name" binding:"required"`
+	Type         string   `json:"type" binding:"required"`
+	Email        string   `json:"email"`
+	Phone        string   `json:"phone"`
+	Address      *Address `json:"address"`
+	LeadTimeDays int64    `json:"lead_time_days"`
+	Active       *bool    `json:"active"`
+}
+
+type PurchaseOrderStatus string
+
+var ErrUnknownPurchaseOrderStatus = errors.New("unknown purchase order status")
+
+const (
+	PurchaseOrdered           PurchaseOrderStatus = "ORDERED"
+	PurchasePartiallyReceived PurchaseOrderStatus = "PARTIALLY_RECEIVED"
+	PurchaseReceived          PurchaseOrderStatus = "RECEIVED"
+	PurchaseCancelled         PurchaseOrderStatus = "CANCELLED"
+)
+
+func IsValidPurchaseOrderStatus(status string) (PurchaseOrderStatus, error) {
+	switch status {
+	case PurchaseOrdered.String():
+		break
+	case PurchasePartiallyReceived.String():
+		break
+	case PurchaseReceived.String():
+		break
+	case PurchaseCancelled.String():
+		break
+	default:
+		return "", ErrUnknownPurchaseOrderStatus
+	}
+
+	return PurchaseOrderStatus(status), nil
+}
+
+// IsOpen reports whether books of the purchase order are still expected
+func (p PurchaseOrderStatus) IsOpen() bool {
+	return p == PurchaseOrdered || p == PurchasePartiallyReceived
+}
+func (p PurchaseOrderStatus) IsReceived() bool {
+	return p == PurchaseReceived
+}
+func (p PurchaseOrderStatus) IsCancelled() bool {
+	return p == PurchaseCancelled
+}
+func (p PurchaseOrderStatus) String() string {
+	return string(p)
+}
+
+// PurchaseOrder orders books from a supplier, they are received into LocationId. Lines have one book each.
+type PurchaseOrder struct {
+	Id         string              `json:"id" bson:"_id"`
+	SupplierId string              `json:"supplier_id" bson:"supplier_id"`
+	LocationId string              `json:"location_id" bson:"location_id"`
+	Status     PurchaseOrderStatus `json:"status" bson:"status"`
+	Currency   string              `json:"currency" bson:"currency"`
+	Lines      []PurchaseOrderLine `json:"lines" bson:"lines"`
+	Total      int64               `json:"total" bson:"total"`
+	Receipts   []GoodsReceipt      `json:"receipts" bson:"receipts"`
+	ExpectedAt time.Time           `json:"expected_at" bson:"expected_at"`
+	Note       string              `json:"note,omitempty" bson:"note,omitempty"`
+	CreatedBy  string              `json:"created_by" bson:"created_by"`
+	CreatedAt  time.Time           `json:"created_at" bson:"created_at"`
+	ClosedAt   *time.Time          `json:"closed_at,omitempty" bson:"closed_at,omitempty"`
+}
+
+// Line returns the line of a book, nil when the book isn't ordered
+func (p PurchaseOrder) Line(bookId string) *PurchaseOrderLine {
+	for i := range p.Lines {
+		if p.Lines[i].BookId == bookId {
+			return &p.Lines[i]
+		}
+	}
+	return nil
+}
+
+type PurchaseOrderLine struct {
+	BookId      string `json:"book_id" bson:"book_id"`
+	Qty         int64  `json:"qty" bson:"qty"`
+	ReceivedQty int64  `json:"received_qty" bson:"received_qty"`
+	UnitCost    int64  `json:"unit_cost" bson:"unit_cost"`
+}
+
+// Outstanding returns how many copies are still expected
+func (l PurchaseOrderLine) Outstanding() int64 {
+	return l.Qty - l.ReceivedQty
+}
+
+// GoodsReceipt is a delivery received against a purchase order, every line is a stock receipt in the ledger
+type GoodsReceipt struct {
+	Id         string         `json:"id" bson:"id"`
+	Lines      []ReceivedLine `json:"lines" bson:"lines"`
+	AdminId    string         `json:"admin_id" bson:"admin_id"`
+	Note       string         `json:"note,omitempty" bson:"note,omitempty"`
+	ReceivedAt time.Time      `json:"received_at" bson:"received_at"`
+}
+
+type ReceivedLine struct {
+	BookId string `json:"book_id" bson:"book_id" binding:"required"`
+	Qty    int64  `json:"qty" bson:"qty" binding:"required"`
+}
+
+type AddPurchaseOrder struct {
+	AdminId    string                 `json:"admin_id" binding:"required"`
+	SupplierId string                 `json:"supplier_id" binding:"required"`
+	LocationId string                 `json:"location_id"`
+	Currency   string                 `json:"currency"`
+	Lines      []AddPurchaseOrderLine `json:"lines" binding:"required,dive"`
+	ExpectedAt time.Time              `json:"expected_at" binding:"required"`
+	Note       string                 `json:"note"`
+}
+
+type AddPurchaseOrderLine struct {
+	BookId   string `json:"book_id" binding:"required"`
+	Qty      int64  `json:"qty" binding:"required"`
+	UnitCost int64  `json:"unit_cost"`
+}
+
+type ReceivePurchaseOrder struct {
+	AdminId string         `json:"admin_id" binding:"required"`
+	Lines   []ReceivedLine `json:"lines" binding:"required,dive"`
+	Note    string         `json:"note"`
+}
+
+type CancelPurchaseOrder struct {
+	AdminId string `json:"admin_id" binding:"required"`
+}
+
+// SupplierOutstanding is what a supplier still has to deliver on its open purchase orders in a currency
+type SupplierOutstanding struct {
+	SupplierId       string     `json:"supplier_id" bson:"supplier_id"`
+	SupplierName     string     `json:"supplier_name" bson:"-"`
+	Currency         string     `json:"currency" bson:"currency"`
+	OpenOrders       int64      `json:"open_orders" bson:"open_orders"`
+	OverdueOrders    int64      `json:"overdue_orders" bson:"overdue_orders"`
+	OutstandingQty   int64      `json:"outstanding_qty" bson:"outstanding_qty"`
+	OutstandingValue int64      `json:"outstanding_value" bson:"outstanding_value"`
+	NextExpectedAt   *time.Time `json:"next_expected_at,omitempty" bson:"next_expected_at,omitempty"`
+}
@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+type RefundStatus string
+
+const (
+	RefundPending   RefundStatus = "PENDING"
+	RefundSucceeded RefundStatus = "SUCCEEDED"
+)
+
+// IsPending reports whether the refund is recorded but not confirmed by the provider yet, refunds from
+// before the status have none and are done
+func (r RefundStatus) IsPending() bool {
+	return r == RefundPending
+}
+func (r RefundStatus) String() string {
+	return string(r)
+}
+
+// Refund is money given back to the customer through the payment provider
+type Refund struct {
+	Id          string       `json:"id" bson:"_id"`
+	ReturnId    string       `json:"return_id" bson:"return_id"`
+	OrderId     string       `json:"order_id" bson:"order_id"`
+	PaymentId   string       `json:"payment_id" bson:"payment_id"`
+	UserId      string       `json:"user_id" bson:"user_id"`
+	Provider    string       `json:"provider" bson:"provider"`
+	ProviderRef string       `json:"provider_ref" bson:"provider_ref"`
+	Amount      int64        `json:"amount" bson:"amount"`
+	Currency    string       `json:"currency" bson:"currency"`
+	Status      RefundStatus `json:"status" bson:"status"`
+	CreatedAt   time.Time    `json:"created_at" bson:"created_at"`
+}
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+type ReturnStatus string
+
+var ErrUnknownReturnStatus = errors.New("unknown return status")
+
+const (
+	ReturnRequested ReturnStatus = "REQUESTED"
+	ReturnApproved  ReturnStatus = "APPROVED"
+	ReturnRejected  ReturnStatus = "REJECTED"
+	ReturnReceived  ReturnStatus = "RECEIVED"
+	ReturnRefunded  ReturnStatus = "REFUNDED"
+)
+
+func IsValidReturnStatus(status string) (ReturnStatus, error) {
+	switch status {
+	case ReturnRequested.String():
+		break
+	case ReturnApproved.String():
+		break
+	case ReturnRejected.String():
+		break
+	case ReturnReceived.String():
+		break
+	case ReturnRefunded.String():
+		break
+	default:
+		return "", ErrUnknownReturnStatus
+	}
+
+	return ReturnStatus(status), nil
+}
+
+func (r ReturnStatus) IsRequested() bool {
+	return r == ReturnRequested
+}
+func (r ReturnStatus) IsApproved() bool {
+	return r == ReturnApproved
+}
+func (r ReturnStatus) IsRejected() bool {
+	return r == ReturnRejected
+}
+func (r ReturnStatus) IsReceived() bool {
+	return r == ReturnReceived
+}
+func (r ReturnStatus) IsRefunded() bool {
+	return r == ReturnRefunded
+}
+func (r ReturnStatus) String() string {
+	return string(r)
+}
+
+// Return is a return merchandise authorization for books of a delivered order
+type Return struct {
+	Id           string       `json:"id" bson:"_id"`
+	OrderId      string       `json:"order_id" bson:"order_id"`
+	UserId       string       `json:"user_id" bson:"user_id"`
+	BookId       string       `json:"book_id" bson:"book_id"`
+	Qty          int64        `json:"qty" bson:"qty"`
+	Reason       string       `json:"reason" bson:"reason"`
+	Status       ReturnStatus `json:"status" bson:"status"`
+	RejectReason string       `json:"reject_reason,omitempty" bson:"reject_reason,omitempty"`
+	RefundAmount int64        `json:"refund_amount" bson:"refund_amount"`
+	ReviewedBy   string       `json:"reviewed_by,omitempty" bson:"reviewed_by,omitempty"`
+	RequestedAt  time.Time    `json:"requested_at" bson:"requested_at"`
+	ReviewedAt   *time.Time   `json:"reviewed_at,omitempty" bson:"reviewed_at,omitempty"`
+	ReceivedAt   *time.Time   `json:"received_at,omitempty" bson:"received_at,omitempty"`
+	RefundedAt   *time.Time   `json:"refunded_at,omitempty" bson:"refunded_at,omitempty"`
+}
+
+type AddReturn struct {
+	UserId  string `json:"user_id" binding:"required"`
+	OrderId string `json:"order_id" binding:"required"`
+	Qty     int64  `json:"qty" binding:"required"`
+	Reason  string `json:"reason" binding:"required"`
+}
+
+type ReviewReturn struct {
+	AdminId string `json:"admin_id" binding:"required"`
+	Reason  string `json:"reason"`
+}
+
+type ReceiveReturn struct {
+	AdminId string `json:"admin_id" binding:"required"`
+	// RefundAmount overrides the refund of the returned books, e.g. a partial refund for a damaged book
+	RefundAmount *int64 `json:"refund_amount"`
+}
@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+// Series is a run of titles read in order, each title is a volume of it
+type Series struct {
+	Id          string    `json:"id" bson:"_id"`
+	Name        string    `json:"name" bson:"name"`
+	NameKey     string    `json:"-" bson:"name_key"`
+	Description string    `json:"description,omitempty" bson:"description,omitempty"`
+	CreatedAt   time.Time `json:"created_at" bson:"created_at"`
+}
+
+type AddSeries struct {
+	AdminId     string `json:"admin_id" binding:"required"`
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+}
+
+type UpdateSeries struct {
+	AdminId     string  `json:"admin_id" binding:"required"`
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+}
+
+// SeriesEntry places the title of a book in a series, Volume orders the series and may have a fraction
+// for novellas between volumes, e.g. 2.5
+type SeriesEntry struct {
+	SeriesId string  `json:"series_id" bson:"series_id"`
+	Name     string  `json:"name" bson:"name"`
+	Volume   float64 `json:"volume" bson:"volume"`
+}
+
+// SeriesVolume is a title of a series, BookId is the format of it to link to
+type SeriesVolume struct {
+	Volume  float64 `json:"volume"`
+	TitleId string  `json:"title_id"`
+	BookId  string  `json:"book_id"`
+	Name    string  `json:"name"`
+}
+
+// SeriesNav tells where a book is in its series
+type SeriesNav struct {
+	SeriesEntry
+	Previous *SeriesVolume `json:"previous,omitempty"`
+	Next     *SeriesVolume `json:"next,omitempty"`
+}
+
+type SeriesResp struct {
+	Series
+	Volumes []SeriesVolume `json:"volumes"`
+}
+
+type SetSeriesVolume struct {
+	AdminId string   `json:"admin_id" binding:"required"`
+	BookId  string   `json:"book_id" binding:"required"`
+	Volume  *float64 `json:"volume" binding:"required"`
+}
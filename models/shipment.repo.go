@@ -0,0 +1,134 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+type Carrier string
+
+var ErrUnknownCarrier = errors.New("unknown carrier")
+
+const (
+	JNE          Carrier = "JNE"
+	JNT          Carrier = "JNT"
+	SiCepat      Carrier = "SICEPAT"
+	PosIndonesia Carrier = "POS_INDONESIA"
+)
+
+// carrierTrackingUrls are the public tracking pages, %s is replaced by the tracking number
+var carrierTrackingUrls = map[Carrier]string{
+	JNE:          "https://www.jne.co.id/id/tracking/trace?awb=%s",
+	JNT:          "https://www.jet.co.id/track?awb=%s",
+	SiCepat:      "https://www.sicepat.com/checkAwb?awb=%s",
+	PosIndonesia: "https://www.posindonesia.co.id/id/tracking?barcode=%s",
+}
+
+func IsValidCarrier(carrier string) (Carrier, error) {
+	switch carrier {
+	case JNE.String():
+		break
+	case JNT.String():
+		break
+	case SiCepat.String():
+		break
+	case PosIndonesia.String():
+		break
+	default:
+		return "", ErrUnknownCarrier
+	}
+
+	return Carrier(carrier), nil
+}
+
+// Carriers returns every supported carrier
+func Carriers() []Carrier {
+	return []Carrier{JNE, JNT, SiCepat, PosIndonesia}
+}
+
+// TrackingUrl returns the carrier tracking page of a tracking number
+func (c Carrier) TrackingUrl(trackingNumber string) string {
+	return strings.Replace(carrierTrackingUrls[c], "%s", trackingNumber, 1)
+}
+func (c Carrier) String() string {
+	return string(c)
+}
+
+type ServiceLevel string
+
+var ErrUnknownServiceLevel = errors.New("unknown service level")
+
+const (
+	Economy ServiceLevel = "ECONOMY"
+	Regular ServiceLevel = "REGULAR"
+	Express ServiceLevel = "EXPRESS"
+	SameDay ServiceLevel = "SAME_DAY"
+)
+
+func IsValidServiceLevel(serviceLevel string) (ServiceLevel, error) {
+	switch serviceLevel {
+	case Economy.String():
+		break
+	case Regular.String():
+		break
+	case Express.String():
+		break
+	case SameDay.String():
+		break
+	default:
+		return "", ErrUnknownServiceLevel
+	}
+
+	return ServiceLevel(serviceLevel), nil
+}
+
+func (s ServiceLevel) String() string {
+	return string(s)
+}
+
+type ShipmentStatus string
+
+const (
+	ShipmentShipped   ShipmentStatus = "SHIPPED"
+	ShipmentDelivered ShipmentStatus = "DELIVERED"
+)
+
+func (s ShipmentStatus) IsShipped() bool {
+	return s == ShipmentShipped
+}
+func (s ShipmentStatus) IsDelivered() bool {
+	return s == ShipmentDelivered
+}
+func (s ShipmentStatus) String() string {
+	return string(s)
+}
+
+type Shipment struct {
+	Id             string         `json:"id" bson:"_id"`
+	OrderId        string         `json:"order_id" bson:"order_id"`
+	UserId         string         `json:"user_id" bson:"user_id"`
+	Carrier        Carrier        `json:"carrier" bson:"carrier"`
+	ServiceLevel   ServiceLevel   `json:"service_level" bson:"service_level"`
+	TrackingNumber string         `json:"tracking_number" bson:"tracking_number"`
+	TrackingUrl    string         `json:"tracking_url" bson:"tracking_url"`
+	Address        Address        `json:"address" bson:"address"`
+	Status         ShipmentStatus `json:"status" bson:"status"`
+	ShippedBy      string         `json:"shipped_by" bson:"shipped_by"`
+	ShippedAt      time.Time      `json:"shipped_at" bson:"shipped_at"`
+	DeliveredAt    *time.Time     `json:"delivered_at,omitempty" bson:"delivered_at,omitempty"`
+}
+
+type AddShipment struct {
+	AdminId        string `json:"admin_id" binding:"required"`
+	OrderId        string `json:"order_id" binding:"required"`
+	Carrier        string `json:"carrier" binding:"required"`
+	ServiceLevel   string `json:"service_level" binding:"required"`
+	TrackingNumber string `json:"tracking_number" binding:"required"`
+	// Address overrides the shipping address of the order
+	Address *Address `json:"address"`
+}
+
+type DeliverShipment struct {
+	AdminId string `json:"admin_id" binding:"required"`
+}
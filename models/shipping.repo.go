@@ -0,0 +1,102 @@
+package models
+
+import "errors"
+
+type ShippingRateType string
+
+var ErrUnknownShippingRateType = errors.New("unknown shipping rate type")
+
+const (
+	FlatRate         ShippingRateType = "FLAT"
+	WeightTieredRate ShippingRateType = "WEIGHT_TIERED"
+)
+
+func IsValidShippingRateType(rateType string) (ShippingRateType, error) {
+	switch rateType {
+	case FlatRate.String():
+		break
+	case WeightTieredRate.String():
+		break
+	default:
+		return "", ErrUnknownShippingRateType
+	}
+
+	return ShippingRateType(rateType), nil
+}
+
+func (s ShippingRateType) IsFlat() bool {
+	return s == FlatRate
+}
+func (s ShippingRateType) IsWeightTiered() bool {
+	return s == WeightTieredRate
+}
+func (s ShippingRateType) String() string {
+	return string(s)
+}
+
+// WeightTier charges Fee for parcels up to UpToGrams
+type WeightTier struct {
+	UpToGrams int64 `json:"up_to_grams" bson:"up_to_grams"`
+	Fee       int64 `json:"fee" bson:"fee"`
+}
+
+type ShippingRate struct {
+	Type    ShippingRateType `json:"type" bson:"type"`
+	FlatFee int64            `json:"flat_fee" bson:"flat_fee"`
+	// Tiers are sorted by weight, parcels heavier than the last tier pay its fee plus ExtraFeePerKg for every started kg above it
+	Tiers         []WeightTier `json:"tiers" bson:"tiers"`
+	ExtraFeePerKg int64        `json:"extra_fee_per_kg" bson:"extra_fee_per_kg"`
+	// FreeOverSubtotal makes shipping free when the item subtotal reaches it, 0 disables it
+	FreeOverSubtotal int64 `json:"free_over_subtotal" bson:"free_over_subtotal"`
+}
+
+// ShippingZone is a destination area with its rate table, provinces are matched before countries
+type ShippingZone struct {
+	Id        string       `json:"id" bson:"_id"`
+	Name      string       `json:"name" bson:"name"`
+	Countries []string     `json:"countries" bson:"countries"`
+	Provinces []string     `json:"provinces" bson:"provinces"`
+	IsDefault bool         `json:"is_default" bson:"is_default"`
+	Rate      ShippingRate `json:"rate" bson:"rate"`
+}
+
+type AddShippingZone struct {
+	AdminId   string       `json:"admin_id" binding:"required"`
+	Name      string       `json:"name" binding:"required"`
+	Countries []string     `json:"countries"`
+	Provinces []string     `json:"provinces"`
+	IsDefault bool         `json:"is_default"`
+	Rate      ShippingRate `json:"rate" binding:"required"`
+}
+
+type ShippingQuote struct {
+	ZoneId      string `json:"zone_id"`
+	ZoneName    string `json:"zone_name"`
+	WeightGrams int64  `json:"weight_grams"`
+	Fee         int64  `json:"fee"`
+}
+
+// OrderQuote is the price breakdown of an order
+type OrderQuote struct {
+	Currency       string         `json:"currency"`
+	ExchangeRate   *LockedRate    `json:"exchange_rate,omitempty"`
+	BaseCurrency   string         `json:"base_currency"`
+	BaseGrandTotal int64          `json:"base_grand_total"`
+	UnitPrice      int64          `json:"unit_price"`
+	Subtotal       int64          `json:"subtotal"`
+	CouponCode     string         `json:"coupon_code,omitempty"`
+	Discount       int64          `json:"discount"`
+	ShippingFee    int64          `json:"shipping_fee"`
+	TaxTotal       int64          `json:"tax_total"`
+	TaxLines       []TaxLine      `json:"tax_lines"`
+	GrandTotal     int64          `json:"grand_total"`
+	Shipping       *ShippingQuote `json:"shipping,omitempty"`
+}
+
+type QuoteReq struct {
+	BookId          string   `json:"book_id" binding:"required"`
+	Qty             int64    `json:"qty" binding:"required"`
+	ShippingAddress *Address `json:"shipping_address"`
+	CouponCode      string   `json:"coupon_code"`
+	Currency        string   `json:"currency"`
+}
@@ -0,0 +1,135 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+type StockReason string
+
+var ErrUnknownStockReason = errors.New("unknown stock reason")
+
+const (
+	StockOpeningBalance      StockReason = "OPENING_BALANCE"
+	StockReceipt             StockReason = "RECEIPT"
+	StockSale                StockReason = "SALE"
+	StockCancellationRestore StockReason = "CANCELLATION_RESTORE"
+	StockExpiryRestore       StockReason = "EXPIRY_RESTORE"
+	StockReturn              StockReason = "RETURN"
+	StockManualAdjustment    StockReason = "MANUAL_ADJUSTMENT"
+	StockTakeCorrection      StockReason = "STOCK_TAKE_CORRECTION"
+	StockTransferOut         StockReason = "TRANSFER_OUT"
+	StockTransferIn          StockReason = "TRANSFER_IN"
+	StockTransferReversal    StockReason = "TRANSFER_REVERSAL"
+	StockWaitlistHold        StockReason = "WAITLIST_HOLD"
+	StockWaitlistRelease     StockReason = "WAITLIST_RELEASE"
+)
+
+func IsValidStockReason(reason string) (StockReason, error) {
+	switch reason {
+	case StockOpeningBalance.String():
+		break
+	case StockReceipt.String():
+		break
+	case StockSale.String():
+		break
+	case StockCancellationRestore.String():
+		break
+	case StockExpiryRestore.String():
+		break
+	case StockReturn.String():
+		break
+	case StockManualAdjustment.String():
+		break
+	case StockTakeCorrection.String():
+		break
+	case StockTransferOut.String():
+		break
+	case StockTransferIn.String():
+		break
+	case StockTransferReversal.String():
+		break
+	case StockWaitlistHold.String():
+		break
+	case StockWaitlistRelease.String():
+		break
+	default:
+		return "", ErrUnknownStockReason
+	}
+
+	return StockReason(reason), nil
+}
+
+// IsManual reports whether admins can post the reason by hand, the others come from orders and returns
+func (s StockReason) IsManual() bool {
+	return s == StockReceipt || s == StockManualAdjustment || s == StockTakeCorrection
+}
+func (s StockReason) String() string {
+	return string(s)
+}
+
+// StockMovement is a ledger entry of a book stock change at a location, Balance is the stock of the
+// location right after it
+type StockMovement struct {
+	Id         string      `json:"id" bson:"_id"`
+	BookId     string      `json:"book_id" bson:"book_id"`
+	LocationId string      `json:"location_id" bson:"location_id"`
+	Delta      int64       `json:"delta" bson:"delta"`
+	Balance    int64       `json:"balance" bson:"balance"`
+	Reason     StockReason `json:"reason" bson:"reason"`
+	ActorId    string      `json:"actor_id" bson:"actor_id"`
+	RefId      string      `json:"ref_id,omitempty" bson:"ref_id,omitempty"`
+	Note       string      `json:"note,omitempty" bson:"note,omitempty"`
+	CreatedAt  time.Time   `json:"created_at" bson:"created_at"`
+}
+
+type AdjustStock struct {
+	AdminId    string `json:"admin_id" binding:"required"`
+	LocationId string `json:"location_id"`
+	Delta      int64  `json:"delta" binding:"required"`
+	Reason     string `json:"reason" binding:"required"`
+	Note       string `json:"note"`
+}
+
+type RebuildStock struct {
+	AdminId string `json:"admin_id" binding:"required"`
+}
+
+// StockLedgerSum is the sum of the ledger of a book at a location
+type StockLedgerSum struct {
+	BookId     string `json:"book_id" bson:"book_id"`
+	LocationId string `json:"location_id" bson:"location_id"`
+	Qty        int64  `json:"qty" bson:"qty"`
+	Movements  int64  `json:"movements" bson:"movements"`
+}
+
+// StockAudit compares the stock of a book with its ledger, Discrepancy is the stock less the ledger sum.
+// Qty is the catalogue stock, the sum of the sellable locations.
+type StockAudit struct {
+	BookId      string          `json:"book_id"`
+	Qty         int64           `json:"qty"`
+	SellableQty int64           `json:"sellable_qty"`
+	Discrepancy int64           `json:"discrepancy"`
+	Locations   []LocationAudit `json:"locations"`
+}
+
+type LocationAudit struct {
+	LocationId  string `json:"location_id"`
+	Qty         int64  `json:"qty"`
+	LedgerQty   int64  `json:"ledger_qty"`
+	Movements   int64  `json:"movements"`
+	Discrepancy int64  `json:"discrepancy"`
+}
+
+// HasDiscrepancy reports whether any stock of the book doesn't match what it is built from
+func (s StockAudit) HasDiscrepancy() bool {
+	if s.Discrepancy != 0 {
+		return true
+	}
+	for _, location := range s.Locations {
+		if location.Discrepancy != 0 {
+			return true
+		}
+	}
+	return false
+}
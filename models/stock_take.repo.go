@@ -0,0 +1,107 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+type StockTakeStatus string
+
+var ErrUnknownStockTakeStatus = errors.New("unknown stock take status")
+
+const (
+	StockTakeOpen      StockTakeStatus = "OPEN"
+	StockTakeCommitted StockTakeStatus = "COMMITTED"
+	StockTakeCancelled StockTakeStatus = "CANCELLED"
+)
+
+func IsValidStockTakeStatus(status string) (StockTakeStatus, error) {
+	switch status {
+	case StockTakeOpen.String():
+		break
+	case StockTakeCommitted.String():
+		break
+	case StockTakeCancelled.String():
+		break
+	default:
+		return "", ErrUnknownStockTakeStatus
+	}
+
+	return StockTakeStatus(status), nil
+}
+
+func (s StockTakeStatus) IsOpen() bool {
+	return s == StockTakeOpen
+}
+func (s StockTakeStatus) String() string {
+	return string(s)
+}
+
+// StockTake is a physical count of a location. The stock of every book at the location is frozen when the
+// session opens, one session per location can be open at a time.
+type StockTake struct {
+	Id          string          `json:"id" bson:"_id"`
+	LocationId  string          `json:"location_id" bson:"location_id"`
+	Status      StockTakeStatus `json:"status" bson:"status"`
+	Note        string          `json:"note,omitempty" bson:"note,omitempty"`
+	OpenedBy    string          `json:"opened_by" bson:"opened_by"`
+	OpenedAt    time.Time       `json:"opened_at" bson:"opened_at"`
+	ClosedBy    string          `json:"closed_by,omitempty" bson:"closed_by,omitempty"`
+	ClosedAt    *time.Time      `json:"closed_at,omitempty" bson:"closed_at,omitempty"`
+	Corrections int64           `json:"corrections" bson:"corrections"`
+}
+
+// StockTakeLine is the snapshot and the count of a book in a stock take. Counts of the same book add up,
+// e.g. copies found on two shelves, a recount replaces them.
+type StockTakeLine struct {
+	Id          string       `json:"id" bson:"_id"`
+	StockTakeId string       `json:"stock_take_id" bson:"stock_take_id"`
+	BookId      string       `json:"book_id" bson:"book_id"`
+	SnapshotQty int64        `json:"snapshot_qty" bson:"snapshot_qty"`
+	Counted     bool         `json:"counted" bson:"counted"`
+	CountedQty  int64        `json:"counted_qty" bson:"counted_qty"`
+	CountedAt   *time.Time   `json:"counted_at,omitempty" bson:"counted_at,omitempty"`
+	Counts      []StockCount `json:"counts" bson:"counts"`
+}
+
+type StockCount struct {
+	Qty       int64     `json:"qty" bson:"qty"`
+	CountedBy string    `json:"counted_by" bson:"counted_by"`
+	CountedAt time.Time `json:"counted_at" bson:"counted_at"`
+}
+
+// StockTakeVariance compares the count of a book with the stock expected when it was counted, the snapshot
+// plus what moved at the location between opening the session and counting the book
+type StockTakeVariance struct {
+	BookId      string `json:"book_id" bson:"book_id"`
+	SnapshotQty int64  `json:"snapshot_qty" bson:"snapshot_qty"`
+	MovedQty    int64  `json:"moved_qty" bson:"moved_qty"`
+	ExpectedQty int64  `json:"expected_qty" bson:"expected_qty"`
+	Counted     bool   `json:"counted" bson:"counted"`
+	CountedQty  int64  `json:"counted_qty" bson:"counted_qty"`
+	Variance    int64  `json:"variance" bson:"variance"`
+}
+
+type OpenStockTake struct {
+	AdminId    string `json:"admin_id" binding:"required"`
+	LocationId string `json:"location_id"`
+	Note       string `json:"note"`
+}
+
+type SubmitStockCounts struct {
+	AdminId string           `json:"admin_id" binding:"required"`
+	Counts  []StockCountLine `json:"counts" binding:"required,dive"`
+}
+
+type StockCountLine struct {
+	BookId string `json:"book_id" binding:"required"`
+	Qty    int64  `json:"qty"`
+	// Recount replaces the earlier counts of the book instead of adding to them
+	Recount bool `json:"recount"`
+}
+
+type CloseStockTake struct {
+	AdminId string `json:"admin_id" binding:"required"`
+	// ZeroUncounted commits books which weren't counted as counted 0, otherwise they are left as they are
+	ZeroUncounted bool `json:"zero_uncounted"`
+}
@@ -0,0 +1,99 @@
+package models
+
+import "errors"
+
+type TaxClass string
+
+var ErrUnknownTaxClass = errors.New("unknown tax class")
+
+const (
+	StandardTax TaxClass = "STANDARD"
+	ReducedTax  TaxClass = "REDUCED"
+	ExemptTax   TaxClass = "EXEMPT"
+)
+
+func IsValidTaxClass(taxClass string) (TaxClass, error) {
+	switch taxClass {
+	case StandardTax.String():
+		break
+	case ReducedTax.String():
+		break
+	case ExemptTax.String():
+		break
+	default:
+		return "", ErrUnknownTaxClass
+	}
+
+	return TaxClass(taxClass), nil
+}
+
+func (t TaxClass) String() string {
+	return string(t)
+}
+
+// TaxRegion holds the tax rates of an area in basis points, 1100 is 11%. When PricesIncludeTax is set
+// book prices already contain the tax in that region, otherwise tax is added on top at checkout.
+type TaxRegion struct {
+	Id               string   `json:"id" bson:"_id"`
+	Name             string   `json:"name" bson:"name"`
+	Countries        []string `json:"countries" bson:"countries"`
+	Provinces        []string `json:"provinces" bson:"provinces"`
+	IsDefault        bool     `json:"is_default" bson:"is_default"`
+	StandardRateBps  int64    `json:"standard_rate_bps" bson:"standard_rate_bps"`
+	ReducedRateBps   int64    `json:"reduced_rate_bps" bson:"reduced_rate_bps"`
+	PricesIncludeTax bool     `json:"prices_include_tax" bson:"prices_include_tax"`
+}
+
+// RateBps returns the rate of a tax class in the region
+func (t TaxRegion) RateBps(taxClass TaxClass) int64 {
+	switch taxClass {
+	case ReducedTax:
+		return t.ReducedRateBps
+	case ExemptTax:
+		return 0
+	}
+	return t.StandardRateBps
+}
+
+type AddTaxRegion struct {
+	AdminId          string   `json:"admin_id" binding:"required"`
+	Name             string   `json:"name" binding:"required"`
+	Countries        []string `json:"countries"`
+	Provinces        []string `json:"provinces"`
+	IsDefault        bool     `json:"is_default"`
+	StandardRateBps  int64    `json:"standard_rate_bps"`
+	ReducedRateBps   int64    `json:"reduced_rate_bps"`
+	PricesIncludeTax bool     `json:"prices_include_tax"`
+}
+
+// TaxLine is the tax charged on an order, stored so reports don't depend on today's rates
+type TaxLine struct {
+	RegionId      string   `json:"region_id" bson:"region_id"`
+	RegionName    string   `json:"region_name" bson:"region_name"`
+	TaxClass      TaxClass `json:"tax_class" bson:"tax_class"`
+	RateBps       int64    `json:"rate_bps" bson:"rate_bps"`
+	TaxableAmount int64    `json:"taxable_amount" bson:"taxable_amount"`
+	Amount        int64    `json:"amount" bson:"amount"`
+	Inclusive     bool     `json:"inclusive" bson:"inclusive"`
+}
+
+// PriceDisplay is a book price as shown to customers of a tax region
+type PriceDisplay struct {
+	RegionId    string `json:"region_id,omitempty"`
+	Price       int64  `json:"price"`
+	Tax         int64  `json:"tax"`
+	IncludesTax bool   `json:"includes_tax"`
+	Currency    string `json:"currency"`
+}
+
+type TaxReportRow struct {
+	Period        string   `json:"period" bson:"period"`
+	Currency      string   `json:"currency" bson:"currency"`
+	RegionId      string   `json:"region_id" bson:"region_id"`
+	RegionName    string   `json:"region_name" bson:"region_name"`
+	TaxClass      TaxClass `json:"tax_class" bson:"tax_class"`
+	RateBps       int64    `json:"rate_bps" bson:"rate_bps"`
+	TaxableAmount int64    `json:"taxable_amount" bson:"taxable_amount"`
+	TaxAmount     int64    `json:"tax_amount" bson:"tax_amount"`
+	Orders        int64    `json:"orders" bson:"orders"`
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+type WaitlistStatus string
+
+var ErrUnknownWaitlistStatus = errors.New("unknown waitlist status")
+
+const (
+	WaitlistWaiting   WaitlistStatus = "WAITING"
+	WaitlistNotified  WaitlistStatus = "NOTIFIED"
+	WaitlistReserved  WaitlistStatus = "RESERVED"
+	WaitlistFulfilled WaitlistStatus = "FULFILLED"
+	WaitlistExpired   WaitlistStatus = "EXPIRED"
+	WaitlistCancelled WaitlistStatus = "CANCELLED"
+)
+
+func IsValidWaitlistStatus(status string) (WaitlistStatus, error) {
+	switch status {
+	case WaitlistWaiting.String():
+		break
+	case WaitlistNotified.String():
+		break
+	case WaitlistReserved.String():
+		break
+	case WaitlistFulfilled.String():
+		break
+	case WaitlistExpired.String():
+		break
+	case WaitlistCancelled.String():
+		break
+	default:
+		return "", ErrUnknownWaitlistStatus
+	}
+
+	return WaitlistStatus(status), nil
+}
+
+func (w WaitlistStatus) IsWaiting() bool {
+	return w == WaitlistWaiting
+}
+func (w WaitlistStatus) IsReserved() bool {
+	return w == WaitlistReserved
+}
+
+// IsActive reports whether the customer is still waiting for the book or hasn't ordered it yet
+func (w WaitlistStatus) IsActive() bool {
+	return w == WaitlistWaiting || w == WaitlistNotified || w == WaitlistReserved
+}
+func (w WaitlistStatus) String() string {
+	return string(w)
+}
+
+// WaitlistEntry is a customer waiting for a book to be back in stock. Entries are served in the order they
+// were made, a customer who asked for a reservation gets the books held at HoldLocationId until HoldUntil.
+type WaitlistEntry struct {
+	Id             string         `json:"id" bson:"_id"`
+	BookId         string         `json:"book_id" bson:"book_id"`
+	UserId         string         `json:"user_id" bson:"user_id"`
+	Qty            int64          `json:"qty" bson:"qty"`
+	Reserve        bool           `json:"reserve" bson:"reserve"`
+	Status         WaitlistStatus `json:"status" bson:"status"`
+	Active         bool           `json:"active" bson:"active"`
+	HoldLocationId string         `json:"hold_location_id,omitempty" bson:"hold_location_id,omitempty"`
+	HoldUntil      *time.Time     `json:"hold_until,omitempty" bson:"hold_until,omitempty"`
+	CreatedAt      time.Time      `json:"created_at" bson:"created_at"`
+	NotifiedAt     *time.Time     `json:"notified_at,omitempty" bson:"notified_at,omitempty"`
+	ClosedAt       *time.Time     `json:"closed_at,omitempty" bson:"closed_at,omitempty"`
+}
+
+type AddWaitlistEntry struct {
+	UserId  string `json:"user_id" binding:"required"`
+	BookId  string `json:"book_id" binding:"required"`
+	Qty     int64  `json:"qty"`
+	Reserve bool   `json:"reserve"`
+}
@@ -0,0 +1,114 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"regexp"
+
+	"github.com/agustadewa/book-system/configs"
+	"github.com/agustadewa/book-system/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrAuthorNotFound = errors.New("author not found")
+var ErrAuthorExists = errors.New("author already exists")
+
+type Author struct {
+	coll *mongo.Collection
+}
+
+func NewAuthor(client *mongo.Client) *Author {
+	return &Author{coll: client.Database(configs.CatalogDBName).Collection(configs.AuthorCollName)}
+}
+
+// EnsureIndexes creates the unique name index, authors spelled differently are the same author
+func (a *Author) EnsureIndexes(ctx context.Context) error {
+	_, err := a.coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "name_key", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+// Get returns an author by given author id
+func (a *Author) Get(ctx context.Context, authorId string) (*models.Author, error) {
+	var author models.Author
+	if err := a.coll.FindOne(ctx, bson.M{"_id": authorId}).Decode(&author); err == mongo.ErrNoDocuments {
+		return nil, ErrAuthorNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &author, nil
+}
+
+// GetByNameKey returns an author by given name key
+func (a *Author) GetByNameKey(ctx context.Context, nameKey string) (*models.Author, error) {
+	var author models.Author
+	if err := a.coll.FindOne(ctx, bson.M{"name_key": nameKey}).Decode(&author); err == mongo.ErrNoDocuments {
+		return nil, ErrAuthorNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &author, nil
+}
+
+// GetAll returns authors sorted by name, a non empty name key prefix filters them
+func (a *Author) GetAll(ctx context.Context, nameKeyPrefix string, limit int64) (*[]models.Author, error) {
+	filter := bson.M{}
+	if nameKeyPrefix != "" {
+		filter["name_key"] = primitive.Regex{Pattern: "^" + regexp.QuoteMeta(nameKeyPrefix)}
+	}
+
+	var authors []models.Author
+	fr, err := a.coll.Find(ctx, filter, options.Find().SetSort(bson.M{"name_key": 1}).SetLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	if err = fr.All(ctx, &authors); err != nil {
+		return nil, err
+	}
+	return &authors, nil
+}
+
+// Add creates a new author
+func (a *Author) Add(ctx context.Context, payload models.Author) (string, error) {
+	if _, err := a.coll.InsertOne(ctx, payload); mongo.IsDuplicateKeyError(err) {
+		return "", ErrAuthorExists
+	} else if err != nil {
+		return "", err
+	}
+	return payload.Id, nil
+}
+
+// Update updates the name and bio of an author
+func (a *Author) Update(ctx context.Context, author models.Author) error {
+	ur, err := a.coll.UpdateByID(ctx, author.Id, bson.M{"$set": bson.M{
+		"name":     author.Name,
+		"name_key": author.NameKey,
+		"bio":      author.Bio,
+	}})
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrAuthorExists
+	} else if err != nil {
+		return err
+	}
+	if ur.MatchedCount == 0 {
+		return ErrAuthorNotFound
+	}
+	return nil
+}
+
+// Delete deletes an author
+func (a *Author) Delete(ctx context.Context, authorId string) error {
+	dr, err := a.coll.DeleteOne(ctx, bson.M{"_id": authorId})
+	if err != nil {
+		return err
+	}
+	if dr.DeletedCount == 0 {
+		return ErrAuthorNotFound
+	}
+	return nil
+}
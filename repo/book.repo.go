@@ -3,16 +3,24 @@ package repo
 import (
 	"context"
 	"errors"
+	"regexp"
+	"strings"
 
 	"github.com/agustadewa/book-system/configs"
 	"github.com/agustadewa/book-system/models"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 var ErrBookNotFound = errors.New("book not found")
-var ErrBookExists = errors.New("book already exists")
+var ErrInsufficientStock = errors.New("quantity is greater than stock")
+var ErrUnlimitedStock = errors.New("digital books have unlimited stock")
+var ErrBookVariantExists = errors.New("title already has a variant in this format")
+var ErrSkuExists = errors.New("sku already exists")
+var ErrIsbnExists = errors.New("a book with this isbn already exists")
+var ErrBookPriceChanging = errors.New("book price keeps changing, try again")
 
 type Book struct {
 	coll *mongo.Collection
@@ -22,6 +30,48 @@ func NewBook(client *mongo.Client) *Book {
 	return &Book{coll: client.Database(configs.BookDBName).Collection(configs.BookCollName)}
 }
 
+// EnsureIndexes creates the unique sku and isbn indexes, the index used to find the variants of a title and
+// the indexes used to browse books by author, publisher, category, series and work
+func (b *Book) EnsureIndexes(ctx context.Context) error {
+	_, err := b.coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "sku", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"sku": bson.M{"$type": "string"}}),
+		},
+		{
+			Keys:    bson.D{{Key: "isbn_13", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"isbn_13": bson.M{"$type": "string"}}),
+		},
+		{Keys: bson.D{{Key: "title_id", Value: 1}, {Key: "format", Value: 1}}},
+		{Keys: bson.D{{Key: "contributors.author_id", Value: 1}}},
+		{Keys: bson.D{{Key: "publisher_id", Value: 1}}},
+		{Keys: bson.D{{Key: "category_ids", Value: 1}}},
+		{Keys: bson.D{{Key: "series.series_id", Value: 1}, {Key: "series.volume", Value: 1}}},
+		{Keys: bson.D{{Key: "work_id", Value: 1}}},
+	})
+	return err
+}
+
+// EnsureVariants makes books from before formats paperback titles of their own, with their id as sku
+func (b *Book) EnsureVariants(ctx context.Context) error {
+	_, err := b.coll.UpdateMany(ctx, bson.M{"title_id": bson.M{"$exists": false}}, mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"title_id": "$_id",
+			"format":   models.Paperback,
+			"sku":      bson.M{"$ifNull": bson.A{"$sku", "$_id"}},
+		}}},
+	})
+	return err
+}
+
+// EnsureWorks makes titles from before editions works of their own
+func (b *Book) EnsureWorks(ctx context.Context) error {
+	_, err := b.coll.UpdateMany(ctx, bson.M{"work_id": bson.M{"$exists": false}}, mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"work_id": "$title_id"}}},
+	})
+	return err
+}
+
 // Get returns a book by given book id
 func (b *Book) Get(ctx context.Context, bookId string) (*models.Book, error) {
 	var book models.Book
@@ -32,14 +82,39 @@ func (b *Book) Get(ctx context.Context, bookId string) (*models.Book, error) {
 	}
 }
 
-// GetByName returns a book by given book name
-func (b *Book) GetByName(ctx context.Context, bookName string) (*models.Book, error) {
+// GetByIsbn returns a book by given isbn-13
+func (b *Book) GetByIsbn(ctx context.Context, isbn13 string) (*models.Book, error) {
 	var book models.Book
-	if err := b.coll.FindOne(ctx, bson.M{"name": bookName}).Decode(&book); err == mongo.ErrNoDocuments {
+	if err := b.coll.FindOne(ctx, bson.M{"isbn_13": isbn13}).Decode(&book); err == mongo.ErrNoDocuments {
 		return nil, ErrBookNotFound
-	} else {
-		return &book, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &book, nil
+}
+
+// GetAllByTitleId returns the variants of a title
+func (b *Book) GetAllByTitleId(ctx context.Context, titleId string) (*[]models.Book, error) {
+	var books []models.Book
+	fr, err := b.coll.Find(ctx, bson.M{"title_id": titleId}, options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
 	}
+	if err = fr.All(ctx, &books); err != nil {
+		return nil, err
+	}
+	return &books, nil
+}
+
+// GetByTitleIdAndFormat returns the variant of a title in a format
+func (b *Book) GetByTitleIdAndFormat(ctx context.Context, titleId string, format models.BookFormat) (*models.Book, error) {
+	var book models.Book
+	if err := b.coll.FindOne(ctx, bson.M{"title_id": titleId, "format": format}).Decode(&book); err == mongo.ErrNoDocuments {
+		return nil, ErrBookNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &book, nil
 }
 
 // GetAll returns a books by given book limit
@@ -61,7 +136,9 @@ func (b *Book) GetAll(ctx context.Context, limit int64) (*[]models.Book, error)
 
 // Add creates a new book
 func (b *Book) Add(ctx context.Context, payload models.Book) (string, error) {
-	if _, err := b.coll.InsertOne(ctx, payload); err != nil {
+	if _, err := b.coll.InsertOne(ctx, payload); mongo.IsDuplicateKeyError(err) {
+		return "", duplicateBookErr(err)
+	} else if err != nil {
 		return "", err
 	}
 
@@ -80,9 +157,24 @@ func (b *Book) Delete(ctx context.Context, bookId string) error {
 	return nil
 }
 
-// UpdateStock updates a book stock
-func (b *Book) UpdateStock(ctx context.Context, bookId string, newStock int64) error {
-	ur, err := b.coll.UpdateByID(ctx, bookId, bson.M{"$inc": bson.M{"qty": newStock}})
+// IncStock adds delta to a book stock and returns the book after the change. The book stock is the sum of
+// its sellable stock levels, the levels guard against going below 0 and this only follows them.
+func (b *Book) IncStock(ctx context.Context, bookId string, delta int64) (*models.Book, error) {
+	var book models.Book
+	err := b.coll.FindOneAndUpdate(ctx, bson.M{"_id": bookId}, bson.M{"$inc": bson.M{"qty": delta}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&book)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrBookNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &book, nil
+}
+
+// SetStock sets a book stock, used to rebuild it from the stock levels
+func (b *Book) SetStock(ctx context.Context, bookId string, qty int64) error {
+	ur, err := b.coll.UpdateByID(ctx, bookId, bson.M{"$set": bson.M{"qty": qty}})
 	if err != nil {
 		return err
 	}
@@ -92,9 +184,282 @@ func (b *Book) UpdateStock(ctx context.Context, bookId string, newStock int64) e
 	return nil
 }
 
+// GetAllByAuthorId returns the books an author contributed to
+func (b *Book) GetAllByAuthorId(ctx context.Context, authorId string, limit int64) (*[]models.Book, error) {
+	return b.getAllSortedByName(ctx, bson.M{"contributors.author_id": authorId}, limit)
+}
+
+// GetAllByPublisherId returns the books of a publisher
+func (b *Book) GetAllByPublisherId(ctx context.Context, publisherId string, limit int64) (*[]models.Book, error) {
+	return b.getAllSortedByName(ctx, bson.M{"publisher_id": publisherId}, limit)
+}
+
+// GetAllByCategoryId returns the books in a category and the categories below it
+func (b *Book) GetAllByCategoryId(ctx context.Context, categoryId string, limit int64) (*[]models.Book, error) {
+	return b.getAllSortedByName(ctx, bson.M{"category_ids": categoryId}, limit)
+}
+
+func (b *Book) getAllSortedByName(ctx context.Context, filter bson.M, limit int64) (*[]models.Book, error) {
+	var books []models.Book
+	fr, err := b.coll.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "name", Value: 1}, {Key: "_id", Value: 1}}).SetLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	if err = fr.All(ctx, &books); err != nil {
+		return nil, err
+	}
+	return &books, nil
+}
+
+// CountByAuthorId returns how many books an author contributed to
+func (b *Book) CountByAuthorId(ctx context.Context, authorId string) (int64, error) {
+	return b.coll.CountDocuments(ctx, bson.M{"contributors.author_id": authorId})
+}
+
+// CountByPublisherId returns how many books a publisher has
+func (b *Book) CountByPublisherId(ctx context.Context, publisherId string) (int64, error) {
+	return b.coll.CountDocuments(ctx, bson.M{"publisher_id": publisherId})
+}
+
+// CountByCategoryId returns how many books are in a category and the categories below it
+func (b *Book) CountByCategoryId(ctx context.Context, categoryId string) (int64, error) {
+	return b.coll.CountDocuments(ctx, bson.M{"category_ids": categoryId})
+}
+
+// GetAllWithoutEntities returns books from before the catalogue entities, they only have the author,
+// publisher and category names
+func (b *Book) GetAllWithoutEntities(ctx context.Context) (*[]models.Book, error) {
+	var books []models.Book
+	fr, err := b.coll.Find(ctx, bson.M{"contributors": bson.M{"$exists": false}})
+	if err != nil {
+		return nil, err
+	}
+	if err = fr.All(ctx, &books); err != nil {
+		return nil, err
+	}
+	return &books, nil
+}
+
+// SetEntities links a book to its catalogue entities and sets the names shown
+func (b *Book) SetEntities(ctx context.Context, bookId string, updatePayload models.UpdateTitle) error {
+	ur, err := b.coll.UpdateByID(ctx, bookId, bson.M{"$set": updatePayload})
+	if err != nil {
+		return err
+	}
+	if ur.MatchedCount == 0 {
+		return ErrBookNotFound
+	}
+	return nil
+}
+
+// RenamePublisher sets the publisher name of the books of a publisher
+func (b *Book) RenamePublisher(ctx context.Context, publisherId string, name string) error {
+	_, err := b.coll.UpdateMany(ctx, bson.M{"publisher_id": publisherId}, bson.M{"$set": bson.M{"publisher": name}})
+	return err
+}
+
+// RenameCategory sets the category name of the books right in a category
+func (b *Book) RenameCategory(ctx context.Context, categoryId string, name string) error {
+	_, err := b.coll.UpdateMany(ctx,
+		bson.M{"$expr": bson.M{"$eq": bson.A{bson.M{"$arrayElemAt": bson.A{"$category_ids", -1}}, categoryId}}},
+		bson.M{"$set": bson.M{"category": name}},
+	)
+	return err
+}
+
+// MoveCategory replaces the lineage of the books in a category and the categories below it, the ids from
+// the moved category down are kept and the ancestors above it replaced
+func (b *Book) MoveCategory(ctx context.Context, categoryId string, ancestors []string) error {
+	_, err := b.coll.UpdateMany(ctx, bson.M{"category_ids": categoryId}, mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"category_ids": bson.M{"$concatArrays": bson.A{
+				ancestors,
+				bson.M{"$slice": bson.A{
+					"$category_ids",
+					bson.M{"$indexOfArray": bson.A{"$category_ids", categoryId}},
+					bson.M{"$size": "$category_ids"},
+				}},
+			}},
+		}}},
+	})
+	return err
+}
+
+// GetAllBySeriesId returns the books of a series in volume order
+func (b *Book) GetAllBySeriesId(ctx context.Context, seriesId string) (*[]models.Book, error) {
+	var books []models.Book
+	fr, err := b.coll.Find(ctx,
+		bson.M{"series.series_id": seriesId},
+		options.Find().SetSort(bson.D{{Key: "series.volume", Value: 1}, {Key: "title_id", Value: 1}, {Key: "_id", Value: 1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err = fr.All(ctx, &books); err != nil {
+		return nil, err
+	}
+	return &books, nil
+}
+
+// CountBySeriesId returns how many books are in a series
+func (b *Book) CountBySeriesId(ctx context.Context, seriesId string) (int64, error) {
+	return b.coll.CountDocuments(ctx, bson.M{"series.series_id": seriesId})
+}
+
+// SetSeries places every variant of a title in a series, nil takes the title out of its series
+func (b *Book) SetSeries(ctx context.Context, titleId string, entry *models.SeriesEntry) error {
+	update := bson.M{"$set": bson.M{"series": entry}}
+	if entry == nil {
+		update = bson.M{"$unset": bson.M{"series": ""}}
+	}
+	ur, err := b.coll.UpdateMany(ctx, bson.M{"title_id": titleId}, update)
+	if err != nil {
+		return err
+	}
+	if ur.MatchedCount == 0 {
+		return ErrBookNotFound
+	}
+	return nil
+}
+
+// RenameSeries sets the series name of the books of a series
+func (b *Book) RenameSeries(ctx context.Context, seriesId string, name string) error {
+	_, err := b.coll.UpdateMany(ctx, bson.M{"series.series_id": seriesId}, bson.M{"$set": bson.M{"series.name": name}})
+	return err
+}
+
+// GetAllByWorkId returns the books of every edition of a work
+func (b *Book) GetAllByWorkId(ctx context.Context, workId string) (*[]models.Book, error) {
+	var books []models.Book
+	fr, err := b.coll.Find(ctx, bson.M{"work_id": workId}, options.Find().SetSort(bson.D{{Key: "title_id", Value: 1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	if err = fr.All(ctx, &books); err != nil {
+		return nil, err
+	}
+	return &books, nil
+}
+
+// SetWork makes every variant of a title an edition of a work
+func (b *Book) SetWork(ctx context.Context, titleId string, workId string) error {
+	ur, err := b.coll.UpdateMany(ctx, bson.M{"title_id": titleId}, bson.M{"$set": bson.M{"work_id": workId}})
+	if err != nil {
+		return err
+	}
+	if ur.MatchedCount == 0 {
+		return ErrBookNotFound
+	}
+	return nil
+}
+
+// MergeWorks makes the editions of a work editions of another work
+func (b *Book) MergeWorks(ctx context.Context, fromWorkId string, toWorkId string) error {
+	_, err := b.coll.UpdateMany(ctx, bson.M{"work_id": fromWorkId}, bson.M{"$set": bson.M{"work_id": toWorkId}})
+	return err
+}
+
+// Search returns the books whose name or author contains q, ignoring case, or whose isbn is isbn13. With
+// collapseEditions the editions of a work are one hit, the first of them by name.
+func (b *Book) Search(ctx context.Context, q string, isbn13 string, collapseEditions bool, limit int64) (*[]models.BookSearchHit, error) {
+	pattern := primitive.Regex{Pattern: regexp.QuoteMeta(q), Options: "i"}
+	or := bson.A{bson.M{"name": pattern}, bson.M{"author": pattern}}
+	if isbn13 != "" {
+		or = append(or, bson.M{"isbn_13": isbn13})
+	}
+	sort := bson.D{{Key: "name", Value: 1}, {Key: "_id", Value: 1}}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"$or": or}}},
+		{{Key: "$sort", Value: sort}},
+	}
+	if collapseEditions {
+		pipeline = append(pipeline,
+			bson.D{{Key: "$group", Value: bson.M{
+				"_id":    bson.M{"$ifNull": bson.A{"$work_id", "$title_id"}},
+				"book":   bson.M{"$first": "$$ROOT"},
+				"titles": bson.M{"$addToSet": "$title_id"},
+			}}},
+			bson.D{{Key: "$replaceRoot", Value: bson.M{"newRoot": bson.M{"$mergeObjects": bson.A{
+				"$book",
+				bson.M{"editions": bson.M{"$size": "$titles"}},
+			}}}}},
+			bson.D{{Key: "$sort", Value: sort}},
+		)
+	}
+	pipeline = append(pipeline, bson.D{{Key: "$limit", Value: limit}})
+
+	var hits []models.BookSearchHit
+	ar, err := b.coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	if err = ar.All(ctx, &hits); err != nil {
+		return nil, err
+	}
+	return &hits, nil
+}
+
+// GetAllStock returns the id, format and stock of every book
+func (b *Book) GetAllStock(ctx context.Context) (*[]models.Book, error) {
+	var books []models.Book
+	fr, err := b.coll.Find(ctx, bson.M{}, options.Find().SetProjection(bson.M{"qty": 1, "format": 1}))
+	if err != nil {
+		return nil, err
+	}
+	if err = fr.All(ctx, &books); err != nil {
+		return nil, err
+	}
+	return &books, nil
+}
+
+// GetAllLowStock returns the physical books whose stock is at or below their reorder threshold
+func (b *Book) GetAllLowStock(ctx context.Context) (*[]models.Book, error) {
+	var books []models.Book
+	fr, err := b.coll.Find(ctx, bson.M{
+		"format":            bson.M{"$nin": []models.BookFormat{models.Ebook, models.Audiobook}},
+		"reorder_threshold": bson.M{"$gt": 0},
+		"$expr":             bson.M{"$lte": bson.A{"$qty", "$reorder_threshold"}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err = fr.All(ctx, &books); err != nil {
+		return nil, err
+	}
+	return &books, nil
+}
+
+// GetAllByIds returns the books with the given ids
+func (b *Book) GetAllByIds(ctx context.Context, bookIds []string) (*[]models.Book, error) {
+	var books []models.Book
+	fr, err := b.coll.Find(ctx, bson.M{"_id": bson.M{"$in": bookIds}})
+	if err != nil {
+		return nil, err
+	}
+	if err = fr.All(ctx, &books); err != nil {
+		return nil, err
+	}
+	return &books, nil
+}
+
 // Update updates a book
 func (b *Book) Update(ctx context.Context, bookId string, updatePayload models.UpdateBook) error {
 	ur, err := b.coll.UpdateByID(ctx, bookId, bson.M{"$set": updatePayload})
+	if mongo.IsDuplicateKeyError(err) {
+		return duplicateBookErr(err)
+	} else if err != nil {
+		return err
+	}
+	if ur.MatchedCount == 0 {
+		return ErrBookNotFound
+	}
+	return nil
+}
+
+// UpdateTitle updates the title details of every variant of a title
+func (b *Book) UpdateTitle(ctx context.Context, titleId string, updatePayload models.UpdateTitle) error {
+	ur, err := b.coll.UpdateMany(ctx, bson.M{"title_id": titleId}, bson.M{"$set": updatePayload})
 	if err != nil {
 		return err
 	}
@@ -103,3 +468,73 @@ func (b *Book) Update(ctx context.Context, bookId string, updatePayload models.U
 	}
 	return nil
 }
+
+// SetFile sets the file of a digital book
+func (b *Book) SetFile(ctx context.Context, bookId string, file models.BookFile) error {
+	ur, err := b.coll.UpdateByID(ctx, bookId, bson.M{"$set": bson.M{"file": file}})
+	if err != nil {
+		return err
+	}
+	if ur.MatchedCount == 0 {
+		return ErrBookNotFound
+	}
+	return nil
+}
+
+// StartScheduledPrice sets the price of a schedule and returns the price it replaced. The price and the
+// schedule mark with the replaced price are written in one update, which only applies while the price is the
+// one read. A book already marked by the schedule keeps its price and returns the marked one.
+func (b *Book) StartScheduledPrice(ctx context.Context, bookId string, scheduleId string, price int64) (int64, error) {
+	for attempt := 0; attempt < 3; attempt++ {
+		book, err := b.Get(ctx, bookId)
+		if err != nil {
+			return 0, err
+		}
+		if book.ScheduledPrice != nil && book.ScheduledPrice.ScheduleId == scheduleId {
+			return book.ScheduledPrice.PreviousPrice, nil
+		}
+
+		ur, err := b.coll.UpdateOne(ctx, bson.M{"_id": bookId, "price": book.Price}, bson.M{"$set": bson.M{
+			"price":           price,
+			"scheduled_price": models.ScheduledPrice{ScheduleId: scheduleId, PreviousPrice: book.Price},
+		}})
+		if err != nil {
+			return 0, err
+		}
+		if ur.MatchedCount > 0 {
+			return book.Price, nil
+		}
+		// changed since it was read, read it again
+	}
+	return 0, ErrBookPriceChanging
+}
+
+// EndScheduledPrice puts back the price a schedule replaced while the book still has the schedule price and
+// removes the schedule mark, false is returned when the price has been changed by someone else
+func (b *Book) EndScheduledPrice(ctx context.Context, bookId string, scheduleId string, price int64, previous int64) (bool, error) {
+	ur, err := b.coll.UpdateOne(ctx,
+		bson.M{"_id": bookId, "price": price},
+		bson.M{"$set": bson.M{"price": previous}, "$unset": bson.M{"scheduled_price": ""}},
+	)
+	if err != nil {
+		return false, err
+	}
+	if ur.MatchedCount > 0 {
+		return true, nil
+	}
+
+	// the newer price stays, the mark goes
+	_, err = b.coll.UpdateOne(ctx,
+		bson.M{"_id": bookId, "scheduled_price.schedule_id": scheduleId},
+		bson.M{"$unset": bson.M{"scheduled_price": ""}},
+	)
+	return false, err
+}
+
+// duplicateBookErr tells which unique book index a duplicate key error comes from
+func duplicateBookErr(err error) error {
+	if strings.Contains(err.Error(), "isbn_13") {
+		return ErrIsbnExists
+	}
+	return ErrSkuExists
+}
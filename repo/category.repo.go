@@ -0,0 +1,155 @@
+package repo
+
+import (
+	"context"
+	"errors"
+
+	"github.com/agustadewa/book-system/configs"
+	"github.com/agustadewa/book-system/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrCategoryNotFound = errors.New("category not found")
+var ErrCategoryExists = errors.New("category already exists under this parent")
+
+type Category struct {
+	coll *mongo.Collection
+}
+
+func NewCategory(client *mongo.Client) *Category {
+	return &Category{coll: client.Database(configs.CatalogDBName).Collection(configs.CategoryCollName)}
+}
+
+// EnsureIndexes creates the unique name index among siblings and the index used to find the descendants
+// of a category
+func (c *Category) EnsureIndexes(ctx context.Context) error {
+	_, err := c.coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "parent_id", Value: 1}, {Key: "name_key", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "path", Value: 1}}},
+	})
+	return err
+}
+
+// Get returns a category by given category id
+func (c *Category) Get(ctx context.Context, categoryId string) (*models.Category, error) {
+	var category models.Category
+	if err := c.coll.FindOne(ctx, bson.M{"_id": categoryId}).Decode(&category); err == mongo.ErrNoDocuments {
+		return nil, ErrCategoryNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+// GetByNameKey returns a child of a category by its name key, an empty parent id is the root
+func (c *Category) GetByNameKey(ctx context.Context, parentId string, nameKey string) (*models.Category, error) {
+	var category models.Category
+	if err := c.coll.FindOne(ctx, bson.M{"parent_id": parentId, "name_key": nameKey}).Decode(&category); err == mongo.ErrNoDocuments {
+		return nil, ErrCategoryNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+// GetAll returns every category sorted by name
+func (c *Category) GetAll(ctx context.Context) (*[]models.Category, error) {
+	var categories []models.Category
+	fr, err := c.coll.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"name_key": 1}))
+	if err != nil {
+		return nil, err
+	}
+	if err = fr.All(ctx, &categories); err != nil {
+		return nil, err
+	}
+	return &categories, nil
+}
+
+// GetAllByIds returns the categories with the given ids
+func (c *Category) GetAllByIds(ctx context.Context, categoryIds []string) (*[]models.Category, error) {
+	var categories []models.Category
+	fr, err := c.coll.Find(ctx, bson.M{"_id": bson.M{"$in": categoryIds}})
+	if err != nil {
+		return nil, err
+	}
+	if err = fr.All(ctx, &categories); err != nil {
+		return nil, err
+	}
+	return &categories, nil
+}
+
+// GetAllDescendants returns the categories below a category
+func (c *Category) GetAllDescendants(ctx context.Context, categoryId string) (*[]models.Category, error) {
+	var categories []models.Category
+	fr, err := c.coll.Find(ctx, bson.M{"path": categoryId})
+	if err != nil {
+		return nil, err
+	}
+	if err = fr.All(ctx, &categories); err != nil {
+		return nil, err
+	}
+	return &categories, nil
+}
+
+// CountChildren returns how many categories are right below a category
+func (c *Category) CountChildren(ctx context.Context, categoryId string) (int64, error) {
+	return c.coll.CountDocuments(ctx, bson.M{"parent_id": categoryId})
+}
+
+// Add creates a new category
+func (c *Category) Add(ctx context.Context, payload models.Category) (string, error) {
+	if _, err := c.coll.InsertOne(ctx, payload); mongo.IsDuplicateKeyError(err) {
+		return "", ErrCategoryExists
+	} else if err != nil {
+		return "", err
+	}
+	return payload.Id, nil
+}
+
+// Update updates the name and place in the tree of a category
+func (c *Category) Update(ctx context.Context, category models.Category) error {
+	ur, err := c.coll.UpdateByID(ctx, category.Id, bson.M{"$set": bson.M{
+		"name":      category.Name,
+		"name_key":  category.NameKey,
+		"parent_id": category.ParentId,
+		"path":      category.Path,
+	}})
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrCategoryExists
+	} else if err != nil {
+		return err
+	}
+	if ur.MatchedCount == 0 {
+		return ErrCategoryNotFound
+	}
+	return nil
+}
+
+// SetPath sets the ancestors of a category, used when a category above it moves
+func (c *Category) SetPath(ctx context.Context, categoryId string, path []string) error {
+	ur, err := c.coll.UpdateByID(ctx, categoryId, bson.M{"$set": bson.M{"path": path}})
+	if err != nil {
+		return err
+	}
+	if ur.MatchedCount == 0 {
+		return ErrCategoryNotFound
+	}
+	return nil
+}
+
+// Delete deletes a category
+func (c *Category) Delete(ctx context.Context, categoryId string) error {
+	dr, err := c.coll.DeleteOne(ctx, bson.M{"_id": categoryId})
+	if err != nil {
+		return err
+	}
+	if dr.DeletedCount == 0 {
+		return ErrCategoryNotFound
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package repo
+
+import (
+	"context"
+	"errors"
+
+	"github.com/agustadewa/book-system/configs"
+	"github.com/agustadewa/book-system/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrCouponNotFound = errors.New("coupon not found")
+var ErrCouponExists = errors.New("coupon code already exists")
+var ErrCouponUsedUp = errors.New("coupon has reached its usage limit")
+var ErrCouponUserLimitReached = errors.New("coupon has reached its usage limit for the user")
+
+type Coupon struct {
+	coll  *mongo.Collection
+	usage *mongo.Collection
+}
+
+func NewCoupon(client *mongo.Client) *Coupon {
+	db := client.Database(configs.CouponDBName)
+	return &Coupon{coll: db.Collection(configs.CouponCollName), usage: db.Collection(configs.CouponUsageCollName)}
+}
+
+// EnsureIndexes creates the unique index of coupon codes
+func (c *Coupon) EnsureIndexes(ctx context.Context) error {
+	_, err := c.coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.M{"code": 1},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+// Get returns a coupon by given coupon id
+func (c *Coupon) Get(ctx context.Context, couponId string) (*models.Coupon, error) {
+	var coupon models.Coupon
+	if err := c.coll.FindOne(ctx, bson.M{"_id": couponId}).Decode(&coupon); err == mongo.ErrNoDocuments {
+		return nil, ErrCouponNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &coupon, nil
+}
+
+// GetByCode returns a coupon by given code
+func (c *Coupon) GetByCode(ctx context.Context, code string) (*models.Coupon, error) {
+	var coupon models.Coupon
+	if err := c.coll.FindOne(ctx, bson.M{"code": code}).Decode(&coupon); err == mongo.ErrNoDocuments {
+		return nil, ErrCouponNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &coupon, nil
+}
+
+// GetAll returns coupons sorted by newest
+func (c *Coupon) GetAll(ctx context.Context, limit int64) (*[]models.Coupon, error) {
+	var coupons []models.Coupon
+	fr, err := c.coll.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"created_at": -1}).SetLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	if err = fr.All(ctx, &coupons); err != nil {
+		return nil, err
+	}
+	return &coupons, nil
+}
+
+// GetAllWithNames returns coupons from before the catalogue entities, they are restricted to author and
+// category names
+func (c *Coupon) GetAllWithNames(ctx context.Context) (*[]models.Coupon, error) {
+	var coupons []models.Coupon
+	fr, err := c.coll.Find(ctx, bson.M{"$or": bson.A{
+		bson.M{"authors.0": bson.M{"$exists": true}},
+		bson.M{"categories.0": bson.M{"$exists": true}},
+	}})
+	if err != nil {
+		return nil, err
+	}
+	if err = fr.All(ctx, &coupons); err != nil {
+		return nil, err
+	}
+	return &coupons, nil
+}
+
+// SetEntities restricts a coupon to authors and categories by id in place of their names
+func (c *Coupon) SetEntities(ctx context.Context, couponId string, authorIds []string, categoryIds []string) error {
+	ur, err := c.coll.UpdateOne(ctx, bson.M{"_id": couponId}, bson.M{
+		"$set":   bson.M{"author_ids": authorIds, "category_ids": categoryIds},
+		"$unset": bson.M{"authors": "", "categories": ""},
+	})
+	if err != nil {
+		return err
+	}
+	if ur.MatchedCount == 0 {
+		return ErrCouponNotFound
+	}
+	return nil
+}
+
+// Add creates a new coupon
+func (c *Coupon) Add(ctx context.Context, payload models.Coupon) (string, error) {
+	if _, err := c.coll.InsertOne(ctx, payload); mongo.IsDuplicateKeyError(err) {
+		return "", ErrCouponExists
+	} else if err != nil {
+		return "", err
+	}
+
+	return payload.Id, nil
+}
+
+// Update replaces the terms of a coupon, the usage count is kept
+func (c *Coupon) Update(ctx context.Context, payload models.Coupon) error {
+	ur, err := c.coll.UpdateOne(ctx, bson.M{"_id": payload.Id}, bson.M{"$set": bson.M{
+		"code":           payload.Code,
+		"type":           payload.Type,
+		"value":          payload.Value,
+		"max_discount":   payload.MaxDiscount,
+		"min_spend":      payload.MinSpend,
+		"usage_limit":    payload.UsageLimit,
+		"per_user_limit": payload.PerUserLimit,
+		"valid_from":     payload.ValidFrom,
+		"valid_until":    payload.ValidUntil,
+		"book_ids":       payload.BookIds,
+		"author_ids":     payload.AuthorIds,
+		"category_ids":   payload.CategoryIds,
+		"active":         payload.Active,
+	}})
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrCouponExists
+	} else if err != nil {
+		return err
+	}
+	if ur.MatchedCount == 0 {
+		return ErrCouponNotFound
+	}
+	return nil
+}
+
+// Delete deletes a coupon
+func (c *Coupon) Delete(ctx context.Context, couponId string) error {
+	dr, err := c.coll.DeleteOne(ctx, bson.M{"_id": couponId})
+	if err != nil {
+		return err
+	}
+	if dr.DeletedCount == 0 {
+		return ErrCouponNotFound
+	}
+	return nil
+}
+
+// Redeem counts a use of a coupon by a user. Both counters are only incremented while they are under
+// their limits, so concurrent checkouts can't redeem a coupon more times than it allows.
+func (c *Coupon) Redeem(ctx context.Context, coupon *models.Coupon, userId string) error {
+	ur, err := c.coll.UpdateOne(ctx, bson.M{
+		"_id": coupon.Id,
+		"$expr": bson.M{"$or": bson.A{
+			bson.M{"$lte": bson.A{"$usage_limit", 0}},
+			bson.M{"$lt": bson.A{"$used_count", "$usage_limit"}},
+		}},
+	}, bson.M{"$inc": bson.M{"used_count": 1}})
+	if err != nil {
+		return err
+	}
+	if ur.MatchedCount == 0 {
+		return ErrCouponUsedUp
+	}
+
+	// a user at the limit doesn't match the filter, so the upsert tries to insert the same id and fails
+	filter := bson.M{"_id": couponUsageId(coupon.Id, userId)}
+	if coupon.PerUserLimit > 0 {
+		filter["count"] = bson.M{"$lt": coupon.PerUserLimit}
+	}
+	_, err = c.usage.UpdateOne(ctx, filter, bson.M{
+		"$inc":         bson.M{"count": 1},
+		"$setOnInsert": bson.M{"coupon_id": coupon.Id, "user_id": userId},
+	}, options.Update().SetUpsert(true))
+	if err == nil {
+		return nil
+	}
+
+	if _, rerr := c.coll.UpdateOne(ctx, bson.M{"_id": coupon.Id}, bson.M{"$inc": bson.M{"used_count": -1}}); rerr != nil {
+		return rerr
+	}
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrCouponUserLimitReached
+	}
+	return err
+}
+
+// Release gives back a use of a coupon by a user
+func (c *Coupon) Release(ctx context.Context, couponId string, userId string) error {
+	if _, err := c.coll.UpdateOne(ctx, bson.M{"_id": couponId, "used_count": bson.M{"$gt": 0}}, bson.M{"$inc": bson.M{"used_count": -1}}); err != nil {
+		return err
+	}
+	_, err := c.usage.UpdateOne(ctx, bson.M{"_id": couponUsageId(couponId, userId), "count": bson.M{"$gt": 0}}, bson.M{"$inc": bson.M{"count": -1}})
+	return err
+}
+
+func couponUsageId(couponId string, userId string) string {
+	return couponId + ":" + userId
+}
@@ -0,0 +1,57 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/agustadewa/book-system/configs"
+	"github.com/agustadewa/book-system/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrCouponRedemptionNotFound = errors.New("coupon redemption not found")
+
+type CouponRedemption struct {
+	coll *mongo.Collection
+}
+
+func NewCouponRedemption(client *mongo.Client) *CouponRedemption {
+	return &CouponRedemption{coll: client.Database(configs.CouponDBName).Collection(configs.CouponRedemptionCollName)}
+}
+
+// GetAllByCouponId returns the redemptions of a coupon sorted by newest
+func (c *CouponRedemption) GetAllByCouponId(ctx context.Context, couponId string, limit int64) (*[]models.CouponRedemption, error) {
+	var redemptions []models.CouponRedemption
+	fr, err := c.coll.Find(ctx, bson.M{"coupon_id": couponId}, options.Find().SetSort(bson.M{"created_at": -1}).SetLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	if err = fr.All(ctx, &redemptions); err != nil {
+		return nil, err
+	}
+	return &redemptions, nil
+}
+
+// Add creates a new coupon redemption, the id is the order id as an order redeems one coupon
+func (c *CouponRedemption) Add(ctx context.Context, payload models.CouponRedemption) error {
+	_, err := c.coll.InsertOne(ctx, payload)
+	return err
+}
+
+// SetReleased marks the redemption of an order released and returns it, only the first call finds it
+func (c *CouponRedemption) SetReleased(ctx context.Context, orderId string, releasedAt time.Time) (*models.CouponRedemption, error) {
+	var redemption models.CouponRedemption
+	err := c.coll.FindOneAndUpdate(ctx,
+		bson.M{"_id": orderId, "released": false},
+		bson.M{"$set": bson.M{"released": true, "released_at": releasedAt}},
+	).Decode(&redemption)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrCouponRedemptionNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &redemption, nil
+}
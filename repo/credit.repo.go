@@ -0,0 +1,48 @@
+package repo
+
+import (
+	"context"
+	"errors"
+
+	"github.com/agustadewa/book-system/configs"
+	"github.com/agustadewa/book-system/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrCreditExists = errors.New("credit already exists")
+
+type Credit struct {
+	coll *mongo.Collection
+}
+
+func NewCredit(client *mongo.Client) *Credit {
+	return &Credit{coll: client.Database(configs.CreditDBName).Collection(configs.CreditCollName)}
+}
+
+// GetAllByUserId returns credits by given user id, newest first
+func (cr *Credit) GetAllByUserId(ctx context.Context, userId string, limit int64) (*[]models.Credit, error) {
+	var credits []models.Credit
+	fr, err := cr.coll.Find(ctx, bson.M{"user_id": userId}, options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	if err = fr.All(ctx, &credits); err != nil {
+		return nil, err
+	}
+	return &credits, nil
+}
+
+// Add creates a new credit
+func (cr *Credit) Add(ctx context.Context, payload models.Credit) (string, error) {
+	if _, err := cr.coll.InsertOne(ctx, payload); mongo.IsDuplicateKeyError(err) {
+		return "", ErrCreditExists
+	} else if err != nil {
+		return "", err
+	}
+
+	return payload.Id, nil
+}
@@ -0,0 +1,48 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/agustadewa/book-system/configs"
+	"github.com/agustadewa/book-system/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type Download struct {
+	coll *mongo.Collection
+}
+
+func NewDownload(client *mongo.Client) *Download {
+	return &Download{coll: client.Database(configs.LibraryDBName).Collection(configs.DownloadCollName)}
+}
+
+// EnsureIndexes creates the index used to list the downloads of a library entry
+func (d *Download) EnsureIndexes(ctx context.Context) error {
+	_, err := d.coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "entry_id", Value: 1}, {Key: "created_at", Value: -1}},
+	})
+	return err
+}
+
+// GetAllByEntryId returns the downloads of a library entry, latest first
+func (d *Download) GetAllByEntryId(ctx context.Context, entryId string) (*[]models.Download, error) {
+	var downloads []models.Download
+	fr, err := d.coll.Find(ctx, bson.M{"entry_id": entryId}, options.Find().SetSort(bson.M{"created_at": -1}))
+	if err != nil {
+		return nil, err
+	}
+	if err = fr.All(ctx, &downloads); err != nil {
+		return nil, err
+	}
+	return &downloads, nil
+}
+
+// Add records a download
+func (d *Download) Add(ctx context.Context, payload models.Download) (string, error) {
+	if _, err := d.coll.InsertOne(ctx, payload); err != nil {
+		return "", err
+	}
+	return payload.Id, nil
+}
@@ -0,0 +1,76 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/agustadewa/book-system/configs"
+	"github.com/agustadewa/book-system/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrExchangeRateNotFound = errors.New("exchange rate not found")
+
+type ExchangeRate struct {
+	coll *mongo.Collection
+}
+
+func NewExchangeRate(client *mongo.Client) *ExchangeRate {
+	return &ExchangeRate{coll: client.Database(configs.ExchangeRateDBName).Collection(configs.ExchangeRateCollName)}
+}
+
+// EnsureIndexes creates the index used to find the rate of a currency pair at a time
+func (e *ExchangeRate) EnsureIndexes(ctx context.Context) error {
+	_, err := e.coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "base", Value: 1}, {Key: "quote", Value: 1}, {Key: "effective_from", Value: -1}},
+	})
+	return err
+}
+
+// GetEffective returns the rate of a currency pair in effect at the given time
+func (e *ExchangeRate) GetEffective(ctx context.Context, base string, quote string, at time.Time) (*models.ExchangeRate, error) {
+	var rate models.ExchangeRate
+	err := e.coll.FindOne(ctx,
+		bson.M{"base": base, "quote": quote, "effective_from": bson.M{"$lte": at}},
+		options.FindOne().SetSort(bson.D{{Key: "effective_from", Value: -1}, {Key: "created_at", Value: -1}}),
+	).Decode(&rate)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrExchangeRateNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &rate, nil
+}
+
+// GetAll returns the rates sorted by newest, empty base or quote matches any currency
+func (e *ExchangeRate) GetAll(ctx context.Context, base string, quote string, limit int64) (*[]models.ExchangeRate, error) {
+	filter := bson.M{}
+	if base != "" {
+		filter["base"] = base
+	}
+	if quote != "" {
+		filter["quote"] = quote
+	}
+
+	var rates []models.ExchangeRate
+	fr, err := e.coll.Find(ctx, filter, options.Find().SetSort(bson.M{"effective_from": -1}).SetLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	if err = fr.All(ctx, &rates); err != nil {
+		return nil, err
+	}
+	return &rates, nil
+}
+
+// Add creates a new exchange rate, rates are never edited so orders can always point to the rate they used
+func (e *ExchangeRate) Add(ctx context.Context, payload models.ExchangeRate) (string, error) {
+	if _, err := e.coll.InsertOne(ctx, payload); err != nil {
+		return "", err
+	}
+
+	return payload.Id, nil
+}
@@ -0,0 +1,125 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/agustadewa/book-system/configs"
+	"github.com/agustadewa/book-system/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrIdempotencyKeyNotFound = errors.New("idempotency key not found")
+var ErrIdempotencyKeyExists = errors.New("idempotency key already exists")
+
+type Idempotency struct {
+	coll *mongo.Collection
+}
+
+func NewIdempotency(client *mongo.Client) *Idempotency {
+	return &Idempotency{coll: client.Database(configs.IdempotencyDBName).Collection(configs.IdempotencyCollName)}
+}
+
+// EnsureIndexes creates the ttl index which removes expired keys
+func (i *Idempotency) EnsureIndexes(ctx context.Context) error {
+	_, err := i.coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.M{"expires_at": 1},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return err
+}
+
+// Get returns an idempotency key by given scope, caller and key
+func (i *Idempotency) Get(ctx context.Context, scope string, caller string, key string) (*models.IdempotencyKey, error) {
+	var idempotencyKey models.IdempotencyKey
+	if err := i.coll.FindOne(ctx, bson.M{"_id": idempotencyKeyId(scope, caller, key)}).Decode(&idempotencyKey); err == mongo.ErrNoDocuments {
+		return nil, ErrIdempotencyKeyNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &idempotencyKey, nil
+}
+
+// Reserve stores a new in-progress idempotency key held for the lease, it fails when the key is already used
+func (i *Idempotency) Reserve(ctx context.Context, scope string, caller string, key string, requestHash string, ttl time.Duration, lease time.Duration) error {
+	now := time.Now()
+	payload := models.IdempotencyKey{
+		Id:          idempotencyKeyId(scope, caller, key),
+		Scope:       scope,
+		Caller:      caller,
+		Key:         key,
+		RequestHash: requestHash,
+		CreatedAt:   now,
+		LockedUntil: now.Add(lease),
+		ExpiresAt:   now.Add(ttl),
+	}
+	if _, err := i.coll.InsertOne(ctx, payload); mongo.IsDuplicateKeyError(err) {
+		return ErrIdempotencyKeyExists
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// TakeOver reserves again an idempotency key which expired or whose request stopped before completing it,
+// it fails when the key is completed or still held by another request
+func (i *Idempotency) TakeOver(ctx context.Context, scope string, caller string, key string, requestHash string, ttl time.Duration, lease time.Duration) error {
+	now := time.Now()
+	ur, err := i.coll.UpdateOne(ctx,
+		bson.M{"_id": idempotencyKeyId(scope, caller, key), "$or": bson.A{
+			bson.M{"expires_at": bson.M{"$lt": now}},
+			bson.M{"completed": false, "locked_until": bson.M{"$lt": now}},
+		}},
+		bson.M{"$set": bson.M{
+			"request_hash": requestHash,
+			"completed":    false,
+			"status_code":  0,
+			"response":     nil,
+			"created_at":   now,
+			"locked_until": now.Add(lease),
+			"expires_at":   now.Add(ttl),
+		}},
+	)
+	if err != nil {
+		return err
+	}
+	if ur.MatchedCount == 0 {
+		return ErrIdempotencyKeyExists
+	}
+	return nil
+}
+
+// Complete stores the response of the request made with the idempotency key
+func (i *Idempotency) Complete(ctx context.Context, scope string, caller string, key string, statusCode int, response []byte) error {
+	ur, err := i.coll.UpdateByID(ctx, idempotencyKeyId(scope, caller, key), bson.M{"$set": bson.M{
+		"completed":   true,
+		"status_code": statusCode,
+		"response":    response,
+	}})
+	if err != nil {
+		return err
+	}
+	if ur.MatchedCount == 0 {
+		return ErrIdempotencyKeyNotFound
+	}
+	return nil
+}
+
+// Delete deletes an idempotency key
+func (i *Idempotency) Delete(ctx context.Context, scope string, caller string, key string) error {
+	dr, err := i.coll.DeleteOne(ctx, bson.M{"_id": idempotencyKeyId(scope, caller, key)})
+	if err != nil {
+		return err
+	}
+	if dr.DeletedCount == 0 {
+		return ErrIdempotencyKeyNotFound
+	}
+	return nil
+}
+
+func idempotencyKeyId(scope string, caller string, key string) string {
+	return scope + ":" + caller + ":" + key
+}
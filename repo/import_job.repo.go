@@ -0,0 +1,127 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/agustadewa/book-system/configs"
+	"github.com/agustadewa/book-system/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrImportJobNotFound = errors.New("import job not found")
+
+type ImportJob struct {
+	coll *mongo.Collection
+}
+
+func NewImportJob(client *mongo.Client) *ImportJob {
+	return &ImportJob{coll: client.Database(configs.ImportDBName).Collection(configs.ImportJobCollName)}
+}
+
+// EnsureIndexes creates the index used to pick the next queued job
+func (i *ImportJob) EnsureIndexes(ctx context.Context) error {
+	_, err := i.coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: 1}},
+	})
+	return err
+}
+
+// Get returns an import job by given job id
+func (i *ImportJob) Get(ctx context.Context, jobId string) (*models.ImportJob, error) {
+	var job models.ImportJob
+	if err := i.coll.FindOne(ctx, bson.M{"_id": jobId}).Decode(&job); err == mongo.ErrNoDocuments {
+		return nil, ErrImportJobNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// GetAll returns the import jobs sorted by newest
+func (i *ImportJob) GetAll(ctx context.Context, limit int64) (*[]models.ImportJob, error) {
+	var jobs []models.ImportJob
+	fr, err := i.coll.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"created_at": -1}).SetLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	if err = fr.All(ctx, &jobs); err != nil {
+		return nil, err
+	}
+	return &jobs, nil
+}
+
+// Add creates a new import job
+func (i *ImportJob) Add(ctx context.Context, payload models.ImportJob) (string, error) {
+	if _, err := i.coll.InsertOne(ctx, payload); err != nil {
+		return "", err
+	}
+	return payload.Id, nil
+}
+
+// Claim starts the oldest queued job and returns it, nil is returned when no job is queued
+func (i *ImportJob) Claim(ctx context.Context, now time.Time) (*models.ImportJob, error) {
+	var job models.ImportJob
+	err := i.coll.FindOneAndUpdate(ctx,
+		bson.M{"status": models.ImportQueued},
+		bson.M{"$set": bson.M{"status": models.ImportRunning, "started_at": now}},
+		options.FindOneAndUpdate().SetSort(bson.M{"created_at": 1}).SetReturnDocument(options.After),
+	).Decode(&job)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Requeue queues the jobs left running, e.g. by a restart, to be run again
+func (i *ImportJob) Requeue(ctx context.Context) error {
+	_, err := i.coll.UpdateMany(ctx, bson.M{"status": models.ImportRunning}, bson.M{
+		"$set":   bson.M{"status": models.ImportQueued},
+		"$unset": bson.M{"started_at": ""},
+	})
+	return err
+}
+
+// SetProgress sets the row counts of a running job
+func (i *ImportJob) SetProgress(ctx context.Context, job models.ImportJob) error {
+	ur, err := i.coll.UpdateByID(ctx, job.Id, bson.M{"$set": bson.M{
+		"rows":    job.Rows,
+		"created": job.Created,
+		"updated": job.Updated,
+		"skipped": job.Skipped,
+		"errors":  job.Errors,
+	}})
+	if err != nil {
+		return err
+	}
+	if ur.MatchedCount == 0 {
+		return ErrImportJobNotFound
+	}
+	return nil
+}
+
+// SetFinished ends a job as done or failed with its final row counts
+func (i *ImportJob) SetFinished(ctx context.Context, job models.ImportJob, status models.ImportJobStatus, jobErr string, now time.Time) error {
+	ur, err := i.coll.UpdateByID(ctx, job.Id, bson.M{"$set": bson.M{
+		"status":      status,
+		"error":       jobErr,
+		"rows":        job.Rows,
+		"created":     job.Created,
+		"updated":     job.Updated,
+		"skipped":     job.Skipped,
+		"errors":      job.Errors,
+		"finished_at": now,
+	}})
+	if err != nil {
+		return err
+	}
+	if ur.MatchedCount == 0 {
+		return ErrImportJobNotFound
+	}
+	return nil
+}
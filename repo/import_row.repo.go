@@ -0,0 +1,64 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/agustadewa/book-system/configs"
+	"github.com/agustadewa/book-system/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ImportRow struct {
+	coll *mongo.Collection
+}
+
+func NewImportRow(client *mongo.Client) *ImportRow {
+	return &ImportRow{coll: client.Database(configs.ImportDBName).Collection(configs.ImportRowCollName)}
+}
+
+// EnsureIndexes creates the index used to read the report of a job in row order
+func (i *ImportRow) EnsureIndexes(ctx context.Context) error {
+	_, err := i.coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "job_id", Value: 1}, {Key: "row", Value: 1}},
+	})
+	return err
+}
+
+// GetAllByJobId returns the rows of an import job in row order, a non empty status filters them
+func (i *ImportRow) GetAllByJobId(ctx context.Context, jobId string, status models.ImportRowStatus, skip int64, limit int64) (*[]models.ImportRow, error) {
+	filter := bson.M{"job_id": jobId}
+	if status != "" {
+		filter["status"] = status
+	}
+
+	var rows []models.ImportRow
+	fr, err := i.coll.Find(ctx, filter, options.Find().SetSort(bson.M{"row": 1}).SetSkip(skip).SetLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	if err = fr.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+	return &rows, nil
+}
+
+// AddMany saves a batch of row results
+func (i *ImportRow) AddMany(ctx context.Context, rows []models.ImportRow) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	docs := make([]interface{}, 0, len(rows))
+	for _, row := range rows {
+		docs = append(docs, row)
+	}
+	_, err := i.coll.InsertMany(ctx, docs)
+	return err
+}
+
+// DeleteByJobId deletes the row results of a job, a job run again starts its report over
+func (i *ImportRow) DeleteByJobId(ctx context.Context, jobId string) error {
+	_, err := i.coll.DeleteMany(ctx, bson.M{"job_id": jobId})
+	return err
+}
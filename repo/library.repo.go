@@ -0,0 +1,96 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/agustadewa/book-system/configs"
+	"github.com/agustadewa/book-system/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrLibraryEntryNotFound = errors.New("library entry not found")
+var ErrLibraryEntryExists = errors.New("order is already in the library")
+var ErrDownloadLimitReached = errors.New("download limit is reached")
+var ErrLibraryEntryRevoked = errors.New("library entry is revoked, its order was refunded")
+
+type Library struct {
+	coll *mongo.Collection
+}
+
+func NewLibrary(client *mongo.Client) *Library {
+	return &Library{coll: client.Database(configs.LibraryDBName).Collection(configs.LibraryCollName)}
+}
+
+// EnsureIndexes creates the unique order index, an order is granted once, and the index used to list a
+// customer library
+func (l *Library) EnsureIndexes(ctx context.Context) error {
+	_, err := l.coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "order_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}}},
+	})
+	return err
+}
+
+// Get returns a library entry by given entry id
+func (l *Library) Get(ctx context.Context, entryId string) (*models.LibraryEntry, error) {
+	var entry models.LibraryEntry
+	if err := l.coll.FindOne(ctx, bson.M{"_id": entryId}).Decode(&entry); err == mongo.ErrNoDocuments {
+		return nil, ErrLibraryEntryNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// GetAllByUserId returns the library of a customer, latest purchase first
+func (l *Library) GetAllByUserId(ctx context.Context, userId string) (*[]models.LibraryEntry, error) {
+	var entries []models.LibraryEntry
+	fr, err := l.coll.Find(ctx, bson.M{"user_id": userId}, options.Find().SetSort(bson.M{"created_at": -1}))
+	if err != nil {
+		return nil, err
+	}
+	if err = fr.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+	return &entries, nil
+}
+
+// Add creates a new library entry, it fails when the order is already granted
+func (l *Library) Add(ctx context.Context, payload models.LibraryEntry) (string, error) {
+	if _, err := l.coll.InsertOne(ctx, payload); mongo.IsDuplicateKeyError(err) {
+		return "", ErrLibraryEntryExists
+	} else if err != nil {
+		return "", err
+	}
+	return payload.Id, nil
+}
+
+// SetRevokedByOrderId revokes the library entry of an order, an order without an entry or with a revoked one
+// is left alone
+func (l *Library) SetRevokedByOrderId(ctx context.Context, orderId string, at time.Time) error {
+	_, err := l.coll.UpdateOne(ctx,
+		bson.M{"order_id": orderId, "revoked_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revoked_at": at}},
+	)
+	return err
+}
+
+// IncDownloads counts a download of a library entry, it fails when the entry is out of downloads
+func (l *Library) IncDownloads(ctx context.Context, entryId string, at time.Time) (*models.LibraryEntry, error) {
+	var entry models.LibraryEntry
+	err := l.coll.FindOneAndUpdate(ctx,
+		bson.M{"_id": entryId, "$expr": bson.M{"$lt": bson.A{"$downloads", "$download_limit"}}},
+		bson.M{"$inc": bson.M{"downloads": 1}, "$set": bson.M{"last_download_at": at}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&entry)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrDownloadLimitReached
+	} else if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
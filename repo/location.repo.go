@@ -0,0 +1,76 @@
+package repo
+
+import (
+	"context"
+	"errors"
+
+	"github.com/agustadewa/book-system/configs"
+	"github.com/agustadewa/book-system/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrLocationNotFound = errors.New("location not found")
+var ErrLocationExists = errors.New("location already exists")
+
+type Location struct {
+	coll *mongo.Collection
+}
+
+func NewLocation(client *mongo.Client) *Location {
+	return &Location{coll: client.Database(configs.LocationDBName).Collection(configs.LocationCollName)}
+}
+
+// Get returns a location by given location id
+func (l *Location) Get(ctx context.Context, locationId string) (*models.Location, error) {
+	var location models.Location
+	if err := l.coll.FindOne(ctx, bson.M{"_id": locationId}).Decode(&location); err == mongo.ErrNoDocuments {
+		return nil, ErrLocationNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &location, nil
+}
+
+// GetAll returns every location sorted by priority
+func (l *Location) GetAll(ctx context.Context) (*[]models.Location, error) {
+	var locations []models.Location
+	fr, err := l.coll.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"priority": 1}))
+	if err != nil {
+		return nil, err
+	}
+	if err = fr.All(ctx, &locations); err != nil {
+		return nil, err
+	}
+	return &locations, nil
+}
+
+// Add creates a new location
+func (l *Location) Add(ctx context.Context, payload models.Location) (string, error) {
+	if _, err := l.coll.InsertOne(ctx, payload); mongo.IsDuplicateKeyError(err) {
+		return "", ErrLocationExists
+	} else if err != nil {
+		return "", err
+	}
+
+	return payload.Id, nil
+}
+
+// Update updates a location
+func (l *Location) Update(ctx context.Context, payload models.Location) error {
+	ur, err := l.coll.UpdateOne(ctx, bson.M{"_id": payload.Id}, bson.M{"$set": bson.M{
+		"name":        payload.Name,
+		"type":        payload.Type,
+		"sellable":    payload.Sellable,
+		"priority":    payload.Priority,
+		"coordinates": payload.Coordinates,
+	}})
+	if err != nil {
+		return err
+	}
+	if ur.MatchedCount == 0 {
+		return ErrLocationNotFound
+	}
+	return nil
+}
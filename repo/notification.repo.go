@@ -0,0 +1,125 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/agustadewa/book-system/configs"
+	"github.com/agustadewa/book-system/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrNotificationNotFound = errors.New("notification not found")
+var ErrNotificationExists = errors.New("notification already exists")
+
+type Notification struct {
+	coll *mongo.Collection
+}
+
+func NewNotification(client *mongo.Client) *Notification {
+	return &Notification{coll: client.Database(configs.NotificationDBName).Collection(configs.NotificationCollName)}
+}
+
+// EnsureIndexes creates the unique index which allows one open notification per key and the indexes used to
+// list the notifications of a team and of a customer
+func (n *Notification) EnsureIndexes(ctx context.Context) error {
+	_, err := n.coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "key", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"resolved": false}),
+		},
+		{
+			Keys: bson.D{{Key: "team", Value: 1}, {Key: "created_at", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}},
+		},
+	})
+	return err
+}
+
+// GetAll returns the notifications of a team sorted by newest, unread only returns the notifications the
+// admin hasn't read
+func (n *Notification) GetAll(ctx context.Context, team string, unreadBy string, limit int64) (*[]models.Notification, error) {
+	filter := bson.M{"team": team}
+	if unreadBy != "" {
+		filter["read_by"] = bson.M{"$ne": unreadBy}
+	}
+
+	var notifications []models.Notification
+	fr, err := n.coll.Find(ctx, filter, options.Find().SetSort(bson.M{"created_at": -1}).SetLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	if err = fr.All(ctx, &notifications); err != nil {
+		return nil, err
+	}
+	return &notifications, nil
+}
+
+// GetAllByUserId returns the notifications of a customer sorted by newest
+func (n *Notification) GetAllByUserId(ctx context.Context, userId string, limit int64) (*[]models.Notification, error) {
+	var notifications []models.Notification
+	fr, err := n.coll.Find(ctx, bson.M{"user_id": userId}, options.Find().SetSort(bson.M{"created_at": -1}).SetLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	if err = fr.All(ctx, &notifications); err != nil {
+		return nil, err
+	}
+	return &notifications, nil
+}
+
+// GetAllOpenByType returns the notifications of a type which aren't resolved
+func (n *Notification) GetAllOpenByType(ctx context.Context, notificationType models.NotificationType) (*[]models.Notification, error) {
+	var notifications []models.Notification
+	fr, err := n.coll.Find(ctx, bson.M{"type": notificationType, "resolved": false})
+	if err != nil {
+		return nil, err
+	}
+	if err = fr.All(ctx, &notifications); err != nil {
+		return nil, err
+	}
+	return &notifications, nil
+}
+
+// Add creates a new notification, it fails when a notification with the same key is still open
+func (n *Notification) Add(ctx context.Context, payload models.Notification) (string, error) {
+	if _, err := n.coll.InsertOne(ctx, payload); mongo.IsDuplicateKeyError(err) {
+		return "", ErrNotificationExists
+	} else if err != nil {
+		return "", err
+	}
+
+	return payload.Id, nil
+}
+
+// SetRead marks a notification as read by an admin
+func (n *Notification) SetRead(ctx context.Context, notificationId string, adminId string) error {
+	ur, err := n.coll.UpdateByID(ctx, notificationId, bson.M{"$addToSet": bson.M{"read_by": adminId}})
+	if err != nil {
+		return err
+	}
+	if ur.MatchedCount == 0 {
+		return ErrNotificationNotFound
+	}
+	return nil
+}
+
+// SetResolved closes a notification, a new one with the same key can be added afterwards
+func (n *Notification) SetResolved(ctx context.Context, notificationId string) error {
+	ur, err := n.coll.UpdateOne(ctx, bson.M{"_id": notificationId, "resolved": false}, bson.M{"$set": bson.M{
+		"resolved":    true,
+		"resolved_at": time.Now(),
+	}})
+	if err != nil {
+		return err
+	}
+	if ur.MatchedCount == 0 {
+		return ErrNotificationNotFound
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package repo
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/agustadewa/book-system/configs"
 	"github.com/agustadewa/book-system/models"
@@ -13,6 +14,11 @@ import (
 
 var ErrOrderNotFound = errors.New("order not found")
 var ErrOrderExists = errors.New("order already exists")
+var ErrOrderNotAwaitingPayment = errors.New("order isn't waiting for payment")
+var ErrOrderNotAwaitingStock = errors.New("order isn't waiting for stock")
+var ErrOrderStatusChanged = errors.New("order status has been changed")
+
+var awaitingStockStatuses = []models.OrderStatus{models.Preordered, models.Backordered}
 
 type Order struct {
 	coll *mongo.Collection
@@ -115,6 +121,135 @@ func (o *Order) UpdateStatus(ctx context.Context, orderId string, orderStatus mo
 	return nil
 }
 
+// UpdateStatusFrom updates order status by given order id only when the current status is still from
+func (o *Order) UpdateStatusFrom(ctx context.Context, orderId string, from models.OrderStatus, to models.OrderStatus) error {
+	ur, err := o.coll.UpdateOne(ctx, bson.M{"_id": orderId, "status": from.String()}, bson.M{"$set": bson.M{"status": to.String()}})
+	if err != nil {
+		return err
+	}
+	if ur.MatchedCount == 0 {
+		return ErrOrderStatusChanged
+	}
+	return nil
+}
+
+// AddPaidAmount increments the paid amount of an order accepting payment by a payment and returns the
+// updated order. A payment added before isn't added again, the order is returned as it is.
+func (o *Order) AddPaidAmount(ctx context.Context, orderId string, paymentId string, amount int64) (*models.Order, error) {
+	var order models.Order
+	err := o.coll.FindOneAndUpdate(ctx,
+		bson.M{
+			"_id":         orderId,
+			"status":      bson.M{"$in": []models.OrderStatus{models.WaitingForPayment, models.Preordered, models.Backordered}},
+			"payment_ids": bson.M{"$ne": paymentId},
+		},
+		bson.M{"$inc": bson.M{"paid_amount": amount}, "$addToSet": bson.M{"payment_ids": paymentId}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&order)
+	if err == mongo.ErrNoDocuments {
+		current, err := o.Get(ctx, orderId)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range current.PaymentIds {
+			if id == paymentId {
+				return current, nil
+			}
+		}
+		return nil, ErrOrderNotAwaitingPayment
+	} else if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// GetAllAwaitingStock returns the pre-orders and back-orders of a book in order time sequence
+func (o *Order) GetAllAwaitingStock(ctx context.Context, bookId string) (*[]models.Order, error) {
+	var orders []models.Order
+	fr, err := o.coll.Find(ctx,
+		bson.M{"book_id": bookId, "status": bson.M{"$in": awaitingStockStatuses}},
+		options.Find().SetSort(bson.M{"order_time": 1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err = fr.All(ctx, &orders); err != nil {
+		return nil, err
+	}
+	return &orders, nil
+}
+
+// GetAllBookIdsAwaitingStock returns the ids of the books with pre-orders or back-orders
+func (o *Order) GetAllBookIdsAwaitingStock(ctx context.Context) ([]string, error) {
+	values, err := o.coll.Distinct(ctx, "book_id", bson.M{"status": bson.M{"$in": awaitingStockStatuses}})
+	if err != nil {
+		return nil, err
+	}
+	bookIds := make([]string, 0, len(values))
+	for _, value := range values {
+		if bookId, ok := value.(string); ok {
+			bookIds = append(bookIds, bookId)
+		}
+	}
+	return bookIds, nil
+}
+
+// QtyAwaitingStock sums the books the pre-orders and back-orders of a book wait for
+func (o *Order) QtyAwaitingStock(ctx context.Context, bookId string) (int64, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"book_id": bookId, "status": bson.M{"$in": awaitingStockStatuses}}}},
+		{{Key: "$group", Value: bson.M{"_id": nil, "qty": bson.M{"$sum": "$qty"}}}},
+	}
+
+	var sums []struct {
+		Qty int64 `bson:"qty"`
+	}
+	ar, err := o.coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return 0, err
+	}
+	if err = ar.All(ctx, &sums); err != nil {
+		return 0, err
+	}
+	if len(sums) == 0 {
+		return 0, nil
+	}
+	return sums[0].Qty, nil
+}
+
+// SetAllocated gives a pre-order or back-order its books, it fails when the order was called off meanwhile
+func (o *Order) SetAllocated(ctx context.Context, orderId string, status models.OrderStatus, locationId string, at time.Time) error {
+	ur, err := o.coll.UpdateOne(ctx,
+		bson.M{"_id": orderId, "status": bson.M{"$in": awaitingStockStatuses}},
+		bson.M{"$set": bson.M{"status": status, "location_id": locationId, "allocated_at": at}},
+	)
+	if err != nil {
+		return err
+	}
+	if ur.MatchedCount == 0 {
+		return ErrOrderNotAwaitingStock
+	}
+	return nil
+}
+
+// AddRefundedAmount increments the refunded amount of an order by a refund and returns the updated order, a
+// refund added before isn't added again
+func (o *Order) AddRefundedAmount(ctx context.Context, orderId string, refundId string, amount int64) (*models.Order, error) {
+	var order models.Order
+	err := o.coll.FindOneAndUpdate(ctx,
+		bson.M{"_id": orderId, "refund_ids": bson.M{"$ne": refundId}},
+		bson.M{"$inc": bson.M{"refunded_amount": amount}, "$addToSet": bson.M{"refund_ids": refundId}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&order)
+	if err == mongo.ErrNoDocuments {
+		// added before, or no such order
+		return o.Get(ctx, orderId)
+	} else if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
 // Delete deletes an order
 func (o *Order) Delete(ctx context.Context, orderId string) error {
 	dr, err := o.coll.DeleteOne(ctx, bson.M{"_id": orderId})
@@ -138,3 +273,94 @@ func (o *Order) DeleteMany(ctx context.Context, orderIds []string) error {
 	}
 	return nil
 }
+
+// TaxReport sums the tax lines of paid orders placed in [from, to) per period, currency, region and rate.
+// periodFormat is the $dateToString format of a period in UTC, %Y-%m groups by month. Order times are
+// compared as times, they are stored with the offset of the server which placed them. Refunds give back
+// their share of the books and their tax, shipping isn't refunded. Refunded orders are left out and partially
+// refunded ones count what was kept.
+func (o *Order) TaxReport(ctx context.Context, from time.Time, to time.Time, periodFormat string) (*[]models.TaxReportRow, error) {
+	statuses := []models.OrderStatus{models.Paid, models.OnShipping, models.Delivered, models.PartiallyRefunded}
+	// the books total of models.Order ItemsTotal, the total less shipping
+	itemsTotal := bson.M{"$subtract": bson.A{"$total_price", bson.M{"$ifNull": bson.A{"$shipping_fee", 0}}}}
+	kept := bson.M{"$cond": bson.A{
+		bson.M{"$gt": bson.A{itemsTotal, 0}},
+		bson.M{"$max": bson.A{0, bson.M{"$subtract": bson.A{1, bson.M{"$divide": bson.A{bson.M{"$ifNull": bson.A{"$refunded_amount", 0}}, itemsTotal}}}}}},
+		1,
+	}}
+	keptAmount := func(field string) bson.M {
+		return bson.M{"$toLong": bson.M{"$round": bson.A{bson.M{"$multiply": bson.A{field, "$kept"}}, 0}}}
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"status": bson.M{"$in": statuses}}}},
+		{{Key: "$addFields", Value: bson.M{
+			"ordered_at": bson.M{"$dateFromString": bson.M{"dateString": "$order_time"}},
+			"kept":       kept,
+		}}},
+		{{Key: "$match", Value: bson.M{"ordered_at": bson.M{"$gte": from, "$lt": to}}}},
+		{{Key: "$unwind", Value: "$tax_lines"}},
+		{{Key: "$group", Value: bson.M{
+			"_id": bson.M{
+				"period":      bson.M{"$dateToString": bson.M{"date": "$ordered_at", "format": periodFormat}},
+				"currency":    bson.M{"$ifNull": bson.A{"$currency", configs.DefaultCurrency}},
+				"region_id":   "$tax_lines.region_id",
+				"region_name": "$tax_lines.region_name",
+				"tax_class":   "$tax_lines.tax_class",
+				"rate_bps":    "$tax_lines.rate_bps",
+			},
+			"taxable_amount": bson.M{"$sum": keptAmount("$tax_lines.taxable_amount")},
+			"tax_amount":     bson.M{"$sum": keptAmount("$tax_lines.amount")},
+			"orders":         bson.M{"$sum": 1},
+		}}},
+		{{Key: "$project", Value: bson.M{
+			"_id":            0,
+			"period":         "$_id.period",
+			"currency":       "$_id.currency",
+			"region_id":      "$_id.region_id",
+			"region_name":    "$_id.region_name",
+			"tax_class":      "$_id.tax_class",
+			"rate_bps":       "$_id.rate_bps",
+			"taxable_amount": 1,
+			"tax_amount":     1,
+			"orders":         1,
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "period", Value: 1}, {Key: "region_name", Value: 1}, {Key: "rate_bps", Value: -1}}}},
+	}
+
+	var rows []models.TaxReportRow
+	ar, err := o.coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	if err = ar.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+	return &rows, nil
+}
+
+// SalesByBook sums the copies of every book sold in orders placed since the given time
+func (o *Order) SalesByBook(ctx context.Context, since time.Time) ([]models.BookSales, error) {
+	statuses := []models.OrderStatus{models.Paid, models.OnShipping, models.Delivered, models.PartiallyRefunded}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"status": bson.M{"$in": statuses}}}},
+		{{Key: "$addFields", Value: bson.M{
+			"ordered_at": bson.M{"$dateFromString": bson.M{"dateString": "$order_time"}},
+		}}},
+		{{Key: "$match", Value: bson.M{"ordered_at": bson.M{"$gte": since}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":    "$book_id",
+			"qty":    bson.M{"$sum": "$qty"},
+			"orders": bson.M{"$sum": 1},
+		}}},
+	}
+
+	var sales []models.BookSales
+	ar, err := o.coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	if err = ar.All(ctx, &sales); err != nil {
+		return nil, err
+	}
+	return sales, nil
+}
@@ -8,10 +8,12 @@ import (
 	"github.com/agustadewa/book-system/models"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 var ErrPaymentNotFound = errors.New("payment not found")
 var ErrPaymentExists = errors.New("payment already exists")
+var ErrPaymentStatusChanged = errors.New("payment status has been changed")
 
 type Payment struct {
 	coll *mongo.Collection
@@ -51,6 +53,42 @@ func (p *Payment) GetByOrderId(ctx context.Context, orderId string) (*models.Pay
 	}
 }
 
+// GetByProviderRef returns a payment by given provider and provider reference
+func (p *Payment) GetByProviderRef(ctx context.Context, provider string, providerRef string) (*models.Payment, error) {
+	var payment models.Payment
+	if err := p.coll.FindOne(ctx, bson.M{"provider": provider, "provider_ref": providerRef}).Decode(&payment); err == mongo.ErrNoDocuments {
+		return nil, ErrPaymentNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// GetByOrderIdAndInProgress returns a payment by given order id which the provider hasn't settled yet
+func (p *Payment) GetByOrderIdAndInProgress(ctx context.Context, orderId string) (*models.Payment, error) {
+	var payment models.Payment
+	filter := bson.M{"order_id": orderId, "status": bson.M{"$in": []string{models.PaymentPending.String(), models.PaymentPendingReview.String()}}}
+	if err := p.coll.FindOne(ctx, filter).Decode(&payment); err == mongo.ErrNoDocuments {
+		return nil, ErrPaymentNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// GetAllByOrderId returns payments by given order id
+func (p *Payment) GetAllByOrderId(ctx context.Context, orderId string) (*[]models.Payment, error) {
+	var payments []models.Payment
+	fr, err := p.coll.Find(ctx, bson.M{"order_id": orderId}, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	if err = fr.All(ctx, &payments); err != nil {
+		return nil, err
+	}
+	return &payments, nil
+}
+
 // Add creates a new payment
 func (p *Payment) Add(ctx context.Context, payload models.Payment) (string, error) {
 	if _, err := p.coll.InsertOne(ctx, payload); err != nil {
@@ -60,6 +98,98 @@ func (p *Payment) Add(ctx context.Context, payload models.Payment) (string, erro
 	return payload.Id, nil
 }
 
+// UpdateStatus updates payment status by given payment id
+func (p *Payment) UpdateStatus(ctx context.Context, paymentId string, paymentStatus models.PaymentStatus) error {
+	ur, err := p.coll.UpdateByID(ctx, paymentId, bson.M{"$set": bson.M{"status": paymentStatus.String()}})
+	if err != nil {
+		return err
+	}
+	if ur.MatchedCount == 0 {
+		return ErrPaymentNotFound
+	}
+	return nil
+}
+
+// UpdateStatusFrom updates payment status by given payment id only when the current status is still from
+func (p *Payment) UpdateStatusFrom(ctx context.Context, paymentId string, from models.PaymentStatus, to models.PaymentStatus) error {
+	ur, err := p.coll.UpdateOne(ctx, bson.M{"_id": paymentId, "status": from.String()}, bson.M{"$set": bson.M{"status": to.String()}})
+	if err != nil {
+		return err
+	}
+	if ur.MatchedCount == 0 {
+		return ErrPaymentStatusChanged
+	}
+	return nil
+}
+
+// SetReceipt stores the uploaded receipt of a pending payment and updates its status
+func (p *Payment) SetReceipt(ctx context.Context, paymentId string, receipt models.Receipt, status models.PaymentStatus) error {
+	ur, err := p.coll.UpdateOne(ctx, bson.M{"_id": paymentId, "status": models.PaymentPending.String()}, bson.M{"$set": bson.M{"receipt": receipt, "status": status.String()}})
+	if err != nil {
+		return err
+	}
+	if ur.MatchedCount == 0 {
+		return ErrPaymentStatusChanged
+	}
+	return nil
+}
+
+// SetReview stores the admin review of a payment waiting for review
+func (p *Payment) SetReview(ctx context.Context, paymentId string, review models.PaymentReview) error {
+	ur, err := p.coll.UpdateOne(ctx, bson.M{"_id": paymentId, "status": models.PaymentPendingReview.String()}, bson.M{"$set": bson.M{"review": review}})
+	if err != nil {
+		return err
+	}
+	if ur.MatchedCount == 0 {
+		return ErrPaymentStatusChanged
+	}
+	return nil
+}
+
+// GetAllPendingReview returns payments waiting for review, oldest receipt first
+func (p *Payment) GetAllPendingReview(ctx context.Context, limit int64) (*[]models.Payment, error) {
+	var payments []models.Payment
+	fr, err := p.coll.Find(ctx, bson.M{"status": models.PaymentPendingReview.String()}, options.Find().
+		SetSort(bson.D{{Key: "receipt.uploaded_at", Value: 1}}).
+		SetLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	if err = fr.All(ctx, &payments); err != nil {
+		return nil, err
+	}
+	return &payments, nil
+}
+
+// SetAllocation stores how much of a succeeded payment went to the order and how much became credit
+func (p *Payment) SetAllocation(ctx context.Context, paymentId string, applied int64, credited int64) error {
+	ur, err := p.coll.UpdateByID(ctx, paymentId, bson.M{"$set": bson.M{"applied": applied, "credited": credited}})
+	if err != nil {
+		return err
+	}
+	if ur.MatchedCount == 0 {
+		return ErrPaymentNotFound
+	}
+	return nil
+}
+
+// AddRefunded increments the refunded amount of a payment by a refund, a refund added before isn't added
+// again
+func (p *Payment) AddRefunded(ctx context.Context, paymentId string, refundId string, amount int64) error {
+	ur, err := p.coll.UpdateOne(ctx,
+		bson.M{"_id": paymentId, "refund_ids": bson.M{"$ne": refundId}},
+		bson.M{"$inc": bson.M{"refunded": amount}, "$addToSet": bson.M{"refund_ids": refundId}},
+	)
+	if err != nil {
+		return err
+	}
+	if ur.MatchedCount == 0 {
+		_, err = p.Get(ctx, paymentId)
+		return err
+	}
+	return nil
+}
+
 // Delete deletes an a payment
 func (p *Payment) Delete(ctx context.Context, paymentId string) error {
 	dr, err := p.coll.DeleteOne(ctx, bson.M{"_id": paymentId})
@@ -0,0 +1,46 @@
+package repo
+
+import (
+	"context"
+	"errors"
+
+	"github.com/agustadewa/book-system/configs"
+	"github.com/agustadewa/book-system/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var ErrPaymentWebhookEventNotFound = errors.New("payment webhook event not found")
+var ErrPaymentWebhookEventExists = errors.New("payment webhook event already processed")
+
+type PaymentWebhook struct {
+	coll *mongo.Collection
+}
+
+func NewPaymentWebhook(client *mongo.Client) *PaymentWebhook {
+	return &PaymentWebhook{coll: client.Database(configs.PaymentWebhookDBName).Collection(configs.PaymentWebhookCollName)}
+}
+
+// Add stores a received event, it fails when the provider event id is already stored
+func (p *PaymentWebhook) Add(ctx context.Context, payload models.PaymentWebhookEvent) (string, error) {
+	payload.Id = payload.Provider + ":" + payload.EventId
+	if _, err := p.coll.InsertOne(ctx, payload); mongo.IsDuplicateKeyError(err) {
+		return "", ErrPaymentWebhookEventExists
+	} else if err != nil {
+		return "", err
+	}
+
+	return payload.Id, nil
+}
+
+// Delete deletes a received event so it can be processed again
+func (p *PaymentWebhook) Delete(ctx context.Context, eventId string) error {
+	dr, err := p.coll.DeleteOne(ctx, bson.M{"_id": eventId})
+	if err != nil {
+		return err
+	}
+	if dr.DeletedCount == 0 {
+		return ErrPaymentWebhookEventNotFound
+	}
+	return nil
+}
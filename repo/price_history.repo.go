@@ -0,0 +1,55 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/agustadewa/book-system/configs"
+	"github.com/agustadewa/book-system/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type PriceHistory struct {
+	coll *mongo.Collection
+}
+
+func NewPriceHistory(client *mongo.Client) *PriceHistory {
+	return &PriceHistory{coll: client.Database(configs.PriceDBName).Collection(configs.PriceHistoryCollName)}
+}
+
+// EnsureIndexes creates the index used to list the history of a book
+func (p *PriceHistory) EnsureIndexes(ctx context.Context) error {
+	_, err := p.coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "book_id", Value: 1}, {Key: "changed_at", Value: -1}},
+	})
+	return err
+}
+
+// GetAllByBookId returns the price history of a book sorted by newest
+func (p *PriceHistory) GetAllByBookId(ctx context.Context, bookId string, limit int64) (*[]models.PriceHistory, error) {
+	var history []models.PriceHistory
+	fr, err := p.coll.Find(ctx, bson.M{"book_id": bookId}, options.Find().SetSort(bson.M{"changed_at": -1}).SetLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	if err = fr.All(ctx, &history); err != nil {
+		return nil, err
+	}
+	return &history, nil
+}
+
+// Add creates a new price history entry
+func (p *PriceHistory) Add(ctx context.Context, payload models.PriceHistory) error {
+	_, err := p.coll.InsertOne(ctx, payload)
+	return err
+}
+
+// SetCurrency stamps the history entries of a book which have no currency yet with the given currency
+func (p *PriceHistory) SetCurrency(ctx context.Context, bookId string, currency string) error {
+	_, err := p.coll.UpdateMany(ctx,
+		bson.M{"book_id": bookId, "currency": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"currency": currency}},
+	)
+	return err
+}
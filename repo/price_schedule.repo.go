@@ -0,0 +1,138 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/agustadewa/book-system/configs"
+	"github.com/agustadewa/book-system/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrPriceScheduleNotFound = errors.New("price schedule not found")
+var ErrPriceScheduleStatusChanged = errors.New("price schedule status has changed")
+
+type PriceSchedule struct {
+	coll *mongo.Collection
+}
+
+func NewPriceSchedule(client *mongo.Client) *PriceSchedule {
+	return &PriceSchedule{coll: client.Database(configs.PriceDBName).Collection(configs.PriceScheduleCollName)}
+}
+
+// Get returns a price schedule by given schedule id
+func (p *PriceSchedule) Get(ctx context.Context, scheduleId string) (*models.PriceSchedule, error) {
+	var schedule models.PriceSchedule
+	if err := p.coll.FindOne(ctx, bson.M{"_id": scheduleId}).Decode(&schedule); err == mongo.ErrNoDocuments {
+		return nil, ErrPriceScheduleNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// GetAllByBookId returns the price schedules of a book sorted by start time
+func (p *PriceSchedule) GetAllByBookId(ctx context.Context, bookId string) (*[]models.PriceSchedule, error) {
+	return p.find(ctx, bson.M{"book_id": bookId}, options.Find().SetSort(bson.M{"starts_at": 1}))
+}
+
+// GetAllOpenByBookId returns the schedules of a book which are scheduled or running
+func (p *PriceSchedule) GetAllOpenByBookId(ctx context.Context, bookId string) (*[]models.PriceSchedule, error) {
+	return p.find(ctx, bson.M{
+		"book_id": bookId,
+		"status":  bson.M{"$in": []models.PriceScheduleStatus{models.PriceScheduled, models.PriceScheduleActive}},
+	}, options.Find())
+}
+
+// GetAllOverlapping returns the open schedules of a book running at any time in [startsAt, endsAt),
+// a nil endsAt is open ended
+func (p *PriceSchedule) GetAllOverlapping(ctx context.Context, bookId string, startsAt time.Time, endsAt *time.Time) (*[]models.PriceSchedule, error) {
+	filter := bson.M{
+		"book_id": bookId,
+		"status":  bson.M{"$in": []models.PriceScheduleStatus{models.PriceScheduled, models.PriceScheduleActive}},
+		"$or": bson.A{
+			bson.M{"ends_at": bson.M{"$exists": false}},
+			bson.M{"ends_at": bson.M{"$gt": startsAt}},
+		},
+	}
+	if endsAt != nil {
+		filter["starts_at"] = bson.M{"$lt": *endsAt}
+	}
+	return p.find(ctx, filter, options.Find())
+}
+
+// GetAllDueToStart returns the scheduled prices whose start time has passed
+func (p *PriceSchedule) GetAllDueToStart(ctx context.Context, now time.Time) (*[]models.PriceSchedule, error) {
+	return p.find(ctx, bson.M{
+		"status":    models.PriceScheduled,
+		"starts_at": bson.M{"$lte": now},
+	}, options.Find().SetSort(bson.M{"starts_at": 1}))
+}
+
+// GetAllDueToEnd returns the active prices whose end time has passed
+func (p *PriceSchedule) GetAllDueToEnd(ctx context.Context, now time.Time) (*[]models.PriceSchedule, error) {
+	return p.find(ctx, bson.M{
+		"status":  models.PriceScheduleActive,
+		"ends_at": bson.M{"$lte": now},
+	}, options.Find().SetSort(bson.M{"ends_at": 1}))
+}
+
+// Add creates a new price schedule
+func (p *PriceSchedule) Add(ctx context.Context, payload models.PriceSchedule) (string, error) {
+	if _, err := p.coll.InsertOne(ctx, payload); err != nil {
+		return "", err
+	}
+
+	return payload.Id, nil
+}
+
+// SetActivated marks a scheduled price active with the price it replaced
+func (p *PriceSchedule) SetActivated(ctx context.Context, scheduleId string, previousPrice int64, activatedAt time.Time) error {
+	return p.updateStatusFrom(ctx, scheduleId, models.PriceScheduled, bson.M{
+		"status":         models.PriceScheduleActive,
+		"previous_price": previousPrice,
+		"activated_at":   activatedAt,
+	})
+}
+
+// SetEnded marks an active price ended
+func (p *PriceSchedule) SetEnded(ctx context.Context, scheduleId string, endedAt time.Time) error {
+	return p.updateStatusFrom(ctx, scheduleId, models.PriceScheduleActive, bson.M{
+		"status":   models.PriceScheduleEnded,
+		"ended_at": endedAt,
+	})
+}
+
+// SetCancelled cancels a price schedule which hasn't started
+func (p *PriceSchedule) SetCancelled(ctx context.Context, scheduleId string, cancelledAt time.Time) error {
+	return p.updateStatusFrom(ctx, scheduleId, models.PriceScheduled, bson.M{
+		"status":   models.PriceScheduleCancelled,
+		"ended_at": cancelledAt,
+	})
+}
+
+func (p *PriceSchedule) updateStatusFrom(ctx context.Context, scheduleId string, from models.PriceScheduleStatus, set bson.M) error {
+	ur, err := p.coll.UpdateOne(ctx, bson.M{"_id": scheduleId, "status": from}, bson.M{"$set": set})
+	if err != nil {
+		return err
+	}
+	if ur.MatchedCount == 0 {
+		return ErrPriceScheduleStatusChanged
+	}
+	return nil
+}
+
+func (p *PriceSchedule) find(ctx context.Context, filter bson.M, opts *options.FindOptions) (*[]models.PriceSchedule, error) {
+	var schedules []models.PriceSchedule
+	fr, err := p.coll.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err = fr.All(ctx, &schedules); err != nil {
+		return nil, err
+	}
+	return &schedules, nil
+}
@@ -0,0 +1,114 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"regexp"
+
+	"github.com/agustadewa/book-system/configs"
+	"github.com/agustadewa/book-system/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrPublisherNotFound = errors.New("publisher not found")
+var ErrPublisherExists = errors.New("publisher already exists")
+
+type Publisher struct {
+	coll *mongo.Collection
+}
+
+func NewPublisher(client *mongo.Client) *Publisher {
+	return &Publisher{coll: client.Database(configs.CatalogDBName).Collection(configs.PublisherCollName)}
+}
+
+// EnsureIndexes creates the unique name index, publishers spelled differently are the same publisher
+func (p *Publisher) EnsureIndexes(ctx context.Context) error {
+	_, err := p.coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "name_key", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+// Get returns a publisher by given publisher id
+func (p *Publisher) Get(ctx context.Context, publisherId string) (*models.Publisher, error) {
+	var publisher models.Publisher
+	if err := p.coll.FindOne(ctx, bson.M{"_id": publisherId}).Decode(&publisher); err == mongo.ErrNoDocuments {
+		return nil, ErrPublisherNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &publisher, nil
+}
+
+// GetByNameKey returns a publisher by given name key
+func (p *Publisher) GetByNameKey(ctx context.Context, nameKey string) (*models.Publisher, error) {
+	var publisher models.Publisher
+	if err := p.coll.FindOne(ctx, bson.M{"name_key": nameKey}).Decode(&publisher); err == mongo.ErrNoDocuments {
+		return nil, ErrPublisherNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &publisher, nil
+}
+
+// GetAll returns publishers sorted by name, a non empty name key prefix filters them
+func (p *Publisher) GetAll(ctx context.Context, nameKeyPrefix string, limit int64) (*[]models.Publisher, error) {
+	filter := bson.M{}
+	if nameKeyPrefix != "" {
+		filter["name_key"] = primitive.Regex{Pattern: "^" + regexp.QuoteMeta(nameKeyPrefix)}
+	}
+
+	var publishers []models.Publisher
+	fr, err := p.coll.Find(ctx, filter, options.Find().SetSort(bson.M{"name_key": 1}).SetLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	if err = fr.All(ctx, &publishers); err != nil {
+		return nil, err
+	}
+	return &publishers, nil
+}
+
+// Add creates a new publisher
+func (p *Publisher) Add(ctx context.Context, payload models.Publisher) (string, error) {
+	if _, err := p.coll.InsertOne(ctx, payload); mongo.IsDuplicateKeyError(err) {
+		return "", ErrPublisherExists
+	} else if err != nil {
+		return "", err
+	}
+	return payload.Id, nil
+}
+
+// Update updates the name and website of a publisher
+func (p *Publisher) Update(ctx context.Context, publisher models.Publisher) error {
+	ur, err := p.coll.UpdateByID(ctx, publisher.Id, bson.M{"$set": bson.M{
+		"name":     publisher.Name,
+		"name_key": publisher.NameKey,
+		"website":  publisher.Website,
+	}})
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrPublisherExists
+	} else if err != nil {
+		return err
+	}
+	if ur.MatchedCount == 0 {
+		return ErrPublisherNotFound
+	}
+	return nil
+}
+
+// Delete deletes a publisher
+func (p *Publisher) Delete(ctx context.Context, publisherId string) error {
+	dr, err := p.coll.DeleteOne(ctx, bson.M{"_id": publisherId})
+	if err != nil {
+		return err
+	}
+	if dr.DeletedCount == 0 {
+		return ErrPublisherNotFound
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/agustadewa/book-system/configs"
+	"github.com/agustadewa/book-system/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrPurchaseOrderNotFound = errors.New("purchase order not found")
+var ErrPurchaseOrderClosed = errors.New("purchase order is received or cancelled")
+var ErrOverReceipt = errors.New("received quantity is greater than the outstanding quantity")
+
+var openPurchaseStatuses = []models.PurchaseOrderStatus{models.PurchaseOrdered, models.PurchasePartiallyReceived}
+
+type PurchaseOrder struct {
+	coll *mongo.Collection
+}
+
+func NewPurchaseOrder(client *mongo.Client) *PurchaseOrder {
+	return &PurchaseOrder{coll: client.Database(configs.PurchaseDBName).Collection(configs.PurchaseOrderCollName)}
+}
+
+// Get returns a purchase order by given purchase order id
+func (p *PurchaseOrder) Get(ctx context.Context, purchaseOrderId string) (*models.PurchaseOrder, error) {
+	var purchaseOrder models.PurchaseOrder
+	if err := p.coll.FindOne(ctx, bson.M{"_id": purchaseOrderId}).Decode(&purchaseOrder); err == mongo.ErrNoDocuments {
+		return nil, ErrPurchaseOrderNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &purchaseOrder, nil
+}
+
+// GetAll returns the purchase orders sorted by newest, empty supplier id or status don't filter
+func (p *PurchaseOrder) GetAll(ctx context.Context, supplierId string, status models.PurchaseOrderStatus, limit int64) (*[]models.PurchaseOrder, error) {
+	filter := bson.M{}
+	if supplierId != "" {
+		filter["supplier_id"] = supplierId
+	}
+	if status != "" {
+		filter["status"] = status
+	}
+
+	var purchaseOrders []models.PurchaseOrder
+	fr, err := p.coll.Find(ctx, filter, options.Find().SetSort(bson.M{"created_at": -1}).SetLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	if err = fr.All(ctx, &purchaseOrders); err != nil {
+		return nil, err
+	}
+	return &purchaseOrders, nil
+}
+
+// Add creates a new purchase order
+func (p *PurchaseOrder) Add(ctx context.Context, payload models.PurchaseOrder) (string, error) {
+	if _, err := p.coll.InsertOne(ctx, payload); err != nil {
+		return "", err
+	}
+
+	return payload.Id, nil
+}
+
+// IncReceived adds qty to the received quantity of a line of an open purchase order, it fails when more
+// than the outstanding quantity would be received. A negative qty takes a receipt back.
+func (p *PurchaseOrder) IncReceived(ctx context.Context, purchaseOrderId string, line models.PurchaseOrderLine, qty int64) error {
+	lineFilter := bson.M{"book_id": line.BookId}
+	if qty > 0 {
+		lineFilter["received_qty"] = bson.M{"$lte": line.Qty - qty}
+	}
+	ur, err := p.coll.UpdateOne(ctx,
+		bson.M{"_id": purchaseOrderId, "status": bson.M{"$in": openPurchaseStatuses}, "lines": bson.M{"$elemMatch": lineFilter}},
+		bson.M{"$inc": bson.M{"lines.$.received_qty": qty}},
+	)
+	if err != nil {
+		return err
+	}
+	if ur.MatchedCount == 0 {
+		return ErrOverReceipt
+	}
+	return nil
+}
+
+// AddReceipt records a goods receipt and sets the status from what is still outstanding on the purchase
+// order after the receipt. The lines are received before, so a closed order still gets the receipt.
+func (p *PurchaseOrder) AddReceipt(ctx context.Context, purchaseOrderId string, receipt models.GoodsReceipt) (*models.PurchaseOrder, error) {
+	var purchaseOrder models.PurchaseOrder
+	err := p.coll.FindOneAndUpdate(ctx, bson.M{"_id": purchaseOrderId}, bson.M{"$push": bson.M{"receipts": receipt}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&purchaseOrder)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrPurchaseOrderNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	status := models.PurchaseReceived
+	for _, line := range purchaseOrder.Lines {
+		if line.Outstanding() > 0 {
+			status = models.PurchasePartiallyReceived
+		}
+	}
+	set := bson.M{"status": status}
+	if !status.IsOpen() {
+		set["closed_at"] = receipt.ReceivedAt
+	}
+	// a receipt that closes the order wins over a later partial one, the status only moves forward
+	ur, err := p.coll.UpdateOne(ctx,
+		bson.M{"_id": purchaseOrderId, "status": bson.M{"$in": openPurchaseStatuses}},
+		bson.M{"$set": set},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if ur.MatchedCount == 0 {
+		return p.Get(ctx, purchaseOrderId)
+	}
+	purchaseOrder.Status = status
+	if !status.IsOpen() {
+		purchaseOrder.ClosedAt = &receipt.ReceivedAt
+	}
+	return &purchaseOrder, nil
+}
+
+// SetCancelled cancels an open purchase order, books received before stay in stock
+func (p *PurchaseOrder) SetCancelled(ctx context.Context, purchaseOrderId string) error {
+	ur, err := p.coll.UpdateOne(ctx,
+		bson.M{"_id": purchaseOrderId, "status": bson.M{"$in": openPurchaseStatuses}},
+		bson.M{"$set": bson.M{"status": models.PurchaseCancelled, "closed_at": time.Now()}},
+	)
+	if err != nil {
+		return err
+	}
+	if ur.MatchedCount == 0 {
+		return ErrPurchaseOrderClosed
+	}
+	return nil
+}
+
+// OutstandingBySupplier sums what is still expected on open purchase orders per supplier and currency,
+// orders expected before now are overdue
+func (p *PurchaseOrder) OutstandingBySupplier(ctx context.Context, now time.Time) ([]models.SupplierOutstanding, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"status": bson.M{"$in": openPurchaseStatuses}}}},
+		{{Key: "$unwind", Value: "$lines"}},
+		{{Key: "$addFields", Value: bson.M{
+			"outstanding": bson.M{"$subtract": bson.A{"$lines.qty", "$lines.received_qty"}},
+		}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":               bson.M{"supplier_id": "$supplier_id", "currency": "$currency"},
+			"orders":            bson.M{"$addToSet": "$_id"},
+			"overdue":           bson.M{"$addToSet": bson.M{"$cond": bson.A{bson.M{"$lt": bson.A{"$expected_at", now}}, "$_id", "$$REMOVE"}}},
+			"outstanding_qty":   bson.M{"$sum": "$outstanding"},
+			"outstanding_value": bson.M{"$sum": bson.M{"$multiply": bson.A{"$outstanding", "$lines.unit_cost"}}},
+			"next_expected_at":  bson.M{"$min": "$expected_at"},
+		}}},
+		{{Key: "$project", Value: bson.M{
+			"_id":               0,
+			"supplier_id":       "$_id.supplier_id",
+			"currency":          "$_id.currency",
+			"open_orders":       bson.M{"$size": "$orders"},
+			"overdue_orders":    bson.M{"$size": "$overdue"},
+			"outstanding_qty":   1,
+			"outstanding_value": 1,
+			"next_expected_at":  1,
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "next_expected_at", Value: 1}}}},
+	}
+
+	var rows []models.SupplierOutstanding
+	ar, err := p.coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	if err = ar.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// OutstandingByBook sums the copies of every book still expected on open purchase orders
+func (p *PurchaseOrder) OutstandingByBook(ctx context.Context) (map[string]int64, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"status": bson.M{"$in": openPurchaseStatuses}}}},
+		{{Key: "$unwind", Value: "$lines"}},
+		{{Key: "$group", Value: bson.M{
+			"_id": "$lines.book_id",
+			"qty": bson.M{"$sum": bson.M{"$subtract": bson.A{"$lines.qty", "$lines.received_qty"}}},
+		}}},
+	}
+
+	var sums []struct {
+		BookId string `bson:"_id"`
+		Qty    int64  `bson:"qty"`
+	}
+	ar, err := p.coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	if err = ar.All(ctx, &sums); err != nil {
+		return nil, err
+	}
+
+	qtyByBook := make(map[string]int64, len(sums))
+	for _, sum := range sums {
+		qtyByBook[sum.BookId] = sum.Qty
+	}
+	return qtyByBook, nil
+}
@@ -0,0 +1,72 @@
+package repo
+
+import (
+	"context"
+	"errors"
+
+	"github.com/agustadewa/book-system/configs"
+	"github.com/agustadewa/book-system/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var ErrRefundNotPending = errors.New("refund isn't pending")
+
+type Refund struct {
+	coll *mongo.Collection
+}
+
+func NewRefund(client *mongo.Client) *Refund {
+	return &Refund{coll: client.Database(configs.RefundDBName).Collection(configs.RefundCollName)}
+}
+
+// GetAllByReturnId returns refunds by given return id
+func (r *Refund) GetAllByReturnId(ctx context.Context, returnId string) (*[]models.Refund, error) {
+	var refunds []models.Refund
+	fr, err := r.coll.Find(ctx, bson.M{"return_id": returnId})
+	if err != nil {
+		return nil, err
+	}
+	if err = fr.All(ctx, &refunds); err != nil {
+		return nil, err
+	}
+	return &refunds, nil
+}
+
+// GetAllByOrderId returns refunds by given order id
+func (r *Refund) GetAllByOrderId(ctx context.Context, orderId string) (*[]models.Refund, error) {
+	var refunds []models.Refund
+	fr, err := r.coll.Find(ctx, bson.M{"order_id": orderId})
+	if err != nil {
+		return nil, err
+	}
+	if err = fr.All(ctx, &refunds); err != nil {
+		return nil, err
+	}
+	return &refunds, nil
+}
+
+// Add creates a new refund
+func (r *Refund) Add(ctx context.Context, payload models.Refund) (string, error) {
+	if _, err := r.coll.InsertOne(ctx, payload); err != nil {
+		return "", err
+	}
+
+	return payload.Id, nil
+}
+
+// SetSucceeded completes a pending refund with the provider reference, it fails when the refund was completed
+// already
+func (r *Refund) SetSucceeded(ctx context.Context, refundId string, providerRef string) error {
+	ur, err := r.coll.UpdateOne(ctx,
+		bson.M{"_id": refundId, "status": models.RefundPending.String()},
+		bson.M{"$set": bson.M{"status": models.RefundSucceeded.String(), "provider_ref": providerRef}},
+	)
+	if err != nil {
+		return err
+	}
+	if ur.MatchedCount == 0 {
+		return ErrRefundNotPending
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/agustadewa/book-system/configs"
+	"github.com/agustadewa/book-system/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrReturnNotFound = errors.New("return not found")
+var ErrReturnStatusChanged = errors.New("return status has been changed")
+
+type Return struct {
+	coll *mongo.Collection
+}
+
+func NewReturn(client *mongo.Client) *Return {
+	return &Return{coll: client.Database(configs.ReturnDBName).Collection(configs.ReturnCollName)}
+}
+
+// Get returns a return by given return id
+func (r *Return) Get(ctx context.Context, returnId string) (*models.Return, error) {
+	var ret models.Return
+	if err := r.coll.FindOne(ctx, bson.M{"_id": returnId}).Decode(&ret); err == mongo.ErrNoDocuments {
+		return nil, ErrReturnNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
+
+// GetAllByOrderId returns returns by given order id
+func (r *Return) GetAllByOrderId(ctx context.Context, orderId string) (*[]models.Return, error) {
+	var returns []models.Return
+	fr, err := r.coll.Find(ctx, bson.M{"order_id": orderId})
+	if err != nil {
+		return nil, err
+	}
+	if err = fr.All(ctx, &returns); err != nil {
+		return nil, err
+	}
+	return &returns, nil
+}
+
+// GetAllByUserId returns returns by given user id, newest first
+func (r *Return) GetAllByUserId(ctx context.Context, userId string, limit int64) (*[]models.Return, error) {
+	var returns []models.Return
+	fr, err := r.coll.Find(ctx, bson.M{"user_id": userId}, options.Find().
+		SetSort(bson.D{{Key: "requested_at", Value: -1}}).
+		SetLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	if err = fr.All(ctx, &returns); err != nil {
+		return nil, err
+	}
+	return &returns, nil
+}
+
+// GetAllByStatus returns returns by given status, oldest first
+func (r *Return) GetAllByStatus(ctx context.Context, status models.ReturnStatus, limit int64) (*[]models.Return, error) {
+	var returns []models.Return
+	fr, err := r.coll.Find(ctx, bson.M{"status": status.String()}, options.Find().
+		SetSort(bson.D{{Key: "requested_at", Value: 1}}).
+		SetLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	if err = fr.All(ctx, &returns); err != nil {
+		return nil, err
+	}
+	return &returns, nil
+}
+
+// Add creates a new return
+func (r *Return) Add(ctx context.Context, payload models.Return) (string, error) {
+	if _, err := r.coll.InsertOne(ctx, payload); err != nil {
+		return "", err
+	}
+
+	return payload.Id, nil
+}
+
+// Review approves or rejects a requested return
+func (r *Return) Review(ctx context.Context, returnId string, status models.ReturnStatus, adminId string, rejectReason string) error {
+	ur, err := r.coll.UpdateOne(ctx,
+		bson.M{"_id": returnId, "status": models.ReturnRequested.String()},
+		bson.M{"$set": bson.M{
+			"status":        status.String(),
+			"reviewed_by":   adminId,
+			"reject_reason": rejectReason,
+			"reviewed_at":   time.Now(),
+		}})
+	if err != nil {
+		return err
+	}
+	if ur.MatchedCount == 0 {
+		return ErrReturnStatusChanged
+	}
+	return nil
+}
+
+// SetReceived marks an approved return as received with the amount to refund
+func (r *Return) SetReceived(ctx context.Context, returnId string, refundAmount int64) error {
+	ur, err := r.coll.UpdateOne(ctx,
+		bson.M{"_id": returnId, "status": models.ReturnApproved.String()},
+		bson.M{"$set": bson.M{
+			"status":        models.ReturnReceived.String(),
+			"refund_amount": refundAmount,
+			"received_at":   time.Now(),
+		}})
+	if err != nil {
+		return err
+	}
+	if ur.MatchedCount == 0 {
+		return ErrReturnStatusChanged
+	}
+	return nil
+}
+
+// SetRefunded marks a received return as refunded
+func (r *Return) SetRefunded(ctx context.Context, returnId string) error {
+	ur, err := r.coll.UpdateOne(ctx,
+		bson.M{"_id": returnId, "status": models.ReturnReceived.String()},
+		bson.M{"$set": bson.M{
+			"status":      models.ReturnRefunded.String(),
+			"refunded_at": time.Now(),
+		}})
+	if err != nil {
+		return err
+	}
+	if ur.MatchedCount == 0 {
+		return ErrReturnStatusChanged
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"regexp"
+
+	"github.com/agustadewa/book-system/configs"
+	"github.com/agustadewa/book-system/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrSeriesNotFound = errors.New("series not found")
+var ErrSeriesExists = errors.New("series already exists")
+
+type Series struct {
+	coll *mongo.Collection
+}
+
+func NewSeries(client *mongo.Client) *Series {
+	return &Series{coll: client.Database(configs.CatalogDBName).Collection(configs.SeriesCollName)}
+}
+
+// EnsureIndexes creates the unique name index, series spelled differently are the same series
+func (s *Series) EnsureIndexes(ctx context.Context) error {
+	_, err := s.coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "name_key", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+// Get returns a series by given series id
+func (s *Series) Get(ctx context.Context, seriesId string) (*models.Series, error) {
+	var series models.Series
+	if err := s.coll.FindOne(ctx, bson.M{"_id": seriesId}).Decode(&series); err == mongo.ErrNoDocuments {
+		return nil, ErrSeriesNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &series, nil
+}
+
+// GetByNameKey returns a series by given name key
+func (s *Series) GetByNameKey(ctx context.Context, nameKey string) (*models.Series, error) {
+	var series models.Series
+	if err := s.coll.FindOne(ctx, bson.M{"name_key": nameKey}).Decode(&series); err == mongo.ErrNoDocuments {
+		return nil, ErrSeriesNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &series, nil
+}
+
+// GetAll returns series sorted by name, a non empty name key prefix filters them
+func (s *Series) GetAll(ctx context.Context, nameKeyPrefix string, limit int64) (*[]models.Series, error) {
+	filter := bson.M{}
+	if nameKeyPrefix != "" {
+		filter["name_key"] = primitive.Regex{Pattern: "^" + regexp.QuoteMeta(nameKeyPrefix)}
+	}
+
+	var series []models.Series
+	fr, err := s.coll.Find(ctx, filter, options.Find().SetSort(bson.M{"name_key": 1}).SetLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	if err = fr.All(ctx, &series); err != nil {
+		return nil, err
+	}
+	return &series, nil
+}
+
+// Add creates a new series
+func (s *Series) Add(ctx context.Context, payload models.Series) (string, error) {
+	if _, err := s.coll.InsertOne(ctx, payload); mongo.IsDuplicateKeyError(err) {
+		return "", ErrSeriesExists
+	} else if err != nil {
+		return "", err
+	}
+	return payload.Id, nil
+}
+
+// Update updates the name and description of a series
+func (s *Series) Update(ctx context.Context, series models.Series) error {
+	ur, err := s.coll.UpdateByID(ctx, series.Id, bson.M{"$set": bson.M{
+		"name":        series.Name,
+		"name_key":    series.NameKey,
+		"description": series.Description,
+	}})
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrSeriesExists
+	} else if err != nil {
+		return err
+	}
+	if ur.MatchedCount == 0 {
+		return ErrSeriesNotFound
+	}
+	return nil
+}
+
+// Delete deletes a series
+func (s *Series) Delete(ctx context.Context, seriesId string) error {
+	dr, err := s.coll.DeleteOne(ctx, bson.M{"_id": seriesId})
+	if err != nil {
+		return err
+	}
+	if dr.DeletedCount == 0 {
+		return ErrSeriesNotFound
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/agustadewa/book-system/configs"
+	"github.com/agustadewa/book-system/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrShipmentNotFound = errors.New("shipment not found")
+var ErrShipmentExists = errors.New("shipment already exists")
+var ErrShipmentStatusChanged = errors.New("shipment status has been changed")
+
+type Shipment struct {
+	coll *mongo.Collection
+}
+
+func NewShipment(client *mongo.Client) *Shipment {
+	return &Shipment{coll: client.Database(configs.ShipmentDBName).Collection(configs.ShipmentCollName)}
+}
+
+// EnsureIndexes creates the unique index which allows one shipment per order
+func (s *Shipment) EnsureIndexes(ctx context.Context) error {
+	_, err := s.coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.M{"order_id": 1},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+// Get returns a shipment by given shipment id
+func (s *Shipment) Get(ctx context.Context, shipmentId string) (*models.Shipment, error) {
+	var shipment models.Shipment
+	if err := s.coll.FindOne(ctx, bson.M{"_id": shipmentId}).Decode(&shipment); err == mongo.ErrNoDocuments {
+		return nil, ErrShipmentNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &shipment, nil
+}
+
+// GetByOrderId returns a shipment by given order id
+func (s *Shipment) GetByOrderId(ctx context.Context, orderId string) (*models.Shipment, error) {
+	var shipment models.Shipment
+	if err := s.coll.FindOne(ctx, bson.M{"order_id": orderId}).Decode(&shipment); err == mongo.ErrNoDocuments {
+		return nil, ErrShipmentNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &shipment, nil
+}
+
+// Add creates a new shipment
+func (s *Shipment) Add(ctx context.Context, payload models.Shipment) (string, error) {
+	if _, err := s.coll.InsertOne(ctx, payload); mongo.IsDuplicateKeyError(err) {
+		return "", ErrShipmentExists
+	} else if err != nil {
+		return "", err
+	}
+
+	return payload.Id, nil
+}
+
+// SetDelivered marks a shipped shipment as delivered
+func (s *Shipment) SetDelivered(ctx context.Context, shipmentId string, deliveredAt time.Time) error {
+	ur, err := s.coll.UpdateOne(ctx,
+		bson.M{"_id": shipmentId, "status": models.ShipmentShipped.String()},
+		bson.M{"$set": bson.M{"status": models.ShipmentDelivered.String(), "delivered_at": deliveredAt}})
+	if err != nil {
+		return err
+	}
+	if ur.MatchedCount == 0 {
+		return ErrShipmentStatusChanged
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package repo
+
+import (
+	"context"
+	"errors"
+
+	"github.com/agustadewa/book-system/configs"
+	"github.com/agustadewa/book-system/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var ErrShippingZoneNotFound = errors.New("shipping zone not found")
+
+type ShippingZone struct {
+	coll *mongo.Collection
+}
+
+func NewShippingZone(client *mongo.Client) *ShippingZone {
+	return &ShippingZone{coll: client.Database(configs.ShippingZoneDBName).Collection(configs.ShippingZoneCollName)}
+}
+
+// Get returns a shipping zone by given zone id
+func (s *ShippingZone) Get(ctx context.Context, zoneId string) (*models.ShippingZone, error) {
+	var zone models.ShippingZone
+	if err := s.coll.FindOne(ctx, bson.M{"_id": zoneId}).Decode(&zone); err == mongo.ErrNoDocuments {
+		return nil, ErrShippingZoneNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &zone, nil
+}
+
+// GetAll returns every shipping zone
+func (s *ShippingZone) GetAll(ctx context.Context) (*[]models.ShippingZone, error) {
+	var zones []models.ShippingZone
+	fr, err := s.coll.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	if err = fr.All(ctx, &zones); err != nil {
+		return nil, err
+	}
+	return &zones, nil
+}
+
+// Add creates a new shipping zone
+func (s *ShippingZone) Add(ctx context.Context, payload models.ShippingZone) (string, error) {
+	if payload.IsDefault {
+		if err := s.unsetDefault(ctx); err != nil {
+			return "", err
+		}
+	}
+	if _, err := s.coll.InsertOne(ctx, payload); err != nil {
+		return "", err
+	}
+
+	return payload.Id, nil
+}
+
+// Replace replaces a shipping zone
+func (s *ShippingZone) Replace(ctx context.Context, payload models.ShippingZone) error {
+	if payload.IsDefault {
+		if err := s.unsetDefault(ctx); err != nil {
+			return err
+		}
+	}
+	ur, err := s.coll.ReplaceOne(ctx, bson.M{"_id": payload.Id}, payload)
+	if err != nil {
+		return err
+	}
+	if ur.MatchedCount == 0 {
+		return ErrShippingZoneNotFound
+	}
+	return nil
+}
+
+// Delete deletes a shipping zone
+func (s *ShippingZone) Delete(ctx context.Context, zoneId string) error {
+	dr, err := s.coll.DeleteOne(ctx, bson.M{"_id": zoneId})
+	if err != nil {
+		return err
+	}
+	if dr.DeletedCount == 0 {
+		return ErrShippingZoneNotFound
+	}
+	return nil
+}
+
+// only one zone is the fallback for destinations no zone covers
+func (s *ShippingZone) unsetDefault(ctx context.Context) error {
+	_, err := s.coll.UpdateMany(ctx, bson.M{"is_default": true}, bson.M{"$set": bson.M{"is_default": false}})
+	return err
+}
@@ -0,0 +1,148 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/agustadewa/book-system/configs"
+	"github.com/agustadewa/book-system/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type StockLevel struct {
+	coll *mongo.Collection
+}
+
+func NewStockLevel(client *mongo.Client) *StockLevel {
+	return &StockLevel{coll: client.Database(configs.LocationDBName).Collection(configs.StockLevelCollName)}
+}
+
+// EnsureIndexes creates the indexes used to find the stock of a book and the stock at a location
+func (s *StockLevel) EnsureIndexes(ctx context.Context) error {
+	_, err := s.coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "book_id", Value: 1}, {Key: "location_id", Value: 1}}},
+		{Keys: bson.D{{Key: "location_id", Value: 1}}},
+	})
+	return err
+}
+
+// Get returns the stock of a book at a location, a book never stocked there has 0
+func (s *StockLevel) Get(ctx context.Context, bookId string, locationId string) (*models.StockLevel, error) {
+	var level models.StockLevel
+	if err := s.coll.FindOne(ctx, bson.M{"_id": stockLevelId(bookId, locationId)}).Decode(&level); err == mongo.ErrNoDocuments {
+		return &models.StockLevel{Id: stockLevelId(bookId, locationId), BookId: bookId, LocationId: locationId}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &level, nil
+}
+
+// GetAllByBookId returns the stock of a book at every location holding it
+func (s *StockLevel) GetAllByBookId(ctx context.Context, bookId string) (*[]models.StockLevel, error) {
+	var levels []models.StockLevel
+	fr, err := s.coll.Find(ctx, bson.M{"book_id": bookId}, options.Find().SetSort(bson.M{"location_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	if err = fr.All(ctx, &levels); err != nil {
+		return nil, err
+	}
+	return &levels, nil
+}
+
+// GetAllByLocationId returns the stock levels of every book at a location
+func (s *StockLevel) GetAllByLocationId(ctx context.Context, locationId string) (*[]models.StockLevel, error) {
+	var levels []models.StockLevel
+	fr, err := s.coll.Find(ctx, bson.M{"location_id": locationId})
+	if err != nil {
+		return nil, err
+	}
+	if err = fr.All(ctx, &levels); err != nil {
+		return nil, err
+	}
+	return &levels, nil
+}
+
+// GetAll returns every stock level
+func (s *StockLevel) GetAll(ctx context.Context) (*[]models.StockLevel, error) {
+	var levels []models.StockLevel
+	fr, err := s.coll.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	if err = fr.All(ctx, &levels); err != nil {
+		return nil, err
+	}
+	return &levels, nil
+}
+
+// CountByBookId returns how many locations have a stock level of a book
+func (s *StockLevel) CountByBookId(ctx context.Context, bookId string) (int64, error) {
+	return s.coll.CountDocuments(ctx, bson.M{"book_id": bookId})
+}
+
+// Inc adds delta to the stock of a book at a location and returns the new level, the stock never goes
+// below 0
+func (s *StockLevel) Inc(ctx context.Context, bookId string, locationId string, delta int64) (*models.StockLevel, error) {
+	filter := bson.M{"_id": stockLevelId(bookId, locationId)}
+	if delta < 0 {
+		filter["qty"] = bson.M{"$gte": -delta}
+	}
+
+	var level models.StockLevel
+	err := s.coll.FindOneAndUpdate(ctx, filter,
+		bson.M{
+			"$inc":         bson.M{"qty": delta},
+			"$set":         bson.M{"updated_at": time.Now()},
+			"$setOnInsert": bson.M{"book_id": bookId, "location_id": locationId},
+		},
+		options.FindOneAndUpdate().SetUpsert(delta >= 0).SetReturnDocument(options.After),
+	).Decode(&level)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrInsufficientStock
+	} else if err != nil {
+		return nil, err
+	}
+	return &level, nil
+}
+
+// Set sets the stock of a book at a location, used to rebuild it from the ledger
+func (s *StockLevel) Set(ctx context.Context, bookId string, locationId string, qty int64) error {
+	_, err := s.coll.UpdateOne(ctx, bson.M{"_id": stockLevelId(bookId, locationId)}, bson.M{
+		"$set":         bson.M{"qty": qty, "updated_at": time.Now()},
+		"$setOnInsert": bson.M{"book_id": bookId, "location_id": locationId},
+	}, options.Update().SetUpsert(true))
+	return err
+}
+
+// SumByBook returns the stock of every book summed over the given locations
+func (s *StockLevel) SumByBook(ctx context.Context, locationIds []string) (map[string]int64, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"location_id": bson.M{"$in": locationIds}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$book_id", "qty": bson.M{"$sum": "$qty"}}}},
+	}
+
+	var sums []struct {
+		BookId string `bson:"_id"`
+		Qty    int64  `bson:"qty"`
+	}
+	ar, err := s.coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	if err = ar.All(ctx, &sums); err != nil {
+		return nil, err
+	}
+
+	qtyByBook := make(map[string]int64, len(sums))
+	for _, sum := range sums {
+		qtyByBook[sum.BookId] = sum.Qty
+	}
+	return qtyByBook, nil
+}
+
+func stockLevelId(bookId string, locationId string) string {
+	return bookId + ":" + locationId
+}
@@ -0,0 +1,132 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/agustadewa/book-system/configs"
+	"github.com/agustadewa/book-system/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrStockMovementNotFound = errors.New("stock movement not found")
+
+type StockMovement struct {
+	coll *mongo.Collection
+}
+
+func NewStockMovement(client *mongo.Client) *StockMovement {
+	return &StockMovement{coll: client.Database(configs.StockMovementDBName).Collection(configs.StockMovementCollName)}
+}
+
+// EnsureIndexes creates the indexes used to read the ledger of a book, of a location and of a reference
+func (s *StockMovement) EnsureIndexes(ctx context.Context) error {
+	_, err := s.coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "book_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "location_id", Value: 1}, {Key: "created_at", Value: 1}}},
+		{Keys: bson.D{{Key: "ref_id", Value: 1}, {Key: "book_id", Value: 1}}},
+	})
+	return err
+}
+
+// GetByRefId returns the movement of a book made for a reference with the given reason
+func (s *StockMovement) GetByRefId(ctx context.Context, bookId string, reason models.StockReason, refId string) (*models.StockMovement, error) {
+	var movement models.StockMovement
+	if err := s.coll.FindOne(ctx, bson.M{"ref_id": refId, "book_id": bookId, "reason": reason}).Decode(&movement); err == mongo.ErrNoDocuments {
+		return nil, ErrStockMovementNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &movement, nil
+}
+
+// GetAllByBookId returns the ledger of a book sorted by newest
+func (s *StockMovement) GetAllByBookId(ctx context.Context, bookId string, limit int64) (*[]models.StockMovement, error) {
+	var movements []models.StockMovement
+	fr, err := s.coll.Find(ctx, bson.M{"book_id": bookId}, options.Find().SetSort(bson.M{"created_at": -1}).SetLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	if err = fr.All(ctx, &movements); err != nil {
+		return nil, err
+	}
+	return &movements, nil
+}
+
+// GetAllByLocationIdSince returns the movements at a location made after the given time sorted by oldest
+func (s *StockMovement) GetAllByLocationIdSince(ctx context.Context, locationId string, since time.Time) (*[]models.StockMovement, error) {
+	var movements []models.StockMovement
+	fr, err := s.coll.Find(ctx,
+		bson.M{"location_id": locationId, "created_at": bson.M{"$gt": since}},
+		options.Find().SetSort(bson.M{"created_at": 1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err = fr.All(ctx, &movements); err != nil {
+		return nil, err
+	}
+	return &movements, nil
+}
+
+// SumByLocationIdUntil returns the ledger sums of every book at a location counting the movements made up to
+// the given time
+func (s *StockMovement) SumByLocationIdUntil(ctx context.Context, locationId string, until time.Time) ([]models.StockLedgerSum, error) {
+	var location interface{} = locationId
+	if locationId == configs.DefaultLocationId {
+		// movements recorded before there were locations are at the default location
+		location = bson.M{"$in": bson.A{locationId, nil}}
+	}
+	return s.sum(ctx, bson.M{"location_id": location, "created_at": bson.M{"$lte": until}})
+}
+
+// Add creates a new stock movement
+func (s *StockMovement) Add(ctx context.Context, payload models.StockMovement) error {
+	_, err := s.coll.InsertOne(ctx, payload)
+	return err
+}
+
+// Sum returns the ledger sums of a book per location
+func (s *StockMovement) Sum(ctx context.Context, bookId string) ([]models.StockLedgerSum, error) {
+	return s.sum(ctx, bson.M{"book_id": bookId})
+}
+
+// SumAll returns the ledger sums of every book with movements per location
+func (s *StockMovement) SumAll(ctx context.Context) ([]models.StockLedgerSum, error) {
+	return s.sum(ctx, bson.M{})
+}
+
+func (s *StockMovement) sum(ctx context.Context, match bson.M) ([]models.StockLedgerSum, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.M{
+			"_id": bson.M{
+				"book_id": "$book_id",
+				// movements recorded before there were locations are at the default location
+				"location_id": bson.M{"$ifNull": bson.A{"$location_id", configs.DefaultLocationId}},
+			},
+			"qty":       bson.M{"$sum": "$delta"},
+			"movements": bson.M{"$sum": 1},
+		}}},
+		{{Key: "$project", Value: bson.M{
+			"_id":         0,
+			"book_id":     "$_id.book_id",
+			"location_id": "$_id.location_id",
+			"qty":         1,
+			"movements":   1,
+		}}},
+	}
+
+	var sums []models.StockLedgerSum
+	ar, err := s.coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	if err = ar.All(ctx, &sums); err != nil {
+		return nil, err
+	}
+	return sums, nil
+}
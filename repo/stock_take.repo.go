@@ -0,0 +1,175 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/agustadewa/book-system/configs"
+	"github.com/agustadewa/book-system/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrStockTakeNotFound = errors.New("stock take not found")
+var ErrStockTakeInProgress = errors.New("a stock take of the location is already open")
+var ErrStockTakeClosed = errors.New("stock take is committed or cancelled")
+
+type StockTake struct {
+	coll *mongo.Collection
+}
+
+func NewStockTake(client *mongo.Client) *StockTake {
+	return &StockTake{coll: client.Database(configs.StockTakeDBName).Collection(configs.StockTakeCollName)}
+}
+
+// EnsureIndexes creates the unique index which allows one open stock take per location
+func (s *StockTake) EnsureIndexes(ctx context.Context) error {
+	_, err := s.coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "location_id", Value: 1}},
+		Options: options.Index().SetUnique(true).
+			SetPartialFilterExpression(bson.M{"status": models.StockTakeOpen}),
+	})
+	return err
+}
+
+// Get returns a stock take by given stock take id
+func (s *StockTake) Get(ctx context.Context, stockTakeId string) (*models.StockTake, error) {
+	var stockTake models.StockTake
+	if err := s.coll.FindOne(ctx, bson.M{"_id": stockTakeId}).Decode(&stockTake); err == mongo.ErrNoDocuments {
+		return nil, ErrStockTakeNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &stockTake, nil
+}
+
+// GetAll returns the stock takes sorted by newest
+func (s *StockTake) GetAll(ctx context.Context, limit int64) (*[]models.StockTake, error) {
+	var stockTakes []models.StockTake
+	fr, err := s.coll.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"opened_at": -1}).SetLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	if err = fr.All(ctx, &stockTakes); err != nil {
+		return nil, err
+	}
+	return &stockTakes, nil
+}
+
+// Add creates a new stock take, it fails when a stock take of the location is open
+func (s *StockTake) Add(ctx context.Context, payload models.StockTake) (string, error) {
+	if _, err := s.coll.InsertOne(ctx, payload); mongo.IsDuplicateKeyError(err) {
+		return "", ErrStockTakeInProgress
+	} else if err != nil {
+		return "", err
+	}
+
+	return payload.Id, nil
+}
+
+// SetClosed commits or cancels an open stock take
+func (s *StockTake) SetClosed(ctx context.Context, stockTakeId string, status models.StockTakeStatus, adminId string, corrections int64) error {
+	ur, err := s.coll.UpdateOne(ctx, bson.M{"_id": stockTakeId, "status": models.StockTakeOpen}, bson.M{"$set": bson.M{
+		"status":      status,
+		"closed_by":   adminId,
+		"closed_at":   time.Now(),
+		"corrections": corrections,
+	}})
+	if err != nil {
+		return err
+	}
+	if ur.MatchedCount == 0 {
+		return ErrStockTakeClosed
+	}
+	return nil
+}
+
+// Delete deletes a stock take, used when its snapshot couldn't be written
+func (s *StockTake) Delete(ctx context.Context, stockTakeId string) error {
+	_, err := s.coll.DeleteOne(ctx, bson.M{"_id": stockTakeId})
+	return err
+}
+
+type StockTakeLine struct {
+	coll *mongo.Collection
+}
+
+func NewStockTakeLine(client *mongo.Client) *StockTakeLine {
+	return &StockTakeLine{coll: client.Database(configs.StockTakeDBName).Collection(configs.StockTakeLineCollName)}
+}
+
+// EnsureIndexes creates the index used to read the lines of a stock take
+func (s *StockTakeLine) EnsureIndexes(ctx context.Context) error {
+	_, err := s.coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "stock_take_id", Value: 1}, {Key: "book_id", Value: 1}},
+	})
+	return err
+}
+
+// GetAllByStockTakeId returns the lines of a stock take
+func (s *StockTakeLine) GetAllByStockTakeId(ctx context.Context, stockTakeId string) (*[]models.StockTakeLine, error) {
+	var lines []models.StockTakeLine
+	fr, err := s.coll.Find(ctx, bson.M{"stock_take_id": stockTakeId}, options.Find().SetSort(bson.M{"book_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	if err = fr.All(ctx, &lines); err != nil {
+		return nil, err
+	}
+	return &lines, nil
+}
+
+// AddMany writes the snapshot lines of a stock take
+func (s *StockTakeLine) AddMany(ctx context.Context, lines []models.StockTakeLine) error {
+	if len(lines) == 0 {
+		return nil
+	}
+	docs := make([]interface{}, 0, len(lines))
+	for _, line := range lines {
+		docs = append(docs, line)
+	}
+	_, err := s.coll.InsertMany(ctx, docs)
+	return err
+}
+
+// AddCount adds a count of a book to a stock take, a recount replaces the earlier counts. A book which
+// wasn't in the snapshot gets a line with a snapshot of 0.
+func (s *StockTakeLine) AddCount(ctx context.Context, stockTakeId string, bookId string, count models.StockCount, recount bool) error {
+	update := bson.M{
+		"$set":         bson.M{"counted": true, "counted_at": count.CountedAt},
+		"$setOnInsert": bson.M{"stock_take_id": stockTakeId, "book_id": bookId, "snapshot_qty": 0},
+	}
+	if recount {
+		update["$set"].(bson.M)["counted_qty"] = count.Qty
+		update["$set"].(bson.M)["counts"] = []models.StockCount{count}
+	} else {
+		update["$inc"] = bson.M{"counted_qty": count.Qty}
+		update["$push"] = bson.M{"counts": count}
+	}
+
+	_, err := s.coll.UpdateOne(ctx, bson.M{"_id": stockTakeLineId(stockTakeId, bookId)}, update, options.Update().SetUpsert(true))
+	return err
+}
+
+// DeleteAllByStockTakeId deletes the lines of a stock take
+func (s *StockTakeLine) DeleteAllByStockTakeId(ctx context.Context, stockTakeId string) error {
+	_, err := s.coll.DeleteMany(ctx, bson.M{"stock_take_id": stockTakeId})
+	return err
+}
+
+func stockTakeLineId(stockTakeId string, bookId string) string {
+	return stockTakeId + ":" + bookId
+}
+
+// StockTakeLineOf returns the snapshot line of a book
+func StockTakeLineOf(stockTakeId string, bookId string, snapshotQty int64) models.StockTakeLine {
+	return models.StockTakeLine{
+		Id:          stockTakeLineId(stockTakeId, bookId),
+		StockTakeId: stockTakeId,
+		BookId:      bookId,
+		SnapshotQty: snapshotQty,
+		Counts:      make([]models.StockCount, 0),
+	}
+}
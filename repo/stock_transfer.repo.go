@@ -0,0 +1,61 @@
+package repo
+
+import (
+	"context"
+	"errors"
+
+	"github.com/agustadewa/book-system/configs"
+	"github.com/agustadewa/book-system/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrStockTransferNotFound = errors.New("stock transfer not found")
+
+type StockTransfer struct {
+	coll *mongo.Collection
+}
+
+func NewStockTransfer(client *mongo.Client) *StockTransfer {
+	return &StockTransfer{coll: client.Database(configs.LocationDBName).Collection(configs.StockTransferCollName)}
+}
+
+// GetAll returns the transfers sorted by newest, an empty book id returns transfers of every book
+func (s *StockTransfer) GetAll(ctx context.Context, bookId string, limit int64) (*[]models.StockTransfer, error) {
+	filter := bson.M{}
+	if bookId != "" {
+		filter["book_id"] = bookId
+	}
+
+	var transfers []models.StockTransfer
+	fr, err := s.coll.Find(ctx, filter, options.Find().SetSort(bson.M{"created_at": -1}).SetLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	if err = fr.All(ctx, &transfers); err != nil {
+		return nil, err
+	}
+	return &transfers, nil
+}
+
+// Add creates a new stock transfer
+func (s *StockTransfer) Add(ctx context.Context, payload models.StockTransfer) (string, error) {
+	if _, err := s.coll.InsertOne(ctx, payload); err != nil {
+		return "", err
+	}
+
+	return payload.Id, nil
+}
+
+// Delete deletes a stock transfer
+func (s *StockTransfer) Delete(ctx context.Context, transferId string) error {
+	dr, err := s.coll.DeleteOne(ctx, bson.M{"_id": transferId})
+	if err != nil {
+		return err
+	}
+	if dr.DeletedCount == 0 {
+		return ErrStockTransferNotFound
+	}
+	return nil
+}
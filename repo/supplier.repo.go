@@ -0,0 +1,75 @@
+package repo
+
+import (
+	"context"
+	"errors"
+
+	"github.com/agustadewa/book-system/configs"
+	"github.com/agustadewa/book-system/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrSupplierNotFound = errors.New("supplier not found")
+
+type Supplier struct {
+	coll *mongo.Collection
+}
+
+func NewSupplier(client *mongo.Client) *Supplier {
+	return &Supplier{coll: client.Database(configs.PurchaseDBName).Collection(configs.SupplierCollName)}
+}
+
+// Get returns a supplier by given supplier id
+func (s *Supplier) Get(ctx context.Context, supplierId string) (*models.Supplier, error) {
+	var supplier models.Supplier
+	if err := s.coll.FindOne(ctx, bson.M{"_id": supplierId}).Decode(&supplier); err == mongo.ErrNoDocuments {
+		return nil, ErrSupplierNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &supplier, nil
+}
+
+// GetAll returns every supplier sorted by name
+func (s *Supplier) GetAll(ctx context.Context) (*[]models.Supplier, error) {
+	var suppliers []models.Supplier
+	fr, err := s.coll.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"name": 1}))
+	if err != nil {
+		return nil, err
+	}
+	if err = fr.All(ctx, &suppliers); err != nil {
+		return nil, err
+	}
+	return &suppliers, nil
+}
+
+// Add creates a new supplier
+func (s *Supplier) Add(ctx context.Context, payload models.Supplier) (string, error) {
+	if _, err := s.coll.InsertOne(ctx, payload); err != nil {
+		return "", err
+	}
+
+	return payload.Id, nil
+}
+
+// Update updates a supplier
+func (s *Supplier) Update(ctx context.Context, payload models.Supplier) error {
+	ur, err := s.coll.UpdateOne(ctx, bson.M{"_id": payload.Id}, bson.M{"$set": bson.M{
+		"name":           payload.Name,
+		"type":           payload.Type,
+		"email":          payload.Email,
+		"phone":          payload.Phone,
+		"address":        payload.Address,
+		"lead_time_days": payload.LeadTimeDays,
+		"active":         payload.Active,
+	}})
+	if err != nil {
+		return err
+	}
+	if ur.MatchedCount == 0 {
+		return ErrSupplierNotFound
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package repo
+
+import (
+	"context"
+	"errors"
+
+	"github.com/agustadewa/book-system/configs"
+	"github.com/agustadewa/book-system/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var ErrTaxRegionNotFound = errors.New("tax region not found")
+
+type TaxRegion struct {
+	coll *mongo.Collection
+}
+
+func NewTaxRegion(client *mongo.Client) *TaxRegion {
+	return &TaxRegion{coll: client.Database(configs.TaxRegionDBName).Collection(configs.TaxRegionCollName)}
+}
+
+// Get returns a tax region by given region id
+func (t *TaxRegion) Get(ctx context.Context, regionId string) (*models.TaxRegion, error) {
+	var region models.TaxRegion
+	if err := t.coll.FindOne(ctx, bson.M{"_id": regionId}).Decode(&region); err == mongo.ErrNoDocuments {
+		return nil, ErrTaxRegionNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &region, nil
+}
+
+// GetAll returns every tax region
+func (t *TaxRegion) GetAll(ctx context.Context) (*[]models.TaxRegion, error) {
+	var regions []models.TaxRegion
+	fr, err := t.coll.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	if err = fr.All(ctx, &regions); err != nil {
+		return nil, err
+	}
+	return &regions, nil
+}
+
+// Add creates a new tax region
+func (t *TaxRegion) Add(ctx context.Context, payload models.TaxRegion) (string, error) {
+	if payload.IsDefault {
+		if err := t.unsetDefault(ctx); err != nil {
+			return "", err
+		}
+	}
+	if _, err := t.coll.InsertOne(ctx, payload); err != nil {
+		return "", err
+	}
+
+	return payload.Id, nil
+}
+
+// Replace replaces a tax region, orders keep the rates they were charged with
+func (t *TaxRegion) Replace(ctx context.Context, payload models.TaxRegion) error {
+	if payload.IsDefault {
+		if err := t.unsetDefault(ctx); err != nil {
+			return err
+		}
+	}
+	ur, err := t.coll.ReplaceOne(ctx, bson.M{"_id": payload.Id}, payload)
+	if err != nil {
+		return err
+	}
+	if ur.MatchedCount == 0 {
+		return ErrTaxRegionNotFound
+	}
+	return nil
+}
+
+// Delete deletes a tax region
+func (t *TaxRegion) Delete(ctx context.Context, regionId string) error {
+	dr, err := t.coll.DeleteOne(ctx, bson.M{"_id": regionId})
+	if err != nil {
+		return err
+	}
+	if dr.DeletedCount == 0 {
+		return ErrTaxRegionNotFound
+	}
+	return nil
+}
+
+// only one region is the fallback for addresses no region covers and for orders picked up at the shop
+func (t *TaxRegion) unsetDefault(ctx context.Context) error {
+	_, err := t.coll.UpdateMany(ctx, bson.M{"is_default": true}, bson.M{"$set": bson.M{"is_default": false}})
+	return err
+}
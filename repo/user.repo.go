@@ -13,6 +13,7 @@ import (
 
 var ErrUserNotFound = errors.New("user not found")
 var ErrUserExists = errors.New("user already exists")
+var ErrUserNotAdmin = errors.New("user is not an admin")
 
 type User struct {
 	coll *mongo.Collection
@@ -32,6 +33,18 @@ func (u *User) Get(ctx context.Context, userId string) (*models.User, error) {
 	}
 }
 
+// GetAdmin returns a user by given user id and fails when the user is not an admin
+func (u *User) GetAdmin(ctx context.Context, userId string) (*models.User, error) {
+	user, err := u.Get(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if !user.IsAdmin {
+		return nil, ErrUserNotAdmin
+	}
+	return user, nil
+}
+
 // GetByUserName returns a user by given user name
 func (u *User) GetByUserName(ctx context.Context, userName string) (*models.User, error) {
 	var user models.User
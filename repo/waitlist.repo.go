@@ -0,0 +1,185 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/agustadewa/book-system/configs"
+	"github.com/agustadewa/book-system/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrWaitlistEntryNotFound = errors.New("waitlist entry not found")
+var ErrWaitlistEntryExists = errors.New("already waiting for this book")
+var ErrWaitlistStatusChanged = errors.New("waitlist entry status has changed")
+
+type Waitlist struct {
+	coll *mongo.Collection
+}
+
+func NewWaitlist(client *mongo.Client) *Waitlist {
+	return &Waitlist{coll: client.Database(configs.WaitlistDBName).Collection(configs.WaitlistCollName)}
+}
+
+// EnsureIndexes creates the unique index which allows one active entry per customer and book, and the
+// index used to serve a book waitlist in order
+func (w *Waitlist) EnsureIndexes(ctx context.Context) error {
+	_, err := w.coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "book_id", Value: 1}, {Key: "user_id", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"active": true}),
+		},
+		{
+			Keys: bson.D{{Key: "book_id", Value: 1}, {Key: "status", Value: 1}, {Key: "created_at", Value: 1}},
+		},
+	})
+	return err
+}
+
+// Get returns a waitlist entry by given entry id
+func (w *Waitlist) Get(ctx context.Context, entryId string) (*models.WaitlistEntry, error) {
+	var entry models.WaitlistEntry
+	if err := w.coll.FindOne(ctx, bson.M{"_id": entryId}).Decode(&entry); err == mongo.ErrNoDocuments {
+		return nil, ErrWaitlistEntryNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// GetHold returns the unexpired reservation of a customer for at least qty books
+func (w *Waitlist) GetHold(ctx context.Context, userId string, bookId string, qty int64, now time.Time) (*models.WaitlistEntry, error) {
+	var entry models.WaitlistEntry
+	err := w.coll.FindOne(ctx, holdFilter(userId, bookId, qty, now)).Decode(&entry)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrWaitlistEntryNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// GetAllByUserId returns the waitlist entries of a customer sorted by newest
+func (w *Waitlist) GetAllByUserId(ctx context.Context, userId string, limit int64) (*[]models.WaitlistEntry, error) {
+	return w.find(ctx, bson.M{"user_id": userId}, options.Find().SetSort(bson.M{"created_at": -1}).SetLimit(limit))
+}
+
+// GetAllByBookId returns the active waitlist entries of a book in serving order
+func (w *Waitlist) GetAllByBookId(ctx context.Context, bookId string) (*[]models.WaitlistEntry, error) {
+	return w.find(ctx, bson.M{"book_id": bookId, "active": true}, options.Find().SetSort(bson.M{"created_at": 1}))
+}
+
+// GetAllWaiting returns the entries of a book still waiting for stock in serving order
+func (w *Waitlist) GetAllWaiting(ctx context.Context, bookId string) (*[]models.WaitlistEntry, error) {
+	return w.find(ctx, bson.M{"book_id": bookId, "status": models.WaitlistWaiting}, options.Find().SetSort(bson.M{"created_at": 1}))
+}
+
+// GetAllExpiredHolds returns the reservations which ran out before now
+func (w *Waitlist) GetAllExpiredHolds(ctx context.Context, now time.Time) (*[]models.WaitlistEntry, error) {
+	return w.find(ctx, bson.M{"status": models.WaitlistReserved, "hold_until": bson.M{"$lte": now}}, options.Find().SetLimit(100))
+}
+
+// Add creates a new waitlist entry, it fails when the customer is already waiting for the book
+func (w *Waitlist) Add(ctx context.Context, payload models.WaitlistEntry) (string, error) {
+	if _, err := w.coll.InsertOne(ctx, payload); mongo.IsDuplicateKeyError(err) {
+		return "", ErrWaitlistEntryExists
+	} else if err != nil {
+		return "", err
+	}
+
+	return payload.Id, nil
+}
+
+// SetNotified marks a waiting entry as notified, a reservation sets where and until when the books are held
+func (w *Waitlist) SetNotified(ctx context.Context, entryId string, holdLocationId string, holdUntil *time.Time) error {
+	now := time.Now()
+	set := bson.M{"status": models.WaitlistNotified, "notified_at": now}
+	if holdUntil != nil {
+		set["status"] = models.WaitlistReserved
+		set["hold_location_id"] = holdLocationId
+		set["hold_until"] = holdUntil
+	}
+	return w.setStatus(ctx, bson.M{"_id": entryId, "status": models.WaitlistWaiting}, set)
+}
+
+// SetClosed closes an entry which is in the given status
+func (w *Waitlist) SetClosed(ctx context.Context, entryId string, from models.WaitlistStatus, to models.WaitlistStatus) error {
+	return w.setStatus(ctx, bson.M{"_id": entryId, "status": from}, bson.M{"status": to, "active": false, "closed_at": time.Now()})
+}
+
+// ClaimHold closes the unexpired reservation of a customer for at least qty books as fulfilled and
+// returns it, the held books are the customer's
+func (w *Waitlist) ClaimHold(ctx context.Context, userId string, bookId string, qty int64, now time.Time) (*models.WaitlistEntry, error) {
+	var entry models.WaitlistEntry
+	err := w.coll.FindOneAndUpdate(ctx, holdFilter(userId, bookId, qty, now), bson.M{"$set": bson.M{
+		"status":    models.WaitlistFulfilled,
+		"active":    false,
+		"closed_at": now,
+	}}).Decode(&entry)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrWaitlistEntryNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// RestoreHold reopens a reservation claimed by an order which couldn't be placed, qty books stay held
+func (w *Waitlist) RestoreHold(ctx context.Context, entryId string, qty int64) error {
+	ur, err := w.coll.UpdateOne(ctx,
+		bson.M{"_id": entryId, "status": models.WaitlistFulfilled},
+		bson.M{"$set": bson.M{"status": models.WaitlistReserved, "active": true, "qty": qty}, "$unset": bson.M{"closed_at": ""}},
+	)
+	if err != nil {
+		return err
+	}
+	if ur.MatchedCount == 0 {
+		return ErrWaitlistStatusChanged
+	}
+	return nil
+}
+
+// SetFulfilled closes the waiting and notified entries of a customer for a book once it is ordered
+func (w *Waitlist) SetFulfilled(ctx context.Context, userId string, bookId string) error {
+	_, err := w.coll.UpdateMany(ctx,
+		bson.M{"user_id": userId, "book_id": bookId, "status": bson.M{"$in": []models.WaitlistStatus{models.WaitlistWaiting, models.WaitlistNotified}}},
+		bson.M{"$set": bson.M{"status": models.WaitlistFulfilled, "active": false, "closed_at": time.Now()}},
+	)
+	return err
+}
+
+func (w *Waitlist) setStatus(ctx context.Context, filter bson.M, set bson.M) error {
+	ur, err := w.coll.UpdateOne(ctx, filter, bson.M{"$set": set})
+	if err != nil {
+		return err
+	}
+	if ur.MatchedCount == 0 {
+		return ErrWaitlistStatusChanged
+	}
+	return nil
+}
+
+func (w *Waitlist) find(ctx context.Context, filter bson.M, opts *options.FindOptions) (*[]models.WaitlistEntry, error) {
+	var entries []models.WaitlistEntry
+	fr, err := w.coll.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err = fr.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+	return &entries, nil
+}
+
+func holdFilter(userId string, bookId string, qty int64, now time.Time) bson.M {
+	return bson.M{
+		"user_id":    userId,
+		"book_id":    bookId,
+		"status":     models.WaitlistReserved,
+		"qty":        bson.M{"$gte": qty},
+		"hold_until": bson.M{"$gt": now},
+	}
+}
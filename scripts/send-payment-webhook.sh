@@ -0,0 +1,36 @@
+#!/usr/bin/env bash
+# Signs and sends a payment webhook event to a local server.
+#
+# usage: scripts/send-payment-webhook.sh <provider> <event type> <provider reference> [event id]
+# e.g.   scripts/send-payment-webhook.sh fake_card payment.succeeded fc_6283c9d0b5d3f2a1e4c7a9b1
+#
+# The secret is read from the same variable as the server, PAYMENT_WEBHOOK_SECRET_MANUAL_TRANSFER or
+# PAYMENT_WEBHOOK_SECRET_FAKE_CARD. WEBHOOK_URL overrides the local server url.
+set -euo pipefail
+
+provider="${1:?provider is required}"
+event_type="${2:?event type is required}"
+reference="${3:?provider reference is required}"
+event_id="${4:-evt_$(date +%s%N)}"
+
+case "$provider" in
+  manual_transfer) secret_env="PAYMENT_WEBHOOK_SECRET_MANUAL_TRANSFER" ;;
+  fake_card) secret_env="PAYMENT_WEBHOOK_SECRET_FAKE_CARD" ;;
+  *) echo "unknown provider $provider" >&2; exit 1 ;;
+esac
+secret="${!secret_env:-}"
+if [ -z "$secret" ]; then
+  echo "environment variable $secret_env is not set" >&2
+  exit 1
+fi
+url="${WEBHOOK_URL:-http://127.0.0.1:4000/payment/webhook/$provider}"
+
+body="{\"id\":\"$event_id\",\"type\":\"$event_type\",\"data\":{\"reference\":\"$reference\"}}"
+ts="$(date +%s)"
+signature="$(printf '%s.%s' "$ts" "$body" | openssl dgst -sha256 -hmac "$secret" -hex | sed 's/^.* //')"
+
+curl -sS -X POST "$url" \
+  -H "Content-Type: application/json" \
+  -H "X-Webhook-Signature: t=$ts,v1=$signature" \
+  -d "$body"
+echo
@@ -0,0 +1,147 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/agustadewa/book-system/configs"
+	"github.com/agustadewa/book-system/models"
+	"github.com/agustadewa/book-system/repo"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Backorders gives incoming stock to pre-orders and back-orders in order time sequence
+type Backorders struct {
+	order     *repo.Order
+	notifier  Notifier
+	library   *Library
+	inventory *Inventory
+}
+
+func NewBackorders(client *mongo.Client) *Backorders {
+	return NewInventory(client).Backorders()
+}
+
+// newBackorders shares the inventory which triggers it, allocations are sales of that inventory
+func newBackorders(client *mongo.Client, inventory *Inventory) *Backorders {
+	return &Backorders{
+		order:     repo.NewOrder(client),
+		notifier:  NewNotifier(client),
+		library:   NewLibrary(client),
+		inventory: inventory,
+	}
+}
+
+// Allocate takes stock for the pre-orders and back-orders of a book, oldest first. An order which can't get
+// all its books stops the later ones from jumping the queue. Pre-orders wait for the release date, digital
+// books have no stock and every order gets them on release.
+func (b *Backorders) Allocate(ctx context.Context, bookId string) error {
+	now := time.Now()
+	book, err := b.inventory.book.Get(ctx, bookId)
+	if err != nil {
+		return err
+	}
+	if !book.IsReleased(now) || (book.Qty <= 0 && !book.IsDigital()) {
+		return nil
+	}
+	orders, err := b.order.GetAllAwaitingStock(ctx, bookId)
+	if err != nil {
+		return err
+	}
+
+	for _, order := range *orders {
+		var locationId string
+		if !book.IsDigital() {
+			sale, err := b.inventory.Sell(ctx, bookId, order.Qty, configs.DefaultAllocationStrategy, order.ShippingAddress, configs.StockSystemActor, order.Id)
+			if err == repo.ErrInsufficientStock {
+				break
+			} else if err != nil {
+				return err
+			}
+			locationId = sale.LocationId
+		}
+
+		// paid in advance orders are ready to ship, the others wait for the rest of the payment
+		status := models.WaitingForPayment
+		if order.PaidAmount >= order.TotalPrice {
+			status = models.Paid
+		}
+		if err = b.order.SetAllocated(ctx, order.Id, status, locationId, time.Now()); err == repo.ErrOrderNotAwaitingStock {
+			// called off meanwhile
+			if locationId == "" {
+				continue
+			}
+			if _, err = b.inventory.Move(ctx, bookId, locationId, order.Qty, models.StockCancellationRestore, configs.StockSystemActor, order.Id, "order called off while allocating"); err != nil {
+				log.Printf("[BACKORDER] can't restore stock of book %v for order %v: %v\n", bookId, order.Id, err)
+			}
+			continue
+		} else if err != nil {
+			return err
+		}
+		if status.IsWaitingForPayment() {
+			// a payment landing while allocating saw the order still waiting for stock
+			if status, err = b.settle(ctx, order.Id); err != nil {
+				return err
+			}
+		}
+		if status.IsPaid() {
+			if err = b.library.Grant(ctx, order.Id); err != nil {
+				log.Printf("[BACKORDER] can't add order %v to the library: %v\n", order.Id, err)
+			}
+		}
+
+		message := fmt.Sprintf("your order of %s has its books", book.Name)
+		if status.IsWaitingForPayment() {
+			message += fmt.Sprintf(", pay the remaining %v to get it shipped", order.TotalPrice-order.PaidAmount)
+		}
+		err = b.notifier.Notify(ctx, models.Notification{
+			Type:    models.OrderReadyNotification,
+			UserId:  order.UserId,
+			Key:     "order_ready:" + order.Id,
+			BookId:  bookId,
+			Message: message,
+		})
+		if err != nil && err != repo.ErrNotificationExists {
+			log.Printf("[BACKORDER] can't notify user %v of order %v: %v\n", order.UserId, order.Id, err)
+		}
+	}
+	return nil
+}
+
+// Owed returns how many books in stock are owed to the pre-orders and back-orders of a book, they get the
+// books before new orders and the waitlist do
+func (b *Backorders) Owed(ctx context.Context, bookId string) (int64, error) {
+	return b.order.QtyAwaitingStock(ctx, bookId)
+}
+
+// AllocateAll allocates stock for every book with pre-orders or back-orders, it picks up books released
+// while their stock was already in
+func (b *Backorders) AllocateAll(ctx context.Context) error {
+	bookIds, err := b.order.GetAllBookIdsAwaitingStock(ctx)
+	if err != nil {
+		return err
+	}
+	for _, bookId := range bookIds {
+		if err = b.Allocate(ctx, bookId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// settle pays an allocated order whose payments cover it
+func (b *Backorders) settle(ctx context.Context, orderId string) (models.OrderStatus, error) {
+	order, err := b.order.Get(ctx, orderId)
+	if err != nil {
+		return "", err
+	}
+	if order.Status.IsWaitingForPayment() && order.PaidAmount >= order.TotalPrice {
+		if err = b.order.UpdateStatus(ctx, order.Id, models.Paid); err != nil {
+			return "", err
+		}
+		return models.Paid, nil
+	}
+	return order.Status, nil
+}
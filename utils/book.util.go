@@ -0,0 +1,228 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/agustadewa/book-system/configs"
+	"github.com/agustadewa/book-system/models"
+	"github.com/agustadewa/book-system/repo"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Books adds new books to the catalogue, books added one at a time and imported books follow the same rules
+type Books struct {
+	book      *repo.Book
+	inventory *Inventory
+	prices    *Prices
+	catalog   *Catalog
+}
+
+func NewBooks(client *mongo.Client) *Books {
+	return &Books{
+		book:      repo.NewBook(client),
+		inventory: NewInventory(client),
+		prices:    NewPrices(client),
+		catalog:   NewCatalog(client),
+	}
+}
+
+// AddBookOf reads a new book request, the required fields are expected to be there
+func AddBookOf(addBookReq models.AddBookReq) (models.AddBook, error) {
+	addBook := models.AddBook{
+		Format:      models.Paperback,
+		Price:       *addBookReq.Price,
+		Qty:         *addBookReq.Qty,
+		Name:        *addBookReq.Name,
+		Language:    *addBookReq.Language,
+		Description: *addBookReq.Description,
+		Image:       *addBookReq.Image,
+		Dimensions:  addBookReq.Dimensions,
+	}
+	if addBookReq.Format != nil {
+		format, err := models.IsValidBookFormat(*addBookReq.Format)
+		if err != nil {
+			return addBook, err
+		}
+		addBook.Format = format
+	}
+	if addBookReq.Sku != nil {
+		addBook.Sku = strings.TrimSpace(*addBookReq.Sku)
+	}
+	if addBookReq.Isbn != nil {
+		isbn13, isbn10, err := ParseIsbn(*addBookReq.Isbn)
+		if err != nil {
+			return addBook, err
+		}
+		addBook.Isbn13, addBook.Isbn10 = isbn13, isbn10
+	}
+	if addBookReq.WeightGrams != nil {
+		addBook.WeightGrams = *addBookReq.WeightGrams
+	}
+	if addBookReq.ReorderThreshold != nil {
+		addBook.ReorderThreshold = *addBookReq.ReorderThreshold
+	}
+	addBook.ReleaseDate = addBookReq.ReleaseDate
+	if addBookReq.AllowBackorder != nil {
+		addBook.AllowBackorder = *addBookReq.AllowBackorder
+	}
+	addBook.TaxClass = models.StandardTax
+	if addBookReq.TaxClass != nil {
+		taxClass, err := models.IsValidTaxClass(*addBookReq.TaxClass)
+		if err != nil {
+			return addBook, err
+		}
+		addBook.TaxClass = taxClass
+	}
+	addBook.Currency = configs.DefaultCurrency
+	if addBookReq.Currency != nil {
+		currency, err := models.IsValidCurrency(*addBookReq.Currency)
+		if err != nil {
+			return addBook, err
+		}
+		addBook.Currency = currency.Code
+	}
+	if addBookReq.Edition != nil {
+		addBook.Edition = strings.TrimSpace(*addBookReq.Edition)
+	}
+
+	return addBook, ValidateAddBook(addBook)
+}
+
+// Link links a new book to its author, publisher and category, names without an entity get one, and to
+// the work it is a new edition of
+func (b *Books) Link(ctx context.Context, addBook *models.AddBook, addBookReq models.AddBookReq) error {
+	var authorNames string
+	if addBookReq.Author != nil {
+		authorNames = *addBookReq.Author
+	}
+	contributors, err := b.catalog.Contributors(ctx, addBookReq.Contributors, authorNames)
+	if err != nil {
+		return err
+	}
+	publisher, err := b.catalog.PublisherOf(ctx, addBookReq.PublisherId, addBookReq.Publisher)
+	if err != nil {
+		return err
+	}
+	category, err := b.catalog.CategoryOf(ctx, addBookReq.CategoryId, addBookReq.Category)
+	if err != nil {
+		return err
+	}
+	addBook.Contributors, addBook.Author = contributors, AuthorNames(contributors)
+	addBook.PublisherId, addBook.Publisher = publisher.Id, publisher.Name
+	addBook.CategoryIds, addBook.Category = category.Lineage(), category.Name
+
+	if addBookReq.EditionOf != nil && *addBookReq.EditionOf != "" {
+		work, err := b.book.Get(ctx, *addBookReq.EditionOf)
+		if err != nil {
+			return err
+		}
+		addBook.WorkId = work.WorkId
+	}
+	return nil
+}
+
+// ValidateAddBook checks the stock, price and size of a new book
+func ValidateAddBook(addBook models.AddBook) error {
+	// books taking pre-orders or back-orders can be added before any stock arrives
+	if addBook.Format.IsDigital() {
+		if addBook.Qty != 0 {
+			return repo.ErrUnlimitedStock
+		}
+	} else if addBook.Qty < 0 || (addBook.Qty == 0 && addBook.ReleaseDate == nil && !addBook.AllowBackorder) {
+		return errors.New("quantity minimum is 1")
+	}
+	if addBook.Price <= 0 {
+		return errors.New("price minimum is 1")
+	}
+	if addBook.WeightGrams < 0 {
+		return errors.New("weight can't be lower than 0")
+	}
+	if d := addBook.Dimensions; d != nil && (d.LengthMm <= 0 || d.WidthMm <= 0 || d.HeightMm <= 0) {
+		return errors.New("dimensions minimum is 1")
+	}
+	if addBook.ReorderThreshold < 0 {
+		return errors.New("reorder threshold can't be lower than 0")
+	}
+	return nil
+}
+
+// Create adds a book as a variant of a title, an empty title id starts a title of its own. A new title
+// without a work is a work of its own. The stock ledger and the price history start with it.
+func (b *Books) Create(ctx context.Context, addBook models.AddBook, titleId string) (string, error) {
+	id := primitive.NewObjectID().Hex()
+	if titleId == "" {
+		titleId = id
+	}
+	if addBook.Sku == "" {
+		addBook.Sku = id
+	}
+
+	addBookPayload := models.Book{
+		Id:          id,
+		TitleId:     titleId,
+		Format:      addBook.Format,
+		Sku:         addBook.Sku,
+		Isbn13:      addBook.Isbn13,
+		Isbn10:      addBook.Isbn10,
+		Name:        addBook.Name,
+		Author:      addBook.Author,
+		Publisher:   addBook.Publisher,
+		Category:    addBook.Category,
+		Language:    addBook.Language,
+		Price:       addBook.Price,
+		Currency:    addBook.Currency,
+		Qty:         addBook.Qty,
+		Description: addBook.Description,
+		Image:       addBook.Image,
+		WeightGrams: addBook.WeightGrams,
+		Dimensions:  addBook.Dimensions,
+		TaxClass:    addBook.TaxClass,
+
+		ReorderThreshold: addBook.ReorderThreshold,
+		ReleaseDate:      addBook.ReleaseDate,
+		AllowBackorder:   addBook.AllowBackorder,
+
+		Contributors: addBook.Contributors,
+		PublisherId:  addBook.PublisherId,
+		CategoryIds:  addBook.CategoryIds,
+
+		WorkId:  addBook.WorkId,
+		Edition: addBook.Edition,
+		Series:  addBook.Series,
+	}
+	if addBookPayload.WorkId == "" {
+		addBookPayload.WorkId = titleId
+	}
+	if _, err := b.book.Add(ctx, addBookPayload); err != nil {
+		return "", err
+	}
+
+	// start the stock ledger
+	if !addBookPayload.IsDigital() {
+		if err := b.inventory.OpeningBalance(ctx, id, configs.DefaultLocationId, addBookPayload.Qty, configs.StockSystemActor, ""); err != nil {
+			return "", err
+		}
+	}
+
+	// start the price history
+	if err := b.prices.Record(ctx, id, 0, addBookPayload.Price, models.PriceInitial, "", ""); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// CheckIsbn fails when another book has the isbn, books without an isbn aren't checked
+func (b *Books) CheckIsbn(ctx context.Context, isbn13 string) error {
+	if isbn13 == "" {
+		return nil
+	}
+	if _, err := b.book.GetByIsbn(ctx, isbn13); err == nil {
+		return repo.ErrIsbnExists
+	} else if err != repo.ErrBookNotFound {
+		return err
+	}
+	return nil
+}
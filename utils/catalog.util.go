@@ -0,0 +1,457 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/agustadewa/book-system/models"
+	"github.com/agustadewa/book-system/repo"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var ErrCategoryCycle = errors.New("a category can't be moved below itself")
+var ErrAuthorRequired = errors.New("author or contributors is required")
+
+// Catalog keeps the authors, publishers and categories of the books. Books keep the names of their
+// entities too so they can be shown without a lookup, renaming an entity renames it on its books.
+type Catalog struct {
+	author    *repo.Author
+	publisher *repo.Publisher
+	category  *repo.Category
+	book      *repo.Book
+}
+
+func NewCatalog(client *mongo.Client) *Catalog {
+	return &Catalog{
+		author:    repo.NewAuthor(client),
+		publisher: repo.NewPublisher(client),
+		category:  repo.NewCategory(client),
+		book:      repo.NewBook(client),
+	}
+}
+
+// NameKey returns a name in lower case without spaces and punctuation, names with the same key are the
+// same entity
+func NameKey(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// AuthorNames returns the names shown as the author of a book, the contributors in the author role or
+// every contributor when none of them is. Names are separated by semicolons, a name may have a comma.
+func AuthorNames(contributors []models.Contributor) string {
+	var names []string
+	for _, contributor := range contributors {
+		if contributor.Role.IsAuthor() {
+			names = append(names, contributor.Name)
+		}
+	}
+	if len(names) == 0 {
+		for _, contributor := range contributors {
+			names = append(names, contributor.Name)
+		}
+	}
+	return strings.Join(names, "; ")
+}
+
+// Contributors returns the contributors of a book, contributors named without an id are matched to the
+// author of that name. Without contributors the author names, separated by semicolons or ampersands, are
+// matched to authors in the author role. Commas don't separate names, "Last, First" is one author. Missing
+// authors are created.
+func (c *Catalog) Contributors(ctx context.Context, reqs []models.ContributorReq, authorNames string) ([]models.Contributor, error) {
+	contributors := make([]models.Contributor, 0, len(reqs))
+	if len(reqs) == 0 {
+		names := strings.FieldsFunc(authorNames, func(r rune) bool { return r == ';' || r == '&' })
+		for _, name := range names {
+			author, err := c.AuthorByName(ctx, name)
+			if err == errEmptyName {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+			contributors = appendContributor(contributors, models.Contributor{AuthorId: author.Id, Name: author.Name, Role: models.AuthorRole})
+		}
+		if len(contributors) == 0 {
+			return nil, ErrAuthorRequired
+		}
+		return contributors, nil
+	}
+
+	for _, req := range reqs {
+		role := models.AuthorRole
+		var err error
+		if req.Role != "" {
+			if role, err = models.IsValidContributorRole(req.Role); err != nil {
+				return nil, err
+			}
+		}
+		var author *models.Author
+		if req.AuthorId != "" {
+			author, err = c.author.Get(ctx, req.AuthorId)
+		} else {
+			author, err = c.AuthorByName(ctx, req.Name)
+		}
+		if err == errEmptyName {
+			return nil, errors.New("contributor author_id or name is required")
+		} else if err != nil {
+			return nil, err
+		}
+		contributors = appendContributor(contributors, models.Contributor{AuthorId: author.Id, Name: author.Name, Role: role})
+	}
+	return contributors, nil
+}
+
+// appendContributor adds a contributor unless the author is already there in the same role
+func appendContributor(contributors []models.Contributor, contributor models.Contributor) []models.Contributor {
+	for _, c := range contributors {
+		if c.AuthorId == contributor.AuthorId && c.Role == contributor.Role {
+			return contributors
+		}
+	}
+	return append(contributors, contributor)
+}
+
+var errEmptyName = errors.New("name is required")
+
+// AuthorByName returns the author with a name, the author is created when there is none
+func (c *Catalog) AuthorByName(ctx context.Context, name string) (*models.Author, error) {
+	name = strings.TrimSpace(name)
+	nameKey := NameKey(name)
+	if nameKey == "" {
+		return nil, errEmptyName
+	}
+	if author, err := c.author.GetByNameKey(ctx, nameKey); err != repo.ErrAuthorNotFound {
+		return author, err
+	}
+
+	author := models.Author{
+		Id:        primitive.NewObjectID().Hex(),
+		Name:      name,
+		NameKey:   nameKey,
+		CreatedAt: time.Now(),
+	}
+	// someone else may have just added it
+	if _, err := c.author.Add(ctx, author); err == repo.ErrAuthorExists {
+		return c.author.GetByNameKey(ctx, nameKey)
+	} else if err != nil {
+		return nil, err
+	}
+	return &author, nil
+}
+
+// PublisherOf returns the publisher of a book by its id, or by its name when there is no id. A publisher
+// named but missing is created.
+func (c *Catalog) PublisherOf(ctx context.Context, publisherId *string, name *string) (*models.Publisher, error) {
+	if publisherId != nil && *publisherId != "" {
+		return c.publisher.Get(ctx, *publisherId)
+	}
+	if name == nil || NameKey(*name) == "" {
+		return nil, errors.New("publisher or publisher_id is required")
+	}
+
+	nameKey := NameKey(*name)
+	if publisher, err := c.publisher.GetByNameKey(ctx, nameKey); err != repo.ErrPublisherNotFound {
+		return publisher, err
+	}
+
+	publisher := models.Publisher{
+		Id:        primitive.NewObjectID().Hex(),
+		Name:      strings.TrimSpace(*name),
+		NameKey:   nameKey,
+		CreatedAt: time.Now(),
+	}
+	if _, err := c.publisher.Add(ctx, publisher); err == repo.ErrPublisherExists {
+		return c.publisher.GetByNameKey(ctx, nameKey)
+	} else if err != nil {
+		return nil, err
+	}
+	return &publisher, nil
+}
+
+// CategoryOf returns the category of a book by its id, or by its name when there is no id. A category
+// named but missing is created at the root of the tree.
+func (c *Catalog) CategoryOf(ctx context.Context, categoryId *string, name *string) (*models.Category, error) {
+	if categoryId != nil && *categoryId != "" {
+		return c.category.Get(ctx, *categoryId)
+	}
+	if name == nil || NameKey(*name) == "" {
+		return nil, errors.New("category or category_id is required")
+	}
+	return c.AddCategory(ctx, "", *name, true)
+}
+
+// AddCategory creates a category below a parent, an empty parent id is the root. With existing a category
+// of the same name is returned instead of failing.
+func (c *Catalog) AddCategory(ctx context.Context, parentId string, name string, existing bool) (*models.Category, error) {
+	nameKey := NameKey(name)
+	if nameKey == "" {
+		return nil, errEmptyName
+	}
+
+	path := make([]string, 0)
+	if parentId != "" {
+		parent, err := c.category.Get(ctx, parentId)
+		if err != nil {
+			return nil, err
+		}
+		path = parent.Lineage()
+	}
+
+	category := models.Category{
+		Id:        primitive.NewObjectID().Hex(),
+		Name:      strings.TrimSpace(name),
+		NameKey:   nameKey,
+		ParentId:  parentId,
+		Path:      path,
+		CreatedAt: time.Now(),
+	}
+	if _, err := c.category.Add(ctx, category); err == repo.ErrCategoryExists && existing {
+		return c.category.GetByNameKey(ctx, parentId, nameKey)
+	} else if err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+// Tree returns the category tree, children sorted by name
+func (c *Catalog) Tree(ctx context.Context) ([]models.CategoryNode, error) {
+	categories, err := c.category.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	children := make(map[string][]models.Category)
+	for _, category := range *categories {
+		children[category.ParentId] = append(children[category.ParentId], category)
+	}
+
+	var nodesOf func(parentId string) []models.CategoryNode
+	nodesOf = func(parentId string) []models.CategoryNode {
+		nodes := make([]models.CategoryNode, 0, len(children[parentId]))
+		for _, category := range children[parentId] {
+			nodes = append(nodes, models.CategoryNode{Category: category, Children: nodesOf(category.Id)})
+		}
+		return nodes
+	}
+	return nodesOf(""), nil
+}
+
+// UpdateAuthor renames an author or changes the bio, a new name is set on the author's books
+func (c *Catalog) UpdateAuthor(ctx context.Context, authorId string, updateAuthor models.UpdateAuthor) (*models.Author, error) {
+	author, err := c.author.Get(ctx, authorId)
+	if err != nil {
+		return nil, err
+	}
+	oldName := author.Name
+
+	if updateAuthor.Name != nil {
+		if author.NameKey = NameKey(*updateAuthor.Name); author.NameKey == "" {
+			return nil, errEmptyName
+		}
+		author.Name = strings.TrimSpace(*updateAuthor.Name)
+	}
+	if updateAuthor.Bio != nil {
+		author.Bio = *updateAuthor.Bio
+	}
+	if err = c.author.Update(ctx, *author); err != nil {
+		return nil, err
+	}
+	if author.Name == oldName {
+		return author, nil
+	}
+
+	books, err := c.book.GetAllByAuthorId(ctx, authorId, 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, book := range *books {
+		contributors := book.Contributors
+		for i := range contributors {
+			if contributors[i].AuthorId == authorId {
+				contributors[i].Name = author.Name
+			}
+		}
+		names := AuthorNames(contributors)
+		if err = c.book.SetEntities(ctx, book.Id, models.UpdateTitle{Author: &names, Contributors: &contributors}); err != nil {
+			return nil, err
+		}
+	}
+	return author, nil
+}
+
+// UpdatePublisher renames a publisher or changes the website, a new name is set on the publisher's books
+func (c *Catalog) UpdatePublisher(ctx context.Context, publisherId string, updatePublisher models.UpdatePublisher) (*models.Publisher, error) {
+	publisher, err := c.publisher.Get(ctx, publisherId)
+	if err != nil {
+		return nil, err
+	}
+	oldName := publisher.Name
+
+	if updatePublisher.Name != nil {
+		if publisher.NameKey = NameKey(*updatePublisher.Name); publisher.NameKey == "" {
+			return nil, errEmptyName
+		}
+		publisher.Name = strings.TrimSpace(*updatePublisher.Name)
+	}
+	if updatePublisher.Website != nil {
+		publisher.Website = *updatePublisher.Website
+	}
+	if err = c.publisher.Update(ctx, *publisher); err != nil {
+		return nil, err
+	}
+	if publisher.Name != oldName {
+		if err = c.book.RenamePublisher(ctx, publisherId, publisher.Name); err != nil {
+			return nil, err
+		}
+	}
+	return publisher, nil
+}
+
+// UpdateCategory renames a category or moves it with everything below it to another parent. The books
+// below it get their new lineage so they're still found from every category above them.
+func (c *Catalog) UpdateCategory(ctx context.Context, categoryId string, updateCategory models.UpdateCategory) (*models.Category, error) {
+	category, err := c.category.Get(ctx, categoryId)
+	if err != nil {
+		return nil, err
+	}
+	oldName, oldPath := category.Name, category.Path
+
+	if updateCategory.Name != nil {
+		if category.NameKey = NameKey(*updateCategory.Name); category.NameKey == "" {
+			return nil, errEmptyName
+		}
+		category.Name = strings.TrimSpace(*updateCategory.Name)
+	}
+
+	moved := updateCategory.ParentId != nil && *updateCategory.ParentId != category.ParentId
+	if moved {
+		path := make([]string, 0)
+		if *updateCategory.ParentId != "" {
+			parent, err := c.category.Get(ctx, *updateCategory.ParentId)
+			if err != nil {
+				return nil, err
+			}
+			for _, id := range parent.Lineage() {
+				if id == categoryId {
+					return nil, ErrCategoryCycle
+				}
+			}
+			path = parent.Lineage()
+		}
+		category.ParentId, category.Path = *updateCategory.ParentId, path
+	}
+
+	if err = c.category.Update(ctx, *category); err != nil {
+		return nil, err
+	}
+
+	if moved {
+		// the descendants keep their ancestors from the category down
+		descendants, err := c.category.GetAllDescendants(ctx, categoryId)
+		if err != nil {
+			return nil, err
+		}
+		for _, descendant := range *descendants {
+			path := append(append(make([]string, 0, len(descendant.Path)), category.Path...), descendant.Path[len(oldPath):]...)
+			if err = c.category.SetPath(ctx, descendant.Id, path); err != nil {
+				return nil, err
+			}
+		}
+		if err = c.book.MoveCategory(ctx, categoryId, category.Path); err != nil {
+			return nil, err
+		}
+	}
+	if category.Name != oldName {
+		if err = c.book.RenameCategory(ctx, categoryId, category.Name); err != nil {
+			return nil, err
+		}
+	}
+	return category, nil
+}
+
+// DeleteAuthor deletes an author without books
+func (c *Catalog) DeleteAuthor(ctx context.Context, authorId string) error {
+	if count, err := c.book.CountByAuthorId(ctx, authorId); err != nil {
+		return err
+	} else if count > 0 {
+		return errors.New("author still has books")
+	}
+	return c.author.Delete(ctx, authorId)
+}
+
+// DeletePublisher deletes a publisher without books
+func (c *Catalog) DeletePublisher(ctx context.Context, publisherId string) error {
+	if count, err := c.book.CountByPublisherId(ctx, publisherId); err != nil {
+		return err
+	} else if count > 0 {
+		return errors.New("publisher still has books")
+	}
+	return c.publisher.Delete(ctx, publisherId)
+}
+
+// DeleteCategory deletes a category without subcategories and books
+func (c *Catalog) DeleteCategory(ctx context.Context, categoryId string) error {
+	if count, err := c.category.CountChildren(ctx, categoryId); err != nil {
+		return err
+	} else if count > 0 {
+		return errors.New("category still has subcategories")
+	}
+	if count, err := c.book.CountByCategoryId(ctx, categoryId); err != nil {
+		return err
+	} else if count > 0 {
+		return errors.New("category still has books")
+	}
+	return c.category.Delete(ctx, categoryId)
+}
+
+// EnsureEntities links books from before the catalogue entities to the authors, publishers and categories
+// of their names, creating the missing ones
+func (c *Catalog) EnsureEntities(ctx context.Context) error {
+	books, err := c.book.GetAllWithoutEntities(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, book := range *books {
+		var updatePayload models.UpdateTitle
+
+		// books without an author name get no contributors, so they aren't looked at again
+		contributors, err := c.Contributors(ctx, nil, book.Author)
+		if err == ErrAuthorRequired {
+			contributors = make([]models.Contributor, 0)
+		} else if err != nil {
+			return err
+		}
+		updatePayload.Contributors = &contributors
+
+		if NameKey(book.Publisher) != "" {
+			publisher, err := c.PublisherOf(ctx, nil, &book.Publisher)
+			if err != nil {
+				return err
+			}
+			updatePayload.PublisherId = &publisher.Id
+		}
+		if NameKey(book.Category) != "" {
+			category, err := c.CategoryOf(ctx, nil, &book.Category)
+			if err != nil {
+				return err
+			}
+			categoryIds := category.Lineage()
+			updatePayload.CategoryIds = &categoryIds
+		}
+
+		if err = c.book.SetEntities(ctx, book.Id, updatePayload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
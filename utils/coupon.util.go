@@ -0,0 +1,198 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/agustadewa/book-system/models"
+	"github.com/agustadewa/book-system/repo"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var ErrCouponInactive = errors.New("coupon is not active")
+var ErrCouponNotStarted = errors.New("coupon is not valid yet")
+var ErrCouponExpired = errors.New("coupon has expired")
+var ErrCouponNotApplicable = errors.New("coupon doesn't apply to the book")
+
+// Coupons redeems coupons for orders and gives them back when an order doesn't go through
+type Coupons struct {
+	coupon     *repo.Coupon
+	redemption *repo.CouponRedemption
+	catalog    *Catalog
+}
+
+func NewCoupons(client *mongo.Client) *Coupons {
+	return &Coupons{coupon: repo.NewCoupon(client), redemption: repo.NewCouponRedemption(client), catalog: NewCatalog(client)}
+}
+
+// GetByCode returns a coupon by the code a customer typed
+func (c *Coupons) GetByCode(ctx context.Context, code string) (*models.Coupon, error) {
+	return c.coupon.GetByCode(ctx, NormalizeCouponCode(code))
+}
+
+// Redeem counts the coupon used by an order
+func (c *Coupons) Redeem(ctx context.Context, coupon *models.Coupon, userId string, orderId string, discount int64) error {
+	if err := c.coupon.Redeem(ctx, coupon, userId); err != nil {
+		return err
+	}
+
+	redemption := models.CouponRedemption{
+		Id:        orderId,
+		CouponId:  coupon.Id,
+		Code:      coupon.Code,
+		UserId:    userId,
+		OrderId:   orderId,
+		Discount:  discount,
+		CreatedAt: time.Now(),
+	}
+	if err := c.redemption.Add(ctx, redemption); err != nil {
+		_ = c.coupon.Release(ctx, coupon.Id, userId)
+		return err
+	}
+	return nil
+}
+
+// Release gives back the coupon of a cancelled, declined or expired order. Orders without a coupon and
+// orders already released are ignored.
+func (c *Coupons) Release(ctx context.Context, orderId string) error {
+	redemption, err := c.redemption.SetReleased(ctx, orderId, time.Now())
+	if err == repo.ErrCouponRedemptionNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return c.coupon.Release(ctx, redemption.CouponId, redemption.UserId)
+}
+
+// EnsureEntities links coupons from before the catalogue entities to the authors and categories of their
+// names. Missing ones are created like for books, so a coupon stays restricted to them.
+func (c *Coupons) EnsureEntities(ctx context.Context) error {
+	coupons, err := c.coupon.GetAllWithNames(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, coupon := range *coupons {
+		authorIds := coupon.AuthorIds
+		for _, name := range coupon.Authors {
+			author, err := c.catalog.AuthorByName(ctx, name)
+			if err == errEmptyName {
+				continue
+			} else if err != nil {
+				return err
+			}
+			authorIds = append(authorIds, author.Id)
+		}
+		categoryIds := coupon.CategoryIds
+		for _, name := range coupon.Categories {
+			if NameKey(name) == "" {
+				continue
+			}
+			category, err := c.catalog.CategoryOf(ctx, nil, &name)
+			if err != nil {
+				return err
+			}
+			categoryIds = append(categoryIds, category.Id)
+		}
+		if err = c.coupon.SetEntities(ctx, coupon.Id, authorIds, categoryIds); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CouponDiscount returns the discount a coupon gives on a subtotal of a book. Usage limits are checked
+// when the coupon is redeemed.
+func CouponDiscount(coupon models.Coupon, book *models.Book, subtotal int64, now time.Time) (int64, error) {
+	if !coupon.Active {
+		return 0, ErrCouponInactive
+	}
+	if coupon.ValidFrom != nil && now.Before(*coupon.ValidFrom) {
+		return 0, ErrCouponNotStarted
+	}
+	if coupon.ValidUntil != nil && !now.Before(*coupon.ValidUntil) {
+		return 0, ErrCouponExpired
+	}
+	if subtotal < coupon.MinSpend {
+		return 0, errors.New("order doesn't reach the coupon minimum spend")
+	}
+	if !couponAppliesTo(coupon, book) {
+		return 0, ErrCouponNotApplicable
+	}
+
+	discount := coupon.Value
+	if coupon.Type.IsPercentage() {
+		discount = subtotal * coupon.Value / 100
+		if coupon.MaxDiscount > 0 && discount > coupon.MaxDiscount {
+			discount = coupon.MaxDiscount
+		}
+	}
+	if discount > subtotal {
+		discount = subtotal
+	}
+	return discount, nil
+}
+
+// ValidateCoupon checks the terms of a coupon before it is stored
+func ValidateCoupon(coupon models.Coupon) error {
+	if _, err := models.IsValidCouponType(coupon.Type.String()); err != nil {
+		return err
+	}
+	if coupon.Code == "" {
+		return errors.New("coupon code is required")
+	}
+	if coupon.Value <= 0 {
+		return errors.New("coupon value minimum is 1")
+	}
+	if coupon.Type.IsPercentage() && coupon.Value > 100 {
+		return errors.New("percentage coupon value maximum is 100")
+	}
+	if coupon.MaxDiscount < 0 || coupon.MinSpend < 0 || coupon.UsageLimit < 0 || coupon.PerUserLimit < 0 {
+		return errors.New("coupon limits can't be lower than 0")
+	}
+	if coupon.ValidFrom != nil && coupon.ValidUntil != nil && !coupon.ValidFrom.Before(*coupon.ValidUntil) {
+		return errors.New("coupon valid from must be before valid until")
+	}
+	return nil
+}
+
+// NormalizeCouponCode makes codes case insensitive
+func NormalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// couponAppliesTo tells whether a coupon can be used for a book, a coupon for a book is good for every format
+// variant of its title. Authors are matched to the contributors of the book and categories to every
+// category of its lineage.
+func couponAppliesTo(coupon models.Coupon, book *models.Book) bool {
+	if len(coupon.BookIds) == 0 && len(coupon.AuthorIds) == 0 && len(coupon.CategoryIds) == 0 {
+		return true
+	}
+	for _, bookId := range coupon.BookIds {
+		if bookId == book.Id || (book.TitleId != "" && bookId == book.TitleId) {
+			return true
+		}
+	}
+	for _, contributor := range book.Contributors {
+		if containsId(coupon.AuthorIds, contributor.AuthorId) {
+			return true
+		}
+	}
+	for _, categoryId := range book.CategoryIds {
+		if containsId(coupon.CategoryIds, categoryId) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsId(ids []string, id string) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
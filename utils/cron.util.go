@@ -5,6 +5,7 @@ import (
 	"log"
 	"time"
 
+	"github.com/agustadewa/book-system/configs"
 	"github.com/agustadewa/book-system/models"
 	"github.com/agustadewa/book-system/repo"
 	"github.com/go-co-op/gocron"
@@ -12,16 +13,28 @@ import (
 )
 
 type cron struct {
-	cron  *gocron.Scheduler
-	order *repo.Order
-	book  *repo.Book
+	cron      *gocron.Scheduler
+	order     *repo.Order
+	payment   *repo.Payment
+	coupons   *Coupons
+	prices    *Prices
+	inventory *Inventory
+	reorder   *Reorder
+	importer  *Importer
+	orders    *Orders
 }
 
 func NewCronJob(mongoClient *mongo.Client) *cron {
 	return &cron{
-		cron:  gocron.NewScheduler(time.UTC),
-		order: repo.NewOrder(mongoClient),
-		book:  repo.NewBook(mongoClient),
+		cron:      gocron.NewScheduler(time.UTC),
+		order:     repo.NewOrder(mongoClient),
+		payment:   repo.NewPayment(mongoClient),
+		coupons:   NewCoupons(mongoClient),
+		prices:    NewPrices(mongoClient),
+		inventory: NewInventory(mongoClient),
+		reorder:   NewReorder(mongoClient),
+		importer:  NewImporter(mongoClient),
+		orders:    NewOrders(mongoClient),
 	}
 }
 
@@ -38,13 +51,66 @@ func (c *cron) removeAllIdleOrders(ctx context.Context) {
 			log.Println("[CRON JOB ERROR] ", err)
 			return
 		}
+		// pre-orders and back-orders wait for payment from when their books came in, the customer didn't
+		// expect them then and gets time to pay
+		idle := 30 * time.Second
+		if order.AllocatedAt != nil {
+			orderTime = *order.AllocatedAt
+			idle = configs.AllocatedPaymentHours * time.Hour
+		}
 
 		gap := time.Now().Sub(orderTime)
-		if gap > 30*time.Second {
+		if gap > idle {
+
+			// keep orders with a payment in progress, a receipt waiting for review waits for the admin and a
+			// payment the customer didn't complete expires
+			if payment, err := c.payment.GetByOrderIdAndInProgress(ctx, order.Id); err == nil {
+				if !payment.Status.IsPending() {
+					continue
+				}
+				createdAt, err := time.Parse(time.RFC3339, payment.CreatedAt)
+				if err != nil {
+					log.Println("[CRON JOB ERROR] ", err)
+					return
+				}
+				if time.Now().Sub(createdAt) <= configs.PaymentPendingTTL {
+					continue
+				}
+				if err = c.payment.UpdateStatusFrom(ctx, payment.Id, payment.Status, models.PaymentFailed); err != nil && err != repo.ErrPaymentStatusChanged {
+					log.Println("[CRON JOB ERROR] ", err.Error())
+					return
+				}
+				log.Printf("[CRON JOB] payment id %v is expired\n", payment.Id)
+				continue
+			} else if err != repo.ErrPaymentNotFound {
+				log.Println("[CRON JOB ERROR] ", err.Error())
+				return
+			}
 
-			// update book quantity
-			if err = c.book.UpdateStock(ctx, order.BookId, order.Qty); err != nil {
-				log.Println("[CRON JOB ERROR] ", err)
+			// partially paid orders get time for the rest, then they are cancelled and the paid amount credited
+			if order.PaidAmount > 0 {
+				if gap <= configs.PartialPaymentTTL {
+					continue
+				}
+				if err = c.orders.CallOff(ctx, &order, models.Cancelled, "partly paid order expired"); err != nil {
+					log.Println("[CRON JOB ERROR] ", err.Error())
+					return
+				}
+				log.Printf("[CRON JOB] order id %v is cancelled\n", order.Id)
+				continue
+			}
+
+			// put the books back in stock
+			if order.TakesStock() {
+				if _, err = c.inventory.Move(ctx, order.BookId, order.LocationId, order.Qty, models.StockExpiryRestore, configs.StockSystemActor, order.Id, ""); err != nil {
+					log.Println("[CRON JOB ERROR] ", err)
+					return
+				}
+			}
+
+			// give back the coupon
+			if err = c.coupons.Release(ctx, order.Id); err != nil {
+				log.Println("[CRON JOB ERROR] ", err.Error())
 				return
 			}
 
@@ -58,10 +124,57 @@ func (c *cron) removeAllIdleOrders(ctx context.Context) {
 	}
 }
 
+func (c *cron) applyPriceSchedules(ctx context.Context) {
+	if err := c.prices.ApplySchedules(ctx, time.Now()); err != nil {
+		log.Println("[CRON JOB ERROR] ", err.Error())
+	}
+}
+
+func (c *cron) expireWaitlistHolds(ctx context.Context) {
+	if err := c.inventory.Waitlist().ExpireHolds(ctx, time.Now()); err != nil {
+		log.Println("[CRON JOB ERROR] ", err.Error())
+	}
+}
+
+func (c *cron) allocateBackorders(ctx context.Context) {
+	if err := c.inventory.Backorders().AllocateAll(ctx); err != nil {
+		log.Println("[CRON JOB ERROR] ", err.Error())
+	}
+}
+
+func (c *cron) checkLowStock(ctx context.Context) {
+	if err := c.reorder.CheckLowStock(ctx); err != nil {
+		log.Println("[CRON JOB ERROR] ", err.Error())
+	}
+}
+
+func (c *cron) runImports(ctx context.Context) {
+	if err := c.importer.RunQueued(ctx); err != nil {
+		log.Println("[CRON JOB ERROR] ", err.Error())
+	}
+}
+
 func (c *cron) DoCronJobTasks(ctx context.Context) {
 	if _, err := c.cron.Every(5).Second().Do(func() {
 		log.Println("[CRON JOB] doing cron job tasks")
 		c.removeAllIdleOrders(ctx)
+		c.applyPriceSchedules(ctx)
+		c.expireWaitlistHolds(ctx)
+	}); err != nil {
+		log.Println("[CRON JOB ERROR] ", err.Error())
+		return
+	}
+	if _, err := c.cron.Every(1).Minute().Do(func() {
+		c.allocateBackorders(ctx)
+		c.checkLowStock(ctx)
+	}); err != nil {
+		log.Println("[CRON JOB ERROR] ", err.Error())
+		return
+	}
+
+	// an import can run for minutes, the next run waits for it
+	if _, err := c.cron.Every(5).Second().SingletonMode().Do(func() {
+		c.runImports(ctx)
 	}); err != nil {
 		log.Println("[CRON JOB ERROR] ", err.Error())
 		return
@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/agustadewa/book-system/configs"
+	"github.com/agustadewa/book-system/models"
+	"github.com/agustadewa/book-system/repo"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var ErrInvalidExchangeRate = errors.New("exchange rate must be a positive decimal")
+
+// Rates converts amounts between currencies with the admin maintained exchange rate table
+type Rates struct {
+	rate *repo.ExchangeRate
+}
+
+func NewRates(client *mongo.Client) *Rates {
+	return &Rates{rate: repo.NewExchangeRate(client)}
+}
+
+// Lock returns the rate converting from one currency to another at the given time, a pair without a rate
+// is converted with the inverse of the opposite pair. Nil is returned for the same currency.
+func (r *Rates) Lock(ctx context.Context, from string, to string, at time.Time) (*models.LockedRate, error) {
+	if from == to {
+		return nil, nil
+	}
+
+	inverted := false
+	rate, err := r.rate.GetEffective(ctx, from, to, at)
+	if err == repo.ErrExchangeRateNotFound {
+		inverted = true
+		rate, err = r.rate.GetEffective(ctx, to, from, at)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.LockedRate{
+		RateId:        rate.Id,
+		Base:          rate.Base,
+		Quote:         rate.Quote,
+		Rate:          rate.Rate,
+		Inverted:      inverted,
+		EffectiveFrom: rate.EffectiveFrom,
+		LockedAt:      at,
+	}, nil
+}
+
+// Convert converts an amount with the rate in effect at the given time
+func (r *Rates) Convert(ctx context.Context, amount int64, from string, to string, at time.Time) (int64, *models.LockedRate, error) {
+	locked, err := r.Lock(ctx, from, to, at)
+	if err != nil {
+		return 0, nil, err
+	}
+	converted, err := ConvertLocked(amount, from, to, locked)
+	return converted, locked, err
+}
+
+// ConvertLocked converts an amount with a locked rate, a nil rate keeps the amount
+func ConvertLocked(amount int64, from string, to string, locked *models.LockedRate) (int64, error) {
+	if locked == nil {
+		return amount, nil
+	}
+
+	fromCurrency, err := models.IsValidCurrency(from)
+	if err != nil {
+		return 0, err
+	}
+	toCurrency, err := models.IsValidCurrency(to)
+	if err != nil {
+		return 0, err
+	}
+	rate, err := ParseExchangeRate(locked.Rate)
+	if err != nil {
+		return 0, err
+	}
+	if locked.Inverted {
+		rate.Inv(rate)
+	}
+
+	return ConvertAmount(amount, fromCurrency, toCurrency, rate), nil
+}
+
+// ConvertAmount converts minor units of a currency to minor units of another, rounded half away from zero
+func ConvertAmount(amount int64, from models.Currency, to models.Currency, rate *big.Rat) int64 {
+	value := new(big.Rat).SetInt64(amount)
+	value.Mul(value, rate)
+
+	exp := to.MinorUnits - from.MinorUnits
+	scale := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(exp))), nil))
+	if exp >= 0 {
+		value.Mul(value, scale)
+	} else {
+		value.Quo(value, scale)
+	}
+
+	quo, rem := new(big.Int).QuoRem(value.Num(), value.Denom(), new(big.Int))
+	if rem.Sign() != 0 && new(big.Int).Mul(new(big.Int).Abs(rem), big.NewInt(2)).Cmp(value.Denom()) >= 0 {
+		quo.Add(quo, big.NewInt(int64(value.Sign())))
+	}
+	return quo.Int64()
+}
+
+// ParseExchangeRate parses a decimal rate
+func ParseExchangeRate(rate string) (*big.Rat, error) {
+	r, ok := new(big.Rat).SetString(rate)
+	if !ok || r.Sign() <= 0 {
+		return nil, ErrInvalidExchangeRate
+	}
+	return r, nil
+}
+
+// CurrencyOrDefault returns the currency of an amount, amounts stored before currencies were tracked are in
+// the store currency
+func CurrencyOrDefault(currency string) string {
+	if currency == "" {
+		return configs.DefaultCurrency
+	}
+	return currency
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/agustadewa/book-system/models"
+)
+
+func TestConvertAmount(t *testing.T) {
+	currencies := map[string]models.Currency{
+		"IDR": {Code: "IDR", MinorUnits: 0},
+		"USD": {Code: "USD", MinorUnits: 2},
+		"EUR": {Code: "EUR", MinorUnits: 2},
+		"JPY": {Code: "JPY", MinorUnits: 0},
+	}
+	tests := []struct {
+		amount int64
+		from   string
+		to     string
+		rate   string
+		want   int64
+	}{
+		{1234, "USD", "EUR", "1", 1234},
+		{1000, "USD", "EUR", "0.925", 925},
+		{160000, "IDR", "USD", "0.0000625", 1000},
+		{1050, "USD", "IDR", "16000", 168000},
+		{1, "USD", "JPY", "150", 2},
+		{3, "USD", "JPY", "150", 5},
+		{1, "USD", "JPY", "149", 1},
+		{-1, "USD", "JPY", "150", -2},
+		{-1, "USD", "JPY", "149", -1},
+		{2, "USD", "EUR", "0.925", 2},
+		{1, "USD", "EUR", "1/3", 0},
+		{2, "USD", "EUR", "1/3", 1},
+	}
+	for _, tt := range tests {
+		rate, ok := new(big.Rat).SetString(tt.rate)
+		if !ok {
+			t.Fatalf("bad rate %q", tt.rate)
+		}
+		if got := ConvertAmount(tt.amount, currencies[tt.from], currencies[tt.to], rate); got != tt.want {
+			t.Errorf("ConvertAmount(%v %s to %s at %s) = %v, want %v", tt.amount, tt.from, tt.to, tt.rate, got, tt.want)
+		}
+	}
+}
+
+func TestConvertLocked(t *testing.T) {
+	tests := []struct {
+		name    string
+		amount  int64
+		from    string
+		to      string
+		locked  *models.LockedRate
+		want    int64
+		wantErr bool
+	}{
+		{"same currency", 1234, "USD", "USD", nil, 1234, false},
+		{"direct rate", 1000, "USD", "EUR", &models.LockedRate{Rate: "0.925"}, 925, false},
+		{"inverted rate", 100, "EUR", "USD", &models.LockedRate{Rate: "0.8", Inverted: true}, 125, false},
+		{"unknown currency", 100, "XXX", "USD", &models.LockedRate{Rate: "1"}, 0, true},
+		{"invalid rate", 100, "USD", "EUR", &models.LockedRate{Rate: "abc"}, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ConvertLocked(tt.amount, tt.from, tt.to, tt.locked)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("%s: ConvertLocked() = %v, %v, want %v, error %v", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestParseExchangeRate(t *testing.T) {
+	tests := []struct {
+		rate string
+		want string
+		err  error
+	}{
+		{"1.25", "5/4", nil},
+		{"16000", "16000/1", nil},
+		{"0.0000625", "1/16000", nil},
+		{"0", "", ErrInvalidExchangeRate},
+		{"-1", "", ErrInvalidExchangeRate},
+		{"abc", "", ErrInvalidExchangeRate},
+	}
+	for _, tt := range tests {
+		got, err := ParseExchangeRate(tt.rate)
+		if err != tt.err || (err == nil && got.String() != tt.want) {
+			t.Errorf("ParseExchangeRate(%q) = %v, %v, want %v, %v", tt.rate, got, err, tt.want, tt.err)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/agustadewa/book-system/configs"
+)
+
+var ErrFileNotFound = errors.New("file not found")
+var ErrInvalidFileKey = errors.New("invalid file key")
+
+// FileStore keeps the files of digital books, keys are slash separated paths inside the store
+type FileStore interface {
+	Put(ctx context.Context, key string, r io.Reader) (int64, error)
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// NewFileStore returns the store digital books are kept in
+func NewFileStore() FileStore {
+	return NewLocalFileStore(configs.BookFileDir)
+}
+
+// localFileStore keeps files in a directory of the local filesystem
+type localFileStore struct {
+	dir string
+}
+
+func NewLocalFileStore(dir string) FileStore {
+	return &localFileStore{dir: dir}
+}
+
+// Put writes the file to a temporary file first, a failed upload doesn't replace the file being served
+func (s *localFileStore) Put(_ context.Context, key string, r io.Reader) (int64, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return 0, err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return 0, err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return 0, err
+	}
+	size, err := io.Copy(tmp, r)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return 0, err
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		_ = os.Remove(tmp.Name())
+		return 0, err
+	}
+	return size, nil
+}
+
+func (s *localFileStore) Open(_ context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrFileNotFound
+	}
+	return file, err
+}
+
+func (s *localFileStore) Delete(_ context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err = os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// path returns where a key is kept, keys can't leave the store directory
+func (s *localFileStore) path(key string) (string, error) {
+	clean := filepath.Clean("/" + key)
+	if key == "" || clean == "/" || strings.Contains(key, "..") {
+		return "", ErrInvalidFileKey
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(clean)), nil
+}
@@ -0,0 +1,450 @@
+package utils
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/agustadewa/book-system/configs"
+	"github.com/agustadewa/book-system/models"
+	"github.com/agustadewa/book-system/repo"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Importer imports catalogue files in the background. Every row is checked like a book added through
+// POST /book, rows are matched to books by isbn: new isbns are added and known ones updated. Stock of
+// known books isn't imported, it only changes through stock movements.
+type Importer struct {
+	job   *repo.ImportJob
+	row   *repo.ImportRow
+	book  *repo.Book
+	books *Books
+	store FileStore
+}
+
+func NewImporter(client *mongo.Client) *Importer {
+	books := NewBooks(client)
+	return &Importer{
+		job:   repo.NewImportJob(client),
+		row:   repo.NewImportRow(client),
+		book:  books.book,
+		books: books,
+		store: NewLocalFileStore(configs.ImportFileDir),
+	}
+}
+
+// Queue keeps an uploaded file and queues its import
+func (i *Importer) Queue(ctx context.Context, adminId string, format models.ImportFormat, fileName string, r io.Reader) (*models.ImportJob, error) {
+	job := models.ImportJob{
+		Id:        primitive.NewObjectID().Hex(),
+		AdminId:   adminId,
+		Format:    format,
+		FileName:  fileName,
+		Status:    models.ImportQueued,
+		CreatedAt: time.Now(),
+	}
+	job.FileKey = job.Id + "." + strings.ToLower(format.String())
+	if _, err := i.store.Put(ctx, job.FileKey, r); err != nil {
+		return nil, err
+	}
+	if _, err := i.job.Add(ctx, job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// RunQueued runs the queued jobs one after another until none is left
+func (i *Importer) RunQueued(ctx context.Context) error {
+	for {
+		job, err := i.job.Claim(ctx, time.Now())
+		if err != nil {
+			return err
+		}
+		if job == nil {
+			return nil
+		}
+
+		log.Printf("[IMPORT] job %s of %s started\n", job.Id, job.FileName)
+		status, jobErr := models.ImportDone, ""
+		if err = i.run(ctx, job); err != nil {
+			status, jobErr = models.ImportFailed, err.Error()
+		}
+		if err = i.job.SetFinished(ctx, *job, status, jobErr, time.Now()); err != nil {
+			return err
+		}
+		log.Printf("[IMPORT] job %s %s, %v created, %v updated, %v skipped, %v errors\n",
+			job.Id, status, job.Created, job.Updated, job.Skipped, job.Errors)
+	}
+}
+
+// run imports the rows of a job, results are saved and counted in batches so the job shows its progress
+func (i *Importer) run(ctx context.Context, job *models.ImportJob) error {
+	file, err := i.store.Open(ctx, job.FileKey)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// a job run again after a restart starts over, rows already imported are found by isbn
+	if err = i.row.DeleteByJobId(ctx, job.Id); err != nil {
+		return err
+	}
+	job.Rows, job.Created, job.Updated, job.Skipped, job.Errors = 0, 0, 0, 0, 0
+
+	rows := make([]models.ImportRow, 0, configs.ImportRowBatch)
+	flush := func() error {
+		if err := i.row.AddMany(ctx, rows); err != nil {
+			return err
+		}
+		rows = rows[:0]
+		return i.job.SetProgress(ctx, *job)
+	}
+
+	each := func(record models.ImportRecord) error {
+		row := i.importRecord(ctx, job, record)
+		rows = append(rows, row)
+
+		job.Rows++
+		switch row.Status {
+		case models.ImportRowCreated:
+			job.Created++
+		case models.ImportRowUpdated:
+			job.Updated++
+		case models.ImportRowSkipped:
+			job.Skipped++
+		case models.ImportRowError:
+			job.Errors++
+		}
+
+		if len(rows) == configs.ImportRowBatch {
+			return flush()
+		}
+		return nil
+	}
+
+	switch job.Format {
+	case models.CsvImport:
+		err = ReadCsv(file, each)
+	case models.OnixImport:
+		err = ReadOnix(file, each)
+	default:
+		err = models.ErrUnknownImportFormat
+	}
+	// keep the results of the rows read before a broken file
+	if flushErr := flush(); err == nil {
+		err = flushErr
+	}
+	return err
+}
+
+// importRecord adds or updates the book of a row and returns the row result
+func (i *Importer) importRecord(ctx context.Context, job *models.ImportJob, record models.ImportRecord) models.ImportRow {
+	row := models.ImportRow{
+		Id:    primitive.NewObjectID().Hex(),
+		JobId: job.Id,
+		Row:   record.Row,
+	}
+	if record.Book.Isbn != nil {
+		row.Isbn = *record.Book.Isbn
+	}
+	if record.Book.Name != nil {
+		row.Name = *record.Book.Name
+	}
+	fail := func(err error) models.ImportRow {
+		row.Status, row.Reason = models.ImportRowError, err.Error()
+		return row
+	}
+
+	if record.Err != nil {
+		return fail(record.Err)
+	}
+	if record.Skip != "" {
+		row.Status, row.Reason = models.ImportRowSkipped, record.Skip
+		return row
+	}
+
+	// the rules of POST /book
+	if err := binding.Validator.ValidateStruct(record.Book); err != nil {
+		return fail(validationErr(err))
+	}
+	addBook, err := AddBookOf(record.Book)
+	if err != nil {
+		return fail(err)
+	}
+	if addBook.Isbn13 == "" {
+		return fail(errors.New("isbn is required, imported books are matched by isbn"))
+	}
+
+	book, err := i.book.GetByIsbn(ctx, addBook.Isbn13)
+	if err == repo.ErrBookNotFound {
+		if err = i.books.Link(ctx, &addBook, record.Book); err != nil {
+			return fail(err)
+		}
+		if row.BookId, err = i.books.Create(ctx, addBook, ""); err != nil {
+			return fail(err)
+		}
+		row.Status = models.ImportRowCreated
+		return row
+	} else if err != nil {
+		return fail(err)
+	}
+
+	row.BookId = book.Id
+	changed, err := i.update(ctx, job, book, addBook, record.Book)
+	if err != nil {
+		return fail(err)
+	}
+	if !changed {
+		row.Status, row.Reason = models.ImportRowSkipped, "book is up to date"
+		return row
+	}
+	row.Status = models.ImportRowUpdated
+	return row
+}
+
+// update brings a known book in line with its row, fields missing from the row are left alone. It
+// reports whether anything changed.
+func (i *Importer) update(ctx context.Context, job *models.ImportJob, book *models.Book, addBook models.AddBook, req models.AddBookReq) (bool, error) {
+	if req.Format != nil && addBook.Format != book.Format {
+		return false, fmt.Errorf("isbn belongs to a %s book", book.Format)
+	}
+	if err := i.books.Link(ctx, &addBook, req); err != nil {
+		return false, err
+	}
+
+	var updatePayload models.UpdateBook
+	if addBook.Price != book.Price {
+		updatePayload.Price = &addBook.Price
+	}
+	if req.Sku != nil && addBook.Sku != "" && addBook.Sku != book.Sku {
+		updatePayload.Sku = &addBook.Sku
+	}
+	if req.WeightGrams != nil && addBook.WeightGrams != book.WeightGrams {
+		updatePayload.WeightGrams = &addBook.WeightGrams
+	}
+	if req.Dimensions != nil && (book.Dimensions == nil || *addBook.Dimensions != *book.Dimensions) {
+		updatePayload.Dimensions = addBook.Dimensions
+	}
+	if req.TaxClass != nil && addBook.TaxClass != book.TaxClass {
+		updatePayload.TaxClass = &addBook.TaxClass
+	}
+	if req.ReorderThreshold != nil && addBook.ReorderThreshold != book.ReorderThreshold {
+		updatePayload.ReorderThreshold = &addBook.ReorderThreshold
+	}
+	if req.ReleaseDate != nil && (book.ReleaseDate == nil || !addBook.ReleaseDate.Equal(*book.ReleaseDate)) {
+		updatePayload.ReleaseDate = addBook.ReleaseDate
+	}
+	if req.AllowBackorder != nil && addBook.AllowBackorder != book.AllowBackorder {
+		updatePayload.AllowBackorder = &addBook.AllowBackorder
+	}
+	// a new currency comes with the row price in it
+	priceReason := models.PriceImport
+	if req.Currency != nil && addBook.Currency != CurrencyOrDefault(book.Currency) {
+		price, err := i.books.prices.ChangeCurrency(ctx, book, addBook.Currency, &addBook.Price)
+		if err != nil {
+			return false, err
+		}
+		updatePayload.Currency, updatePayload.Price = &addBook.Currency, &price
+		priceReason = models.PriceCurrencyChange
+	}
+
+	var titlePayload models.UpdateTitle
+	if addBook.Name != book.Name {
+		titlePayload.Name = &addBook.Name
+	}
+	if addBook.Language != book.Language {
+		titlePayload.Language = &addBook.Language
+	}
+	if addBook.Description != book.Description {
+		titlePayload.Description = &addBook.Description
+	}
+	if addBook.Image != book.Image {
+		titlePayload.Image = &addBook.Image
+	}
+	if !reflect.DeepEqual(addBook.Contributors, book.Contributors) {
+		titlePayload.Contributors, titlePayload.Author = &addBook.Contributors, &addBook.Author
+	}
+	if addBook.PublisherId != book.PublisherId {
+		titlePayload.PublisherId, titlePayload.Publisher = &addBook.PublisherId, &addBook.Publisher
+	}
+	if !reflect.DeepEqual(addBook.CategoryIds, book.CategoryIds) {
+		titlePayload.CategoryIds, titlePayload.Category = &addBook.CategoryIds, &addBook.Category
+	}
+	if req.Edition != nil && addBook.Edition != book.Edition {
+		titlePayload.Edition = &addBook.Edition
+	}
+
+	if updatePayload.IsEmpty() && titlePayload.IsEmpty() {
+		return false, nil
+	}
+	if !updatePayload.IsEmpty() {
+		if err := i.book.Update(ctx, book.Id, updatePayload); err != nil {
+			return false, err
+		}
+	}
+	if !titlePayload.IsEmpty() {
+		if err := i.book.UpdateTitle(ctx, book.TitleId, titlePayload); err != nil {
+			return false, err
+		}
+	}
+
+	// keep the old price in the history
+	if updatePayload.Price != nil {
+		if err := i.books.prices.Record(ctx, book.Id, book.Price, *updatePayload.Price, priceReason, "", job.AdminId); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// Report writes the results of a job as csv in row order, a non empty status filters the rows
+func (i *Importer) Report(ctx context.Context, jobId string, status models.ImportRowStatus, w io.Writer) error {
+	out := csv.NewWriter(w)
+	if err := out.Write([]string{"row", "isbn", "name", "status", "book_id", "reason"}); err != nil {
+		return err
+	}
+
+	for skip := int64(0); ; skip += configs.ImportRowBatch {
+		rows, err := i.row.GetAllByJobId(ctx, jobId, status, skip, configs.ImportRowBatch)
+		if err != nil {
+			return err
+		}
+		for _, row := range *rows {
+			record := []string{strconv.FormatInt(row.Row, 10), row.Isbn, row.Name, row.Status.String(), row.BookId, row.Reason}
+			if err = out.Write(record); err != nil {
+				return err
+			}
+		}
+		if len(*rows) < configs.ImportRowBatch {
+			break
+		}
+	}
+
+	out.Flush()
+	return out.Error()
+}
+
+// csvStrings and csvInts are the columns of a csv import with release_date, as YYYY-MM-DD, and
+// allow_backorder. Columns are named in the header row in any order, prices are in minor units of the
+// currency like in POST /book.
+var csvStrings = map[string]func(book *models.AddBookReq) **string{
+	"isbn":        func(book *models.AddBookReq) **string { return &book.Isbn },
+	"sku":         func(book *models.AddBookReq) **string { return &book.Sku },
+	"format":      func(book *models.AddBookReq) **string { return &book.Format },
+	"name":        func(book *models.AddBookReq) **string { return &book.Name },
+	"author":      func(book *models.AddBookReq) **string { return &book.Author },
+	"publisher":   func(book *models.AddBookReq) **string { return &book.Publisher },
+	"category":    func(book *models.AddBookReq) **string { return &book.Category },
+	"language":    func(book *models.AddBookReq) **string { return &book.Language },
+	"description": func(book *models.AddBookReq) **string { return &book.Description },
+	"image":       func(book *models.AddBookReq) **string { return &book.Image },
+	"edition":     func(book *models.AddBookReq) **string { return &book.Edition },
+	"currency":    func(book *models.AddBookReq) **string { return &book.Currency },
+	"tax_class":   func(book *models.AddBookReq) **string { return &book.TaxClass },
+}
+
+var csvInts = map[string]func(book *models.AddBookReq) **int64{
+	"price":             func(book *models.AddBookReq) **int64 { return &book.Price },
+	"qty":               func(book *models.AddBookReq) **int64 { return &book.Qty },
+	"weight_grams":      func(book *models.AddBookReq) **int64 { return &book.WeightGrams },
+	"reorder_threshold": func(book *models.AddBookReq) **int64 { return &book.ReorderThreshold },
+}
+
+// setCsvValue sets the field of a column, unknown columns are left out
+func setCsvValue(book *models.AddBookReq, column string, value string) error {
+	if field, ok := csvStrings[column]; ok {
+		*field(book) = &value
+		return nil
+	}
+	if field, ok := csvInts[column]; ok {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s isn't a whole number", column)
+		}
+		*field(book) = &n
+		return nil
+	}
+
+	switch column {
+	case "release_date":
+		releaseDate, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return fmt.Errorf("%s isn't a YYYY-MM-DD date", column)
+		}
+		book.ReleaseDate = &releaseDate
+	case "allow_backorder":
+		allowBackorder, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s isn't true or false", column)
+		}
+		book.AllowBackorder = &allowBackorder
+	}
+	return nil
+}
+
+// ReadCsv reads the books of a csv file with a header row, empty cells are missing values
+func ReadCsv(r io.Reader, each func(models.ImportRecord) error) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return errors.New("csv file is empty")
+	} else if err != nil {
+		return err
+	}
+	columns := make([]string, len(header))
+	for c, name := range header {
+		columns[c] = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+	}
+
+	var row int64
+	for {
+		values, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		row++
+
+		record := models.ImportRecord{Row: row}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			record.Err = parseErr.Err
+		} else if err != nil {
+			return err
+		}
+
+		for c := 0; c < len(values) && c < len(columns) && record.Err == nil; c++ {
+			if value := strings.TrimSpace(values[c]); value != "" {
+				record.Err = setCsvValue(&record.Book, columns[c], value)
+			}
+		}
+		if err = each(record); err != nil {
+			return err
+		}
+	}
+}
+
+// validationErr words binding errors like POST /book does, e.g. "Price is required"
+func validationErr(err error) error {
+	var ve validator.ValidationErrors
+	if !errors.As(err, &ve) {
+		return err
+	}
+	errStrs := make([]string, 0, len(ve))
+	for _, fe := range ve {
+		errStrs = append(errStrs, fmt.Sprintf("%s is %s", fe.Field(), fe.Tag()))
+	}
+	return errors.New(strings.Join(errStrs, ", "))
+}
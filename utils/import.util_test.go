@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"encoding/csv"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agustadewa/book-system/models"
+)
+
+func stringOf(value string) *string {
+	return &value
+}
+
+func int64Of(value int64) *int64 {
+	return &value
+}
+
+func TestReadCsv(t *testing.T) {
+	releaseDate := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	allowBackorder := true
+
+	tests := []struct {
+		name    string
+		csv     string
+		records []models.ImportRecord
+		err     string
+	}{
+		{
+			name: "header in any order and case",
+			csv:  "\ufeffName, ISBN ,Price,qty,shelf\nThe Go Book, 978-0-306-40615-7 ,1299,3,A1\n",
+			records: []models.ImportRecord{{Row: 1, Book: models.AddBookReq{
+				Name: stringOf("The Go Book"), Isbn: stringOf("978-0-306-40615-7"), Price: int64Of(1299), Qty: int64Of(3),
+			}}},
+		},
+		{
+			name: "empty cells and short rows are missing values",
+			csv:  "isbn,name,price\n,The Go Book,\n9780306406157\n",
+			records: []models.ImportRecord{
+				{Row: 1, Book: models.AddBookReq{Name: stringOf("The Go Book")}},
+				{Row: 2, Book: models.AddBookReq{Isbn: stringOf("9780306406157")}},
+			},
+		},
+		{
+			name: "dates and flags",
+			csv:  "release_date,allow_backorder\n2024-02-01,true\n",
+			records: []models.ImportRecord{{Row: 1, Book: models.AddBookReq{
+				ReleaseDate: &releaseDate, AllowBackorder: &allowBackorder,
+			}}},
+		},
+		{
+			name: "invalid values fail their row only",
+			csv:  "name,price\nA,12.99\nB,1299\n",
+			records: []models.ImportRecord{
+				{Row: 1, Book: models.AddBookReq{Name: stringOf("A")}, Err: errors.New("price isn't a whole number")},
+				{Row: 2, Book: models.AddBookReq{Name: stringOf("B"), Price: int64Of(1299)}},
+			},
+		},
+		{
+			name: "invalid date",
+			csv:  "release_date\n01/02/2024\n",
+			records: []models.ImportRecord{
+				{Row: 1, Err: errors.New("release_date isn't a YYYY-MM-DD date")},
+			},
+		},
+		{
+			name: "invalid flag",
+			csv:  "allow_backorder\nmaybe\n",
+			records: []models.ImportRecord{
+				{Row: 1, Err: errors.New("allow_backorder isn't true or false")},
+			},
+		},
+		{
+			name: "malformed row",
+			csv:  "name\nthe \"go\" book\nB\n",
+			records: []models.ImportRecord{
+				{Row: 1, Err: csv.ErrBareQuote},
+				{Row: 2, Book: models.AddBookReq{Name: stringOf("B")}},
+			},
+		},
+		{
+			name: "empty file",
+			csv:  "",
+			err:  "csv file is empty",
+		},
+	}
+	for _, tt := range tests {
+		var records []models.ImportRecord
+		err := ReadCsv(strings.NewReader(tt.csv), func(record models.ImportRecord) error {
+			records = append(records, record)
+			return nil
+		})
+		if (err == nil && tt.err != "") || (err != nil && err.Error() != tt.err) {
+			t.Errorf("%s: ReadCsv() error = %v, want %q", tt.name, err, tt.err)
+			continue
+		}
+		if !equalRecords(records, tt.records) {
+			t.Errorf("%s: ReadCsv() records = %+v, want %+v", tt.name, records, tt.records)
+		}
+	}
+}
+
+// equalRecords compares import records, errors by their message
+func equalRecords(got []models.ImportRecord, want []models.ImportRecord) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if (got[i].Err == nil) != (want[i].Err == nil) || (got[i].Err != nil && got[i].Err.Error() != want[i].Err.Error()) {
+			return false
+		}
+		g, w := got[i], want[i]
+		g.Err, w.Err = nil, nil
+		if !reflect.DeepEqual(g, w) {
+			return false
+		}
+	}
+	return true
+}
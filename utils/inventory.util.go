@@ -0,0 +1,543 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/agustadewa/book-system/configs"
+	"github.com/agustadewa/book-system/models"
+	"github.com/agustadewa/book-system/repo"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Inventory changes book stock per location and writes every change to the stock ledger, so the stock can
+// be rebuilt and audited from the ledger. The catalogue stock of a book is the sum of its sellable locations.
+// Incoming books go to pre-orders and back-orders first, a book coming back in stock is offered to its
+// waitlist.
+type Inventory struct {
+	book       *repo.Book
+	location   *repo.Location
+	level      *repo.StockLevel
+	movement   *repo.StockMovement
+	transfer   *repo.StockTransfer
+	backorders *Backorders
+	waitlist   *Waitlist
+}
+
+func NewInventory(client *mongo.Client) *Inventory {
+	i := &Inventory{
+		book:     repo.NewBook(client),
+		location: repo.NewLocation(client),
+		level:    repo.NewStockLevel(client),
+		movement: repo.NewStockMovement(client),
+		transfer: repo.NewStockTransfer(client),
+	}
+	i.backorders = newBackorders(client, i)
+	i.waitlist = newWaitlist(client, i)
+	return i
+}
+
+// Backorders returns the pre-orders and back-orders served by the inventory
+func (i *Inventory) Backorders() *Backorders {
+	return i.backorders
+}
+
+// Waitlist returns the waitlist served by the inventory
+func (i *Inventory) Waitlist() *Waitlist {
+	return i.waitlist
+}
+
+// Available returns the stock of a book which can be sold to a new order, books owed to the orders waiting
+// for stock aren't
+func (i *Inventory) Available(ctx context.Context, book models.Book) (int64, error) {
+	if book.IsDigital() {
+		return book.Qty, nil
+	}
+	owed, err := i.backorders.Owed(ctx, book.Id)
+	if err != nil {
+		return 0, err
+	}
+	return book.Qty - owed, nil
+}
+
+// Move adds delta to a book stock at a location and records it, refId is the order, return or transfer
+// which caused it. An empty location is the default location. Digital books have no stock to move.
+func (i *Inventory) Move(ctx context.Context, bookId string, locationId string, delta int64, reason models.StockReason, actorId string, refId string, note string) (*models.StockMovement, error) {
+	return i.move(ctx, bookId, locationId, delta, reason, actorId, refId, note, true)
+}
+
+// move is Move, restock tells whether incoming sellable books are new stock for back-orders and the
+// waitlist. Books moved between sellable locations aren't.
+func (i *Inventory) move(ctx context.Context, bookId string, locationId string, delta int64, reason models.StockReason, actorId string, refId string, note string, restock bool) (*models.StockMovement, error) {
+	if locationId == "" {
+		locationId = configs.DefaultLocationId
+	}
+	location, err := i.location.Get(ctx, locationId)
+	if err != nil {
+		return nil, err
+	}
+	if current, err := i.book.Get(ctx, bookId); err != nil {
+		return nil, err
+	} else if current.IsDigital() {
+		return nil, repo.ErrUnlimitedStock
+	}
+
+	level, err := i.level.Inc(ctx, bookId, location.Id, delta)
+	if err != nil {
+		return nil, err
+	}
+	var book *models.Book
+	if location.Sellable {
+		if book, err = i.book.IncStock(ctx, bookId, delta); err != nil {
+			i.undo(ctx, bookId, location, delta, false)
+			return nil, err
+		}
+	}
+
+	movement := models.StockMovement{
+		Id:         primitive.NewObjectID().Hex(),
+		BookId:     bookId,
+		LocationId: location.Id,
+		Delta:      delta,
+		Balance:    level.Qty,
+		Reason:     reason,
+		ActorId:    actorId,
+		RefId:      refId,
+		Note:       note,
+		CreatedAt:  time.Now(),
+	}
+	if err = i.movement.Add(ctx, movement); err != nil {
+		// a change which isn't in the ledger would show up as a discrepancy, undo it
+		i.undo(ctx, bookId, location, delta, location.Sellable)
+		return nil, err
+	}
+
+	if book != nil && delta > 0 && restock {
+		i.restocked(ctx, book, location.Id)
+	}
+	return &movement, nil
+}
+
+// MoveOnce is Move for a change made once per reference, e.g. the books of a received return. When the ledger
+// has a movement of the book for the reference with the same reason the change was made before and the
+// recorded movement is returned, so a retry doesn't move the books twice.
+func (i *Inventory) MoveOnce(ctx context.Context, bookId string, locationId string, delta int64, reason models.StockReason, actorId string, refId string, note string) (*models.StockMovement, error) {
+	if movement, err := i.movement.GetByRefId(ctx, bookId, reason, refId); err == nil {
+		return movement, nil
+	} else if err != repo.ErrStockMovementNotFound {
+		return nil, err
+	}
+	return i.Move(ctx, bookId, locationId, delta, reason, actorId, refId, note)
+}
+
+// Sell takes qty books for an order from a single sellable location chosen by the allocation strategy,
+// the nearest strategy falls back to priority when the address has no coordinates
+func (i *Inventory) Sell(ctx context.Context, bookId string, qty int64, strategy models.AllocationStrategy, address *models.Address, actorId string, orderId string) (*models.StockMovement, error) {
+	candidates, err := i.allocationCandidates(ctx, bookId, qty, strategy, address)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, location := range candidates {
+		movement, err := i.Move(ctx, bookId, location.Id, -qty, models.StockSale, actorId, orderId, "")
+		if err == repo.ErrInsufficientStock {
+			// sold meanwhile, try the next location
+			continue
+		}
+		return movement, err
+	}
+	return nil, repo.ErrInsufficientStock
+}
+
+// Transfer moves stock of a book between locations. Only books coming from a location which isn't sellable
+// add to the sellable stock and go to back-orders and the waitlist.
+func (i *Inventory) Transfer(ctx context.Context, bookId string, fromLocationId string, toLocationId string, qty int64, adminId string, note string) (*models.StockTransfer, error) {
+	if fromLocationId == toLocationId {
+		return nil, errors.New("transfer locations must differ")
+	}
+	if qty <= 0 {
+		return nil, errors.New("quantity minimum is 1")
+	}
+	from, err := i.location.Get(ctx, fromLocationId)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = i.location.Get(ctx, toLocationId); err != nil {
+		return nil, err
+	}
+
+	transfer := models.StockTransfer{
+		Id:             primitive.NewObjectID().Hex(),
+		BookId:         bookId,
+		FromLocationId: fromLocationId,
+		ToLocationId:   toLocationId,
+		Qty:            qty,
+		AdminId:        adminId,
+		Note:           note,
+		CreatedAt:      time.Now(),
+	}
+	// record the transfer before moving its stock, a failure after the stock moved would make the caller
+	// move it again
+	if _, err = i.transfer.Add(ctx, transfer); err != nil {
+		return nil, err
+	}
+	if _, err = i.Move(ctx, bookId, fromLocationId, -qty, models.StockTransferOut, adminId, transfer.Id, note); err != nil {
+		i.dropTransfer(ctx, transfer)
+		return nil, err
+	}
+	if _, err = i.move(ctx, bookId, toLocationId, qty, models.StockTransferIn, adminId, transfer.Id, note, !from.Sellable); err != nil {
+		if _, rerr := i.move(ctx, bookId, fromLocationId, qty, models.StockTransferReversal, adminId, transfer.Id, "transfer failed", false); rerr != nil {
+			// the books left the source, keep the transfer so the ledger entry has its reference
+			log.Printf("[INVENTORY] can't put back transfer %v of book %v: %v\n", transfer.Id, bookId, rerr)
+			return nil, err
+		}
+		i.dropTransfer(ctx, transfer)
+		return nil, err
+	}
+	return &transfer, nil
+}
+
+// dropTransfer deletes a transfer whose stock didn't move
+func (i *Inventory) dropTransfer(ctx context.Context, transfer models.StockTransfer) {
+	if err := i.transfer.Delete(ctx, transfer.Id); err != nil {
+		log.Printf("[INVENTORY] can't delete failed transfer %v of book %v: %v\n", transfer.Id, transfer.BookId, err)
+	}
+}
+
+// Audit compares the stock of a book at every location with its ledger
+func (i *Inventory) Audit(ctx context.Context, bookId string) (*models.StockAudit, error) {
+	book, err := i.book.Get(ctx, bookId)
+	if err != nil {
+		return nil, err
+	}
+	levels, err := i.level.GetAllByBookId(ctx, bookId)
+	if err != nil {
+		return nil, err
+	}
+	sums, err := i.movement.Sum(ctx, bookId)
+	if err != nil {
+		return nil, err
+	}
+	sellable, err := i.sellableLocations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return stockAudit(book.Id, book.Qty, *levels, sums, sellable), nil
+}
+
+// Discrepancies returns the audits of every book whose stock doesn't match its ledger or whose catalogue
+// stock doesn't match its sellable locations
+func (i *Inventory) Discrepancies(ctx context.Context) ([]models.StockAudit, error) {
+	books, err := i.book.GetAllStock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	levels, err := i.level.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sums, err := i.movement.SumAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sellable, err := i.sellableLocations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	levelsByBook := map[string][]models.StockLevel{}
+	for _, level := range *levels {
+		levelsByBook[level.BookId] = append(levelsByBook[level.BookId], level)
+	}
+	sumsByBook := map[string][]models.StockLedgerSum{}
+	for _, sum := range sums {
+		sumsByBook[sum.BookId] = append(sumsByBook[sum.BookId], sum)
+	}
+
+	audits := make([]models.StockAudit, 0)
+	for _, book := range *books {
+		audit := stockAudit(book.Id, book.Qty, levelsByBook[book.Id], sumsByBook[book.Id], sellable)
+		if audit.HasDiscrepancy() {
+			audits = append(audits, *audit)
+		}
+	}
+	return audits, nil
+}
+
+// Rebuild sets the stock of a book at every location to the sum of its ledger, and the catalogue stock
+// to the sum of the sellable locations
+func (i *Inventory) Rebuild(ctx context.Context, bookId string) (*models.StockAudit, error) {
+	levels, err := i.level.GetAllByBookId(ctx, bookId)
+	if err != nil {
+		return nil, err
+	}
+	sums, err := i.movement.Sum(ctx, bookId)
+	if err != nil {
+		return nil, err
+	}
+	sellable, err := i.sellableLocations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ledgerQty := map[string]int64{}
+	for _, level := range *levels {
+		ledgerQty[level.LocationId] = 0
+	}
+	for _, sum := range sums {
+		ledgerQty[sum.LocationId] = sum.Qty
+	}
+
+	var sellableQty int64
+	rebuilt := make([]models.StockLevel, 0, len(ledgerQty))
+	for locationId, qty := range ledgerQty {
+		if err = i.level.Set(ctx, bookId, locationId, qty); err != nil {
+			return nil, err
+		}
+		if sellable[locationId] {
+			sellableQty += qty
+		}
+		rebuilt = append(rebuilt, models.StockLevel{BookId: bookId, LocationId: locationId, Qty: qty})
+	}
+	if err = i.book.SetStock(ctx, bookId, sellableQty); err != nil {
+		return nil, err
+	}
+	return stockAudit(bookId, sellableQty, rebuilt, sums, sellable), nil
+}
+
+// RecomputeAvailability sets the catalogue stock of every book to the sum of its sellable locations,
+// run when a location becomes sellable or stops being sellable
+func (i *Inventory) RecomputeAvailability(ctx context.Context) error {
+	sellable, err := i.sellableLocations(ctx)
+	if err != nil {
+		return err
+	}
+	locationIds := make([]string, 0, len(sellable))
+	for locationId := range sellable {
+		locationIds = append(locationIds, locationId)
+	}
+
+	qtyByBook, err := i.level.SumByBook(ctx, locationIds)
+	if err != nil {
+		return err
+	}
+	books, err := i.book.GetAllStock(ctx)
+	if err != nil {
+		return err
+	}
+	for _, book := range *books {
+		if qty := qtyByBook[book.Id]; qty != book.Qty {
+			if err = i.book.SetStock(ctx, book.Id, qty); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// EnsureStockLevels creates the default location and moves the stock of books which have no stock levels
+// yet into it. Books without a ledger get their stock recorded as opening balance, they would otherwise
+// rebuild to 0.
+func (i *Inventory) EnsureStockLevels(ctx context.Context) error {
+	if _, err := i.location.Get(ctx, configs.DefaultLocationId); err == repo.ErrLocationNotFound {
+		_, err = i.location.Add(ctx, models.Location{
+			Id:        configs.DefaultLocationId,
+			Name:      "Main warehouse",
+			Type:      models.WarehouseLocation,
+			Sellable:  true,
+			Priority:  10,
+			CreatedAt: time.Now(),
+		})
+		if err != nil && err != repo.ErrLocationExists {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+
+	books, err := i.book.GetAllStock(ctx)
+	if err != nil {
+		return err
+	}
+	sums, err := i.movement.SumAll(ctx)
+	if err != nil {
+		return err
+	}
+	hasLedger := make(map[string]bool, len(sums))
+	for _, sum := range sums {
+		hasLedger[sum.BookId] = true
+	}
+
+	for _, book := range *books {
+		if book.IsDigital() {
+			continue
+		}
+		count, err := i.level.CountByBookId(ctx, book.Id)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+
+		if !hasLedger[book.Id] {
+			err = i.OpeningBalance(ctx, book.Id, configs.DefaultLocationId, book.Qty, configs.StockSystemActor, "stock before the ledger")
+		} else {
+			err = i.level.Set(ctx, book.Id, configs.DefaultLocationId, book.Qty)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OpeningBalance records the stock a book was created with at a location, the catalogue stock is already set
+func (i *Inventory) OpeningBalance(ctx context.Context, bookId string, locationId string, qty int64, actorId string, note string) error {
+	if err := i.level.Set(ctx, bookId, locationId, qty); err != nil {
+		return err
+	}
+	return i.movement.Add(ctx, models.StockMovement{
+		Id:         primitive.NewObjectID().Hex(),
+		BookId:     bookId,
+		LocationId: locationId,
+		Delta:      qty,
+		Balance:    qty,
+		Reason:     models.StockOpeningBalance,
+		ActorId:    actorId,
+		Note:       note,
+		CreatedAt:  time.Now(),
+	})
+}
+
+// allocationCandidates returns the sellable locations holding at least qty books in allocation order
+func (i *Inventory) allocationCandidates(ctx context.Context, bookId string, qty int64, strategy models.AllocationStrategy, address *models.Address) ([]models.Location, error) {
+	levels, err := i.level.GetAllByBookId(ctx, bookId)
+	if err != nil {
+		return nil, err
+	}
+	locations, err := i.location.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	qtyAt := map[string]int64{}
+	for _, level := range *levels {
+		qtyAt[level.LocationId] = level.Qty
+	}
+
+	candidates := make([]models.Location, 0)
+	for _, location := range *locations {
+		if location.Sellable && qtyAt[location.Id] >= qty {
+			candidates = append(candidates, location)
+		}
+	}
+
+	// locations come sorted by priority, the nearest strategy reorders them by distance
+	if strategy == models.NearestAllocation && address != nil && address.Coordinates != nil {
+		from := *address.Coordinates
+		sort.SliceStable(candidates, func(a, b int) bool {
+			return locationDistance(from, candidates[a]) < locationDistance(from, candidates[b])
+		})
+	}
+	return candidates, nil
+}
+
+func (i *Inventory) sellableLocations(ctx context.Context) (map[string]bool, error) {
+	locations, err := i.location.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sellable := map[string]bool{}
+	for _, location := range *locations {
+		if location.Sellable {
+			sellable[location.Id] = true
+		}
+	}
+	return sellable, nil
+}
+
+// restocked gives books which came in to the orders waiting for them, what is left is offered to the
+// waitlist of the book. Customers wait for more books than are in stock, not only for sold out books.
+func (i *Inventory) restocked(ctx context.Context, book *models.Book, locationId string) {
+	if err := i.backorders.Allocate(ctx, book.Id); err != nil {
+		log.Printf("[INVENTORY] can't allocate stock of book %v to back-orders: %v\n", book.Id, err)
+	}
+
+	current, err := i.book.Get(ctx, book.Id)
+	if err != nil {
+		log.Printf("[INVENTORY] can't serve the waitlist of book %v: %v\n", book.Id, err)
+		return
+	}
+	available, err := i.Available(ctx, *current)
+	if err != nil {
+		log.Printf("[INVENTORY] can't serve the waitlist of book %v: %v\n", book.Id, err)
+		return
+	}
+	if available > 0 {
+		if err = i.waitlist.Restocked(ctx, book.Id, locationId, available); err != nil {
+			log.Printf("[INVENTORY] can't serve the waitlist of book %v: %v\n", book.Id, err)
+		}
+	}
+}
+
+func (i *Inventory) undo(ctx context.Context, bookId string, location *models.Location, delta int64, book bool) {
+	if _, err := i.level.Inc(ctx, bookId, location.Id, -delta); err != nil {
+		log.Printf("[INVENTORY] can't undo stock change of book %v at %v by %v: %v\n", bookId, location.Id, delta, err)
+	}
+	if book {
+		if _, err := i.book.IncStock(ctx, bookId, -delta); err != nil {
+			log.Printf("[INVENTORY] can't undo stock change of book %v by %v: %v\n", bookId, delta, err)
+		}
+	}
+}
+
+func stockAudit(bookId string, qty int64, levels []models.StockLevel, sums []models.StockLedgerSum, sellable map[string]bool) *models.StockAudit {
+	audit := &models.StockAudit{BookId: bookId, Qty: qty, Locations: make([]models.LocationAudit, 0)}
+
+	byLocation := map[string]*models.LocationAudit{}
+	locationAudit := func(locationId string) *models.LocationAudit {
+		if _, ok := byLocation[locationId]; !ok {
+			byLocation[locationId] = &models.LocationAudit{LocationId: locationId}
+		}
+		return byLocation[locationId]
+	}
+	for _, level := range levels {
+		locationAudit(level.LocationId).Qty = level.Qty
+		if sellable[level.LocationId] {
+			audit.SellableQty += level.Qty
+		}
+	}
+	for _, sum := range sums {
+		la := locationAudit(sum.LocationId)
+		la.LedgerQty = sum.Qty
+		la.Movements = sum.Movements
+	}
+
+	for _, la := range byLocation {
+		la.Discrepancy = la.Qty - la.LedgerQty
+		audit.Locations = append(audit.Locations, *la)
+	}
+	sort.Slice(audit.Locations, func(a, b int) bool { return audit.Locations[a].LocationId < audit.Locations[b].LocationId })
+	audit.Discrepancy = audit.Qty - audit.SellableQty
+	return audit
+}
+
+// locationDistance returns the great circle distance in km, locations without coordinates are the farthest
+func locationDistance(from models.Coordinates, location models.Location) float64 {
+	if location.Coordinates == nil {
+		return math.Inf(1)
+	}
+	const earthRadiusKm = 6371
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	lat1, lat2 := toRad(from.Latitude), toRad(location.Coordinates.Latitude)
+	dLat := lat2 - lat1
+	dLon := toRad(location.Coordinates.Longitude - from.Longitude)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
@@ -0,0 +1,127 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+)
+
+var ErrInvalidIsbn = errors.New("invalid isbn")
+var ErrIsbnNotConvertible = errors.New("only 978 isbn-13 have an isbn-10")
+
+// NormalizeIsbn strips the hyphens and spaces of an isbn, a lowercase check digit x becomes X
+func NormalizeIsbn(isbn string) string {
+	var b strings.Builder
+	for _, r := range isbn {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == 'x' || r == 'X':
+			b.WriteRune('X')
+		case r == '-' || r == ' ':
+		default:
+			// kept so the isbn fails validation
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// IsValidIsbn10 reports whether a normalized isbn is an isbn-10 with a valid check digit
+func IsValidIsbn10(isbn string) bool {
+	if len(isbn) != 10 {
+		return false
+	}
+	sum := 0
+	for i := 0; i < 10; i++ {
+		c := isbn[i]
+		var digit int
+		switch {
+		case c >= '0' && c <= '9':
+			digit = int(c - '0')
+		case c == 'X' && i == 9:
+			digit = 10
+		default:
+			return false
+		}
+		sum += (10 - i) * digit
+	}
+	return sum%11 == 0
+}
+
+// IsValidIsbn13 reports whether a normalized isbn is an isbn-13 with a valid check digit
+func IsValidIsbn13(isbn string) bool {
+	if len(isbn) != 13 || !(strings.HasPrefix(isbn, "978") || strings.HasPrefix(isbn, "979")) {
+		return false
+	}
+	for i := 0; i < 13; i++ {
+		if isbn[i] < '0' || isbn[i] > '9' {
+			return false
+		}
+	}
+	return isbn13CheckDigit(isbn[:12]) == isbn[12]
+}
+
+// Isbn10To13 converts a valid isbn-10 to its isbn-13
+func Isbn10To13(isbn10 string) (string, error) {
+	if !IsValidIsbn10(isbn10) {
+		return "", ErrInvalidIsbn
+	}
+	body := "978" + isbn10[:9]
+	return body + string(isbn13CheckDigit(body)), nil
+}
+
+// Isbn13To10 converts a valid isbn-13 to its isbn-10, 979 isbn-13 have none
+func Isbn13To10(isbn13 string) (string, error) {
+	if !IsValidIsbn13(isbn13) {
+		return "", ErrInvalidIsbn
+	}
+	if !strings.HasPrefix(isbn13, "978") {
+		return "", ErrIsbnNotConvertible
+	}
+	body := isbn13[3:12]
+	sum := 0
+	for i := 0; i < 9; i++ {
+		sum += (10 - i) * int(body[i]-'0')
+	}
+	check := (11 - sum%11) % 11
+	if check == 10 {
+		return body + "X", nil
+	}
+	return body + string(rune('0'+check)), nil
+}
+
+// ParseIsbn validates an isbn-10 or isbn-13 written with or without hyphens and returns both forms, the
+// isbn-10 is empty for 979 isbn-13
+func ParseIsbn(isbn string) (isbn13 string, isbn10 string, err error) {
+	normalized := NormalizeIsbn(isbn)
+	switch len(normalized) {
+	case 10:
+		if isbn13, err = Isbn10To13(normalized); err != nil {
+			return "", "", err
+		}
+		return isbn13, normalized, nil
+	case 13:
+		if !IsValidIsbn13(normalized) {
+			return "", "", ErrInvalidIsbn
+		}
+		isbn10, err = Isbn13To10(normalized)
+		if err == ErrIsbnNotConvertible {
+			err = nil
+		}
+		return normalized, isbn10, err
+	default:
+		return "", "", ErrInvalidIsbn
+	}
+}
+
+func isbn13CheckDigit(body string) byte {
+	sum := 0
+	for i := 0; i < 12; i++ {
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+		sum += weight * int(body[i]-'0')
+	}
+	return byte('0' + (10-sum%10)%10)
+}
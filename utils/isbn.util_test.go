@@ -0,0 +1,116 @@
+package utils
+
+import "testing"
+
+func TestNormalizeIsbn(t *testing.T) {
+	tests := []struct {
+		isbn string
+		want string
+	}{
+		{"978-0-306-40615-7", "9780306406157"},
+		{"0 8044 2957 x", "080442957X"},
+		{"0-306-40615-2", "0306406152"},
+		{"978.0306406157", "978.0306406157"},
+	}
+	for _, tt := range tests {
+		if got := NormalizeIsbn(tt.isbn); got != tt.want {
+			t.Errorf("NormalizeIsbn(%q) = %q, want %q", tt.isbn, got, tt.want)
+		}
+	}
+}
+
+func TestIsValidIsbn10(t *testing.T) {
+	tests := []struct {
+		isbn string
+		want bool
+	}{
+		{"0306406152", true},
+		{"080442957X", true},
+		{"0306406153", false},
+		{"X804429570", false},
+		{"030640615", false},
+		{"03064061a2", false},
+	}
+	for _, tt := range tests {
+		if got := IsValidIsbn10(tt.isbn); got != tt.want {
+			t.Errorf("IsValidIsbn10(%q) = %v, want %v", tt.isbn, got, tt.want)
+		}
+	}
+}
+
+func TestIsValidIsbn13(t *testing.T) {
+	tests := []struct {
+		isbn string
+		want bool
+	}{
+		{"9780306406157", true},
+		{"9791090636071", true},
+		{"9780306406158", false},
+		{"9770306406157", false},
+		{"978030640615", false},
+		{"978030640615X", false},
+	}
+	for _, tt := range tests {
+		if got := IsValidIsbn13(tt.isbn); got != tt.want {
+			t.Errorf("IsValidIsbn13(%q) = %v, want %v", tt.isbn, got, tt.want)
+		}
+	}
+}
+
+func TestIsbn10To13(t *testing.T) {
+	tests := []struct {
+		isbn10 string
+		want   string
+		err    error
+	}{
+		{"0306406152", "9780306406157", nil},
+		{"080442957X", "9780804429573", nil},
+		{"0306406153", "", ErrInvalidIsbn},
+	}
+	for _, tt := range tests {
+		got, err := Isbn10To13(tt.isbn10)
+		if got != tt.want || err != tt.err {
+			t.Errorf("Isbn10To13(%q) = %q, %v, want %q, %v", tt.isbn10, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestIsbn13To10(t *testing.T) {
+	tests := []struct {
+		isbn13 string
+		want   string
+		err    error
+	}{
+		{"9780306406157", "0306406152", nil},
+		{"9780804429573", "080442957X", nil},
+		{"9791090636071", "", ErrIsbnNotConvertible},
+		{"9780306406158", "", ErrInvalidIsbn},
+	}
+	for _, tt := range tests {
+		got, err := Isbn13To10(tt.isbn13)
+		if got != tt.want || err != tt.err {
+			t.Errorf("Isbn13To10(%q) = %q, %v, want %q, %v", tt.isbn13, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestParseIsbn(t *testing.T) {
+	tests := []struct {
+		isbn   string
+		isbn13 string
+		isbn10 string
+		err    error
+	}{
+		{"0-306-40615-2", "9780306406157", "0306406152", nil},
+		{"978-0-306-40615-7", "9780306406157", "0306406152", nil},
+		{"979-10-90636-07-1", "9791090636071", "", nil},
+		{"978-0-306-40615-8", "", "", ErrInvalidIsbn},
+		{"12345", "", "", ErrInvalidIsbn},
+	}
+	for _, tt := range tests {
+		isbn13, isbn10, err := ParseIsbn(tt.isbn)
+		if isbn13 != tt.isbn13 || isbn10 != tt.isbn10 || err != tt.err {
+			t.Errorf("ParseIsbn(%q) = %q, %q, %v, want %q, %q, %v", tt.isbn, isbn13, isbn10, err, tt.isbn13, tt.isbn10, tt.err)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package utils
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/agustadewa/book-system/configs"
+	"github.com/agustadewa/book-system/models"
+	"github.com/agustadewa/book-system/repo"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var ErrInvalidDownloadLink = errors.New("invalid download link")
+var ErrDownloadLinkExpired = errors.New("download link has expired")
+
+// Library gives customers the digital books they paid for. Files are downloaded through signed links
+// which expire, every download is counted against the purchase limit and logged.
+type Library struct {
+	entry    *repo.Library
+	download *repo.Download
+	book     *repo.Book
+	order    *repo.Order
+	store    FileStore
+	secret   string
+}
+
+func NewLibrary(client *mongo.Client) *Library {
+	return &Library{
+		entry:    repo.NewLibrary(client),
+		download: repo.NewDownload(client),
+		book:     repo.NewBook(client),
+		order:    repo.NewOrder(client),
+		store:    NewFileStore(),
+		secret:   configs.DownloadSigningSecret,
+	}
+}
+
+// Grant adds a paid digital order to the customer library and marks it delivered, a digital order has
+// nothing to ship. Other orders are left alone and granting an order again only finishes marking it.
+func (l *Library) Grant(ctx context.Context, orderId string) error {
+	order, err := l.order.Get(ctx, orderId)
+	if err != nil {
+		return err
+	}
+	if !order.Format.IsDigital() || (!order.Status.IsPaid() && !order.Status.IsDelivered()) {
+		return nil
+	}
+	book, err := l.book.Get(ctx, order.BookId)
+	if err != nil {
+		return err
+	}
+
+	_, err = l.entry.Add(ctx, models.LibraryEntry{
+		Id:            primitive.NewObjectID().Hex(),
+		UserId:        order.UserId,
+		BookId:        book.Id,
+		OrderId:       order.Id,
+		Name:          book.Name,
+		Format:        book.Format,
+		DownloadLimit: configs.DownloadLimit,
+		CreatedAt:     time.Now(),
+	})
+	if err != nil && err != repo.ErrLibraryEntryExists {
+		return err
+	}
+
+	// delivered orders can be returned
+	if order.Status.IsPaid() {
+		if err = l.order.UpdateStatusFrom(ctx, order.Id, models.Paid, models.Delivered); err != nil && err != repo.ErrOrderStatusChanged {
+			return err
+		}
+	}
+	return nil
+}
+
+// Revoke takes a refunded, returned or called off digital order out of the customer library, its links stop working
+func (l *Library) Revoke(ctx context.Context, orderId string) error {
+	return l.entry.SetRevokedByOrderId(ctx, orderId, time.Now())
+}
+
+// Link returns a download link of a library entry of the customer valid until now plus the link TTL
+func (l *Library) Link(ctx context.Context, entryId string, userId string, now time.Time) (*models.DownloadLink, error) {
+	entry, err := l.entry.Get(ctx, entryId)
+	if err != nil {
+		return nil, err
+	}
+	if entry.UserId != userId {
+		return nil, errors.New("library entry doesn't belong to the user")
+	}
+	if entry.IsRevoked() {
+		return nil, repo.ErrLibraryEntryRevoked
+	}
+	if entry.DownloadsLeft() == 0 {
+		return nil, repo.ErrDownloadLimitReached
+	}
+
+	expiresAt := now.Add(configs.DownloadLinkTTL).Truncate(time.Second)
+	expires := strconv.FormatInt(expiresAt.Unix(), 10)
+	query := url.Values{}
+	query.Set("expires", expires)
+	query.Set("signature", SignDownload(l.secret, entry.Id, expires))
+	return &models.DownloadLink{
+		EntryId:       entry.Id,
+		Url:           fmt.Sprintf("/library/download/%s?%s", url.PathEscape(entry.Id), query.Encode()),
+		ExpiresAt:     expiresAt,
+		DownloadsLeft: entry.DownloadsLeft(),
+	}, nil
+}
+
+// Open checks a download link and opens the file of its library entry. The download is counted and logged
+// once the file is open, the caller closes it.
+func (l *Library) Open(ctx context.Context, entryId string, expires string, signature string, now time.Time, ip string, userAgent string) (io.ReadCloser, *models.BookFile, error) {
+	if err := VerifyDownload(l.secret, entryId, expires, signature, now); err != nil {
+		return nil, nil, err
+	}
+	entry, err := l.entry.Get(ctx, entryId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if entry.IsRevoked() {
+		return nil, nil, repo.ErrLibraryEntryRevoked
+	}
+	book, err := l.book.Get(ctx, entry.BookId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if book.File == nil {
+		return nil, nil, ErrFileNotFound
+	}
+
+	file, err := l.store.Open(ctx, book.File.Key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err = l.entry.IncDownloads(ctx, entry.Id, now); err != nil {
+		_ = file.Close()
+		return nil, nil, err
+	}
+	if _, err = l.download.Add(ctx, models.Download{
+		Id:        primitive.NewObjectID().Hex(),
+		EntryId:   entry.Id,
+		UserId:    entry.UserId,
+		BookId:    entry.BookId,
+		Ip:        ip,
+		UserAgent: userAgent,
+		CreatedAt: now,
+	}); err != nil {
+		_ = file.Close()
+		return nil, nil, err
+	}
+	return file, book.File, nil
+}
+
+// Store returns the store the files are kept in
+func (l *Library) Store() FileStore {
+	return l.store
+}
+
+// SignDownload returns the signature of a download link, expires is the unix time the link stops working
+func SignDownload(secret string, entryId string, expires string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(entryId))
+	mac.Write([]byte("."))
+	mac.Write([]byte(expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyDownload checks the signature of a download link and rejects expired links
+func VerifyDownload(secret string, entryId string, expires string, signature string, now time.Time) error {
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || signature == "" {
+		return ErrInvalidDownloadLink
+	}
+	if !hmac.Equal([]byte(signature), []byte(SignDownload(secret, entryId, expires))) {
+		return ErrInvalidDownloadLink
+	}
+	if now.After(time.Unix(unix, 0)) {
+		return ErrDownloadLinkExpired
+	}
+	return nil
+}
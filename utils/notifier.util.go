@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/agustadewa/book-system/models"
+	"github.com/agustadewa/book-system/repo"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Notifier delivers notifications to a team or a customer. Notify fails with repo.ErrNotificationExists when a notification
+// with the same key is still open, so a condition is only notified once.
+type Notifier interface {
+	Notify(ctx context.Context, notification models.Notification) error
+}
+
+func NewNotifier(client *mongo.Client) Notifier {
+	return &inboxNotifier{notification: repo.NewNotification(client)}
+}
+
+// inboxNotifier keeps notifications in the database, admins read them from the notification endpoints
+type inboxNotifier struct {
+	notification *repo.Notification
+}
+
+func (n *inboxNotifier) Notify(ctx context.Context, notification models.Notification) error {
+	notification.Id = primitive.NewObjectID().Hex()
+	notification.Resolved = false
+	notification.ReadBy = make([]string, 0)
+	notification.CreatedAt = time.Now()
+	if _, err := n.notification.Add(ctx, notification); err != nil {
+		return err
+	}
+
+	recipient := notification.Team
+	if notification.UserId != "" {
+		recipient = "user " + notification.UserId
+	}
+	log.Printf("[NOTIFY] %s to %s: %s\n", notification.Type, recipient, notification.Message)
+	return nil
+}
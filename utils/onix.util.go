@@ -0,0 +1,352 @@
+package utils
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/agustadewa/book-system/models"
+)
+
+var ErrOnixShortTags = errors.New("ONIX short tags aren't supported, send the file with reference tags")
+
+// onixProduct is the part of an ONIX 3.0 product record the catalogue is made from
+type onixProduct struct {
+	NotificationType string `xml:"NotificationType"`
+	Identifiers      []struct {
+		Type  string `xml:"ProductIDType"`
+		Value string `xml:"IDValue"`
+	} `xml:"ProductIdentifier"`
+	Descriptive struct {
+		ProductForm string `xml:"ProductForm"`
+		Measures    []struct {
+			Type  string `xml:"MeasureType"`
+			Value string `xml:"Measurement"`
+			Unit  string `xml:"MeasureUnitCode"`
+		} `xml:"Measure"`
+		Titles []struct {
+			Type     string `xml:"TitleType"`
+			Elements []struct {
+				Level         string `xml:"TitleElementLevel"`
+				Text          string `xml:"TitleText"`
+				Prefix        string `xml:"TitlePrefix"`
+				WithoutPrefix string `xml:"TitleWithoutPrefix"`
+				Subtitle      string `xml:"Subtitle"`
+			} `xml:"TitleElement"`
+		} `xml:"TitleDetail"`
+		Contributors []struct {
+			Role           []string `xml:"ContributorRole"`
+			PersonName     string   `xml:"PersonName"`
+			NamesBeforeKey string   `xml:"NamesBeforeKey"`
+			KeyNames       string   `xml:"KeyNames"`
+			CorporateName  string   `xml:"CorporateName"`
+		} `xml:"Contributor"`
+		EditionStatement string `xml:"EditionStatement"`
+		Languages        []struct {
+			Role string `xml:"LanguageRole"`
+			Code string `xml:"LanguageCode"`
+		} `xml:"Language"`
+		Subjects []struct {
+			Main    *struct{} `xml:"MainSubject"`
+			Code    string    `xml:"SubjectCode"`
+			Heading string    `xml:"SubjectHeadingText"`
+		} `xml:"Subject"`
+	} `xml:"DescriptiveDetail"`
+	Collateral struct {
+		Texts []struct {
+			Type string `xml:"TextType"`
+			Text string `xml:"Text"`
+		} `xml:"TextContent"`
+		Resources []struct {
+			ContentType string `xml:"ResourceContentType"`
+			Versions    []struct {
+				Links []string `xml:"ResourceLink"`
+			} `xml:"ResourceVersion"`
+		} `xml:"SupportingResource"`
+	} `xml:"CollateralDetail"`
+	Publishing struct {
+		Publishers []struct {
+			Role string `xml:"PublishingRole"`
+			Name string `xml:"PublisherName"`
+		} `xml:"Publisher"`
+		Dates []struct {
+			Role string `xml:"PublishingDateRole"`
+			Date string `xml:"Date"`
+		} `xml:"PublishingDate"`
+	} `xml:"PublishingDetail"`
+	Supplies []struct {
+		Details []struct {
+			Stocks []struct {
+				OnHand string `xml:"OnHand"`
+			} `xml:"Stock"`
+			Prices []onixPrice `xml:"Price"`
+		} `xml:"SupplyDetail"`
+	} `xml:"ProductSupply"`
+}
+
+type onixPrice struct {
+	Type     string `xml:"PriceType"`
+	Amount   string `xml:"PriceAmount"`
+	Currency string `xml:"CurrencyCode"`
+}
+
+// onixFormats are the ONIX product forms the catalogue sells
+var onixFormats = map[string]models.BookFormat{
+	"BB": models.Hardcover,
+	"BC": models.Paperback,
+	"EA": models.Ebook,
+	"EB": models.Ebook,
+	"EC": models.Ebook,
+	"ED": models.Ebook,
+	"AJ": models.Audiobook,
+	"AN": models.Audiobook,
+}
+
+// onixRoles are the ONIX contributor roles the catalogue keeps
+var onixRoles = map[string]models.ContributorRole{
+	"A01": models.AuthorRole,
+	"B06": models.TranslatorRole,
+	"A12": models.IllustratorRole,
+	"B01": models.EditorRole,
+	"E07": models.NarratorRole,
+}
+
+// ReadOnix reads the products of an ONIX 3.0 message with reference tags, one record per product
+func ReadOnix(r io.Reader, each func(models.ImportRecord) error) error {
+	decoder := xml.NewDecoder(r)
+	var row int64
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "ONIXmessage", "product":
+			return ErrOnixShortTags
+		case "Product":
+			var product onixProduct
+			if err = decoder.DecodeElement(&product, &start); err != nil {
+				return err
+			}
+			row++
+			if err = each(onixRecord(row, product)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func onixRecord(row int64, product onixProduct) models.ImportRecord {
+	record := models.ImportRecord{Row: row}
+	book := &record.Book
+
+	for _, identifier := range product.Identifiers {
+		// isbn-13, or the gtin-13 of a book
+		if identifier.Type == "15" || (identifier.Type == "03" && (strings.HasPrefix(identifier.Value, "978") || strings.HasPrefix(identifier.Value, "979"))) {
+			book.Isbn = onixString(identifier.Value)
+			break
+		}
+		if identifier.Type == "02" && book.Isbn == nil {
+			book.Isbn = onixString(identifier.Value)
+		}
+	}
+
+	d := product.Descriptive
+	for _, title := range d.Titles {
+		if title.Type != "01" {
+			continue
+		}
+		for _, element := range title.Elements {
+			if element.Level != "01" {
+				continue
+			}
+			name := element.Text
+			if name == "" {
+				name = strings.TrimSpace(element.Prefix + " " + element.WithoutPrefix)
+			}
+			if element.Subtitle != "" {
+				name += ": " + element.Subtitle
+			}
+			book.Name = onixString(name)
+		}
+	}
+
+	// delete notifications take nothing out of the catalogue, books with orders can't simply go
+	if product.NotificationType == "05" {
+		record.Skip = "delete notifications aren't imported"
+		return record
+	}
+
+	format, ok := onixFormats[d.ProductForm]
+	if !ok {
+		record.Err = fmt.Errorf("product form %s isn't sold", d.ProductForm)
+		return record
+	}
+	book.Format = onixString(format.String())
+
+	for _, contributor := range d.Contributors {
+		name := contributor.PersonName
+		if name == "" {
+			name = strings.TrimSpace(contributor.NamesBeforeKey + " " + contributor.KeyNames)
+		}
+		if name == "" {
+			name = contributor.CorporateName
+		}
+		for _, code := range contributor.Role {
+			if role, ok := onixRoles[code]; ok {
+				book.Contributors = append(book.Contributors, models.ContributorReq{Name: name, Role: role.String()})
+			}
+		}
+	}
+	book.Edition = onixString(d.EditionStatement)
+	for _, language := range d.Languages {
+		if language.Role == "01" {
+			book.Language = onixString(language.Code)
+			break
+		}
+	}
+	for _, subject := range d.Subjects {
+		category := subject.Heading
+		if category == "" {
+			category = subject.Code
+		}
+		if subject.Main != nil || book.Category == nil {
+			book.Category = onixString(category)
+		}
+		if subject.Main != nil {
+			break
+		}
+	}
+
+	// weight in grams, size in millimetres
+	sizes := make(map[string]int64)
+	for _, measure := range d.Measures {
+		value, err := strconv.ParseFloat(measure.Value, 64)
+		if err != nil {
+			continue
+		}
+		switch measure.Unit {
+		case "kg":
+			value *= 1000
+		case "lb":
+			value *= 453.59237
+		case "oz":
+			value *= 28.349523125
+		case "cm":
+			value *= 10
+		case "in":
+			value *= 25.4
+		}
+		sizes[measure.Type] = int64(math.Round(value))
+	}
+	if weight, ok := sizes["08"]; ok {
+		book.WeightGrams = &weight
+	}
+	if sizes["01"] > 0 && sizes["02"] > 0 && sizes["03"] > 0 {
+		book.Dimensions = &models.Dimensions{LengthMm: sizes["01"], WidthMm: sizes["02"], HeightMm: sizes["03"]}
+	}
+
+	for _, text := range product.Collateral.Texts {
+		if text.Type == "03" || (text.Type == "02" && book.Description == nil) {
+			book.Description = onixString(text.Text)
+		}
+	}
+	for _, resource := range product.Collateral.Resources {
+		if resource.ContentType != "01" {
+			continue
+		}
+		for _, version := range resource.Versions {
+			if len(version.Links) > 0 && book.Image == nil {
+				book.Image = onixString(version.Links[0])
+			}
+		}
+	}
+
+	for _, publisher := range product.Publishing.Publishers {
+		if publisher.Role == "01" || book.Publisher == nil {
+			book.Publisher = onixString(publisher.Name)
+		}
+	}
+	for _, date := range product.Publishing.Dates {
+		if date.Role != "01" {
+			continue
+		}
+		releaseDate, err := time.Parse("20060102", date.Date)
+		if err != nil {
+			record.Err = fmt.Errorf("publication date %s isn't a YYYYMMDD date", date.Date)
+			return record
+		}
+		book.ReleaseDate = &releaseDate
+	}
+
+	// the first stock and the recommended retail price, with tax when given
+	qty := int64(0)
+	var price *onixPrice
+	for s := range product.Supplies {
+		for i := range product.Supplies[s].Details {
+			detail := &product.Supplies[s].Details[i]
+			if len(detail.Stocks) > 0 && qty == 0 {
+				qty, _ = strconv.ParseInt(detail.Stocks[0].OnHand, 10, 64)
+			}
+			for j := range detail.Prices {
+				if price == nil || (detail.Prices[j].Type == "02" && price.Type != "02") {
+					price = &detail.Prices[j]
+				}
+			}
+		}
+	}
+	if format.IsDigital() {
+		qty = 0
+	}
+	book.Qty = &qty
+	if price != nil {
+		currency, err := models.IsValidCurrency(price.Currency)
+		if err != nil {
+			record.Err = fmt.Errorf("price currency %s: %s", price.Currency, err)
+			return record
+		}
+		amount, err := parseAmount(price.Amount, currency)
+		if err != nil {
+			record.Err = err
+			return record
+		}
+		book.Price, book.Currency = &amount, &currency.Code
+	}
+	return record
+}
+
+// onixString returns a trimmed value, nil when it is empty so it reads as missing
+func onixString(value string) *string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+// parseAmount reads a decimal amount into the minor units of a currency, e.g. 12.99 dollars is 1299
+func parseAmount(amount string, currency models.Currency) (int64, error) {
+	value, ok := new(big.Rat).SetString(strings.TrimSpace(amount))
+	if !ok {
+		return 0, fmt.Errorf("price %s isn't a number", amount)
+	}
+	scale := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(currency.MinorUnits)), nil))
+	value.Mul(value, scale)
+	if !value.IsInt() {
+		return 0, fmt.Errorf("price %s has more decimals than %s", amount, currency.Code)
+	}
+	return value.Num().Int64(), nil
+}
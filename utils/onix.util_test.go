@@ -0,0 +1,191 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agustadewa/book-system/models"
+)
+
+const onixPaperback = `<Product>
+	<NotificationType>03</NotificationType>
+	<ProductIdentifier><ProductIDType>02</ProductIDType><IDValue>0306406152</IDValue></ProductIdentifier>
+	<ProductIdentifier><ProductIDType>15</ProductIDType><IDValue>9780306406157</IDValue></ProductIdentifier>
+	<DescriptiveDetail>
+		<ProductForm>BC</ProductForm>
+		<Measure><MeasureType>01</MeasureType><Measurement>23.4</Measurement><MeasureUnitCode>cm</MeasureUnitCode></Measure>
+		<Measure><MeasureType>02</MeasureType><Measurement>156</Measurement><MeasureUnitCode>mm</MeasureUnitCode></Measure>
+		<Measure><MeasureType>03</MeasureType><Measurement>2</Measurement><MeasureUnitCode>cm</MeasureUnitCode></Measure>
+		<Measure><MeasureType>08</MeasureType><Measurement>0.45</Measurement><MeasureUnitCode>kg</MeasureUnitCode></Measure>
+		<TitleDetail>
+			<TitleType>01</TitleType>
+			<TitleElement>
+				<TitleElementLevel>01</TitleElementLevel>
+				<TitlePrefix>The</TitlePrefix>
+				<TitleWithoutPrefix>Go Book</TitleWithoutPrefix>
+				<Subtitle>A Guide</Subtitle>
+			</TitleElement>
+		</TitleDetail>
+		<Contributor><ContributorRole>A01</ContributorRole><NamesBeforeKey>Jane</NamesBeforeKey><KeyNames>Doe</KeyNames></Contributor>
+		<Contributor><ContributorRole>Z99</ContributorRole><PersonName>Nobody</PersonName></Contributor>
+		<Contributor><ContributorRole>B06</ContributorRole><PersonName>John Roe</PersonName></Contributor>
+		<EditionStatement>2nd edition</EditionStatement>
+		<Language><LanguageRole>01</LanguageRole><LanguageCode>eng</LanguageCode></Language>
+		<Subject><SubjectCode>COM051000</SubjectCode></Subject>
+		<Subject><MainSubject/><SubjectCode>COM000000</SubjectCode><SubjectHeadingText>Computers</SubjectHeadingText></Subject>
+	</DescriptiveDetail>
+	<CollateralDetail>
+		<TextContent><TextType>02</TextType><Text>Short</Text></TextContent>
+		<TextContent><TextType>03</TextType><Text>Long description</Text></TextContent>
+		<SupportingResource>
+			<ResourceContentType>01</ResourceContentType>
+			<ResourceVersion><ResourceLink>https://example.com/cover.jpg</ResourceLink></ResourceVersion>
+		</SupportingResource>
+	</CollateralDetail>
+	<PublishingDetail>
+		<Publisher><PublishingRole>01</PublishingRole><PublisherName>Acme</PublisherName></Publisher>
+		<PublishingDate><PublishingDateRole>01</PublishingDateRole><Date>20240201</Date></PublishingDate>
+	</PublishingDetail>
+	<ProductSupply>
+		<SupplyDetail>
+			<Stock><OnHand>12</OnHand></Stock>
+			<Price><PriceType>01</PriceType><PriceAmount>10.00</PriceAmount><CurrencyCode>USD</CurrencyCode></Price>
+			<Price><PriceType>02</PriceType><PriceAmount>12.99</PriceAmount><CurrencyCode>USD</CurrencyCode></Price>
+		</SupplyDetail>
+	</ProductSupply>
+</Product>`
+
+func TestReadOnix(t *testing.T) {
+	releaseDate := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	weight := int64(450)
+
+	tests := []struct {
+		name     string
+		products string
+		records  []models.ImportRecord
+		err      error
+	}{
+		{
+			name:     "paperback",
+			products: onixPaperback,
+			records: []models.ImportRecord{{Row: 1, Book: models.AddBookReq{
+				Format: stringOf(models.Paperback.String()),
+				Isbn:   stringOf("9780306406157"),
+				Price:  int64Of(1299),
+				Qty:    int64Of(12),
+				Name:   stringOf("The Go Book: A Guide"),
+				Contributors: []models.ContributorReq{
+					{Name: "Jane Doe", Role: models.AuthorRole.String()},
+					{Name: "John Roe", Role: models.TranslatorRole.String()},
+				},
+				Publisher:   stringOf("Acme"),
+				Category:    stringOf("Computers"),
+				Language:    stringOf("eng"),
+				Description: stringOf("Long description"),
+				Image:       stringOf("https://example.com/cover.jpg"),
+				Edition:     stringOf("2nd edition"),
+				WeightGrams: &weight,
+				Dimensions:  &models.Dimensions{LengthMm: 234, WidthMm: 156, HeightMm: 20},
+				Currency:    stringOf("USD"),
+				ReleaseDate: &releaseDate,
+			}}},
+		},
+		{
+			name: "ebook has no stock",
+			products: `<Product>
+				<ProductIdentifier><ProductIDType>03</ProductIDType><IDValue>9791090636071</IDValue></ProductIdentifier>
+				<DescriptiveDetail><ProductForm>ED</ProductForm></DescriptiveDetail>
+				<ProductSupply><SupplyDetail>
+					<Stock><OnHand>100</OnHand></Stock>
+					<Price><PriceAmount>150000</PriceAmount><CurrencyCode>IDR</CurrencyCode></Price>
+				</SupplyDetail></ProductSupply>
+			</Product>`,
+			records: []models.ImportRecord{{Row: 1, Book: models.AddBookReq{
+				Format:   stringOf(models.Ebook.String()),
+				Isbn:     stringOf("9791090636071"),
+				Price:    int64Of(150000),
+				Qty:      int64Of(0),
+				Currency: stringOf("IDR"),
+			}}},
+		},
+		{
+			name: "delete notification",
+			products: `<Product>
+				<NotificationType>05</NotificationType>
+				<ProductIdentifier><ProductIDType>15</ProductIDType><IDValue>9780306406157</IDValue></ProductIdentifier>
+				<DescriptiveDetail><ProductForm>BC</ProductForm></DescriptiveDetail>
+			</Product>`,
+			records: []models.ImportRecord{{Row: 1, Book: models.AddBookReq{Isbn: stringOf("9780306406157")}, Skip: "delete notifications aren't imported"}},
+		},
+		{
+			name:     "product form not sold",
+			products: `<Product><DescriptiveDetail><ProductForm>PC</ProductForm></DescriptiveDetail></Product>`,
+			records:  []models.ImportRecord{{Row: 1, Err: errors.New("product form PC isn't sold")}},
+		},
+		{
+			name: "price with more decimals than the currency",
+			products: `<Product>
+				<DescriptiveDetail><ProductForm>BB</ProductForm></DescriptiveDetail>
+				<ProductSupply><SupplyDetail><Price><PriceAmount>10.50</PriceAmount><CurrencyCode>JPY</CurrencyCode></Price></SupplyDetail></ProductSupply>
+			</Product>`,
+			records: []models.ImportRecord{{Row: 1, Book: models.AddBookReq{Format: stringOf(models.Hardcover.String()), Qty: int64Of(0)}, Err: errors.New("price 10.50 has more decimals than JPY")}},
+		},
+		{
+			name: "invalid publication date",
+			products: `<Product>
+				<DescriptiveDetail><ProductForm>BB</ProductForm></DescriptiveDetail>
+				<PublishingDetail><PublishingDate><PublishingDateRole>01</PublishingDateRole><Date>2024-02-01</Date></PublishingDate></PublishingDetail>
+			</Product>`,
+			records: []models.ImportRecord{{Row: 1, Book: models.AddBookReq{Format: stringOf(models.Hardcover.String())}, Err: errors.New("publication date 2024-02-01 isn't a YYYYMMDD date")}},
+		},
+		{
+			name:     "short tags",
+			products: `<product><a001>1</a001></product>`,
+			err:      ErrOnixShortTags,
+		},
+	}
+	for _, tt := range tests {
+		message := `<?xml version="1.0" encoding="UTF-8"?><ONIXMessage release="3.0"><Header/>` + tt.products + `</ONIXMessage>`
+		var records []models.ImportRecord
+		err := ReadOnix(strings.NewReader(message), func(record models.ImportRecord) error {
+			records = append(records, record)
+			return nil
+		})
+		if err != tt.err {
+			t.Errorf("%s: ReadOnix() error = %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+		if !equalRecords(records, tt.records) {
+			t.Errorf("%s: ReadOnix() records = %+v, want %+v", tt.name, records, tt.records)
+		}
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		amount   string
+		currency string
+		want     int64
+		wantErr  bool
+	}{
+		{"12.99", "USD", 1299, false},
+		{"12.9", "USD", 1290, false},
+		{" 12 ", "USD", 1200, false},
+		{"150000", "IDR", 150000, false},
+		{"12.999", "USD", 0, true},
+		{"150000.5", "IDR", 0, true},
+		{"twelve", "USD", 0, true},
+	}
+	for _, tt := range tests {
+		currency, err := models.IsValidCurrency(tt.currency)
+		if err != nil {
+			t.Fatalf("IsValidCurrency(%q) = %v", tt.currency, err)
+		}
+		got, err := parseAmount(tt.amount, currency)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("parseAmount(%q, %s) = %v, %v, want %v, error %v", tt.amount, tt.currency, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/agustadewa/book-system/configs"
+	"github.com/agustadewa/book-system/models"
+	"github.com/agustadewa/book-system/repo"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Orders ends orders which won't be paid or shipped
+type Orders struct {
+	order     *repo.Order
+	credit    *repo.Credit
+	coupons   *Coupons
+	inventory *Inventory
+	library   *Library
+}
+
+func NewOrders(client *mongo.Client) *Orders {
+	return &Orders{
+		order:     repo.NewOrder(client),
+		credit:    repo.NewCredit(client),
+		coupons:   NewCoupons(client),
+		inventory: NewInventory(client),
+		library:   NewLibrary(client),
+	}
+}
+
+// CallOff ends an order which isn't shipped yet with the given status, declined or cancelled. Its books go
+// back to stock, its coupon is given back, its ebook leaves the library and what was paid so far is credited
+// to the customer. The status is set first so only one call-off runs, calling off an order again with the
+// same status finishes a call-off which failed halfway.
+func (o *Orders) CallOff(ctx context.Context, order *models.Order, status models.OrderStatus, reason string) error {
+	// orders from before stock locations reserve books at the default location
+	tookStock := order.TakesStock() && (order.LocationId != "" || order.Status.ReservesStock())
+	if order.Status != status {
+		if !order.Status.CanBeCalledOff() {
+			return fmt.Errorf("order is %s and can't be %s", order.Status, strings.ToLower(status.String()))
+		}
+		if err := o.order.UpdateStatusFrom(ctx, order.Id, order.Status, status); err != nil {
+			return err
+		}
+	}
+
+	// payments can't land anymore, the order is read again for what they paid
+	current, err := o.order.Get(ctx, order.Id)
+	if err != nil {
+		return err
+	}
+	*order = *current
+
+	// books taken for the order go back once, a retry finds the restore in the ledger
+	if tookStock {
+		if _, err = o.inventory.MoveOnce(ctx, order.BookId, order.LocationId, order.Qty, models.StockCancellationRestore, configs.StockSystemActor, order.Id, reason); err != nil {
+			return err
+		}
+	}
+	if err = o.coupons.Release(ctx, order.Id); err != nil {
+		return err
+	}
+	// a credited ebook can't be downloaded anymore
+	if order.Format.IsDigital() {
+		if err = o.library.Revoke(ctx, order.Id); err != nil {
+			return err
+		}
+	}
+
+	// one credit per order, a retry after a failure doesn't credit twice. An overpayment was credited when it
+	// was paid, only what went to the order is credited here.
+	paid := order.PaidAmount
+	if paid > order.TotalPrice {
+		paid = order.TotalPrice
+	}
+	if paid -= order.RefundedAmount; paid > 0 {
+		if _, err = o.credit.Add(ctx, models.Credit{
+			Id:        "order-" + order.Id,
+			UserId:    order.UserId,
+			OrderId:   order.Id,
+			Amount:    paid,
+			Currency:  CurrencyOrDefault(order.Currency),
+			Reason:    reason,
+			CreatedAt: time.Now(),
+		}); err != nil && err != repo.ErrCreditExists {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,303 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/agustadewa/book-system/configs"
+	"github.com/agustadewa/book-system/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var ErrUnknownPaymentProvider = errors.New("unknown payment provider")
+var ErrPaymentIntentNotFound = errors.New("payment intent not found")
+var ErrPaymentNotCaptured = errors.New("payment is not captured")
+var ErrRefundExceedsPayment = errors.New("refund amount is greater than captured amount")
+var ErrPaymentStatusUnavailable = errors.New("payment provider doesn't report payment status")
+
+// PaymentIntent is the state of a payment on the provider side
+type PaymentIntent struct {
+	Reference     string
+	Amount        int64
+	Currency      string
+	Status        models.PaymentStatus
+	FailureReason string
+	Refunded      int64
+}
+
+// PaymentRefund is the result of a refund made through a provider
+type PaymentRefund struct {
+	Reference string
+	Amount    int64
+	Currency  string
+}
+
+// CaptureDetails carries what the customer submitted to complete a payment
+type CaptureDetails struct {
+	Receipt    string
+	CardNumber string
+}
+
+// PaymentProvider moves money for an order, the order is only paid once the provider confirms it. A refund
+// sent again with the same idempotency key returns the first refund instead of refunding twice.
+type PaymentProvider interface {
+	Name() string
+	CreateIntent(ctx context.Context, orderId string, amount int64, currency string) (*PaymentIntent, error)
+	Capture(ctx context.Context, reference string, details CaptureDetails) (*PaymentIntent, error)
+	Refund(ctx context.Context, reference string, amount int64, idempotencyKey string) (*PaymentRefund, error)
+	Status(ctx context.Context, reference string) (*PaymentIntent, error)
+}
+
+// ReviewableProvider is a provider whose payments are confirmed by an admin reviewing the receipt
+type ReviewableProvider interface {
+	PaymentProvider
+	Review(ctx context.Context, reference string, approved bool) (*PaymentIntent, error)
+}
+
+// providers are shared by every handler, the fake card provider keeps its intents in memory
+var paymentProviders = map[string]PaymentProvider{
+	configs.ManualTransferProvider: newManualTransfer(),
+	configs.FakeCardProvider:       newFakeCard(),
+}
+
+// GetPaymentProvider returns a payment provider by given name, empty name returns the default provider
+func GetPaymentProvider(name string) (PaymentProvider, error) {
+	if name == "" {
+		name = configs.DefaultPaymentProvider
+	}
+	provider, ok := paymentProviders[name]
+	if !ok {
+		return nil, ErrUnknownPaymentProvider
+	}
+	return provider, nil
+}
+
+// intentStore keeps simulated payment intents
+type intentStore struct {
+	mu      sync.Mutex
+	prefix  string
+	intents map[string]*PaymentIntent
+	refunds map[string]*PaymentRefund
+}
+
+func newIntentStore(prefix string) *intentStore {
+	return &intentStore{prefix: prefix, intents: map[string]*PaymentIntent{}, refunds: map[string]*PaymentRefund{}}
+}
+
+func (s *intentStore) create(amount int64, currency string) *PaymentIntent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	intent := &PaymentIntent{
+		Reference: s.prefix + primitive.NewObjectID().Hex(),
+		Amount:    amount,
+		Currency:  currency,
+		Status:    models.PaymentPending,
+	}
+	s.intents[intent.Reference] = intent
+	copied := *intent
+	return &copied
+}
+
+func (s *intentStore) update(reference string, fn func(intent *PaymentIntent) error) (*PaymentIntent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	intent, ok := s.intents[reference]
+	if !ok {
+		return nil, ErrPaymentIntentNotFound
+	}
+	if err := fn(intent); err != nil {
+		return nil, err
+	}
+	copied := *intent
+	return &copied, nil
+}
+
+// refund refunds a captured intent once per idempotency key, a repeated key returns the first refund
+func (s *intentStore) refund(reference string, amount int64, idempotencyKey string) (*PaymentRefund, error) {
+	var refund PaymentRefund
+	_, err := s.update(reference, func(intent *PaymentIntent) error {
+		if existing, ok := s.refunds[idempotencyKey]; ok {
+			refund = *existing
+			return nil
+		}
+		if !intent.Status.IsSucceeded() {
+			return ErrPaymentNotCaptured
+		}
+		if amount <= 0 || intent.Refunded+amount > intent.Amount {
+			return ErrRefundExceedsPayment
+		}
+		intent.Refunded += amount
+
+		refund = PaymentRefund{
+			Reference: s.prefix + "re_" + primitive.NewObjectID().Hex(),
+			Amount:    amount,
+			Currency:  intent.Currency,
+		}
+		stored := refund
+		s.refunds[idempotencyKey] = &stored
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &refund, nil
+}
+
+// manualTransfer is the bank transfer flow, the customer transfers the money and uploads a receipt which
+// an admin reviews. There is no one to ask on the other side, so the payment record is the only state.
+type manualTransfer struct{}
+
+func newManualTransfer() *manualTransfer {
+	return &manualTransfer{}
+}
+
+func (p *manualTransfer) Name() string {
+	return configs.ManualTransferProvider
+}
+
+func (p *manualTransfer) CreateIntent(_ context.Context, _ string, amount int64, currency string) (*PaymentIntent, error) {
+	return &PaymentIntent{
+		Reference: "mt_" + primitive.NewObjectID().Hex(),
+		Amount:    amount,
+		Currency:  currency,
+		Status:    models.PaymentPending,
+	}, nil
+}
+
+func (p *manualTransfer) Capture(_ context.Context, reference string, details CaptureDetails) (*PaymentIntent, error) {
+	if details.Receipt == "" {
+		return nil, errors.New("receipt is required for manual transfer")
+	}
+	return &PaymentIntent{Reference: reference, Status: models.PaymentPendingReview}, nil
+}
+
+func (p *manualTransfer) Review(_ context.Context, reference string, approved bool) (*PaymentIntent, error) {
+	if approved {
+		return &PaymentIntent{Reference: reference, Status: models.PaymentSucceeded}, nil
+	}
+	return &PaymentIntent{Reference: reference, Status: models.PaymentRejected}, nil
+}
+
+// Refund is done by the admin transferring the money back, the refund is recorded as is
+func (p *manualTransfer) Refund(_ context.Context, reference string, amount int64, idempotencyKey string) (*PaymentRefund, error) {
+	if amount <= 0 {
+		return nil, ErrRefundExceedsPayment
+	}
+	return &PaymentRefund{Reference: "mt_re_" + idempotencyKey, Amount: amount}, nil
+}
+
+func (p *manualTransfer) Status(_ context.Context, _ string) (*PaymentIntent, error) {
+	return nil, ErrPaymentStatusUnavailable
+}
+
+// Fake card numbers, any other number passing the luhn check succeeds
+const (
+	FakeCardSucceeds     = "4242424242424242"
+	FakeCardDeclines     = "4000000000000002"
+	FakeCardRequiresWait = "4000000000003220"
+
+	// pending fake card payments are confirmed by the provider after this delay
+	fakeCardConfirmDelay = 5 * time.Second
+)
+
+// fakeCard is a deterministic card provider for local use
+type fakeCard struct {
+	store      *intentStore
+	mu         sync.Mutex
+	capturedAt map[string]time.Time
+}
+
+func newFakeCard() *fakeCard {
+	return &fakeCard{store: newIntentStore("fc_"), capturedAt: map[string]time.Time{}}
+}
+
+func (p *fakeCard) Name() string {
+	return configs.FakeCardProvider
+}
+
+func (p *fakeCard) CreateIntent(_ context.Context, _ string, amount int64, currency string) (*PaymentIntent, error) {
+	return p.store.create(amount, currency), nil
+}
+
+func (p *fakeCard) Capture(_ context.Context, reference string, details CaptureDetails) (*PaymentIntent, error) {
+	cardNumber := strings.ReplaceAll(details.CardNumber, " ", "")
+	if cardNumber == "" {
+		return nil, errors.New("card number is required for card payment")
+	}
+
+	intent, err := p.store.update(reference, func(intent *PaymentIntent) error {
+		if !intent.Status.IsPending() {
+			return fmt.Errorf("payment intent is already %s", strings.ToLower(intent.Status.String()))
+		}
+
+		switch {
+		case cardNumber == FakeCardDeclines:
+			intent.Status = models.PaymentFailed
+			intent.FailureReason = "card declined"
+		case cardNumber == FakeCardRequiresWait:
+			intent.Status = models.PaymentPending
+		case !isValidLuhn(cardNumber):
+			intent.Status = models.PaymentFailed
+			intent.FailureReason = "invalid card number"
+		default:
+			intent.Status = models.PaymentSucceeded
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	p.capturedAt[reference] = time.Now()
+	p.mu.Unlock()
+
+	return intent, nil
+}
+
+func (p *fakeCard) Refund(_ context.Context, reference string, amount int64, idempotencyKey string) (*PaymentRefund, error) {
+	return p.store.refund(reference, amount, idempotencyKey)
+}
+
+func (p *fakeCard) Status(_ context.Context, reference string) (*PaymentIntent, error) {
+	p.mu.Lock()
+	capturedAt, captured := p.capturedAt[reference]
+	p.mu.Unlock()
+
+	return p.store.update(reference, func(intent *PaymentIntent) error {
+		if captured && intent.Status.IsPending() && time.Since(capturedAt) >= fakeCardConfirmDelay {
+			intent.Status = models.PaymentSucceeded
+		}
+		return nil
+	})
+}
+
+func isValidLuhn(number string) bool {
+	if len(number) < 12 {
+		return false
+	}
+
+	sum := 0
+	double := false
+	for i := len(number) - 1; i >= 0; i-- {
+		d := int(number[i] - '0')
+		if d < 0 || d > 9 {
+			return false
+		}
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
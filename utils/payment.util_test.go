@@ -0,0 +1,25 @@
+package utils
+
+import "testing"
+
+func TestIsValidLuhn(t *testing.T) {
+	tests := []struct {
+		number string
+		want   bool
+	}{
+		{FakeCardSucceeds, true},
+		{FakeCardDeclines, true},
+		{FakeCardRequiresWait, true},
+		{"79927398713", false},
+		{"378282246310005", true},
+		{"4242424242424241", false},
+		{"4242 4242 4242 4242", false},
+		{"424242424242424a", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := isValidLuhn(tt.number); got != tt.want {
+			t.Errorf("isValidLuhn(%q) = %v, want %v", tt.number, got, tt.want)
+		}
+	}
+}
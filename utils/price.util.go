@@ -0,0 +1,173 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/agustadewa/book-system/models"
+	"github.com/agustadewa/book-system/repo"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Prices changes book prices and keeps the history of every change
+type Prices struct {
+	book     *repo.Book
+	schedule *repo.PriceSchedule
+	history  *repo.PriceHistory
+	rates    *Rates
+}
+
+func NewPrices(client *mongo.Client) *Prices {
+	return &Prices{
+		book:     repo.NewBook(client),
+		schedule: repo.NewPriceSchedule(client),
+		history:  repo.NewPriceHistory(client),
+		rates:    NewRates(client),
+	}
+}
+
+// Record adds a price change to the history of a book
+func (p *Prices) Record(ctx context.Context, bookId string, previous int64, price int64, reason models.PriceChangeReason, scheduleId string, changedBy string) error {
+	return p.history.Add(ctx, models.PriceHistory{
+		Id:            primitive.NewObjectID().Hex(),
+		BookId:        bookId,
+		Price:         price,
+		PreviousPrice: previous,
+		Reason:        reason,
+		ScheduleId:    scheduleId,
+		ChangedBy:     changedBy,
+		ChangedAt:     time.Now(),
+	})
+}
+
+// ChangeCurrency prepares moving a book to another currency and returns its price in the new currency, the
+// given price or the current price converted at today's rate. The history so far is stamped with the old
+// currency. A book with open price schedules can't change currency, their prices are in the old currency.
+func (p *Prices) ChangeCurrency(ctx context.Context, book *models.Book, currency string, price *int64) (int64, error) {
+	schedules, err := p.schedule.GetAllOpenByBookId(ctx, book.Id)
+	if err != nil {
+		return 0, err
+	}
+	if len(*schedules) > 0 {
+		return 0, fmt.Errorf("book has price schedule %s in %s, cancel its schedules before changing the currency", (*schedules)[0].Id, CurrencyOrDefault(book.Currency))
+	}
+
+	newPrice := int64(0)
+	if price != nil {
+		newPrice = *price
+	} else if newPrice, _, err = p.rates.Convert(ctx, book.Price, CurrencyOrDefault(book.Currency), currency, time.Now()); err != nil {
+		return 0, err
+	}
+
+	if err = p.history.SetCurrency(ctx, book.Id, CurrencyOrDefault(book.Currency)); err != nil {
+		return 0, err
+	}
+	return newPrice, nil
+}
+
+// ApplySchedules starts the scheduled prices which are due and ends the ones which are over
+func (p *Prices) ApplySchedules(ctx context.Context, now time.Time) error {
+	ending, err := p.schedule.GetAllDueToEnd(ctx, now)
+	if err != nil {
+		return err
+	}
+	for i := range *ending {
+		if err = p.EndSchedule(ctx, &(*ending)[i], now, ""); err != nil && err != repo.ErrPriceScheduleStatusChanged {
+			return err
+		}
+	}
+
+	starting, err := p.schedule.GetAllDueToStart(ctx, now)
+	if err != nil {
+		return err
+	}
+	for i := range *starting {
+		schedule := &(*starting)[i]
+
+		// a schedule which was missed entirely, e.g. the server was down, is ended right away
+		if schedule.EndsAt != nil && !schedule.EndsAt.After(now) {
+			if err = p.startSchedule(ctx, schedule, now); err != nil && err != repo.ErrPriceScheduleStatusChanged {
+				return err
+			}
+			if err = p.EndSchedule(ctx, schedule, now, ""); err != nil && err != repo.ErrPriceScheduleStatusChanged {
+				return err
+			}
+			continue
+		}
+
+		if err = p.startSchedule(ctx, schedule, now); err != nil && err != repo.ErrPriceScheduleStatusChanged {
+			return err
+		}
+	}
+	return nil
+}
+
+// EndSchedule ends an active price and puts back the price it replaced. When the price was changed
+// while the schedule was running the newer price is kept. The price is put back before the schedule is
+// ended, a failure leaves the schedule active and the next run ends it.
+func (p *Prices) EndSchedule(ctx context.Context, schedule *models.PriceSchedule, now time.Time, changedBy string) error {
+	reverted, err := p.book.EndScheduledPrice(ctx, schedule.BookId, schedule.Id, schedule.Price, schedule.PreviousPrice)
+	if err != nil {
+		return err
+	}
+	if err = p.schedule.SetEnded(ctx, schedule.Id, now); err != nil {
+		return err
+	}
+
+	if !reverted {
+		log.Printf("[PRICE] book %v price changed during schedule %v, keeping it\n", schedule.BookId, schedule.Id)
+		return nil
+	}
+	if schedule.Price == schedule.PreviousPrice {
+		return nil
+	}
+	return p.Record(ctx, schedule.BookId, schedule.Price, schedule.PreviousPrice, models.PriceScheduleEnd, schedule.Id, changedBy)
+}
+
+// CancelSchedule cancels a schedule which hasn't started. A start cut short, e.g. by a restart, may have set
+// the price already, it is put back.
+func (p *Prices) CancelSchedule(ctx context.Context, schedule *models.PriceSchedule, now time.Time) error {
+	if err := p.schedule.SetCancelled(ctx, schedule.Id, now); err != nil {
+		return err
+	}
+
+	book, err := p.book.Get(ctx, schedule.BookId)
+	if err != nil {
+		return err
+	}
+	if book.ScheduledPrice == nil || book.ScheduledPrice.ScheduleId != schedule.Id {
+		return nil
+	}
+	_, err = p.book.EndScheduledPrice(ctx, book.Id, schedule.Id, schedule.Price, book.ScheduledPrice.PreviousPrice)
+	return err
+}
+
+// startSchedule sets the scheduled price and keeps the replaced price to put back when the schedule ends.
+// The book is marked with the schedule and the replaced price together with the price, a start cut short
+// before the schedule is activated is finished by the next run with the marked price.
+func (p *Prices) startSchedule(ctx context.Context, schedule *models.PriceSchedule, now time.Time) error {
+	previous, err := p.book.StartScheduledPrice(ctx, schedule.BookId, schedule.Id, schedule.Price)
+	if err != nil {
+		return err
+	}
+
+	if err = p.schedule.SetActivated(ctx, schedule.Id, previous, now); err == repo.ErrPriceScheduleStatusChanged {
+		// cancelled meanwhile, undo the price unless it changed again
+		if _, rerr := p.book.EndScheduledPrice(ctx, schedule.BookId, schedule.Id, schedule.Price, previous); rerr != nil {
+			return rerr
+		}
+		return err
+	} else if err != nil {
+		return err
+	}
+
+	schedule.Status = models.PriceScheduleActive
+	schedule.PreviousPrice = previous
+	if previous == schedule.Price {
+		return nil
+	}
+	return p.Record(ctx, schedule.BookId, previous, schedule.Price, models.PriceScheduleStart, schedule.Id, "")
+}
@@ -0,0 +1,188 @@
+package utils
+
+import (
+	"context"
+	"time"
+
+	"github.com/agustadewa/book-system/configs"
+	"github.com/agustadewa/book-system/models"
+	"github.com/agustadewa/book-system/repo"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Pricing computes what an order costs, the same quote is shown at checkout and stored on the order
+type Pricing struct {
+	zone  *repo.ShippingZone
+	tax   *repo.TaxRegion
+	rates *Rates
+}
+
+func NewPricing(client *mongo.Client) *Pricing {
+	return &Pricing{zone: repo.NewShippingZone(client), tax: repo.NewTaxRegion(client), rates: NewRates(client)}
+}
+
+// Quote returns the price breakdown of qty books shipped to address, orders without an address are
+// picked up at the shop and ship for free, digital books aren't shipped. The coupon discount comes off the books before tax, and tax
+// is charged on the books only, by the region of the address.
+// The order is priced in the store currency and converted to currency with the rate in effect now,
+// an empty currency is the store currency.
+func (p *Pricing) Quote(ctx context.Context, book *models.Book, qty int64, address *models.Address, coupon *models.Coupon, currency string) (*models.OrderQuote, error) {
+	now := time.Now()
+
+	unitPrice, _, err := p.rates.Convert(ctx, book.Price, CurrencyOrDefault(book.Currency), configs.DefaultCurrency, now)
+	if err != nil {
+		return nil, err
+	}
+
+	quote := &models.OrderQuote{
+		Currency:     configs.DefaultCurrency,
+		BaseCurrency: configs.DefaultCurrency,
+		UnitPrice:    unitPrice,
+		Subtotal:     unitPrice * qty,
+		TaxLines:     make([]models.TaxLine, 0),
+	}
+
+	if coupon != nil {
+		discount, err := CouponDiscount(*coupon, book, quote.Subtotal, now)
+		if err != nil {
+			return nil, err
+		}
+		quote.CouponCode = coupon.Code
+		quote.Discount = discount
+	}
+	discounted := quote.Subtotal - quote.Discount
+
+	if address != nil && !book.IsDigital() {
+		zones, err := p.zone.GetAll(ctx)
+		if err != nil {
+			return nil, err
+		}
+		zone, err := MatchShippingZone(*zones, *address)
+		if err != nil {
+			return nil, err
+		}
+
+		weight := ChargeableWeight(book, qty)
+		quote.Shipping = &models.ShippingQuote{
+			ZoneId:      zone.Id,
+			ZoneName:    zone.Name,
+			WeightGrams: weight,
+			Fee:         ShippingFee(zone.Rate, weight, discounted),
+		}
+		quote.ShippingFee = quote.Shipping.Fee
+	}
+
+	region, err := p.taxRegion(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	if region != nil {
+		line := TaxLineOf(*region, book.TaxClass, discounted)
+		quote.TaxLines = append(quote.TaxLines, line)
+	}
+
+	sumQuote(quote)
+	quote.BaseGrandTotal = quote.GrandTotal
+
+	if currency == "" || currency == configs.DefaultCurrency {
+		return quote, nil
+	}
+	return p.convertQuote(ctx, quote, currency, now)
+}
+
+// PriceDisplay returns the price of a book as customers of a tax region see it in currency, an empty
+// region id uses the default region and an empty currency the store currency. Nil is returned when
+// neither a region nor a currency applies.
+func (p *Pricing) PriceDisplay(ctx context.Context, book *models.Book, regionId string, currency string) (*models.PriceDisplay, error) {
+	var region *models.TaxRegion
+	var err error
+	if regionId != "" {
+		region, err = p.tax.Get(ctx, regionId)
+	} else {
+		region, err = p.taxRegion(ctx, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if region == nil && currency == "" {
+		return nil, nil
+	}
+
+	bookCurrency := CurrencyOrDefault(book.Currency)
+	display := &models.PriceDisplay{Price: book.Price, Currency: bookCurrency}
+	if region != nil {
+		line := TaxLineOf(*region, book.TaxClass, book.Price)
+		display.RegionId = region.Id
+		display.Tax = line.Amount
+		display.IncludesTax = line.Inclusive
+	}
+
+	if currency == "" || currency == bookCurrency {
+		return display, nil
+	}
+	locked, err := p.rates.Lock(ctx, bookCurrency, currency, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if display.Price, err = ConvertLocked(display.Price, bookCurrency, currency, locked); err != nil {
+		return nil, err
+	}
+	if display.Tax, err = ConvertLocked(display.Tax, bookCurrency, currency, locked); err != nil {
+		return nil, err
+	}
+	display.Currency = currency
+	return display, nil
+}
+
+// taxRegion returns the region taxing an order, nil when no region covers it
+func (p *Pricing) taxRegion(ctx context.Context, address *models.Address) (*models.TaxRegion, error) {
+	regions, err := p.tax.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	region, err := MatchTaxRegion(*regions, address)
+	if err == ErrNoTaxRegion {
+		return nil, nil
+	}
+	return region, err
+}
+
+// convertQuote converts every amount of a quote and locks the rate used, the totals are summed again
+// from the converted amounts so they still add up
+func (p *Pricing) convertQuote(ctx context.Context, quote *models.OrderQuote, currency string, at time.Time) (*models.OrderQuote, error) {
+	locked, err := p.rates.Lock(ctx, quote.Currency, currency, at)
+	if err != nil {
+		return nil, err
+	}
+
+	amounts := []*int64{&quote.UnitPrice, &quote.Subtotal, &quote.Discount, &quote.ShippingFee}
+	if quote.Shipping != nil {
+		amounts = append(amounts, &quote.Shipping.Fee)
+	}
+	for i := range quote.TaxLines {
+		amounts = append(amounts, &quote.TaxLines[i].TaxableAmount, &quote.TaxLines[i].Amount)
+	}
+	for _, amount := range amounts {
+		if *amount, err = ConvertLocked(*amount, quote.Currency, currency, locked); err != nil {
+			return nil, err
+		}
+	}
+
+	quote.Currency = currency
+	quote.ExchangeRate = locked
+	sumQuote(quote)
+	return quote, nil
+}
+
+// sumQuote sums the tax and grand total of a quote, tax included in the prices isn't added again
+func sumQuote(quote *models.OrderQuote) {
+	var addedTax int64
+	quote.TaxTotal = 0
+	for _, line := range quote.TaxLines {
+		quote.TaxTotal += line.Amount
+		if !line.Inclusive {
+			addedTax += line.Amount
+		}
+	}
+	quote.GrandTotal = quote.Subtotal - quote.Discount + addedTax + quote.ShippingFee
+}
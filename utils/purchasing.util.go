@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/agustadewa/book-system/models"
+	"github.com/agustadewa/book-system/repo"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Purchasing receives purchase orders into stock
+type Purchasing struct {
+	purchaseOrder *repo.PurchaseOrder
+	inventory     *Inventory
+}
+
+func NewPurchasing(client *mongo.Client) *Purchasing {
+	return &Purchasing{
+		purchaseOrder: repo.NewPurchaseOrder(client),
+		inventory:     NewInventory(client),
+	}
+}
+
+// Receive books a delivery against an open purchase order, every line is added to stock at the location of
+// the purchase order as a receipt. A line which fails stops the receipt, the lines before it stay received.
+func (p *Purchasing) Receive(ctx context.Context, purchaseOrderId string, lines []models.ReceivedLine, adminId string, note string) (*models.PurchaseOrder, error) {
+	purchaseOrder, err := p.purchaseOrder.Get(ctx, purchaseOrderId)
+	if err != nil {
+		return nil, err
+	}
+	if !purchaseOrder.Status.IsOpen() {
+		return nil, repo.ErrPurchaseOrderClosed
+	}
+	if err = ValidateReceivedLines(*purchaseOrder, lines); err != nil {
+		return nil, err
+	}
+
+	receipt := models.GoodsReceipt{
+		Id:      primitive.NewObjectID().Hex(),
+		Lines:   make([]models.ReceivedLine, 0, len(lines)),
+		AdminId: adminId,
+		Note:    note,
+	}
+	for _, received := range lines {
+		line := purchaseOrder.Line(received.BookId)
+		if err = p.purchaseOrder.IncReceived(ctx, purchaseOrder.Id, *line, received.Qty); err != nil {
+			break
+		}
+		if _, err = p.inventory.Move(ctx, received.BookId, purchaseOrder.LocationId, received.Qty, models.StockReceipt, adminId, purchaseOrder.Id, note); err != nil {
+			if uerr := p.purchaseOrder.IncReceived(ctx, purchaseOrder.Id, *line, -received.Qty); uerr != nil {
+				log.Printf("[PURCHASING] can't undo receipt of book %v on %v: %v\n", received.BookId, purchaseOrder.Id, uerr)
+			}
+			break
+		}
+		receipt.Lines = append(receipt.Lines, received)
+	}
+	if len(receipt.Lines) == 0 {
+		return nil, err
+	}
+
+	receipt.ReceivedAt = time.Now()
+	updated, aerr := p.purchaseOrder.AddReceipt(ctx, purchaseOrder.Id, receipt)
+	if aerr != nil {
+		return nil, aerr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%v of %v lines are received, the rest failed: %w", len(receipt.Lines), len(lines), err)
+	}
+	return updated, nil
+}
+
+// ValidateReceivedLines checks a delivery against the lines of a purchase order
+func ValidateReceivedLines(purchaseOrder models.PurchaseOrder, lines []models.ReceivedLine) error {
+	if len(lines) == 0 {
+		return errors.New("a receipt needs at least one line")
+	}
+	seen := map[string]bool{}
+	for _, received := range lines {
+		line := purchaseOrder.Line(received.BookId)
+		if line == nil {
+			return fmt.Errorf("book %s isn't on the purchase order", received.BookId)
+		}
+		if seen[received.BookId] {
+			return fmt.Errorf("book %s is received twice", received.BookId)
+		}
+		seen[received.BookId] = true
+		if received.Qty <= 0 {
+			return errors.New("received quantity minimum is 1")
+		}
+		if received.Qty > line.Outstanding() {
+			return fmt.Errorf("%v of book %s are outstanding, %v received", line.Outstanding(), received.BookId, received.Qty)
+		}
+	}
+	return nil
+}
+
+// ValidatePurchaseOrderLines checks the lines of a new purchase order, every book once with a quantity
+func ValidatePurchaseOrderLines(lines []models.AddPurchaseOrderLine) error {
+	if len(lines) == 0 {
+		return errors.New("a purchase order needs at least one line")
+	}
+	seen := map[string]bool{}
+	for _, line := range lines {
+		if seen[line.BookId] {
+			return fmt.Errorf("book %s is ordered twice, use one line", line.BookId)
+		}
+		seen[line.BookId] = true
+		if line.Qty <= 0 {
+			return errors.New("quantity minimum is 1")
+		}
+		if line.UnitCost < 0 {
+			return errors.New("unit cost can't be lower than 0")
+		}
+	}
+	return nil
+}
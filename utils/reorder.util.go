@@ -0,0 +1,159 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/agustadewa/book-system/configs"
+	"github.com/agustadewa/book-system/models"
+	"github.com/agustadewa/book-system/repo"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Reorder watches book stock against the reorder thresholds and suggests how much to reorder
+type Reorder struct {
+	book         *repo.Book
+	order        *repo.Order
+	purchase     *repo.PurchaseOrder
+	notification *repo.Notification
+	notifier     Notifier
+}
+
+func NewReorder(client *mongo.Client) *Reorder {
+	return &Reorder{
+		book:         repo.NewBook(client),
+		order:        repo.NewOrder(client),
+		purchase:     repo.NewPurchaseOrder(client),
+		notification: repo.NewNotification(client),
+		notifier:     NewNotifier(client),
+	}
+}
+
+// CheckLowStock alerts the purchasing team about books at or below their reorder threshold, once until the
+// book is restocked above it
+func (r *Reorder) CheckLowStock(ctx context.Context) error {
+	books, err := r.book.GetAllLowStock(ctx)
+	if err != nil {
+		return err
+	}
+
+	low := map[string]bool{}
+	for _, book := range *books {
+		low[book.Id] = true
+		err = r.notifier.Notify(ctx, models.Notification{
+			Type:    models.LowStockNotification,
+			Team:    configs.PurchasingTeam,
+			Key:     lowStockKey(book.Id),
+			BookId:  book.Id,
+			Message: fmt.Sprintf("%s is low on stock, %v left with a reorder threshold of %v", book.Name, book.Qty, book.ReorderThreshold),
+		})
+		if err != nil && err != repo.ErrNotificationExists {
+			return err
+		}
+	}
+
+	// restocked books get alerted again when they run low the next time
+	open, err := r.notification.GetAllOpenByType(ctx, models.LowStockNotification)
+	if err != nil {
+		return err
+	}
+	for _, notification := range *open {
+		if low[notification.BookId] {
+			continue
+		}
+		if err = r.notification.SetResolved(ctx, notification.Id); err != nil && err != repo.ErrNotificationNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+// Suggestions returns the books which should be reordered, sales of the last salesDays give the daily sales
+// and a reorder brings the stock to the reorder threshold plus coverDays of sales
+func (r *Reorder) Suggestions(ctx context.Context, salesDays int, coverDays int, now time.Time) ([]models.ReorderSuggestion, error) {
+	sales, err := r.order.SalesByBook(ctx, now.AddDate(0, 0, -salesDays))
+	if err != nil {
+		return nil, err
+	}
+	lowStock, err := r.book.GetAllLowStock(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sold := map[string]int64{}
+	bookIds := make([]string, 0, len(sales)+len(*lowStock))
+	for _, sale := range sales {
+		sold[sale.BookId] = sale.Qty
+		bookIds = append(bookIds, sale.BookId)
+	}
+	for _, book := range *lowStock {
+		if _, ok := sold[book.Id]; !ok {
+			bookIds = append(bookIds, book.Id)
+		}
+	}
+	books, err := r.book.GetAllByIds(ctx, bookIds)
+	if err != nil {
+		return nil, err
+	}
+	onOrder, err := r.purchase.OutstandingByBook(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	suggestions := make([]models.ReorderSuggestion, 0)
+	for _, book := range *books {
+		suggestion := reorderSuggestion(book, sold[book.Id], onOrder[book.Id], salesDays, coverDays)
+		if suggestion.SuggestedQty > 0 {
+			suggestions = append(suggestions, suggestion)
+		}
+	}
+
+	// books running out first come first, books without sales last
+	sort.Slice(suggestions, func(a, b int) bool {
+		da, db := suggestions[a].DaysOfStock, suggestions[b].DaysOfStock
+		if da == nil || db == nil {
+			if (da == nil) != (db == nil) {
+				return db == nil
+			}
+			return suggestions[a].Name < suggestions[b].Name
+		}
+		return *da < *db
+	})
+	return suggestions, nil
+}
+
+func reorderSuggestion(book models.Book, unitsSold int64, onOrderQty int64, salesDays int, coverDays int) models.ReorderSuggestion {
+	suggestion := models.ReorderSuggestion{
+		BookId:           book.Id,
+		Name:             book.Name,
+		Qty:              book.Qty,
+		OnOrderQty:       onOrderQty,
+		ReorderThreshold: book.ReorderThreshold,
+		UnitsSold:        unitsSold,
+	}
+	if salesDays > 0 {
+		suggestion.DailySales = float64(unitsSold) / float64(salesDays)
+	}
+	if suggestion.DailySales > 0 {
+		daysOfStock := math.Round(float64(book.Qty)/suggestion.DailySales*10) / 10
+		suggestion.DaysOfStock = &daysOfStock
+	}
+
+	// stock at the threshold still alerts, a book without sales is brought just above it
+	target := book.ReorderThreshold + int64(math.Ceil(suggestion.DailySales*float64(coverDays)))
+	if book.ReorderThreshold > 0 && target == book.ReorderThreshold {
+		target++
+	}
+	if target > book.Qty+onOrderQty {
+		suggestion.SuggestedQty = target - book.Qty - onOrderQty
+	}
+	suggestion.DailySales = math.Round(suggestion.DailySales*100) / 100
+	return suggestion
+}
+
+func lowStockKey(bookId string) string {
+	return "low_stock:" + bookId
+}
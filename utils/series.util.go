@@ -0,0 +1,190 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/agustadewa/book-system/models"
+	"github.com/agustadewa/book-system/repo"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Series keeps the volumes of the series in order. A volume is a title, so every format of it is in the
+// series, and readers are sent on to the next volume in the format they're looking at when there is one.
+type Series struct {
+	series *repo.Series
+	book   *repo.Book
+}
+
+func NewSeries(client *mongo.Client) *Series {
+	return &Series{
+		series: repo.NewSeries(client),
+		book:   repo.NewBook(client),
+	}
+}
+
+// Add creates a series
+func (s *Series) Add(ctx context.Context, addSeries models.AddSeries) (string, error) {
+	nameKey := NameKey(addSeries.Name)
+	if nameKey == "" {
+		return "", errEmptyName
+	}
+	return s.series.Add(ctx, models.Series{
+		Id:          primitive.NewObjectID().Hex(),
+		Name:        strings.TrimSpace(addSeries.Name),
+		NameKey:     nameKey,
+		Description: addSeries.Description,
+		CreatedAt:   time.Now(),
+	})
+}
+
+// Get returns a series with its volumes
+func (s *Series) Get(ctx context.Context, seriesId string) (*models.SeriesResp, error) {
+	series, err := s.series.Get(ctx, seriesId)
+	if err != nil {
+		return nil, err
+	}
+	volumes, err := s.Volumes(ctx, seriesId, "")
+	if err != nil {
+		return nil, err
+	}
+	return &models.SeriesResp{Series: *series, Volumes: volumes}, nil
+}
+
+// Volumes returns the titles of a series in volume order, each linked to its variant in the given format
+// or to its first variant
+func (s *Series) Volumes(ctx context.Context, seriesId string, format models.BookFormat) ([]models.SeriesVolume, error) {
+	books, err := s.book.GetAllBySeriesId(ctx, seriesId)
+	if err != nil {
+		return nil, err
+	}
+
+	volumes := make([]models.SeriesVolume, 0)
+	for _, book := range *books {
+		// the variants of a title come one after another
+		if n := len(volumes); n > 0 && volumes[n-1].TitleId == book.TitleId {
+			if book.Format == format {
+				volumes[n-1].BookId = book.Id
+			}
+			continue
+		}
+		volumes = append(volumes, models.SeriesVolume{
+			Volume:  book.Series.Volume,
+			TitleId: book.TitleId,
+			BookId:  book.Id,
+			Name:    book.Name,
+		})
+	}
+	return volumes, nil
+}
+
+// Nav returns the volumes before and after a book in its series, nil when the book isn't in a series
+func (s *Series) Nav(ctx context.Context, book models.Book) (*models.SeriesNav, error) {
+	if book.Series == nil {
+		return nil, nil
+	}
+	volumes, err := s.Volumes(ctx, book.Series.SeriesId, book.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	nav := models.SeriesNav{SeriesEntry: *book.Series}
+	for i, volume := range volumes {
+		if volume.TitleId != book.TitleId {
+			continue
+		}
+		if i > 0 {
+			nav.Previous = &volumes[i-1]
+		}
+		if i < len(volumes)-1 {
+			nav.Next = &volumes[i+1]
+		}
+		break
+	}
+	return &nav, nil
+}
+
+// SetVolume places the title of a book in a series as the given volume, a title can be in one series
+func (s *Series) SetVolume(ctx context.Context, seriesId string, bookId string, volume float64) error {
+	if volume <= 0 {
+		return errors.New("volume must be greater than 0")
+	}
+	series, err := s.series.Get(ctx, seriesId)
+	if err != nil {
+		return err
+	}
+	book, err := s.book.Get(ctx, bookId)
+	if err != nil {
+		return err
+	}
+	if book.Series != nil && book.Series.SeriesId != seriesId {
+		return fmt.Errorf("book is already in series %s", book.Series.Name)
+	}
+
+	// check the volume is free
+	volumes, err := s.Volumes(ctx, seriesId, "")
+	if err != nil {
+		return err
+	}
+	for _, v := range volumes {
+		if v.Volume == volume && v.TitleId != book.TitleId {
+			return fmt.Errorf("volume %v of the series is %s", volume, v.Name)
+		}
+	}
+
+	return s.book.SetSeries(ctx, book.TitleId, &models.SeriesEntry{SeriesId: series.Id, Name: series.Name, Volume: volume})
+}
+
+// RemoveVolume takes the title of a book out of a series
+func (s *Series) RemoveVolume(ctx context.Context, seriesId string, bookId string) error {
+	book, err := s.book.Get(ctx, bookId)
+	if err != nil {
+		return err
+	}
+	if book.Series == nil || book.Series.SeriesId != seriesId {
+		return errors.New("book isn't in the series")
+	}
+	return s.book.SetSeries(ctx, book.TitleId, nil)
+}
+
+// Update renames a series or changes the description, a new name is set on the books of the series
+func (s *Series) Update(ctx context.Context, seriesId string, updateSeries models.UpdateSeries) (*models.Series, error) {
+	series, err := s.series.Get(ctx, seriesId)
+	if err != nil {
+		return nil, err
+	}
+	oldName := series.Name
+
+	if updateSeries.Name != nil {
+		if series.NameKey = NameKey(*updateSeries.Name); series.NameKey == "" {
+			return nil, errEmptyName
+		}
+		series.Name = strings.TrimSpace(*updateSeries.Name)
+	}
+	if updateSeries.Description != nil {
+		series.Description = *updateSeries.Description
+	}
+	if err = s.series.Update(ctx, *series); err != nil {
+		return nil, err
+	}
+	if series.Name != oldName {
+		if err = s.book.RenameSeries(ctx, seriesId, series.Name); err != nil {
+			return nil, err
+		}
+	}
+	return series, nil
+}
+
+// Delete deletes a series without books
+func (s *Series) Delete(ctx context.Context, seriesId string) error {
+	if count, err := s.book.CountBySeriesId(ctx, seriesId); err != nil {
+		return err
+	} else if count > 0 {
+		return errors.New("series still has books")
+	}
+	return s.series.Delete(ctx, seriesId)
+}
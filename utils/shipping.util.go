@@ -0,0 +1,108 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/agustadewa/book-system/configs"
+	"github.com/agustadewa/book-system/models"
+)
+
+var ErrNoShippingZone = errors.New("no shipping zone covers the destination")
+
+// MatchShippingZone picks the zone of an address, a province match wins over a country match
+// and the default zone covers everything else
+func MatchShippingZone(zones []models.ShippingZone, address models.Address) (*models.ShippingZone, error) {
+	var countryMatch, defaultZone *models.ShippingZone
+	for i := range zones {
+		zone := &zones[i]
+		if containsFold(zone.Provinces, address.Province) {
+			return zone, nil
+		}
+		if countryMatch == nil && containsFold(zone.Countries, address.Country) {
+			countryMatch = zone
+		}
+		if defaultZone == nil && zone.IsDefault {
+			defaultZone = zone
+		}
+	}
+
+	if countryMatch != nil {
+		return countryMatch, nil
+	}
+	if defaultZone != nil {
+		return defaultZone, nil
+	}
+	return nil, ErrNoShippingZone
+}
+
+// ChargeableWeight returns the weight a parcel of books is charged by, the heavier of its actual and volumetric weight
+func ChargeableWeight(book *models.Book, qty int64) int64 {
+	weight := book.WeightGrams
+	if weight <= 0 {
+		weight = configs.DefaultBookWeightGrams
+	}
+	if d := book.Dimensions; d != nil {
+		volumetric := d.LengthMm * d.WidthMm * d.HeightMm / configs.VolumetricDivisor
+		if volumetric > weight {
+			weight = volumetric
+		}
+	}
+	return weight * qty
+}
+
+// ShippingFee returns the fee of a parcel by the rate table of its zone
+func ShippingFee(rate models.ShippingRate, weightGrams int64, subtotal int64) int64 {
+	if rate.FreeOverSubtotal > 0 && subtotal >= rate.FreeOverSubtotal {
+		return 0
+	}
+
+	if rate.Type.IsFlat() || len(rate.Tiers) == 0 {
+		return rate.FlatFee
+	}
+
+	for _, tier := range rate.Tiers {
+		if weightGrams <= tier.UpToGrams {
+			return tier.Fee
+		}
+	}
+
+	// heavier than the last tier, every started kg above it is charged
+	last := rate.Tiers[len(rate.Tiers)-1]
+	extraKg := (weightGrams - last.UpToGrams + 999) / 1000
+	return last.Fee + extraKg*rate.ExtraFeePerKg
+}
+
+// ValidateShippingRate checks a rate table before it is stored
+func ValidateShippingRate(rate models.ShippingRate) error {
+	if _, err := models.IsValidShippingRateType(rate.Type.String()); err != nil {
+		return err
+	}
+	if rate.FlatFee < 0 || rate.ExtraFeePerKg < 0 || rate.FreeOverSubtotal < 0 {
+		return errors.New("shipping fees can't be lower than 0")
+	}
+	if rate.Type.IsWeightTiered() {
+		if len(rate.Tiers) == 0 {
+			return errors.New("weight tiered rate needs at least one tier")
+		}
+		for i, tier := range rate.Tiers {
+			if tier.Fee < 0 {
+				return errors.New("shipping fees can't be lower than 0")
+			}
+			if tier.UpToGrams <= 0 || (i > 0 && tier.UpToGrams <= rate.Tiers[i-1].UpToGrams) {
+				return errors.New("weight tiers must be sorted by weight")
+			}
+		}
+	}
+	return nil
+}
+
+func containsFold(values []string, value string) bool {
+	value = strings.TrimSpace(value)
+	for _, v := range values {
+		if strings.EqualFold(strings.TrimSpace(v), value) {
+			return true
+		}
+	}
+	return false
+}
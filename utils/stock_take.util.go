@@ -0,0 +1,213 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/agustadewa/book-system/configs"
+	"github.com/agustadewa/book-system/models"
+	"github.com/agustadewa/book-system/repo"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// StockTakes runs physical counts of a location and commits the differences to the stock ledger
+type StockTakes struct {
+	stockTake *repo.StockTake
+	line      *repo.StockTakeLine
+	book      *repo.Book
+	location  *repo.Location
+	level     *repo.StockLevel
+	movement  *repo.StockMovement
+	inventory *Inventory
+}
+
+func NewStockTakes(client *mongo.Client) *StockTakes {
+	return &StockTakes{
+		stockTake: repo.NewStockTake(client),
+		line:      repo.NewStockTakeLine(client),
+		book:      repo.NewBook(client),
+		location:  repo.NewLocation(client),
+		level:     repo.NewStockLevel(client),
+		movement:  repo.NewStockMovement(client),
+		inventory: NewInventory(client),
+	}
+}
+
+// Open starts a stock take of a location and freezes the stock of every book there
+func (s *StockTakes) Open(ctx context.Context, locationId string, adminId string, note string) (*models.StockTake, error) {
+	if locationId == "" {
+		locationId = configs.DefaultLocationId
+	}
+	if _, err := s.location.Get(ctx, locationId); err != nil {
+		return nil, err
+	}
+
+	// the snapshot is the ledger up to the opening time and the variances add the movements after it, so
+	// a movement made while opening is counted once
+	openedAt := time.Now()
+	sums, err := s.movement.SumByLocationIdUntil(ctx, locationId, openedAt)
+	if err != nil {
+		return nil, err
+	}
+	levels, err := s.level.GetAllByLocationId(ctx, locationId)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := map[string]int64{}
+	for _, level := range *levels {
+		snapshot[level.BookId] = 0
+	}
+	for _, sum := range sums {
+		snapshot[sum.BookId] = sum.Qty
+	}
+
+	stockTake := models.StockTake{
+		Id:         primitive.NewObjectID().Hex(),
+		LocationId: locationId,
+		Status:     models.StockTakeOpen,
+		Note:       note,
+		OpenedBy:   adminId,
+		OpenedAt:   openedAt,
+	}
+	if _, err = s.stockTake.Add(ctx, stockTake); err != nil {
+		return nil, err
+	}
+
+	lines := make([]models.StockTakeLine, 0, len(snapshot))
+	for bookId, qty := range snapshot {
+		lines = append(lines, repo.StockTakeLineOf(stockTake.Id, bookId, qty))
+	}
+	if err = s.line.AddMany(ctx, lines); err != nil {
+		_ = s.line.DeleteAllByStockTakeId(ctx, stockTake.Id)
+		_ = s.stockTake.Delete(ctx, stockTake.Id)
+		return nil, err
+	}
+	return &stockTake, nil
+}
+
+// Count adds a batch of counts to an open stock take, several people may count at the same time
+func (s *StockTakes) Count(ctx context.Context, stockTakeId string, counts []models.StockCountLine, adminId string) error {
+	stockTake, err := s.stockTake.Get(ctx, stockTakeId)
+	if err != nil {
+		return err
+	}
+	if !stockTake.Status.IsOpen() {
+		return repo.ErrStockTakeClosed
+	}
+	if len(counts) == 0 {
+		return errors.New("a batch needs at least one count")
+	}
+	for _, count := range counts {
+		if count.Qty < 0 {
+			return fmt.Errorf("count of book %s can't be lower than 0", count.BookId)
+		}
+		if book, err := s.book.Get(ctx, count.BookId); err != nil {
+			return fmt.Errorf("%s: %w", count.BookId, err)
+		} else if book.IsDigital() {
+			return fmt.Errorf("%s: %w", count.BookId, repo.ErrUnlimitedStock)
+		}
+	}
+
+	now := time.Now()
+	for _, count := range counts {
+		stockCount := models.StockCount{Qty: count.Qty, CountedBy: adminId, CountedAt: now}
+		if err = s.line.AddCount(ctx, stockTake.Id, count.BookId, stockCount, count.Recount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Variances compares every book of a stock take with the stock expected when it was counted. Books which
+// aren't counted yet are expected as of now.
+func (s *StockTakes) Variances(ctx context.Context, stockTake models.StockTake) ([]models.StockTakeVariance, error) {
+	lines, err := s.line.GetAllByStockTakeId(ctx, stockTake.Id)
+	if err != nil {
+		return nil, err
+	}
+	movements, err := s.movement.GetAllByLocationIdSince(ctx, stockTake.LocationId, stockTake.OpenedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	movementsByBook := map[string][]models.StockMovement{}
+	for _, movement := range *movements {
+		// corrections of this stock take aren't sales during the count
+		if movement.RefId == stockTake.Id {
+			continue
+		}
+		movementsByBook[movement.BookId] = append(movementsByBook[movement.BookId], movement)
+	}
+
+	variances := make([]models.StockTakeVariance, 0, len(*lines))
+	for _, line := range *lines {
+		variance := models.StockTakeVariance{
+			BookId:      line.BookId,
+			SnapshotQty: line.SnapshotQty,
+			Counted:     line.Counted,
+			CountedQty:  line.CountedQty,
+		}
+		for _, movement := range movementsByBook[line.BookId] {
+			if line.CountedAt != nil && movement.CreatedAt.After(*line.CountedAt) {
+				break
+			}
+			variance.MovedQty += movement.Delta
+		}
+		variance.ExpectedQty = variance.SnapshotQty + variance.MovedQty
+		if variance.Counted {
+			variance.Variance = variance.CountedQty - variance.ExpectedQty
+		}
+		variances = append(variances, variance)
+	}
+	return variances, nil
+}
+
+// Commit posts a stock take correction for every counted book with a variance and closes the stock take.
+// Uncounted books are left as they are unless zeroUncounted says they weren't found. A correction is posted
+// once per book, so a commit that failed halfway can be retried.
+func (s *StockTakes) Commit(ctx context.Context, stockTakeId string, adminId string, zeroUncounted bool) ([]models.StockMovement, error) {
+	stockTake, err := s.stockTake.Get(ctx, stockTakeId)
+	if err != nil {
+		return nil, err
+	}
+	if !stockTake.Status.IsOpen() {
+		return nil, repo.ErrStockTakeClosed
+	}
+	variances, err := s.Variances(ctx, *stockTake)
+	if err != nil {
+		return nil, err
+	}
+
+	corrections := make([]models.StockMovement, 0)
+	var pending []models.StockTakeVariance
+	for _, variance := range variances {
+		if !variance.Counted && zeroUncounted {
+			variance.Variance = -variance.ExpectedQty
+		}
+		if variance.Variance != 0 {
+			pending = append(pending, variance)
+		}
+	}
+	// corrections of this stock take are left out of the variances, a retry finds the same ones
+	for _, variance := range pending {
+		note := fmt.Sprintf("stock take counted %v, expected %v", variance.CountedQty, variance.ExpectedQty)
+		movement, err := s.inventory.MoveOnce(ctx, variance.BookId, stockTake.LocationId, variance.Variance, models.StockTakeCorrection, adminId, stockTake.Id, note)
+		if err != nil {
+			return corrections, fmt.Errorf("%v of %v corrections are posted, book %s failed: %w", len(corrections), len(pending), variance.BookId, err)
+		}
+		corrections = append(corrections, *movement)
+	}
+
+	if err = s.stockTake.SetClosed(ctx, stockTake.Id, models.StockTakeCommitted, adminId, int64(len(pending))); err != nil {
+		return nil, err
+	}
+	return corrections, nil
+}
+
+// Cancel closes a stock take without changing stock
+func (s *StockTakes) Cancel(ctx context.Context, stockTakeId string, adminId string) error {
+	return s.stockTake.SetClosed(ctx, stockTakeId, models.StockTakeCancelled, adminId, 0)
+}
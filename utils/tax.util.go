@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"errors"
+
+	"github.com/agustadewa/book-system/models"
+)
+
+var ErrNoTaxRegion = errors.New("no tax region covers the address")
+
+// basis points of a whole, 10000 bps is 100%
+const bpsScale = 10000
+
+// MatchTaxRegion picks the tax region of an address the same way shipping zones are matched, a nil
+// address is an order picked up at the shop and is taxed by the default region
+func MatchTaxRegion(regions []models.TaxRegion, address *models.Address) (*models.TaxRegion, error) {
+	var countryMatch, defaultRegion *models.TaxRegion
+	for i := range regions {
+		region := &regions[i]
+		if address != nil && containsFold(region.Provinces, address.Province) {
+			return region, nil
+		}
+		if address != nil && countryMatch == nil && containsFold(region.Countries, address.Country) {
+			countryMatch = region
+		}
+		if defaultRegion == nil && region.IsDefault {
+			defaultRegion = region
+		}
+	}
+
+	if countryMatch != nil {
+		return countryMatch, nil
+	}
+	if defaultRegion != nil {
+		return defaultRegion, nil
+	}
+	return nil, ErrNoTaxRegion
+}
+
+// TaxAmount returns the tax of an amount rounded half up, an inclusive amount already contains the tax
+// so it is extracted instead of added
+func TaxAmount(amount int64, rateBps int64, inclusive bool) int64 {
+	if rateBps <= 0 || amount <= 0 {
+		return 0
+	}
+	divisor := int64(bpsScale)
+	if inclusive {
+		divisor += rateBps
+	}
+	return (amount*rateBps + divisor/2) / divisor
+}
+
+// TaxLineOf returns the tax line of an amount of books of a tax class in a region
+func TaxLineOf(region models.TaxRegion, taxClass models.TaxClass, amount int64) models.TaxLine {
+	if taxClass == "" {
+		taxClass = models.StandardTax
+	}
+	rate := region.RateBps(taxClass)
+	return models.TaxLine{
+		RegionId:      region.Id,
+		RegionName:    region.Name,
+		TaxClass:      taxClass,
+		RateBps:       rate,
+		TaxableAmount: amount,
+		Amount:        TaxAmount(amount, rate, region.PricesIncludeTax),
+		Inclusive:     region.PricesIncludeTax,
+	}
+}
+
+// ValidateTaxRegion checks the rates of a region before it is stored
+func ValidateTaxRegion(region models.TaxRegion) error {
+	if region.StandardRateBps < 0 || region.ReducedRateBps < 0 {
+		return errors.New("tax rates can't be lower than 0")
+	}
+	if region.StandardRateBps > bpsScale || region.ReducedRateBps > bpsScale {
+		return errors.New("tax rates can't be higher than 10000 bps")
+	}
+	if !region.IsDefault && len(region.Countries) == 0 && len(region.Provinces) == 0 {
+		return errors.New("tax region needs countries or provinces unless it's the default")
+	}
+	return nil
+}
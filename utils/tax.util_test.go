@@ -0,0 +1,27 @@
+package utils
+
+import "testing"
+
+func TestTaxAmount(t *testing.T) {
+	tests := []struct {
+		amount    int64
+		rateBps   int64
+		inclusive bool
+		want      int64
+	}{
+		{10000, 1100, false, 1100},
+		{11100, 1100, true, 1100},
+		{999, 1100, false, 110},
+		{1000, 1100, true, 99},
+		{5, 1000, false, 1},
+		{4, 1000, false, 0},
+		{10000, 0, false, 0},
+		{-10000, 1100, false, 0},
+		{10000, 1, false, 1},
+	}
+	for _, tt := range tests {
+		if got := TaxAmount(tt.amount, tt.rateBps, tt.inclusive); got != tt.want {
+			t.Errorf("TaxAmount(%v, %v, %v) = %v, want %v", tt.amount, tt.rateBps, tt.inclusive, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/agustadewa/book-system/configs"
+	"github.com/agustadewa/book-system/models"
+	"github.com/agustadewa/book-system/repo"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Waitlist tells customers when a sold out book is back in stock, first come first served. Customers who
+// asked for a reservation get the books held for them for a while.
+type Waitlist struct {
+	entry     *repo.Waitlist
+	notifier  Notifier
+	inventory *Inventory
+}
+
+func NewWaitlist(client *mongo.Client) *Waitlist {
+	return NewInventory(client).Waitlist()
+}
+
+// newWaitlist shares the inventory which triggers it, holds are stock movements of that inventory
+func newWaitlist(client *mongo.Client, inventory *Inventory) *Waitlist {
+	return &Waitlist{
+		entry:     repo.NewWaitlist(client),
+		notifier:  NewNotifier(client),
+		inventory: inventory,
+	}
+}
+
+// Subscribe puts a customer on the waitlist of a book
+func (w *Waitlist) Subscribe(ctx context.Context, book models.Book, userId string, qty int64, reserve bool) (*models.WaitlistEntry, error) {
+	if qty <= 0 {
+		return nil, errors.New("quantity minimum is 1")
+	}
+	if book.IsDigital() {
+		return nil, errors.New("book is in stock, order it instead")
+	}
+	// books owed to orders waiting for stock can't be ordered, the customer waits for them too
+	available, err := w.inventory.Available(ctx, book)
+	if err != nil {
+		return nil, err
+	}
+	if available >= qty {
+		return nil, errors.New("book is in stock, order it instead")
+	}
+
+	entry := models.WaitlistEntry{
+		Id:        primitive.NewObjectID().Hex(),
+		BookId:    book.Id,
+		UserId:    userId,
+		Qty:       qty,
+		Reserve:   reserve,
+		Status:    models.WaitlistWaiting,
+		Active:    true,
+		CreatedAt: time.Now(),
+	}
+	if _, err = w.entry.Add(ctx, entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// Restocked serves the waitlist of a book which is back in stock, available is its stock now. Entries are
+// served in order while there are books for them, an entry which doesn't fit stops the rest from jumping
+// the queue.
+func (w *Waitlist) Restocked(ctx context.Context, bookId string, locationId string, available int64) error {
+	entries, err := w.entry.GetAllWaiting(ctx, bookId)
+	if err != nil {
+		return err
+	}
+	if len(*entries) == 0 {
+		return nil
+	}
+	book, err := w.inventory.book.Get(ctx, bookId)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range *entries {
+		if entry.Qty > available {
+			break
+		}
+		if err = w.serve(ctx, *book, entry, locationId); err == repo.ErrWaitlistStatusChanged {
+			// served by someone else
+			continue
+		} else if err != nil {
+			return err
+		}
+		available -= entry.Qty
+	}
+	return nil
+}
+
+// ClaimHold hands the books held for a customer to their order, nil is returned when there is no hold
+// for at least qty books
+func (w *Waitlist) ClaimHold(ctx context.Context, userId string, bookId string, qty int64) (*models.WaitlistEntry, error) {
+	entry, err := w.entry.ClaimHold(ctx, userId, bookId, qty, time.Now())
+	if err == repo.ErrWaitlistEntryNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	// held books the order doesn't take go back to stock
+	if surplus := entry.Qty - qty; surplus > 0 {
+		if _, err = w.inventory.Move(ctx, bookId, entry.HoldLocationId, surplus, models.StockWaitlistRelease, configs.StockSystemActor, entry.Id, "not ordered"); err != nil {
+			log.Printf("[WAITLIST] can't release %v books held for %v: %v\n", surplus, entry.Id, err)
+		}
+	}
+	return entry, nil
+}
+
+// RestoreHold gives back a claimed hold when the order taking it couldn't be placed, the books held which
+// the order would have taken stay held until the reservation expires
+func (w *Waitlist) RestoreHold(ctx context.Context, entry models.WaitlistEntry, qty int64) error {
+	return w.entry.RestoreHold(ctx, entry.Id, qty)
+}
+
+// HeldQty returns how many books are held for a customer when there is a hold for at least qty books
+func (w *Waitlist) HeldQty(ctx context.Context, userId string, bookId string, qty int64) (int64, error) {
+	entry, err := w.entry.GetHold(ctx, userId, bookId, qty, time.Now())
+	if err == repo.ErrWaitlistEntryNotFound {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	return entry.Qty, nil
+}
+
+// Fulfilled closes the entries of a customer who ordered the book
+func (w *Waitlist) Fulfilled(ctx context.Context, userId string, bookId string) error {
+	return w.entry.SetFulfilled(ctx, userId, bookId)
+}
+
+// Cancel takes a customer off a waitlist, books held for them go back to stock
+func (w *Waitlist) Cancel(ctx context.Context, entryId string, userId string) error {
+	entry, err := w.entry.Get(ctx, entryId)
+	if err != nil {
+		return err
+	}
+	if entry.UserId != userId {
+		return repo.ErrWaitlistEntryNotFound
+	}
+	if !entry.Status.IsActive() {
+		return fmt.Errorf("waitlist entry is %s", entry.Status)
+	}
+	if err = w.entry.SetClosed(ctx, entry.Id, entry.Status, models.WaitlistCancelled); err != nil {
+		return err
+	}
+	if entry.Status.IsReserved() {
+		return w.release(ctx, *entry, "reservation cancelled")
+	}
+	return nil
+}
+
+// ExpireHolds puts books held for customers who didn't order in time back to stock, which offers them to
+// the next customers waiting
+func (w *Waitlist) ExpireHolds(ctx context.Context, now time.Time) error {
+	entries, err := w.entry.GetAllExpiredHolds(ctx, now)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range *entries {
+		if err = w.entry.SetClosed(ctx, entry.Id, models.WaitlistReserved, models.WaitlistExpired); err == repo.ErrWaitlistStatusChanged {
+			// ordered meanwhile
+			continue
+		} else if err != nil {
+			return err
+		}
+		if err = w.release(ctx, entry, "reservation expired"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *Waitlist) serve(ctx context.Context, book models.Book, entry models.WaitlistEntry, locationId string) error {
+	message := "is back in stock"
+
+	var holdUntil *time.Time
+	if entry.Reserve {
+		until := time.Now().Add(configs.WaitlistHoldMinutes * time.Minute)
+		_, err := w.inventory.Move(ctx, entry.BookId, locationId, -entry.Qty, models.StockWaitlistHold, configs.StockSystemActor, entry.Id, "")
+		if err == nil {
+			holdUntil = &until
+			message = fmt.Sprintf("is back in stock and %v are reserved for you until %s", entry.Qty, until.Format(time.RFC3339))
+		} else if err != repo.ErrInsufficientStock {
+			return err
+		}
+	}
+
+	if err := w.entry.SetNotified(ctx, entry.Id, locationId, holdUntil); err != nil {
+		if holdUntil != nil {
+			entry.HoldLocationId = locationId
+			if rerr := w.release(ctx, entry, "entry changed while reserving"); rerr != nil {
+				log.Printf("[WAITLIST] can't release books held for %v: %v\n", entry.Id, rerr)
+			}
+		}
+		return err
+	}
+
+	err := w.notifier.Notify(ctx, models.Notification{
+		Type:    models.BackInStockNotification,
+		UserId:  entry.UserId,
+		Key:     "waitlist:" + entry.Id,
+		BookId:  entry.BookId,
+		Message: fmt.Sprintf("%s %s", book.Name, message),
+	})
+	if err != nil && err != repo.ErrNotificationExists {
+		return err
+	}
+	return nil
+}
+
+func (w *Waitlist) release(ctx context.Context, entry models.WaitlistEntry, note string) error {
+	if _, err := w.inventory.Move(ctx, entry.BookId, entry.HoldLocationId, entry.Qty, models.StockWaitlistRelease, configs.StockSystemActor, entry.Id, note); err != nil {
+		return err
+	}
+	return nil
+}
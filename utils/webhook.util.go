@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidWebhookSignature = errors.New("invalid webhook signature")
+var ErrWebhookTimestampOutOfRange = errors.New("webhook timestamp is outside the tolerance window")
+
+// SignWebhook returns the signature header value for a webhook body, formatted as "t=<unix>,v1=<hex hmac>".
+// The signed payload is "<unix>.<body>" so the timestamp can't be swapped without breaking the signature.
+func SignWebhook(secret string, timestamp time.Time, body []byte) string {
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", ts, webhookHMAC(secret, ts, body))
+}
+
+// VerifyWebhook checks the signature header of a webhook body and rejects timestamps outside the tolerance
+func VerifyWebhook(secret string, header string, body []byte, now time.Time, tolerance time.Duration) error {
+	var ts string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "t":
+			ts = kv[1]
+		case "v1":
+			signatures = append(signatures, kv[1])
+		}
+	}
+	if ts == "" || len(signatures) == 0 {
+		return ErrInvalidWebhookSignature
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrInvalidWebhookSignature
+	}
+	gap := now.Sub(time.Unix(unix, 0))
+	if gap > tolerance || gap < -tolerance {
+		return ErrWebhookTimestampOutOfRange
+	}
+
+	expected := webhookHMAC(secret, ts, body)
+	for _, signature := range signatures {
+		if hmac.Equal([]byte(signature), []byte(expected)) {
+			return nil
+		}
+	}
+	return ErrInvalidWebhookSignature
+}
+
+func webhookHMAC(secret string, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
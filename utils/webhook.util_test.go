@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVerifyWebhook(t *testing.T) {
+	secret := "whsec_test"
+	body := []byte(`{"event_id":"evt_1","reference":"fc_1","status":"SUCCEEDED"}`)
+	signedAt := time.Unix(1700000000, 0)
+	header := SignWebhook(secret, signedAt, body)
+
+	tests := []struct {
+		name   string
+		secret string
+		header string
+		body   []byte
+		now    time.Time
+		err    error
+	}{
+		{"valid", secret, header, body, signedAt, nil},
+		{"within tolerance", secret, header, body, signedAt.Add(5 * time.Minute), nil},
+		{"clock behind within tolerance", secret, header, body, signedAt.Add(-5 * time.Minute), nil},
+		{"too old", secret, header, body, signedAt.Add(5*time.Minute + time.Second), ErrWebhookTimestampOutOfRange},
+		{"from the future", secret, header, body, signedAt.Add(-5*time.Minute - time.Second), ErrWebhookTimestampOutOfRange},
+		{"wrong secret", "whsec_other", header, body, signedAt, ErrInvalidWebhookSignature},
+		{"changed body", secret, header, []byte(`{"event_id":"evt_1","reference":"fc_1","status":"FAILED"}`), signedAt, ErrInvalidWebhookSignature},
+		{"swapped timestamp", secret, "t=1700000001," + header[len("t=1700000000,"):], body, signedAt, ErrInvalidWebhookSignature},
+		{"several signatures", secret, header + ",v1=deadbeef", body, signedAt, nil},
+		{"missing signature", secret, "t=1700000000", body, signedAt, ErrInvalidWebhookSignature},
+		{"missing timestamp", secret, header[len("t=1700000000,"):], body, signedAt, ErrInvalidWebhookSignature},
+		{"bad timestamp", secret, "t=abc," + header[len("t=1700000000,"):], body, signedAt, ErrInvalidWebhookSignature},
+		{"empty header", secret, "", body, signedAt, ErrInvalidWebhookSignature},
+	}
+	for _, tt := range tests {
+		if err := VerifyWebhook(tt.secret, tt.header, tt.body, tt.now, 5*time.Minute); err != tt.err {
+			t.Errorf("%s: VerifyWebhook() = %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}